@@ -0,0 +1,195 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/sysconfig"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maskedValue          = "******"
+	defaultRetentionDays = 90
+	purgeInterval        = 24 * time.Hour
+)
+
+// sensitiveSuffixes 需要脱敏的字段名后缀（如 api_key、bot_token、credentials、X-Auth-Key）
+var sensitiveSuffixes = []string{"_key", "apikey", "token", "credential", "credentials", "authorization"}
+
+// sensitiveParts 字段名包含即需脱敏的片段
+var sensitiveParts = []string{"password", "secret", "cookie"}
+
+// ignoredKeys 不参与变更对比的字段（时间戳和统计字段）
+var ignoredKeys = map[string]bool{"created_at": true, "updated_at": true, "documents_count": true}
+
+// Record 记录管理操作：创建时before为nil，删除时after为nil，更新和切换记录字段差异（敏感字段脱敏）
+func Record(c *gin.Context, action string, resourceType string, resourceID interface{}, before interface{}, after interface{}) {
+	if !sysconfig.Bool(sysconfig.KeyEnableAuditLog, true) {
+		return
+	}
+
+	beforeMap, afterMap := snapshot(before), snapshot(after)
+	var details Details
+	switch {
+	case beforeMap == nil:
+		details.After = mask(afterMap).(map[string]interface{})
+	case afterMap == nil:
+		details.Before = mask(beforeMap).(map[string]interface{})
+	default:
+		// 先对比原始值再脱敏，保证密钥变更也能被记录
+		details.Changes = diff(beforeMap, afterMap)
+		for key, change := range details.Changes {
+			details.Changes[key] = Change{Before: maskField(key, change.Before), After: maskField(key, change.After)}
+		}
+	}
+	detailsJSON, _ := json.Marshal(details)
+
+	entry := AuditLog{
+		Action:       action,
+		ResourceType: &resourceType,
+		Details:      detailsJSON,
+	}
+	if resourceID != nil {
+		id := fmt.Sprintf("%v", resourceID)
+		entry.ResourceID = &id
+	}
+	if user := global.GetDooTaskUser(c); user != nil {
+		userID := strconv.Itoa(user.UserID)
+		entry.UserID = &userID
+	}
+	if ip := c.ClientIP(); ip != "" {
+		entry.IPAddress = &ip
+	}
+	if ua := c.Request.UserAgent(); ua != "" {
+		entry.UserAgent = &ua
+	}
+
+	if err := global.DB.Create(&entry).Error; err != nil {
+		log.Printf("写入操作日志失败: %v", err)
+	}
+}
+
+// snapshot 将资源转换为字段映射
+func snapshot(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	for key := range ignoredKeys {
+		delete(m, key)
+	}
+	return m
+}
+
+// maskField 按字段名脱敏单个字段值
+func maskField(key string, v interface{}) interface{} {
+	if isSensitive(key) {
+		if v != nil && v != "" {
+			return maskedValue
+		}
+		return v
+	}
+	return mask(v)
+}
+
+// Redact 对任意数据中的敏感字段脱敏（如工具调用参数）
+func Redact(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var copied interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil
+	}
+	return mask(copied)
+}
+
+// mask 递归脱敏敏感字段
+func mask(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = maskField(key, item)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = mask(item)
+		}
+		return value
+	}
+	return v
+}
+
+// isSensitive 判断字段名是否敏感（请求头等字段名中的 - 按 _ 处理）
+func isSensitive(key string) bool {
+	lower := strings.ReplaceAll(strings.ToLower(key), "-", "_")
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	for _, part := range sensitiveParts {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// diff 对比变更前后的字段（只对比两侧都存在的字段，忽略仅一侧预加载的关联数据）
+func diff(before, after map[string]interface{}) map[string]Change {
+	changes := map[string]Change{}
+	for key, afterValue := range after {
+		beforeValue, exists := before[key]
+		if exists && !reflect.DeepEqual(beforeValue, afterValue) {
+			changes[key] = Change{Before: beforeValue, After: afterValue}
+		}
+	}
+	return changes
+}
+
+// Purge 按 audit_log_retention_days 删除过期日志
+func Purge() (int64, error) {
+	days := sysconfig.Int(sysconfig.KeyAuditLogRetentionDays, defaultRetentionDays)
+	if days <= 0 {
+		days = defaultRetentionDays
+	}
+
+	result := global.DB.Where("created_at < ?", time.Now().AddDate(0, 0, -days)).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
+
+// StartRetention 启动日志保留清理（启动时执行一次，之后每天执行）
+func StartRetention() {
+	go func() {
+		for {
+			if count, err := Purge(); err != nil {
+				log.Printf("清理过期操作日志失败: %v", err)
+			} else if count > 0 {
+				log.Printf("已清理过期操作日志 %d 条", count)
+			}
+			time.Sleep(purgeInterval)
+		}
+	}()
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsSensitive(t *testing.T) {
+	cases := map[string]bool{
+		"api_key":       true,
+		"apiKey":        true,
+		"X-API-Key":     true,
+		"x-api-key":     true,
+		"X-Auth-Key":    true,
+		"api-key":       true,
+		"Authorization": true,
+		"X-Auth-Token":  true,
+		"private_key":   true,
+		"client_secret": true,
+		"Cookie":        true,
+		"DB_PASSWORD":   true,
+		"key":           false,
+		"name":          false,
+		"Content-Type":  false,
+		"max_tokens":    false,
+	}
+	for key, want := range cases {
+		if got := isSensitive(key); got != want {
+			t.Errorf("isSensitive(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestRedactNestedHeaders(t *testing.T) {
+	config := map[string]interface{}{
+		"url": "https://mcp.example.com/mcp",
+		"headers": map[string]interface{}{
+			"X-API-Key":     "k1",
+			"X-Auth-Key":    "k2",
+			"api-key":       "k3",
+			"Authorization": "Bearer t",
+			"Content-Type":  "application/json",
+		},
+		"env": map[string]interface{}{
+			"GITHUB_TOKEN": "ghp_x",
+			"LOG_LEVEL":    "debug",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"headers": map[string]interface{}{"x-api-key": "k4"}},
+		},
+	}
+
+	got := Redact(map[string]interface{}{"config": config})
+	want := map[string]interface{}{
+		"config": map[string]interface{}{
+			"url": "https://mcp.example.com/mcp",
+			"headers": map[string]interface{}{
+				"X-API-Key":     maskedValue,
+				"X-Auth-Key":    maskedValue,
+				"api-key":       maskedValue,
+				"Authorization": maskedValue,
+				"Content-Type":  "application/json",
+			},
+			"env": map[string]interface{}{
+				"GITHUB_TOKEN": maskedValue,
+				"LOG_LEVEL":    "debug",
+			},
+			"servers": []interface{}{
+				map[string]interface{}{"headers": map[string]interface{}{"x-api-key": maskedValue}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Redact() = %#v, want %#v", got, want)
+	}
+}
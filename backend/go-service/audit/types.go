@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 操作类型
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionToggle = "toggle"
+)
+
+// 资源类型
+const (
+	ResourceAgent         = "agent"
+	ResourceAIModel       = "ai_model"
+	ResourceAIModelKey    = "ai_model_key"
+	ResourceKnowledgeBase = "knowledge_base"
+	ResourceDocument      = "kb_document"
+	ResourceKBSource      = "kb_source"
+	ResourceKBReindexJob  = "kb_reindex_job"
+	ResourceMCPTool       = "mcp_tool"
+	ResourceUserSetting   = "user_setting"
+	ResourceDialogSetting = "dialog_setting"
+	ResourceSystemConfig  = "system_config"
+)
+
+// AuditLog 操作日志模型
+type AuditLog struct {
+	ID           int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       *string        `gorm:"type:varchar(255)" json:"user_id"`
+	Action       string         `gorm:"type:varchar(100);not null" json:"action"`
+	ResourceType *string        `gorm:"type:varchar(100)" json:"resource_type"`
+	ResourceID   *string        `gorm:"type:varchar(255)" json:"resource_id"`
+	Details      datatypes.JSON `gorm:"type:jsonb;default:'{}'" json:"details"`
+	IPAddress    *string        `gorm:"column:ip_address;type:inet" json:"ip_address"`
+	UserAgent    *string        `gorm:"type:text" json:"user_agent"`
+	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// Change 单个字段的变更
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// Details 日志详情（创建记录after，删除记录before，更新和切换记录changes）
+type Details struct {
+	Before  map[string]interface{} `json:"before,omitempty"`
+	After   map[string]interface{} `json:"after,omitempty"`
+	Changes map[string]Change      `json:"changes,omitempty"`
+}
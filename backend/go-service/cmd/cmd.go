@@ -1,11 +1,16 @@
 package cmd
 
 import (
+	"dootask-ai/go-service/audit"
 	"dootask-ai/go-service/database"
 	"dootask-ai/go-service/global"
 	"dootask-ai/go-service/middleware"
 	"dootask-ai/go-service/routes"
+	"dootask-ai/go-service/routes/api/evaluations"
+	"dootask-ai/go-service/secret"
+	"dootask-ai/go-service/sysconfig"
 	"dootask-ai/go-service/utils"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -23,10 +28,34 @@ var (
 		PreRun: runPre,
 		Run:    runServer,
 	}
+
+	evalCmd = &cobra.Command{
+		Use:    "eval",
+		Short:  "执行智能体评测集",
+		PreRun: runPre,
+		Run:    runEval,
+	}
+	reencryptCmd = &cobra.Command{
+		Use:    "reencrypt",
+		Short:  "使用当前主密钥重新加密已存储的密钥（主密钥轮换后执行）",
+		PreRun: runPre,
+		Run:    runReencrypt,
+	}
+	evalSuiteID     int64
+	evalLabel       string
+	reencryptDryRun bool
 )
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&global.EnvFile, "env-file", ".env", "环境变量文件路径")
+
+	evalCmd.Flags().Int64Var(&evalSuiteID, "suite", 0, "评测集ID")
+	evalCmd.Flags().StringVar(&evalLabel, "label", "", "本次运行的标签（如提示词版本）")
+	evalCmd.MarkFlagRequired("suite")
+	rootCmd.AddCommand(evalCmd)
+
+	reencryptCmd.Flags().BoolVar(&reencryptDryRun, "dry-run", false, "只统计需要重新加密的记录，不写入数据库")
+	rootCmd.AddCommand(reencryptCmd)
 }
 
 func runPre(*cobra.Command, []string) {
@@ -81,6 +110,12 @@ func runServer(*cobra.Command, []string) {
 	// 认证中间件
 	r.Use(middleware.AuthMiddleware())
 
+	// 订阅系统配置变更通知
+	sysconfig.Watch()
+
+	// 启动操作日志定期清理
+	audit.StartRetention()
+
 	// 注册路由
 	routes.RegisterRoutes(r)
 
@@ -95,6 +130,58 @@ func runServer(*cobra.Command, []string) {
 	}
 }
 
+func runEval(*cobra.Command, []string) {
+	run, err := evaluations.RunSuite(evalSuiteID, evalLabel)
+	if err != nil {
+		log.Printf("执行评测失败: %v", err)
+		os.Exit(1)
+	}
+
+	var results []evaluations.EvalResult
+	global.DB.Where("run_id = ?", run.ID).Order("id ASC").Find(&results)
+	for _, result := range results {
+		mark := "PASS"
+		if !result.Passed {
+			mark = "FAIL"
+		}
+		fmt.Printf("[%s] #%d %s (%dms, %d tokens)\n", mark, result.CaseID, result.Question, result.LatencyMs, result.InputTokens+result.OutputTokens)
+	}
+	fmt.Printf("评测运行 #%d: %d/%d 通过，平均耗时 %dms，共 %d tokens\n", run.ID, run.PassedCases, run.TotalCases, run.AvgLatencyMs, run.TotalTokens)
+
+	database.CloseRedis()
+	database.CloseDatabase()
+	if run.PassedCases < run.TotalCases {
+		os.Exit(1)
+	}
+}
+
+func runReencrypt(*cobra.Command, []string) {
+	if secret.ActiveKeyID() == "" {
+		log.Printf("未配置 SECRET_MASTER_KEYS 或 API_KEY，无法加密")
+		os.Exit(1)
+	}
+
+	results, err := secret.ReencryptAll(reencryptDryRun)
+	failed := 0
+	for _, result := range results {
+		fmt.Printf("%s.%s: 扫描 %d，重新加密 %d，失败 %d\n", result.Table, result.Column, result.Scanned, result.Updated, result.Failed)
+		failed += result.Failed
+	}
+	if reencryptDryRun {
+		fmt.Printf("试运行模式，未写入数据库（活跃主密钥: %s）\n", secret.ActiveKeyID())
+	}
+
+	database.CloseRedis()
+	database.CloseDatabase()
+	if err != nil {
+		log.Printf("重新加密失败: %v", err)
+		os.Exit(1)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
 func Execute() error {
 	global.Validator = validator.New()
 
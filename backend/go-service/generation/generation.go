@@ -0,0 +1,117 @@
+package generation
+
+import (
+	"maps"
+
+	"dootask-ai/go-service/global"
+)
+
+// reasoningBudgets 按推理强度换算的思考预算（Anthropic、Gemini 使用token预算而非强度）
+var reasoningBudgets = map[string]int{
+	ReasoningMinimal: 1024,
+	ReasoningLow:     2048,
+	ReasoningMedium:  8192,
+	ReasoningHigh:    24576,
+}
+
+// reasoningEffortProviders 支持 reasoning_effort 参数的服务商（OpenAI 兼容接口）
+var reasoningEffortProviders = map[string]bool{
+	"openai":     true,
+	"azure":      true,
+	"xai":        true,
+	"openrouter": true,
+	"meta":       true,
+}
+
+// Resolve 按顺序合并多层配置，后面的层覆盖前面已设置的字段，Extra 按键合并
+func Resolve(layers ...Settings) Settings {
+	var resolved Settings
+	for _, layer := range layers {
+		if layer.Temperature != nil {
+			resolved.Temperature = layer.Temperature
+		}
+		if layer.TopP != nil {
+			resolved.TopP = layer.TopP
+		}
+		if layer.MaxOutputTokens != nil {
+			resolved.MaxOutputTokens = layer.MaxOutputTokens
+		}
+		if layer.Stop != nil {
+			resolved.Stop = layer.Stop
+		}
+		if layer.ReasoningEffort != nil {
+			resolved.ReasoningEffort = layer.ReasoningEffort
+		}
+		if layer.APIVersion != nil {
+			resolved.APIVersion = layer.APIVersion
+		}
+		if len(layer.Extra) > 0 {
+			if resolved.Extra == nil {
+				resolved.Extra = map[string]any{}
+			}
+			maps.Copy(resolved.Extra, layer.Extra)
+		}
+	}
+	return resolved
+}
+
+// ForDialog 获取对话级覆盖配置，未设置时返回空配置
+func ForDialog(agentID int64, dialogID string) Settings {
+	if dialogID == "" {
+		return Settings{}
+	}
+	var dialog DialogSettings
+	if err := global.DB.Where("agent_id = ? AND dialog_id = ?", agentID, dialogID).First(&dialog).Error; err != nil {
+		return Settings{}
+	}
+	return dialog.Settings
+}
+
+// Apply 将生成参数写入发送给AI服务的 agent_config
+// 通用参数使用AI服务识别的字段名，其余参数按服务商转换后放入 extra 直接传给模型客户端
+func (s Settings) Apply(agentConfig map[string]any, provider string, isThinking bool) {
+	// 思考型模型不支持自定义温度
+	if s.Temperature != nil && !isThinking {
+		agentConfig["temperature"] = *s.Temperature
+	}
+	if s.MaxOutputTokens != nil {
+		agentConfig["max_tokens"] = *s.MaxOutputTokens
+	}
+	if s.APIVersion != nil && *s.APIVersion != "" {
+		agentConfig["api_version"] = *s.APIVersion
+	}
+
+	extra := map[string]any{}
+	if s.TopP != nil && !isThinking {
+		extra["top_p"] = *s.TopP
+	}
+	if len(s.Stop) > 0 {
+		if provider == "anthropic" || provider == "cohere" {
+			extra["stop_sequences"] = s.Stop
+		} else {
+			extra["stop"] = s.Stop
+		}
+	}
+	if s.ReasoningEffort != nil && *s.ReasoningEffort != "" {
+		effort := *s.ReasoningEffort
+		switch {
+		case reasoningEffortProviders[provider]:
+			extra["reasoning_effort"] = effort
+		case provider == "anthropic":
+			// 开启思考时 Anthropic 不允许自定义温度和 top_p，且 max_tokens 必须大于思考预算
+			budget := reasoningBudgets[effort]
+			extra["thinking"] = map[string]any{"type": "enabled", "budget_tokens": budget}
+			delete(agentConfig, "temperature")
+			delete(extra, "top_p")
+			if s.MaxOutputTokens == nil || *s.MaxOutputTokens <= budget {
+				agentConfig["max_tokens"] = budget + 4096
+			}
+		case provider == "google":
+			extra["thinking_budget"] = reasoningBudgets[effort]
+		}
+	}
+	maps.Copy(extra, s.Extra)
+	if len(extra) > 0 {
+		agentConfig["extra"] = extra
+	}
+}
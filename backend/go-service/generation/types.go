@@ -0,0 +1,73 @@
+package generation
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 推理强度
+const (
+	ReasoningMinimal = "minimal"
+	ReasoningLow     = "low"
+	ReasoningMedium  = "medium"
+	ReasoningHigh    = "high"
+)
+
+// Settings 生成参数，未设置的字段沿用上一层配置（模型默认 → 智能体 → 对话）
+type Settings struct {
+	Temperature     *float64       `json:"temperature,omitempty" validate:"omitempty,min=0,max=2"`
+	TopP            *float64       `json:"top_p,omitempty" validate:"omitempty,min=0,max=1"`
+	MaxOutputTokens *int           `json:"max_output_tokens,omitempty" validate:"omitempty,min=1"`
+	Stop            []string       `json:"stop,omitempty" validate:"omitempty,max=4,dive,min=1,max=100"`
+	ReasoningEffort *string        `json:"reasoning_effort,omitempty" validate:"omitempty,oneof=minimal low medium high"`
+	APIVersion      *string        `json:"api_version,omitempty" validate:"omitempty,max=50"` // Azure 等服务商的接口版本
+	Extra           map[string]any `json:"extra,omitempty"`                                   // 直接传给模型客户端的服务商参数
+}
+
+// Value 实现 driver.Valuer，以 JSONB 存储
+func (s Settings) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (s *Settings) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*s = Settings{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("无法解析生成参数: %T", value)
+	}
+	*s = Settings{}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, s)
+}
+
+// DialogSettings 对话级生成参数覆盖
+type DialogSettings struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	AgentID   int64     `gorm:"column:agent_id;not null" json:"agent_id"`
+	DialogID  string    `gorm:"column:dialog_id;type:varchar(255);not null" json:"dialog_id"`
+	Settings  Settings  `gorm:"type:jsonb;default:'{}'" json:"settings"`
+	UpdatedBy int64     `gorm:"column:updated_by;not null" json:"updated_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (DialogSettings) TableName() string {
+	return "dialog_generation_settings"
+}
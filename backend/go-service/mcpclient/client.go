@@ -0,0 +1,278 @@
+package mcpclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed 连接已关闭
+var ErrClosed = errors.New("MCP连接已关闭")
+
+// transport 底层传输：发送一条消息，并通过 incoming 通道接收服务端消息
+type transport interface {
+	send(ctx context.Context, data []byte) error
+	incoming() <-chan []byte
+	close() error
+}
+
+// Client MCP客户端
+type Client struct {
+	cfg       Config
+	transport transport
+	nextID    atomic.Int64
+	init      InitializeResult
+
+	mu      sync.Mutex
+	pending map[int64]chan incoming
+	err     error
+	done    chan struct{}
+}
+
+// Connect 建立连接并完成 initialize 握手
+func Connect(ctx context.Context, cfg Config) (*Client, error) {
+	var (
+		t   transport
+		err error
+	)
+	switch cfg.Transport {
+	case TransportStreamableHTTP, "":
+		t, err = newStreamableHTTP(cfg)
+	case TransportSSE:
+		t, err = newSSE(ctx, cfg)
+	case TransportWebSocket:
+		t, err = newWebSocket(ctx, cfg)
+	case TransportSTDIO:
+		t, err = newSTDIO(cfg)
+	default:
+		err = fmt.Errorf("不支持的传输方式: %s", cfg.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		cfg:       cfg,
+		transport: t,
+		pending:   map[int64]chan incoming{},
+		done:      make(chan struct{}),
+	}
+	go c.dispatch()
+
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "dootask-ai", "version": "1.0.0"},
+	}
+	if err := c.call(ctx, "initialize", params, &c.init); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Err 连接断开的原因，连接正常时返回nil
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Initialize 返回握手结果
+func (c *Client) Initialize() InitializeResult {
+	return c.init
+}
+
+// HasCapability 服务端是否声明了指定能力
+func (c *Client) HasCapability(name string) bool {
+	_, ok := c.init.Capabilities[name]
+	return ok
+}
+
+// ListTools 列出工具
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var result struct {
+		Tools []Tool `json:"tools"`
+	}
+	err := c.call(ctx, "tools/list", map[string]any{}, &result)
+	return result.Tools, err
+}
+
+// ListResources 列出资源
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var result struct {
+		Resources []Resource `json:"resources"`
+	}
+	err := c.call(ctx, "resources/list", map[string]any{}, &result)
+	return result.Resources, err
+}
+
+// ListPrompts 列出提示词模板
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var result struct {
+		Prompts []Prompt `json:"prompts"`
+	}
+	err := c.call(ctx, "prompts/list", map[string]any{}, &result)
+	return result.Prompts, err
+}
+
+// CallTool 调用工具
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error) {
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": arguments}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Ping 检查连接是否可用
+func (c *Client) Ping(ctx context.Context) error {
+	return c.call(ctx, "ping", map[string]any{}, nil)
+}
+
+// PID stdio 子进程ID，其他传输方式返回0
+func (c *Client) PID() int {
+	if t, ok := c.transport.(*stdioTransport); ok {
+		return t.pid()
+	}
+	return 0
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.transport.close()
+}
+
+// call 发送请求并等待对应ID的响应
+func (c *Client) call(ctx context.Context, method string, params any, out any) error {
+	if c.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+
+	id := c.nextID.Add(1)
+	ch := make(chan incoming, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(message{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if err := c.transport.send(ctx, data); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if out != nil && len(resp.Result) > 0 {
+			return json.Unmarshal(resp.Result, out)
+		}
+		return nil
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return fmt.Errorf("%s 请求超时: %w", method, ctx.Err())
+	}
+}
+
+// notify 发送通知（无响应）
+func (c *Client) notify(ctx context.Context, method string, params any) error {
+	data, err := json.Marshal(message{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return c.transport.send(ctx, data)
+}
+
+// dispatch 将服务端消息分发给等待中的请求，并应答服务端发起的请求
+func (c *Client) dispatch() {
+	for data := range c.transport.incoming() {
+		data = bytes.TrimSpace(data)
+		var batch []incoming
+		if len(data) > 0 && data[0] == '[' {
+			if err := json.Unmarshal(data, &batch); err != nil {
+				continue
+			}
+		} else {
+			var msg incoming
+			if err := json.Unmarshal(data, &msg); err != nil {
+				continue
+			}
+			batch = []incoming{msg}
+		}
+
+		for _, msg := range batch {
+			if msg.Method != "" {
+				if len(msg.ID) > 0 {
+					go c.reply(msg)
+				}
+				continue
+			}
+			id, err := strconv.ParseInt(string(bytes.Trim(msg.ID, `"`)), 10, 64)
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+	c.fail(ErrClosed)
+}
+
+// reply 应答服务端发起的请求（仅支持 ping）
+func (c *Client) reply(msg incoming) {
+	resp := map[string]any{"jsonrpc": "2.0", "id": msg.ID}
+	if msg.Method == "ping" {
+		resp["result"] = map[string]any{}
+	} else {
+		resp["error"] = RPCError{Code: -32601, Message: "Method not found"}
+	}
+	data, _ := json.Marshal(resp)
+	c.transport.send(context.Background(), data)
+}
+
+// fail 标记连接失败并唤醒所有等待中的请求
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if terr, ok := c.transport.(interface{ lastError() error }); ok && terr.lastError() != nil && err == ErrClosed {
+		err = fmt.Errorf("%w: %v", ErrClosed, terr.lastError())
+	}
+	c.err = err
+	close(c.done)
+}
@@ -0,0 +1,537 @@
+package mcpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// maxMessageSize 单条消息最大长度
+	maxMessageSize = 16 << 20
+	// stderrTailSize 保留的 stdio 进程错误输出长度
+	stderrTailSize = 2048
+)
+
+// readSSE 解析 Server-Sent Events，fn 返回 false 时停止
+func readSSE(r io.Reader, fn func(event string, data []byte) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+
+	var (
+		event string
+		data  bytes.Buffer
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() > 0 {
+				if !fn(event, bytes.TrimSuffix(data.Bytes(), []byte("\n"))) {
+					return nil
+				}
+			}
+			event = ""
+			data.Reset()
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			data.WriteByte('\n')
+		}
+	}
+	if data.Len() > 0 {
+		fn(event, bytes.TrimSuffix(data.Bytes(), []byte("\n")))
+	}
+	return scanner.Err()
+}
+
+// httpError 读取失败响应
+func httpError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &HTTPError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body)), Header: resp.Header}
+}
+
+// streamableHTTP Streamable HTTP 传输：每条消息单独POST，响应为JSON或SSE流
+type streamableHTTP struct {
+	cfg       Config
+	client    *http.Client
+	messages  chan []byte
+	sessionID string
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newStreamableHTTP(cfg Config) (*streamableHTTP, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("缺少服务地址 url")
+	}
+	return &streamableHTTP{
+		cfg:      cfg,
+		client:   &http.Client{},
+		messages: make(chan []byte, 16),
+		closed:   make(chan struct{}),
+	}, nil
+}
+
+func (t *streamableHTTP) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.cfg.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, value := range t.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("MCP-Protocol-Version", ProtocolVersion)
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if sessionID := resp.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return httpError(resp)
+	}
+	if resp.StatusCode == http.StatusAccepted || resp.ContentLength == 0 {
+		resp.Body.Close()
+		return nil
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer resp.Body.Close()
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+			readSSE(resp.Body, func(event string, data []byte) bool {
+				return t.deliver(data)
+			})
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxMessageSize))
+		if err == nil && len(bytes.TrimSpace(body)) > 0 {
+			t.deliver(body)
+		}
+	}()
+	return nil
+}
+
+func (t *streamableHTTP) deliver(data []byte) bool {
+	select {
+	case t.messages <- data:
+		return true
+	case <-t.closed:
+		return false
+	}
+}
+
+func (t *streamableHTTP) incoming() <-chan []byte {
+	return t.messages
+}
+
+func (t *streamableHTTP) close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		t.mu.Lock()
+		sessionID := t.sessionID
+		t.mu.Unlock()
+		if sessionID != "" {
+			// 通知服务端结束会话
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.cfg.URL, nil); err == nil {
+				for key, value := range t.cfg.Headers {
+					req.Header.Set(key, value)
+				}
+				req.Header.Set("Mcp-Session-Id", sessionID)
+				if resp, err := t.client.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}
+		go func() {
+			t.wg.Wait()
+			close(t.messages)
+		}()
+	})
+	return nil
+}
+
+// sseTransport HTTP+SSE 传输：GET 建立事件流，服务端通过 endpoint 事件告知消息提交地址
+type sseTransport struct {
+	cfg      Config
+	client   *http.Client
+	body     io.ReadCloser
+	endpoint string
+	messages chan []byte
+	err      error
+}
+
+func newSSE(ctx context.Context, cfg Config) (*sseTransport, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("缺少服务地址 url")
+	}
+	req, err := http.NewRequest(http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	t := &sseTransport{cfg: cfg, client: &http.Client{}, messages: make(chan []byte, 16)}
+	type dialResult struct {
+		resp *http.Response
+		err  error
+	}
+	result := make(chan dialResult, 1)
+	go func() {
+		resp, err := t.client.Do(req)
+		result <- dialResult{resp, err}
+	}()
+
+	var resp *http.Response
+	select {
+	case r := <-result:
+		if r.err != nil {
+			return nil, r.err
+		}
+		resp = r.resp
+	case <-ctx.Done():
+		return nil, fmt.Errorf("连接SSE服务超时: %w", ctx.Err())
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, httpError(resp)
+	}
+	t.body = resp.Body
+
+	endpoint := make(chan string, 1)
+	go func() {
+		defer close(t.messages)
+		t.err = readSSE(resp.Body, func(event string, data []byte) bool {
+			if event == "endpoint" {
+				select {
+				case endpoint <- string(data):
+				default:
+				}
+				return true
+			}
+			t.messages <- append([]byte(nil), data...)
+			return true
+		})
+	}()
+
+	select {
+	case path := <-endpoint:
+		base, _ := url.Parse(cfg.URL)
+		ref, err := url.Parse(path)
+		if err != nil {
+			t.close()
+			return nil, fmt.Errorf("服务端返回的消息地址无效: %w", err)
+		}
+		t.endpoint = base.ResolveReference(ref).String()
+	case <-ctx.Done():
+		t.close()
+		return nil, fmt.Errorf("等待SSE endpoint事件超时: %w", ctx.Err())
+	}
+	return t, nil
+}
+
+func (t *sseTransport) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for key, value := range t.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return httpError(resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (t *sseTransport) incoming() <-chan []byte {
+	return t.messages
+}
+
+func (t *sseTransport) lastError() error {
+	return t.err
+}
+
+func (t *sseTransport) close() error {
+	return t.body.Close()
+}
+
+// wsTransport WebSocket 传输：每条消息一个文本帧
+type wsTransport struct {
+	conn     *websocket.Conn
+	messages chan []byte
+	mu       sync.Mutex
+	err      error
+}
+
+func newWebSocket(ctx context.Context, cfg Config) (*wsTransport, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("缺少服务地址 url")
+	}
+	target := cfg.URL
+	if strings.HasPrefix(target, "http") {
+		target = "ws" + strings.TrimPrefix(target, "http")
+	}
+	origin := "http://localhost/"
+	config, err := websocket.NewConfig(target, origin)
+	if err != nil {
+		return nil, err
+	}
+	config.Protocol = cfg.Protocols
+	if len(config.Protocol) == 0 {
+		config.Protocol = []string{"mcp"}
+	}
+	for key, value := range cfg.Headers {
+		config.Header.Set(key, value)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		config.Dialer = &net.Dialer{Deadline: deadline}
+	}
+
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	conn.MaxPayloadBytes = maxMessageSize
+
+	t := &wsTransport{conn: conn, messages: make(chan []byte, 16)}
+	go func() {
+		defer close(t.messages)
+		for {
+			var data []byte
+			if err := websocket.Message.Receive(conn, &data); err != nil {
+				if err != io.EOF {
+					t.err = err
+				}
+				return
+			}
+			t.messages <- data
+		}
+	}()
+	return t, nil
+}
+
+func (t *wsTransport) send(ctx context.Context, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return websocket.Message.Send(t.conn, string(data))
+}
+
+func (t *wsTransport) incoming() <-chan []byte {
+	return t.messages
+}
+
+func (t *wsTransport) lastError() error {
+	return t.err
+}
+
+func (t *wsTransport) close() error {
+	return t.conn.Close()
+}
+
+// stdioTransport 标准输入输出传输：启动子进程，按行收发JSON
+type stdioTransport struct {
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	messages chan []byte
+	stderr   *tailBuffer
+	mu       sync.Mutex
+	exited   chan struct{}
+	waitErr  error
+}
+
+func newSTDIO(cfg Config) (*stdioTransport, error) {
+	command, args := cfg.Command, cfg.Args
+	if fields := strings.Fields(command); len(fields) > 1 {
+		// 兼容将参数写在 command 中的配置
+		command, args = fields[0], append(fields[1:], args...)
+	}
+	if command == "" {
+		return nil, fmt.Errorf("缺少启动命令 command")
+	}
+
+	cmd := exec.Command(command, args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = os.Environ()
+	if cfg.Isolated {
+		cmd.Env = isolatedEnv()
+	}
+	for key, value := range cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &stdioTransport{
+		cmd:      cmd,
+		stdin:    stdin,
+		messages: make(chan []byte, 16),
+		stderr:   &tailBuffer{size: stderrTailSize},
+		exited:   make(chan struct{}),
+	}
+	cmd.Stderr = t.stderr
+	if cfg.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(t.stderr, cfg.Stderr)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动命令失败: %w", err)
+	}
+
+	go func() {
+		defer close(t.messages)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 || (line[0] != '{' && line[0] != '[') {
+				// 忽略非JSON输出（部分服务会向stdout打印日志）
+				continue
+			}
+			t.messages <- append([]byte(nil), line...)
+		}
+	}()
+	go func() {
+		t.waitErr = cmd.Wait()
+		close(t.exited)
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) send(ctx context.Context, data []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-t.exited:
+		return t.lastError()
+	default:
+	}
+	_, err := t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) incoming() <-chan []byte {
+	return t.messages
+}
+
+// lastError 进程退出原因及最后的错误输出
+func (t *stdioTransport) lastError() error {
+	select {
+	case <-t.exited:
+	case <-time.After(100 * time.Millisecond):
+		return nil
+	}
+	stderr := strings.TrimSpace(t.stderr.String())
+	switch {
+	case t.waitErr != nil && stderr != "":
+		return fmt.Errorf("进程退出（%v）: %s", t.waitErr, stderr)
+	case t.waitErr != nil:
+		return fmt.Errorf("进程退出: %v", t.waitErr)
+	case stderr != "":
+		return fmt.Errorf("进程退出: %s", stderr)
+	}
+	return fmt.Errorf("进程退出")
+}
+
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.exited:
+		return nil
+	case <-time.After(2 * time.Second):
+	}
+	if t.cmd.Process != nil {
+		t.cmd.Process.Kill()
+	}
+	<-t.exited
+	return nil
+}
+
+// pid 子进程ID
+func (t *stdioTransport) pid() int {
+	if t.cmd.Process == nil {
+		return 0
+	}
+	return t.cmd.Process.Pid
+}
+
+// isolatedEnv 隔离环境下子进程可见的基础环境变量
+func isolatedEnv() []string {
+	var env []string
+	for _, key := range []string{"PATH", "HOME", "LANG", "TZ", "TMPDIR"} {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	return env
+}
+
+// tailBuffer 只保留最后 size 字节的缓冲区
+type tailBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = b.buf[len(b.buf)-b.size:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,136 @@
+package mcpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// 传输方式
+const (
+	TransportStreamableHTTP = "streamable_http"
+	TransportWebSocket      = "websocket"
+	TransportSSE            = "sse"
+	TransportSTDIO          = "stdio"
+)
+
+// ProtocolVersion 客户端声明的MCP协议版本
+const ProtocolVersion = "2025-03-26"
+
+// Config 连接MCP服务的配置
+type Config struct {
+	Transport string
+	URL       string            // streamable_http / sse / websocket
+	Headers   map[string]string // 请求头（含鉴权）
+	Protocols []string          // websocket 子协议
+	Command   string            // stdio 命令
+	Args      []string          // stdio 参数
+	Env       map[string]string // stdio 环境变量
+	Dir       string            // stdio 工作目录
+	Isolated  bool              // stdio 不继承当前服务的环境变量，只保留 PATH 等基础变量
+	Stderr    io.Writer         // stdio 错误输出（同时写入）
+	Timeout   time.Duration     // 单次请求超时，0表示使用调用方的context
+}
+
+// ServerInfo 服务端信息
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// InitializeResult initialize 握手结果
+type InitializeResult struct {
+	ProtocolVersion string                     `json:"protocolVersion"`
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	ServerInfo      ServerInfo                 `json:"serverInfo"`
+	Instructions    string                     `json:"instructions,omitempty"`
+}
+
+// Tool 服务端提供的工具
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Resource 服务端提供的资源
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Prompt 服务端提供的提示词模板
+type Prompt struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Content 工具返回的内容片段
+type Content struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Data     string          `json:"data,omitempty"` // 图片、音频的base64数据
+	MimeType string          `json:"mimeType,omitempty"`
+	Resource json.RawMessage `json:"resource,omitempty"` // 内嵌资源
+}
+
+// CallToolResult 工具调用结果
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError"`
+}
+
+// Text 拼接结果中的文本内容
+func (r CallToolResult) Text() string {
+	text := ""
+	for _, content := range r.Content {
+		if content.Type == "text" {
+			text += content.Text
+		}
+	}
+	return text
+}
+
+// RPCError JSON-RPC 错误
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("MCP错误 %d: %s", e.Code, e.Message)
+}
+
+// HTTPError 服务端返回的非成功HTTP状态
+type HTTPError struct {
+	StatusCode int
+	Body       string
+	Header     map[string][]string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// message JSON-RPC 消息
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// incoming 收到的 JSON-RPC 消息（ID 可能为字符串或数字）
+type incoming struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *RPCError       `json:"error,omitempty"`
+}
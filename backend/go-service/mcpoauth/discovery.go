@@ -0,0 +1,173 @@
+package mcpoauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// httpClient 访问授权服务器使用的客户端
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// resourceMetadataPattern WWW-Authenticate 中的受保护资源元数据地址
+var resourceMetadataPattern = regexp.MustCompile(`resource_metadata="([^"]+)"`)
+
+// Discover 发现MCP服务的授权服务器：先读取受保护资源元数据，再读取授权服务器元数据；
+// 都未提供时按MCP规范使用服务地址根路径下的 /authorize、/token、/register
+func Discover(ctx context.Context, serverURL string) (*Server, error) {
+	resource, err := resourceURL(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	server := &Server{Resource: resource.String()}
+	issuer := origin(resource)
+
+	if rm := discoverResource(ctx, resource); rm != nil {
+		if rm.Resource != "" {
+			server.Resource = rm.Resource
+		}
+		if len(rm.AuthorizationServers) > 0 {
+			if issuer, err = url.Parse(rm.AuthorizationServers[0]); err != nil {
+				return nil, fmt.Errorf("授权服务器地址无效: %w", err)
+			}
+		}
+		server.Scopes = rm.ScopesSupported
+	}
+
+	metadata := discoverMetadata(ctx, issuer)
+	if metadata == nil {
+		base := strings.TrimSuffix(origin(issuer).String(), "/")
+		metadata = &Metadata{
+			Issuer:                base,
+			AuthorizationEndpoint: base + "/authorize",
+			TokenEndpoint:         base + "/token",
+			RegistrationEndpoint:  base + "/register",
+		}
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, fmt.Errorf("授权服务器元数据缺少 authorization_endpoint 或 token_endpoint")
+	}
+	if len(metadata.CodeChallengeMethodsSupported) > 0 && !slices.Contains(metadata.CodeChallengeMethodsSupported, "S256") {
+		return nil, fmt.Errorf("授权服务器不支持 PKCE S256")
+	}
+	server.Metadata = *metadata
+	return server, nil
+}
+
+// resourceURL MCP服务地址作为资源标识（websocket 地址按对应的 http 地址发现）
+func resourceURL(serverURL string) (*url.URL, error) {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("MCP服务地址无效: %s", serverURL)
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	case "http", "https":
+	default:
+		return nil, fmt.Errorf("MCP服务地址无效: %s", serverURL)
+	}
+	u.Fragment = ""
+	u.RawQuery = ""
+	return u, nil
+}
+
+// origin 地址的根路径
+func origin(u *url.URL) *url.URL {
+	return &url.URL{Scheme: u.Scheme, Host: u.Host}
+}
+
+// wellKnown 按 RFC 8414/9728 将 well-known 路径插入主机和路径之间
+func wellKnown(u *url.URL, name string) string {
+	path := strings.TrimSuffix(u.Path, "/")
+	return origin(u).String() + "/.well-known/" + name + path
+}
+
+// discoverResource 读取受保护资源元数据，优先使用未授权请求返回的 WWW-Authenticate 中的地址
+func discoverResource(ctx context.Context, resource *url.URL) *ResourceMetadata {
+	candidates := []string{}
+	if metadataURL := challengeMetadataURL(ctx, resource.String()); metadataURL != "" {
+		candidates = append(candidates, metadataURL)
+	}
+	candidates = append(candidates, wellKnown(resource, "oauth-protected-resource"))
+	if path := strings.TrimSuffix(resource.Path, "/"); path != "" {
+		candidates = append(candidates, origin(resource).String()+"/.well-known/oauth-protected-resource")
+	}
+	for _, candidate := range candidates {
+		var rm ResourceMetadata
+		if getJSON(ctx, candidate, &rm) == nil && (rm.Resource != "" || len(rm.AuthorizationServers) > 0) {
+			return &rm
+		}
+	}
+	return nil
+}
+
+// challengeMetadataURL 发送未授权的初始化请求，读取401响应中的 resource_metadata
+func challengeMetadataURL(ctx context.Context, serverURL string) string {
+	body := `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{},"clientInfo":{"name":"dootask-ai","version":"1.0.0"}}}`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, serverURL, strings.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		return ""
+	}
+	for _, value := range resp.Header.Values("WWW-Authenticate") {
+		if match := resourceMetadataPattern.FindStringSubmatch(value); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// discoverMetadata 读取授权服务器元数据（依次尝试 OAuth 和 OpenID Connect 的 well-known 地址）
+func discoverMetadata(ctx context.Context, issuer *url.URL) *Metadata {
+	candidates := []string{
+		wellKnown(issuer, "oauth-authorization-server"),
+		wellKnown(issuer, "openid-configuration"),
+	}
+	if path := strings.TrimSuffix(issuer.Path, "/"); path != "" {
+		candidates = append(candidates, strings.TrimSuffix(issuer.String(), "/")+"/.well-known/openid-configuration")
+	}
+	for _, candidate := range candidates {
+		var metadata Metadata
+		if getJSON(ctx, candidate, &metadata) == nil && metadata.AuthorizationEndpoint != "" {
+			return &metadata
+		}
+	}
+	return nil
+}
+
+// getJSON 读取JSON文档
+func getJSON(ctx context.Context, target string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
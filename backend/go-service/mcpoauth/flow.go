@@ -0,0 +1,172 @@
+package mcpoauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// clientName 动态注册时使用的客户端名称
+const clientName = "DooTask AI"
+
+// NewVerifier 生成 PKCE code_verifier
+func NewVerifier() string {
+	return randomString(32)
+}
+
+// NewState 生成授权请求的 state
+func NewState() string {
+	return randomString(24)
+}
+
+// Challenge 计算 S256 code_challenge
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// Register 动态注册公开客户端（RFC 7591）
+func Register(ctx context.Context, metadata Metadata, redirectURI string) (*Client, error) {
+	if metadata.RegistrationEndpoint == "" {
+		return nil, fmt.Errorf("授权服务器不支持动态注册客户端，请在配置中指定 oauth.client_id")
+	}
+	body, _ := json.Marshal(map[string]any{
+		"client_name":                clientName,
+		"redirect_uris":              []string{redirectURI},
+		"grant_types":                []string{"authorization_code", "refresh_token"},
+		"response_types":             []string{"code"},
+		"token_endpoint_auth_method": "none",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.RegistrationEndpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	var result struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := do(req, &result); err != nil {
+		return nil, fmt.Errorf("注册客户端失败: %w", err)
+	}
+	if result.ClientID == "" {
+		return nil, fmt.Errorf("注册客户端失败: 未返回 client_id")
+	}
+	return &Client{ID: result.ClientID, Secret: result.ClientSecret}, nil
+}
+
+// AuthorizationURL 构造授权地址
+func AuthorizationURL(server Server, client Client, redirectURI, state, verifier, scope string) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ID},
+		"redirect_uri":          {redirectURI},
+		"state":                 {state},
+		"code_challenge":        {Challenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if server.Resource != "" {
+		params.Set("resource", server.Resource)
+	}
+	if scope == "" {
+		scope = strings.Join(server.Scopes, " ")
+	}
+	if scope != "" {
+		params.Set("scope", scope)
+	}
+	separator := "?"
+	if strings.Contains(server.Metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return server.Metadata.AuthorizationEndpoint + separator + params.Encode()
+}
+
+// Exchange 使用授权码换取令牌
+func Exchange(ctx context.Context, server Server, client Client, code, verifier, redirectURI string) (*Token, error) {
+	return requestToken(ctx, server, client, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {redirectURI},
+	})
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌（未返回新的刷新令牌时沿用原刷新令牌）
+func Refresh(ctx context.Context, server Server, client Client, refreshToken string) (*Token, error) {
+	token, err := requestToken(ctx, server, client, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// requestToken 请求令牌端点（机密客户端使用 HTTP Basic 认证）
+func requestToken(ctx context.Context, server Server, client Client, form url.Values) (*Token, error) {
+	if server.Resource != "" {
+		form.Set("resource", server.Resource)
+	}
+	if client.Secret == "" {
+		form.Set("client_id", client.ID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.Metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if client.Secret != "" {
+		req.SetBasicAuth(url.QueryEscape(client.ID), url.QueryEscape(client.Secret))
+	}
+
+	var token Token
+	if err := do(req, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("授权服务器未返回 access_token")
+	}
+	if token.TokenType == "" {
+		token.TokenType = "Bearer"
+	}
+	return &token, nil
+}
+
+// do 发送请求并解析JSON响应，失败时返回授权服务器的错误
+func do(req *http.Request, out any) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		oauthErr := &Error{Status: resp.StatusCode}
+		json.Unmarshal(data, oauthErr)
+		return oauthErr
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,272 @@
+package mcpoauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+const testRedirectURI = "https://ai.example.com/oauth/callback"
+
+// mockAuthServer 模拟MCP服务和授权服务器：受保护资源元数据、授权服务器元数据、动态注册和令牌端点
+type mockAuthServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu         sync.Mutex
+	clients    map[string][]string // client_id → redirect_uris
+	codes      map[string]url.Values
+	refreshes  map[string]bool
+	registered int
+}
+
+func newMockAuthServer(t *testing.T) *mockAuthServer {
+	m := &mockAuthServer{
+		t:         t,
+		clients:   map[string][]string{},
+		codes:     map[string]url.Values{},
+		refreshes: map[string]bool{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer resource_metadata="%s/.well-known/oauth-protected-resource/mcp"`, m.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ResourceMetadata{
+			Resource:             m.URL + "/mcp",
+			AuthorizationServers: []string{m.URL + "/auth"},
+			ScopesSupported:      []string{"tools:read", "tools:call"},
+		})
+	})
+	mux.HandleFunc("/.well-known/oauth-authorization-server/auth", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Metadata{
+			Issuer:                        m.URL + "/auth",
+			AuthorizationEndpoint:         m.URL + "/auth/authorize",
+			TokenEndpoint:                 m.URL + "/auth/token",
+			RegistrationEndpoint:          m.URL + "/auth/register",
+			CodeChallengeMethodsSupported: []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/auth/register", m.register)
+	mux.HandleFunc("/auth/token", m.token)
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// register 动态注册客户端（RFC 7591）
+func (m *mockAuthServer) register(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RedirectURIs            []string `json:"redirect_uris"`
+		GrantTypes              []string `json:"grant_types"`
+		TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil || len(req.RedirectURIs) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_client_metadata"})
+		return
+	}
+	if req.TokenEndpointAuthMethod != "none" {
+		m.t.Errorf("token_endpoint_auth_method = %q, want none", req.TokenEndpointAuthMethod)
+	}
+	m.mu.Lock()
+	m.registered++
+	clientID := fmt.Sprintf("client-%d", m.registered)
+	m.clients[clientID] = req.RedirectURIs
+	m.mu.Unlock()
+	writeJSON(w, http.StatusCreated, map[string]any{"client_id": clientID, "redirect_uris": req.RedirectURIs})
+}
+
+// authorize 模拟用户在授权页面同意授权，返回授权码
+func (m *mockAuthServer) authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatalf("parse authorization url: %v", err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.URL+"/auth/authorize" {
+		m.t.Fatalf("authorization endpoint = %s", got)
+	}
+	params := u.Query()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := fmt.Sprintf("code-%d", len(m.codes)+1)
+	m.codes[code] = params
+	return code
+}
+
+// token 令牌端点：授权码换取令牌时校验 PKCE，刷新令牌时签发新的访问令牌
+func (m *mockAuthServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Method != http.MethodPost {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("resource") != m.URL+"/mcp" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_target"})
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.clients[r.PostForm.Get("client_id")]; !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		auth, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code"))
+		switch {
+		case !ok,
+			auth.Get("client_id") != r.PostForm.Get("client_id"),
+			auth.Get("redirect_uri") != r.PostForm.Get("redirect_uri"):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		case auth.Get("code_challenge_method") != "S256" || Challenge(r.PostForm.Get("code_verifier")) != auth.Get("code_challenge"):
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		default:
+			m.refreshes["refresh-1"] = true
+			writeJSON(w, http.StatusOK, Token{AccessToken: "access-1", TokenType: "Bearer", RefreshToken: "refresh-1", ExpiresIn: 3600, Scope: auth.Get("scope")})
+		}
+	case "refresh_token":
+		if !m.refreshes[r.PostForm.Get("refresh_token")] {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		// 不轮换刷新令牌
+		writeJSON(w, http.StatusOK, map[string]any{"access_token": "access-2", "expires_in": 3600})
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	m := newMockAuthServer(t)
+	ctx := context.Background()
+
+	server, err := Discover(ctx, m.URL+"/mcp")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if server.Resource != m.URL+"/mcp" {
+		t.Errorf("Resource = %q", server.Resource)
+	}
+	if server.Metadata.TokenEndpoint != m.URL+"/auth/token" || server.Metadata.RegistrationEndpoint != m.URL+"/auth/register" {
+		t.Errorf("Metadata = %+v", server.Metadata)
+	}
+
+	client, err := Register(ctx, server.Metadata, testRedirectURI)
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if client.ID != "client-1" || client.Secret != "" {
+		t.Errorf("Client = %+v", client)
+	}
+
+	verifier, state := NewVerifier(), NewState()
+	authURL := AuthorizationURL(*server, *client, testRedirectURI, state, verifier, "")
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	params := parsed.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             client.ID,
+		"redirect_uri":          testRedirectURI,
+		"state":                 state,
+		"code_challenge":        Challenge(verifier),
+		"code_challenge_method": "S256",
+		"resource":              m.URL + "/mcp",
+		"scope":                 "tools:read tools:call",
+	}
+	for key, value := range want {
+		if params.Get(key) != value {
+			t.Errorf("authorization url %s = %q, want %q", key, params.Get(key), value)
+		}
+	}
+
+	// 使用错误的 code_verifier 时授权服务器拒绝
+	if _, err := Exchange(ctx, *server, *client, m.authorize(authURL), NewVerifier(), testRedirectURI); err == nil {
+		t.Fatal("Exchange with wrong verifier succeeded")
+	} else if oauthErr := (*Error)(nil); !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" || oauthErr.Status != http.StatusBadRequest {
+		t.Errorf("Exchange error = %v", err)
+	}
+
+	token, err := Exchange(ctx, *server, *client, m.authorize(authURL), verifier, testRedirectURI)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" || token.TokenType != "Bearer" || token.ExpiresIn != 3600 {
+		t.Errorf("Token = %+v", token)
+	}
+
+	refreshed, err := Refresh(ctx, *server, *client, token.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.AccessToken != "access-2" || refreshed.TokenType != "Bearer" {
+		t.Errorf("refreshed Token = %+v", refreshed)
+	}
+	if refreshed.RefreshToken != token.RefreshToken {
+		t.Errorf("RefreshToken = %q, want original %q", refreshed.RefreshToken, token.RefreshToken)
+	}
+
+	if _, err := Refresh(ctx, *server, *client, "unknown"); err == nil {
+		t.Error("Refresh with unknown token succeeded")
+	}
+}
+
+func TestDiscoverFallback(t *testing.T) {
+	// 没有任何元数据时按MCP规范使用服务根路径下的默认端点
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	server, err := Discover(context.Background(), srv.URL+"/v1/mcp")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	want := Metadata{
+		Issuer:                srv.URL,
+		AuthorizationEndpoint: srv.URL + "/authorize",
+		TokenEndpoint:         srv.URL + "/token",
+		RegistrationEndpoint:  srv.URL + "/register",
+	}
+	if server.Metadata.Issuer != want.Issuer || server.Metadata.AuthorizationEndpoint != want.AuthorizationEndpoint ||
+		server.Metadata.TokenEndpoint != want.TokenEndpoint || server.Metadata.RegistrationEndpoint != want.RegistrationEndpoint {
+		t.Errorf("Metadata = %+v, want %+v", server.Metadata, want)
+	}
+	if server.Resource != srv.URL+"/v1/mcp" {
+		t.Errorf("Resource = %q", server.Resource)
+	}
+}
+
+func TestDiscoverRequiresS256(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/oauth-authorization-server" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, Metadata{
+			AuthorizationEndpoint:         srv.URL + "/authorize",
+			TokenEndpoint:                 srv.URL + "/token",
+			CodeChallengeMethodsSupported: []string{"plain"},
+		})
+	}))
+	defer srv.Close()
+
+	if _, err := Discover(context.Background(), srv.URL); err == nil {
+		t.Error("Discover accepted a server without S256 support")
+	}
+}
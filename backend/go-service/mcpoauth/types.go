@@ -0,0 +1,71 @@
+package mcpoauth
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metadata 授权服务器元数据（RFC 8414）
+type Metadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	RegistrationEndpoint          string   `json:"registration_endpoint,omitempty"`
+	ScopesSupported               []string `json:"scopes_supported,omitempty"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// ResourceMetadata 受保护资源元数据（RFC 9728）
+type ResourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+	ScopesSupported      []string `json:"scopes_supported,omitempty"`
+}
+
+// Server 发现结果：MCP服务对应的授权服务器
+type Server struct {
+	Metadata Metadata `json:"metadata"`
+	Resource string   `json:"resource"` // 授权请求中的 resource 参数（RFC 8707）
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// Client 在授权服务器注册的客户端
+type Client struct {
+	ID     string
+	Secret string // 公开客户端为空
+}
+
+// Token 令牌响应
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// ExpiresAt 令牌过期时间，未返回有效期时为nil
+func (t Token) ExpiresAt(now time.Time) *time.Time {
+	if t.ExpiresIn <= 0 {
+		return nil
+	}
+	expiresAt := now.Add(time.Duration(t.ExpiresIn) * time.Second)
+	return &expiresAt
+}
+
+// Error 授权服务器返回的错误（RFC 6749 5.2）
+type Error struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	if e.Code != "" {
+		return e.Code
+	}
+	return fmt.Sprintf("授权服务器返回 HTTP %d", e.Status)
+}
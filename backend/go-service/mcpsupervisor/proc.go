@@ -0,0 +1,107 @@
+package mcpsupervisor
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// clockTicks /proc 中CPU时间的单位（Linux 固定为100）
+const clockTicks = 100
+
+// procStat /proc/<pid>/stat 中需要的字段
+type procStat struct {
+	pid        int
+	ppid       int
+	rssBytes   int64
+	cpuSeconds float64
+}
+
+// usage 进程及其子进程的资源占用
+type usage struct {
+	memoryMB   int
+	cpuSeconds float64
+	pids       []int
+}
+
+// readProcs 读取全部进程的状态，非Linux系统（没有 /proc）返回空
+func readProcs() map[int]procStat {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	pageSize := int64(os.Getpagesize())
+	procs := make(map[int]procStat, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// 进程名可能包含空格和括号，从最后一个右括号之后开始解析
+		idx := strings.LastIndexByte(string(data), ')')
+		if idx < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[idx+1:]))
+		if len(fields) < 22 {
+			continue
+		}
+		ppid, _ := strconv.Atoi(fields[1])
+		utime, _ := strconv.ParseFloat(fields[11], 64)
+		stime, _ := strconv.ParseFloat(fields[12], 64)
+		rss, _ := strconv.ParseInt(fields[21], 10, 64)
+		procs[pid] = procStat{
+			pid:        pid,
+			ppid:       ppid,
+			rssBytes:   rss * pageSize,
+			cpuSeconds: (utime + stime) / clockTicks,
+		}
+	}
+	return procs
+}
+
+// processUsage 统计进程树（如 npx 启动的 node 子进程）的内存和CPU时间
+func processUsage(procs map[int]procStat, pid int) usage {
+	root, ok := procs[pid]
+	if !ok {
+		return usage{}
+	}
+	children := map[int][]int{}
+	for _, p := range procs {
+		children[p.ppid] = append(children[p.ppid], p.pid)
+	}
+
+	var (
+		result usage
+		rss    int64
+		queue  = []int{root.pid}
+	)
+	for len(queue) > 0 {
+		p := procs[queue[0]]
+		queue = append(queue[1:], children[p.pid]...)
+		rss += p.rssBytes
+		result.cpuSeconds += p.cpuSeconds
+		result.pids = append(result.pids, p.pid)
+	}
+	result.memoryMB = int(rss >> 20)
+	return result
+}
+
+// killTree 结束进程及其子进程
+func killTree(pid int) {
+	pids := processUsage(readProcs(), pid).pids
+	if len(pids) == 0 {
+		pids = []int{pid}
+	}
+	// 先结束子进程，避免被重新挂到init进程下
+	for i := len(pids) - 1; i >= 0; i-- {
+		if p, err := os.FindProcess(pids[i]); err == nil {
+			p.Kill()
+		}
+	}
+}
@@ -0,0 +1,494 @@
+package mcpsupervisor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/mcpclient"
+)
+
+// 按工具和启动配置管理的进程池（配置中填充了用户密钥时，每个用户使用独立的进程）
+var (
+	mu          sync.Mutex
+	pools       = map[string]*pool{}
+	monitorOnce sync.Once
+)
+
+// process 托管的stdio MCP进程
+type process struct {
+	client     *mcpclient.Client
+	pid        int
+	startedAt  time.Time
+	lastUsed   time.Time
+	inFlight   int
+	calls      int64
+	memoryMB   int
+	cpuSeconds float64
+}
+
+// pool 单个工具的进程池
+type pool struct {
+	key    string
+	toolID int64
+
+	mu          sync.Mutex
+	cfg         mcpclient.Config
+	processes   []*process
+	starting    int
+	lastUsed    time.Time
+	restarts    int
+	failures    int // 连续启动失败或崩溃的次数，用于计算重启退避
+	retryAt     time.Time
+	lastError   string
+	lastErrorAt time.Time
+	logs        []LogLine
+}
+
+// Lease 一次调用借出的进程，用完后必须归还
+type Lease struct {
+	Client  *mcpclient.Client
+	pool    *pool
+	process *process
+	once    sync.Once
+}
+
+// Release 归还进程
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		l.pool.mu.Lock()
+		defer l.pool.mu.Unlock()
+		l.process.inFlight--
+		l.process.lastUsed = time.Now()
+	})
+}
+
+// Acquire 获取工具的一个可用进程：按需启动，优先选择空闲进程，全部繁忙且未达上限时扩容
+func Acquire(toolID int64, cfg mcpclient.Config) (*Lease, error) {
+	monitorOnce.Do(func() { go monitor() })
+
+	p := getPool(toolID, cfg)
+	limits := currentLimits()
+
+	p.mu.Lock()
+	p.reap()
+	best := p.idlest()
+	if best != nil {
+		if best.inFlight > 0 && len(p.processes)+p.starting < limits.MaxProcesses && time.Now().After(p.retryAt) {
+			// 全部繁忙时后台扩容，本次调用先使用负载最低的进程
+			p.starting++
+			go p.grow()
+		}
+		lease := p.lend(best)
+		p.mu.Unlock()
+		return lease, nil
+	}
+	if time.Now().Before(p.retryAt) {
+		err := fmt.Errorf("进程启动失败，%s后重试: %s", time.Until(p.retryAt).Round(time.Second), p.lastError)
+		p.mu.Unlock()
+		return nil, err
+	}
+	p.starting++
+	p.mu.Unlock()
+
+	proc, err := p.spawn()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.starting--
+	if err != nil {
+		p.fail(0, err)
+		return nil, err
+	}
+	p.processes = append(p.processes, proc)
+	return p.lend(proc), nil
+}
+
+// Restart 停止工具的全部进程，下次调用时重新启动
+func Restart(toolID int64) {
+	for _, p := range toolPools(toolID, false) {
+		p.stopAll("手动重启")
+	}
+}
+
+// Stop 停止工具的全部进程并移除进程池（工具删除、停用或配置变更时调用）
+func Stop(toolID int64) {
+	for _, p := range toolPools(toolID, true) {
+		p.stopAll("工具已停用或配置已变更")
+	}
+}
+
+// GetStatus 获取工具的进程状态和最近的日志（合并该工具的所有进程池）
+func GetStatus(toolID int64, logLimit int) Status {
+	status := Status{Processes: []ProcessStatus{}, Logs: []LogLine{}}
+	var lastErrorAt time.Time
+	for _, p := range toolPools(toolID, false) {
+		p.mu.Lock()
+		for _, proc := range p.processes {
+			status.Processes = append(status.Processes, ProcessStatus{
+				PID:        proc.pid,
+				StartedAt:  proc.startedAt,
+				LastUsedAt: proc.lastUsed,
+				InFlight:   proc.inFlight,
+				Calls:      proc.calls,
+				MemoryMB:   proc.memoryMB,
+				CPUSeconds: proc.cpuSeconds,
+			})
+		}
+		status.Starting += p.starting
+		status.Restarts += p.restarts
+		if p.lastError != "" && p.lastErrorAt.After(lastErrorAt) {
+			status.LastError, lastErrorAt = p.lastError, p.lastErrorAt
+		}
+		if time.Now().Before(p.retryAt) && (status.RetryAt == nil || p.retryAt.Before(*status.RetryAt)) {
+			retryAt := p.retryAt
+			status.RetryAt = &retryAt
+		}
+		if !p.lastUsed.IsZero() && (status.LastUsedAt == nil || p.lastUsed.After(*status.LastUsedAt)) {
+			lastUsed := p.lastUsed
+			status.LastUsedAt = &lastUsed
+		}
+		status.Logs = append(status.Logs, p.logs...)
+		p.mu.Unlock()
+	}
+	status.Running = len(status.Processes) > 0
+
+	slices.SortStableFunc(status.Logs, func(a, b LogLine) int { return a.Time.Compare(b.Time) })
+	if logLimit > 0 && logLimit < len(status.Logs) {
+		status.Logs = status.Logs[len(status.Logs)-logLimit:]
+	}
+	return status
+}
+
+// toolPools 工具的所有进程池，remove 为 true 时同时移除
+func toolPools(toolID int64, remove bool) []*pool {
+	mu.Lock()
+	defer mu.Unlock()
+	var result []*pool
+	for key, p := range pools {
+		if p.toolID == toolID {
+			result = append(result, p)
+			if remove {
+				delete(pools, key)
+			}
+		}
+	}
+	return result
+}
+
+// getPool 获取工具在当前启动配置下的进程池
+func getPool(toolID int64, cfg mcpclient.Config) *pool {
+	key := poolKey(toolID, cfg)
+	mu.Lock()
+	defer mu.Unlock()
+	p, ok := pools[key]
+	if !ok {
+		p = &pool{key: key, toolID: toolID, cfg: cfg}
+		pools[key] = p
+	}
+	p.mu.Lock()
+	p.cfg.Timeout = cfg.Timeout
+	p.lastUsed = time.Now()
+	p.mu.Unlock()
+	return p
+}
+
+// poolKey 工具ID和影响进程启动的配置（map按键排序输出，取哈希避免密钥留在内存的键中）
+func poolKey(toolID int64, cfg mcpclient.Config) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%q|%q|%q|%v", cfg.Command, cfg.Args, cfg.Dir, cfg.Env)))
+	return fmt.Sprintf("%d:%s", toolID, hex.EncodeToString(sum[:8]))
+}
+
+// idlest 负载最低的进程，调用方持有锁
+func (p *pool) idlest() *process {
+	var best *process
+	for _, proc := range p.processes {
+		if best == nil || proc.inFlight < best.inFlight {
+			best = proc
+		}
+	}
+	return best
+}
+
+// lend 借出进程，调用方持有锁
+func (p *pool) lend(proc *process) *Lease {
+	proc.inFlight++
+	proc.calls++
+	proc.lastUsed = time.Now()
+	return &Lease{Client: proc.client, pool: p, process: proc}
+}
+
+// spawn 在隔离环境中启动进程并完成握手
+func (p *pool) spawn() (*process, error) {
+	p.mu.Lock()
+	cfg := p.cfg
+	p.mu.Unlock()
+
+	// 每个进程池使用独立的 HOME 和临时目录，不继承Go服务的环境变量（避免泄露数据库密码等配置）
+	home := filepath.Join(os.TempDir(), "dootask-mcp", strings.ReplaceAll(p.key, ":", "-"))
+	if err := os.MkdirAll(home, 0o700); err != nil {
+		return nil, fmt.Errorf("创建工作目录失败: %w", err)
+	}
+	env := map[string]string{"HOME": home, "TMPDIR": home}
+	for key, value := range cfg.Env {
+		env[key] = value
+	}
+	cfg.Env = env
+	cfg.Isolated = true
+	if cfg.Dir == "" {
+		cfg.Dir = home
+	}
+	stderr := &logWriter{pool: p}
+	cfg.Stderr = stderr
+
+	ctx, cancel := context.WithTimeout(context.Background(), spawnTimeout)
+	defer cancel()
+	client, err := mcpclient.Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	pid := client.PID()
+	p.mu.Lock()
+	p.log(pid, StreamSupervisor, "进程已启动")
+	p.mu.Unlock()
+	stderr.start(pid)
+
+	now := time.Now()
+	return &process{client: client, pid: pid, startedAt: now, lastUsed: now}, nil
+}
+
+// grow 后台启动一个进程加入进程池
+func (p *pool) grow() {
+	proc, err := p.spawn()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.starting--
+	if err != nil {
+		p.fail(0, err)
+		return
+	}
+	p.processes = append(p.processes, proc)
+}
+
+// reap 移除已退出的进程并记录原因，调用方持有锁
+func (p *pool) reap() {
+	alive := p.processes[:0]
+	for _, proc := range p.processes {
+		if err := proc.client.Err(); err != nil {
+			p.restarts++
+			p.fail(proc.pid, err)
+			continue
+		}
+		alive = append(alive, proc)
+	}
+	p.processes = alive
+}
+
+// fail 记录启动失败或进程崩溃，按连续失败次数退避，调用方持有锁
+func (p *pool) fail(pid int, err error) {
+	p.failures++
+	p.lastError, p.lastErrorAt = err.Error(), time.Now()
+	backoff := time.Second << min(p.failures, 9)
+	p.retryAt = time.Now().Add(min(backoff, maxBackoff))
+	p.log(pid, StreamSupervisor, "进程异常: "+err.Error())
+	log.Printf("MCP工具 %d 的stdio进程异常: %v", p.toolID, err)
+}
+
+// check 检查资源限制、回收空闲进程并补足预热进程，返回进程池是否可以移除
+func (p *pool) check(limits Limits, procs map[int]procStat) bool {
+	p.mu.Lock()
+	p.reap()
+	now := time.Now()
+	idle := now.Sub(p.lastUsed) > limits.IdleTimeout
+
+	var (
+		kept     []*process
+		stopping []*process
+		killing  []*process
+	)
+	for _, proc := range p.processes {
+		if procs != nil {
+			u := processUsage(procs, proc.pid)
+			proc.memoryMB, proc.cpuSeconds = u.memoryMB, u.cpuSeconds
+		}
+		if now.Sub(proc.startedAt) > stableAfter {
+			p.failures = 0
+		}
+
+		reason := ""
+		switch {
+		case limits.MemoryMB > 0 && proc.memoryMB > limits.MemoryMB:
+			reason = fmt.Sprintf("内存占用 %dMB 超过上限 %dMB", proc.memoryMB, limits.MemoryMB)
+		case limits.CPUSeconds > 0 && proc.cpuSeconds > float64(limits.CPUSeconds):
+			reason = fmt.Sprintf("累计CPU时间 %.0f秒 超过上限 %d秒", proc.cpuSeconds, limits.CPUSeconds)
+		}
+		if reason != "" {
+			p.restarts++
+			p.lastError, p.lastErrorAt = reason, now
+			p.log(proc.pid, StreamSupervisor, "结束进程: "+reason)
+			killing = append(killing, proc)
+			continue
+		}
+
+		if proc.inFlight == 0 {
+			switch {
+			case idle:
+				reason = "服务空闲"
+			case limits.MaxLifetime > 0 && now.Sub(proc.startedAt) > limits.MaxLifetime:
+				reason = "达到最长运行时间"
+			case len(kept) >= limits.WarmProcesses && now.Sub(proc.lastUsed) > extraIdleTimeout:
+				reason = "回收空闲进程"
+			}
+		}
+		if reason != "" {
+			p.log(proc.pid, StreamSupervisor, "停止进程: "+reason)
+			stopping = append(stopping, proc)
+			continue
+		}
+		kept = append(kept, proc)
+	}
+	p.processes = kept
+
+	// 最近有调用时保持预热进程，崩溃后按退避时间重启
+	if !idle && now.After(p.retryAt) {
+		for i := len(p.processes) + p.starting; i < limits.WarmProcesses; i++ {
+			p.starting++
+			go p.grow()
+		}
+	}
+	removable := idle && len(p.processes) == 0 && p.starting == 0
+	p.mu.Unlock()
+
+	for _, proc := range killing {
+		killTree(proc.pid)
+		proc.client.Close()
+	}
+	for _, proc := range stopping {
+		proc.client.Close()
+	}
+	return removable
+}
+
+// stopAll 停止全部进程
+func (p *pool) stopAll(reason string) {
+	p.mu.Lock()
+	processes := p.processes
+	p.processes = nil
+	p.failures = 0
+	p.retryAt = time.Time{}
+	for _, proc := range processes {
+		p.log(proc.pid, StreamSupervisor, "停止进程: "+reason)
+	}
+	p.mu.Unlock()
+
+	for _, proc := range processes {
+		proc.client.Close()
+	}
+}
+
+// log 追加一行日志，调用方持有锁
+func (p *pool) log(pid int, stream string, text string) {
+	if runes := []rune(text); len(runes) > maxLogLineSize {
+		text = string(runes[:maxLogLineSize]) + "..."
+	}
+	p.logs = append(p.logs, LogLine{Time: time.Now(), PID: pid, Stream: stream, Text: text})
+	if len(p.logs) > maxLogLines {
+		p.logs = append([]LogLine(nil), p.logs[len(p.logs)-maxLogLines:]...)
+	}
+}
+
+// monitor 定期检查所有进程池
+func monitor() {
+	for range time.Tick(monitorInterval) {
+		mu.Lock()
+		snapshot := make(map[string]*pool, len(pools))
+		for key, p := range pools {
+			snapshot[key] = p
+		}
+		mu.Unlock()
+
+		limits := currentLimits()
+		procs := readProcs()
+		for key, p := range snapshot {
+			if !p.check(limits, procs) {
+				continue
+			}
+			mu.Lock()
+			p.mu.Lock()
+			// 检查期间可能有新的调用
+			if pools[key] == p && len(p.processes) == 0 && p.starting == 0 && time.Since(p.lastUsed) > limits.IdleTimeout {
+				delete(pools, key)
+			}
+			p.mu.Unlock()
+			mu.Unlock()
+		}
+	}
+}
+
+// logWriter 将进程的错误输出按行写入进程池日志（进程ID确定前的输出先暂存）
+type logWriter struct {
+	pool    *pool
+	mu      sync.Mutex
+	pid     int
+	buf     []byte
+	pending []string
+}
+
+// start 记录进程ID并写入暂存的输出
+func (w *logWriter) start(pid int) {
+	w.mu.Lock()
+	w.pid = pid
+	lines := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+	w.flush(pid, lines)
+}
+
+// flush 写入日志
+func (w *logWriter) flush(pid int, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	w.pool.mu.Lock()
+	defer w.pool.mu.Unlock()
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			w.pool.log(pid, StreamStderr, line)
+		}
+	}
+}
+
+func (w *logWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	w.buf = append(w.buf, data...)
+	var lines []string
+	for {
+		idx := strings.IndexByte(string(w.buf), '\n')
+		if idx < 0 {
+			break
+		}
+		lines = append(lines, strings.TrimRight(string(w.buf[:idx]), "\r"))
+		w.buf = w.buf[idx+1:]
+	}
+	if len(w.buf) > maxLogLineSize*4 {
+		// 过长的行不等待换行直接写入
+		lines = append(lines, string(w.buf))
+		w.buf = nil
+	}
+	pid := w.pid
+	if pid == 0 {
+		w.pending = append(w.pending, lines...)
+		lines = nil
+	}
+	w.mu.Unlock()
+
+	w.flush(pid, lines)
+	return len(data), nil
+}
@@ -0,0 +1,89 @@
+package mcpsupervisor
+
+import (
+	"time"
+
+	"dootask-ai/go-service/sysconfig"
+)
+
+const (
+	monitorInterval  = 10 * time.Second
+	spawnTimeout     = 60 * time.Second // 进程启动并完成握手的最长时间
+	extraIdleTimeout = time.Minute      // 超出预热数量的进程空闲多久后回收
+	stableAfter      = time.Minute      // 进程稳定运行多久后清零连续失败次数
+	maxBackoff       = 5 * time.Minute
+	maxLogLines      = 500
+	maxLogLineSize   = 2000
+)
+
+// 日志来源
+const (
+	StreamStderr     = "stderr"
+	StreamSupervisor = "supervisor"
+)
+
+// Limits 进程托管限制（系统配置 mcp_stdio_*）
+type Limits struct {
+	WarmProcesses int
+	MaxProcesses  int
+	MemoryMB      int           // 进程及子进程的内存上限，0表示不限制
+	CPUSeconds    int           // 累计CPU时间上限，0表示不限制
+	MaxLifetime   time.Duration // 最长运行时间，0表示不限制
+	IdleTimeout   time.Duration // 无调用多久后停止全部进程
+}
+
+// currentLimits 读取当前的托管限制
+func currentLimits() Limits {
+	limits := Limits{
+		WarmProcesses: sysconfig.Int(sysconfig.KeyMCPStdioWarmProcesses, 1),
+		MaxProcesses:  sysconfig.Int(sysconfig.KeyMCPStdioMaxProcesses, 4),
+		MemoryMB:      sysconfig.Int(sysconfig.KeyMCPStdioMemoryLimitMB, 512),
+		CPUSeconds:    sysconfig.Int(sysconfig.KeyMCPStdioCPULimitSeconds, 600),
+		MaxLifetime:   time.Duration(sysconfig.Int(sysconfig.KeyMCPStdioMaxLifetime, 3600)) * time.Second,
+		IdleTimeout:   time.Duration(sysconfig.Int(sysconfig.KeyMCPStdioIdleTimeout, 600)) * time.Second,
+	}
+	if limits.MaxProcesses < 1 {
+		limits.MaxProcesses = 1
+	}
+	if limits.WarmProcesses < 0 {
+		limits.WarmProcesses = 0
+	}
+	if limits.WarmProcesses > limits.MaxProcesses {
+		limits.WarmProcesses = limits.MaxProcesses
+	}
+	if limits.IdleTimeout <= 0 {
+		limits.IdleTimeout = 600 * time.Second
+	}
+	return limits
+}
+
+// LogLine 一行进程日志
+type LogLine struct {
+	Time   time.Time `json:"time"`
+	PID    int       `json:"pid"`
+	Stream string    `json:"stream"` // stderr 进程错误输出；supervisor 启停记录
+	Text   string    `json:"text"`
+}
+
+// ProcessStatus 运行中的进程
+type ProcessStatus struct {
+	PID        int       `json:"pid"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	InFlight   int       `json:"in_flight"`
+	Calls      int64     `json:"calls"`
+	MemoryMB   int       `json:"memory_mb"`
+	CPUSeconds float64   `json:"cpu_seconds"`
+}
+
+// Status 工具的进程池状态
+type Status struct {
+	Running    bool            `json:"running"`
+	Processes  []ProcessStatus `json:"processes"`
+	Starting   int             `json:"starting"`
+	Restarts   int             `json:"restarts"`
+	LastError  string          `json:"last_error"`
+	RetryAt    *time.Time      `json:"retry_at"`
+	LastUsedAt *time.Time      `json:"last_used_at"`
+	Logs       []LogLine       `json:"logs"`
+}
@@ -38,6 +38,13 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 个人API密钥由 /v1 接口单独鉴权，无需请求DooTask
+		if strings.HasPrefix(authToken, "sk-") {
+			c.Set(global.CtxKeyAuthError, errors.New("api key is not allowed"))
+			c.Next()
+			return
+		}
+
 		// 创建DooTask客户端
 		client := utils.NewDooTaskClient(authToken)
 		user, err := client.Client.GetUserInfo()
@@ -0,0 +1,32 @@
+-- Description: 创建智能体模板表
+-- 智能体模板表（内置模板来自演示智能体，管理员可发布新模板）
+
+CREATE TABLE IF NOT EXISTS agent_templates (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    prompt TEXT NOT NULL,
+    temperature DECIMAL(3,2) DEFAULT 0.7,
+    metadata JSONB DEFAULT '{}',
+    source_agent_id BIGINT,
+    is_builtin BOOLEAN DEFAULT false,
+    is_published BOOLEAN DEFAULT true,
+    usage_count INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_templates_category ON agent_templates((metadata->>'category'));
+CREATE INDEX IF NOT EXISTS idx_agent_templates_tags ON agent_templates USING gin ((metadata->'tags'));
+CREATE INDEX IF NOT EXISTS idx_agent_templates_published ON agent_templates(is_published);
+
+CREATE TRIGGER update_agent_templates_updated_at BEFORE UPDATE ON agent_templates
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- 将演示数据中的全局智能体（未归属任何用户）登记为内置模板
+INSERT INTO agent_templates (name, description, prompt, temperature, metadata, source_agent_id, is_builtin, is_published)
+SELECT a.name, a.description, a.prompt, a.temperature, COALESCE(a.metadata, '{}'::JSONB), a.id, true, true
+FROM agents a
+WHERE a.user_id IS NULL
+  AND NOT EXISTS (SELECT 1 FROM agent_templates t WHERE t.source_agent_id = a.id);
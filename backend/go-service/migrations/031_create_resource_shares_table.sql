@@ -0,0 +1,21 @@
+-- Description: 创建资源共享授权表
+-- 智能体、知识库、MCP工具、AI模型的共享授权（授权给用户或部门）
+
+CREATE TABLE IF NOT EXISTS resource_shares (
+    id BIGSERIAL PRIMARY KEY,
+    resource_type VARCHAR(32) NOT NULL CHECK (resource_type IN ('agent', 'knowledge_base', 'mcp_tool', 'ai_model')),
+    resource_id BIGINT NOT NULL,
+    subject_type VARCHAR(32) NOT NULL CHECK (subject_type IN ('user', 'department')),
+    subject_id BIGINT NOT NULL,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    granted_by BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(resource_type, resource_id, subject_type, subject_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_resource_shares_subject ON resource_shares(subject_type, subject_id);
+CREATE INDEX IF NOT EXISTS idx_resource_shares_resource ON resource_shares(resource_type, resource_id);
+
+CREATE TRIGGER update_resource_shares_updated_at BEFORE UPDATE ON resource_shares
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,67 @@
+-- Description: 创建智能体评测相关表
+-- 评测集（按智能体）、评测用例、评测运行记录及逐用例结果
+
+CREATE TABLE IF NOT EXISTS eval_suites (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    agent_id BIGINT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    judge_model_id BIGINT REFERENCES ai_models(id) ON DELETE SET NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS eval_cases (
+    id BIGSERIAL PRIMARY KEY,
+    suite_id BIGINT NOT NULL REFERENCES eval_suites(id) ON DELETE CASCADE,
+    question TEXT NOT NULL,
+    checks JSONB NOT NULL DEFAULT '[]',
+    sort_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS eval_runs (
+    id BIGSERIAL PRIMARY KEY,
+    suite_id BIGINT NOT NULL REFERENCES eval_suites(id) ON DELETE CASCADE,
+    agent_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    label VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    snapshot JSONB DEFAULT '{}',
+    total_cases INTEGER DEFAULT 0,
+    passed_cases INTEGER DEFAULT 0,
+    avg_latency_ms INTEGER DEFAULT 0,
+    total_tokens INTEGER DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS eval_results (
+    id BIGSERIAL PRIMARY KEY,
+    run_id BIGINT NOT NULL REFERENCES eval_runs(id) ON DELETE CASCADE,
+    case_id BIGINT NOT NULL,
+    question TEXT NOT NULL,
+    answer TEXT,
+    passed BOOLEAN DEFAULT false,
+    checks JSONB DEFAULT '[]',
+    latency_ms INTEGER DEFAULT 0,
+    input_tokens INTEGER DEFAULT 0,
+    output_tokens INTEGER DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_eval_suites_agent_id ON eval_suites(agent_id);
+CREATE INDEX IF NOT EXISTS idx_eval_cases_suite_id ON eval_cases(suite_id);
+CREATE INDEX IF NOT EXISTS idx_eval_runs_suite_id ON eval_runs(suite_id);
+CREATE INDEX IF NOT EXISTS idx_eval_results_run_id ON eval_results(run_id);
+
+CREATE TRIGGER update_eval_suites_updated_at BEFORE UPDATE ON eval_suites
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_eval_cases_updated_at BEFORE UPDATE ON eval_cases
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,47 @@
+-- Description: 创建智能体A/B实验相关表
+-- 实验（每个智能体同时最多一个运行中的实验）、实验变体（提示词/模型/温度及流量权重）和用户分组记录
+
+CREATE TABLE IF NOT EXISTS agent_experiments (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id BIGINT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'running', 'stopped')),
+    started_at TIMESTAMP,
+    ended_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS experiment_variants (
+    id BIGSERIAL PRIMARY KEY,
+    experiment_id BIGINT NOT NULL REFERENCES agent_experiments(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prompt TEXT,
+    ai_model_id BIGINT REFERENCES ai_models(id) ON DELETE SET NULL,
+    temperature DECIMAL(3,2),
+    weight INTEGER NOT NULL DEFAULT 50 CHECK (weight > 0),
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS experiment_assignments (
+    id BIGSERIAL PRIMARY KEY,
+    experiment_id BIGINT NOT NULL REFERENCES agent_experiments(id) ON DELETE CASCADE,
+    variant_id BIGINT NOT NULL REFERENCES experiment_variants(id) ON DELETE CASCADE,
+    dootask_user_id BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(experiment_id, dootask_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_experiments_agent_id ON agent_experiments(agent_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_experiments_running ON agent_experiments(agent_id) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS idx_experiment_variants_experiment_id ON experiment_variants(experiment_id);
+CREATE INDEX IF NOT EXISTS idx_messages_experiment ON messages((metadata->>'experiment_id'));
+
+CREATE TRIGGER update_agent_experiments_updated_at BEFORE UPDATE ON agent_experiments
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_experiment_variants_updated_at BEFORE UPDATE ON experiment_variants
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
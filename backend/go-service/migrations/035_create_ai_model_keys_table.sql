@@ -0,0 +1,20 @@
+-- Description: 创建AI模型密钥池表
+-- 一个模型可配置多个API密钥，按权重和每分钟请求/令牌限额负载均衡
+
+CREATE TABLE IF NOT EXISTS ai_model_keys (
+    id BIGSERIAL PRIMARY KEY,
+    ai_model_id BIGINT NOT NULL REFERENCES ai_models(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    api_key TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 1,
+    rpm_limit INTEGER NOT NULL DEFAULT 0,
+    tpm_limit INTEGER NOT NULL DEFAULT 0,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_model_keys_ai_model_id ON ai_model_keys(ai_model_id);
+
+CREATE TRIGGER update_ai_model_keys_updated_at BEFORE UPDATE ON ai_model_keys
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,19 @@
+-- Description: 添加生成参数配置
+-- 生成参数按 模型默认 → 智能体 → 对话 的顺序覆盖，对话级覆盖按智能体和DooTask对话ID保存
+
+ALTER TABLE ai_models ADD COLUMN IF NOT EXISTS generation_settings JSONB DEFAULT '{}';
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS generation_settings JSONB DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS dialog_generation_settings (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id BIGINT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+    dialog_id VARCHAR(255) NOT NULL,
+    settings JSONB DEFAULT '{}',
+    updated_by BIGINT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (agent_id, dialog_id)
+);
+
+CREATE TRIGGER update_dialog_generation_settings_updated_at BEFORE UPDATE ON dialog_generation_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,14 @@
+-- Description: 创建MCP工具函数缓存表并为智能体添加函数白名单
+-- 缓存每个MCP服务提供的函数列表；agents.tool_functions 按工具ID配置允许调用的函数及参数约束，未配置的工具允许全部函数
+
+CREATE TABLE IF NOT EXISTS mcp_tool_functions (
+    id BIGSERIAL PRIMARY KEY,
+    mcp_tool_id BIGINT NOT NULL REFERENCES mcp_tools(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    input_schema JSONB DEFAULT '{}',
+    synced_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (mcp_tool_id, name)
+);
+
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS tool_functions JSONB DEFAULT '{}';
@@ -0,0 +1,20 @@
+-- Description: 创建工具调用记录表
+-- 记录智能体每次MCP函数调用的参数（已脱敏）、结果大小、耗时和错误，用于工具使用统计
+
+CREATE TABLE IF NOT EXISTS tool_calls (
+    id BIGSERIAL PRIMARY KEY,
+    agent_id BIGINT REFERENCES agents(id) ON DELETE SET NULL,
+    conversation_id BIGINT REFERENCES conversations(id) ON DELETE SET NULL,
+    mcp_tool_id BIGINT REFERENCES mcp_tools(id) ON DELETE SET NULL,
+    function_name VARCHAR(255) NOT NULL,
+    tool_call_id VARCHAR(255),
+    arguments JSONB DEFAULT '{}',
+    result_size INTEGER NOT NULL DEFAULT 0,
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_calls_mcp_tool_id ON tool_calls(mcp_tool_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_agent_id ON tool_calls(agent_id);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_created_at ON tool_calls(created_at);
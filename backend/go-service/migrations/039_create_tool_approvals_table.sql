@@ -0,0 +1,21 @@
+-- Description: 创建工具调用审批记录表
+-- 智能体按函数配置审批策略（auto/confirm/deny），需要确认的调用暂停运行并等待用户回复，审批结果记录在对话下
+
+CREATE TABLE IF NOT EXISTS tool_approvals (
+    id BIGSERIAL PRIMARY KEY,
+    conversation_id BIGINT NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    agent_id BIGINT REFERENCES agents(id) ON DELETE SET NULL,
+    function_name VARCHAR(255) NOT NULL,
+    arguments JSONB DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reply TEXT,
+    decided_by VARCHAR(255),
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_approvals_conversation_id ON tool_approvals(conversation_id, status);
+
+CREATE TRIGGER update_tool_approvals_updated_at BEFORE UPDATE ON tool_approvals
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,26 @@
+-- Description: 添加MCP工具健康状态和检查记录表
+-- 后台定期与每个启用的MCP服务握手，记录状态和延迟；连续失败的服务标记为不健康，请求AI时跳过
+
+ALTER TABLE mcp_tools ADD COLUMN IF NOT EXISTS health_status VARCHAR(20) NOT NULL DEFAULT 'unknown';
+ALTER TABLE mcp_tools ADD COLUMN IF NOT EXISTS health_checked_at TIMESTAMP;
+ALTER TABLE mcp_tools ADD COLUMN IF NOT EXISTS health_latency_ms INTEGER;
+ALTER TABLE mcp_tools ADD COLUMN IF NOT EXISTS health_error TEXT;
+ALTER TABLE mcp_tools ADD COLUMN IF NOT EXISTS health_failures INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS mcp_tool_health_checks (
+    id BIGSERIAL PRIMARY KEY,
+    mcp_tool_id BIGINT NOT NULL REFERENCES mcp_tools(id) ON DELETE CASCADE,
+    healthy BOOLEAN NOT NULL,
+    latency_ms INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    checked_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_mcp_tool_health_checks_tool ON mcp_tool_health_checks(mcp_tool_id, checked_at);
+
+INSERT INTO system_configs (key, value, description)
+SELECT * FROM (VALUES
+    ('mcp_health_check_interval', '300', 'MCP服务健康检查间隔（秒）'),
+    ('mcp_health_failure_threshold', '2', 'MCP服务连续检查失败多少次后标记为不健康')
+) AS tmp(key, value, description)
+WHERE NOT EXISTS (SELECT 1 FROM system_configs WHERE system_configs.key = tmp.key);
@@ -0,0 +1,8 @@
+-- Description: 添加MCP网关配置
+-- 开启后智能体的MCP工具统一通过Go服务的网关端点访问，由网关负责工具命名空间、白名单、超时和调用记录
+
+INSERT INTO system_configs (key, value, description)
+SELECT * FROM (VALUES
+    ('enable_mcp_gateway', 'true', '是否通过MCP网关访问智能体的MCP工具')
+) AS tmp(key, value, description)
+WHERE NOT EXISTS (SELECT 1 FROM system_configs WHERE system_configs.key = tmp.key);
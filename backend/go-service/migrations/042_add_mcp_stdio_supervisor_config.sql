@@ -0,0 +1,13 @@
+-- Description: 添加stdio MCP进程托管配置
+-- stdio MCP服务由Go服务托管进程，按需启动并保持预热，通过MCP网关提供给智能体
+
+INSERT INTO system_configs (key, value, description)
+SELECT * FROM (VALUES
+    ('mcp_stdio_warm_processes', '1', '每个stdio MCP服务保持预热的进程数'),
+    ('mcp_stdio_max_processes', '4', '每个stdio MCP服务最多同时运行的进程数'),
+    ('mcp_stdio_memory_limit_mb', '512', 'stdio MCP进程（含子进程）内存上限（MB，0表示不限制）'),
+    ('mcp_stdio_cpu_limit_seconds', '600', 'stdio MCP进程累计CPU时间上限（秒，超过后重启进程，0表示不限制）'),
+    ('mcp_stdio_max_lifetime', '3600', 'stdio MCP进程最长运行时间（秒，到期空闲时重启，0表示不限制）'),
+    ('mcp_stdio_idle_timeout', '600', 'stdio MCP服务无调用多久后停止全部进程（秒）')
+) AS tmp(key, value, description)
+WHERE NOT EXISTS (SELECT 1 FROM system_configs WHERE system_configs.key = tmp.key);
@@ -0,0 +1,15 @@
+-- Description: 创建用户密钥表
+-- MCP工具配置中的 {{user_secret.名称}} 按调用用户填充，值加密存储
+
+CREATE TABLE IF NOT EXISTS user_secrets (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    value TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TRIGGER update_user_secrets_updated_at BEFORE UPDATE ON user_secrets
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,37 @@
+-- Description: 创建MCP服务OAuth客户端和令牌表
+-- 配置了 oauth 的MCP工具按用户完成授权，客户端按回调地址注册（动态注册或配置指定），令牌加密存储并自动刷新
+
+CREATE TABLE IF NOT EXISTS mcp_oauth_clients (
+    id BIGSERIAL PRIMARY KEY,
+    mcp_tool_id BIGINT NOT NULL REFERENCES mcp_tools(id) ON DELETE CASCADE,
+    redirect_uri VARCHAR(1000) NOT NULL,
+    client_id VARCHAR(500) NOT NULL,
+    client_secret TEXT,
+    server JSONB DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (mcp_tool_id, redirect_uri)
+);
+
+CREATE TABLE IF NOT EXISTS mcp_oauth_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    mcp_tool_id BIGINT NOT NULL REFERENCES mcp_tools(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    oauth_client_id BIGINT NOT NULL REFERENCES mcp_oauth_clients(id) ON DELETE CASCADE,
+    access_token TEXT NOT NULL,
+    refresh_token TEXT,
+    token_type VARCHAR(50) NOT NULL DEFAULT 'Bearer',
+    scope TEXT,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (mcp_tool_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mcp_oauth_tokens_user_id ON mcp_oauth_tokens(user_id);
+
+CREATE TRIGGER update_mcp_oauth_clients_updated_at BEFORE UPDATE ON mcp_oauth_clients
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_mcp_oauth_tokens_updated_at BEFORE UPDATE ON mcp_oauth_tokens
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,32 @@
+-- Description: 创建知识库数据源表
+-- 知识库可以添加网页数据源（单个页面、站点地图或按深度爬取），同步时每个页面生成一个文档；kb_documents.source_id 关联生成文档的数据源
+
+CREATE TABLE IF NOT EXISTS kb_sources (
+    id BIGSERIAL PRIMARY KEY,
+    knowledge_base_id BIGINT NOT NULL REFERENCES knowledge_bases(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    config JSONB DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    synced_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    last_synced_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_kb_sources_knowledge_base_id ON kb_sources(knowledge_base_id);
+
+CREATE TRIGGER update_kb_sources_updated_at BEFORE UPDATE ON kb_sources
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE kb_documents ADD COLUMN IF NOT EXISTS source_id BIGINT REFERENCES kb_sources(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_kb_documents_source_id ON kb_documents(source_id);
+
+INSERT INTO system_configs (key, value, description)
+SELECT * FROM (VALUES
+    ('kb_crawl_max_pages', '200', '网页数据源单次同步最多抓取的页面数'),
+    ('kb_crawl_request_interval', '1000', '网页数据源对同一站点的请求间隔（毫秒，robots.txt 的 Crawl-delay 更大时以其为准）')
+) AS tmp(key, value, description)
+WHERE NOT EXISTS (SELECT 1 FROM system_configs WHERE system_configs.key = tmp.key);
@@ -0,0 +1,12 @@
+-- Description: 知识库数据源支持定时同步和DooTask内容
+-- sync_interval 为定时同步间隔（分钟，0表示只手动同步）；DooTask数据源使用最近一次发起同步的用户身份拉取内容，user_id 和 credential（加密的用户令牌）记录该用户
+
+ALTER TABLE kb_sources ADD COLUMN IF NOT EXISTS sync_interval INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE kb_sources ADD COLUMN IF NOT EXISTS user_id BIGINT;
+ALTER TABLE kb_sources ADD COLUMN IF NOT EXISTS credential TEXT;
+
+INSERT INTO system_configs (key, value, description)
+SELECT * FROM (VALUES
+    ('kb_dootask_max_items', '1000', 'DooTask数据源单次同步最多拉取的文件或任务数')
+) AS tmp(key, value, description)
+WHERE NOT EXISTS (SELECT 1 FROM system_configs WHERE system_configs.key = tmp.key);
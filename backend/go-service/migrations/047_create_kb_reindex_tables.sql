@@ -0,0 +1,36 @@
+-- Description: 创建知识库文档原始文件表和重建索引任务表
+-- 上传的文档保存原始文件，修改向量化设置（模型、提供商、分块大小）时由重建索引任务从原始内容重新分块和向量化，完成后替换原有向量数据并应用新设置
+
+CREATE TABLE IF NOT EXISTS kb_document_files (
+    document_id BIGINT PRIMARY KEY REFERENCES kb_documents(id) ON DELETE CASCADE,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TRIGGER update_kb_document_files_updated_at BEFORE UPDATE ON kb_document_files
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS kb_reindex_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    knowledge_base_id BIGINT NOT NULL REFERENCES knowledge_bases(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    settings JSONB DEFAULT '{}',
+    total_documents INTEGER NOT NULL DEFAULT 0,
+    processed_documents INTEGER NOT NULL DEFAULT 0,
+    failed_documents INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_kb_reindex_jobs_knowledge_base_id ON kb_reindex_jobs(knowledge_base_id);
+
+-- 每个知识库同时只能有一个进行中的重建索引任务
+CREATE UNIQUE INDEX IF NOT EXISTS idx_kb_reindex_jobs_active ON kb_reindex_jobs(knowledge_base_id) WHERE status IN ('pending', 'running');
+
+CREATE TRIGGER update_kb_reindex_jobs_updated_at BEFORE UPDATE ON kb_reindex_jobs
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
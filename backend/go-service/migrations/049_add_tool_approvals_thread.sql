@@ -0,0 +1,9 @@
+-- Description: 工具调用审批记录关联会话线程
+-- 审批只由同一线程中的下一条回复处理；旧记录没有线程信息，无法确定由哪条回复处理，直接标记为过期
+
+ALTER TABLE tool_approvals ADD COLUMN IF NOT EXISTS thread_id VARCHAR(255);
+
+UPDATE tool_approvals SET status = 'expired', updated_at = NOW() WHERE status = 'pending' AND thread_id IS NULL;
+
+DROP INDEX IF EXISTS idx_tool_approvals_conversation_id;
+CREATE INDEX IF NOT EXISTS idx_tool_approvals_conversation_id ON tool_approvals(conversation_id, thread_id, status);
@@ -0,0 +1,6 @@
+-- Description: 更新 webhook_timeout 配置
+-- webhook_timeout 用作请求AI服务的超时时间（包括流式响应），未修改过的初始值 30 秒改为与 AI_REQUEST_TIMEOUT 默认值一致的 60 秒，避免长回复被中断
+
+UPDATE system_configs
+SET value = '60', description = '请求AI服务的超时时间（秒），包括流式响应'
+WHERE key = 'webhook_timeout' AND value = '30';
@@ -0,0 +1,178 @@
+package permission
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/global"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	ctxKeySubject      = "permissionSubject"
+	departmentCacheKey = "permission:departments:%d"
+	departmentCacheTTL = 5 * time.Minute
+)
+
+// CurrentSubject 获取当前请求的授权主体，部门信息缓存在Redis中
+func CurrentSubject(c *gin.Context) *Subject {
+	if v, ok := c.Get(ctxKeySubject); ok {
+		if subject, ok := v.(*Subject); ok {
+			return subject
+		}
+	}
+
+	subject := &Subject{}
+	user := global.GetDooTaskUser(c)
+	if user != nil {
+		subject.UserID = int64(user.UserID)
+		subject.Departments = loadDepartments(c, subject.UserID)
+	}
+	c.Set(ctxKeySubject, subject)
+	return subject
+}
+
+// loadDepartments 获取用户所属部门ID列表
+func loadDepartments(c *gin.Context, userID int64) []int64 {
+	cacheKey := fmt.Sprintf(departmentCacheKey, userID)
+	if global.Redis != nil {
+		if cached, err := global.Redis.Get(c, cacheKey).Result(); err == nil {
+			var departments []int64
+			if json.Unmarshal([]byte(cached), &departments) == nil {
+				return departments
+			}
+		}
+	}
+
+	client := global.GetDooTaskClient(c)
+	if client == nil {
+		return nil
+	}
+	var info struct {
+		Department json.RawMessage `json:"department"`
+	}
+	if err := client.Get(c, "users/info", nil, &info); err != nil {
+		log.Printf("获取用户部门失败: %v", err)
+		return nil
+	}
+	departments := parseDepartments(info.Department)
+
+	if global.Redis != nil {
+		if data, err := json.Marshal(departments); err == nil {
+			global.Redis.Set(c, cacheKey, data, departmentCacheTTL)
+		}
+	}
+	return departments
+}
+
+// parseDepartments 解析部门字段（兼容数组和逗号分隔字符串）
+func parseDepartments(raw json.RawMessage) []int64 {
+	departments := []int64{}
+	if len(raw) == 0 {
+		return departments
+	}
+
+	var items []interface{}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var str string
+		if json.Unmarshal(raw, &str) != nil {
+			return departments
+		}
+		for _, part := range strings.Split(str, ",") {
+			items = append(items, part)
+		}
+	}
+
+	for _, item := range items {
+		var id int64
+		switch v := item.(type) {
+		case float64:
+			id = int64(v)
+		case string:
+			id, _ = strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		}
+		if id > 0 {
+			departments = append(departments, id)
+		}
+	}
+	return departments
+}
+
+// Condition 返回"当前用户至少拥有指定角色"的SQL条件，字段使用表名限定
+func Condition(c *gin.Context, resource ResourceType, role Role) (string, []interface{}) {
+	subject := CurrentSubject(c)
+	table := resource.Table()
+
+	query := fmt.Sprintf(`(%s.user_id = ? OR %s.id IN (
+		SELECT resource_id FROM resource_shares
+		WHERE resource_type = ? AND role IN ?
+		AND ((subject_type = 'user' AND subject_id = ?) OR (subject_type = 'department' AND subject_id IN ?))
+	))`, table, table)
+
+	departments := subject.Departments
+	if len(departments) == 0 {
+		departments = []int64{0}
+	}
+	return query, []interface{}{subject.UserID, string(resource), rolesAtLeast(role), subject.UserID, departments}
+}
+
+// Scope 将授权条件作为GORM Scope应用到查询
+func Scope(c *gin.Context, resource ResourceType, role Role) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		query, args := Condition(c, resource, role)
+		return db.Where(query, args...)
+	}
+}
+
+// RoleOf 获取当前用户对资源的角色，无权限时返回空字符串
+func RoleOf(c *gin.Context, resource ResourceType, id int64) (Role, error) {
+	subject := CurrentSubject(c)
+
+	var ownerID *int64
+	if err := global.DB.Table(resource.Table()).Select("user_id").Where("id = ?", id).Row().Scan(&ownerID); err != nil {
+		return "", err
+	}
+	if ownerID != nil && *ownerID == subject.UserID {
+		return RoleOwner, nil
+	}
+
+	departments := subject.Departments
+	if len(departments) == 0 {
+		departments = []int64{0}
+	}
+	var roles []Role
+	if err := global.DB.Model(&ResourceShare{}).
+		Where("resource_type = ? AND resource_id = ?", resource, id).
+		Where("(subject_type = ? AND subject_id = ?) OR (subject_type = ? AND subject_id IN ?)", SubjectUser, subject.UserID, SubjectDepartment, departments).
+		Pluck("role", &roles).Error; err != nil {
+		return "", err
+	}
+
+	var best Role
+	for _, r := range roles {
+		if roleLevels[r] > roleLevels[best] {
+			best = r
+		}
+	}
+	return best, nil
+}
+
+// Can 判断当前用户对资源是否拥有指定角色
+func Can(c *gin.Context, resource ResourceType, id int64, role Role) bool {
+	current, err := RoleOf(c, resource, id)
+	if err != nil {
+		return false
+	}
+	return current.Includes(role)
+}
+
+// RemoveShares 删除资源的全部共享授权（资源删除时调用）
+func RemoveShares(db *gorm.DB, resource ResourceType, id int64) error {
+	return db.Where("resource_type = ? AND resource_id = ?", resource, id).Delete(&ResourceShare{}).Error
+}
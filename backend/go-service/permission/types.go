@@ -0,0 +1,107 @@
+package permission
+
+import "time"
+
+// ResourceType 可共享的资源类型
+type ResourceType string
+
+const (
+	ResourceAgent         ResourceType = "agent"
+	ResourceKnowledgeBase ResourceType = "knowledge_base"
+	ResourceMCPTool       ResourceType = "mcp_tool"
+	ResourceAIModel       ResourceType = "ai_model"
+)
+
+// resourceTables 资源类型对应的数据表
+var resourceTables = map[ResourceType]string{
+	ResourceAgent:         "agents",
+	ResourceKnowledgeBase: "knowledge_bases",
+	ResourceMCPTool:       "mcp_tools",
+	ResourceAIModel:       "ai_models",
+}
+
+// Valid 资源类型是否有效
+func (r ResourceType) Valid() bool {
+	_, ok := resourceTables[r]
+	return ok
+}
+
+// Table 资源类型对应的数据表名
+func (r ResourceType) Table() string {
+	return resourceTables[r]
+}
+
+// Role 共享角色
+type Role string
+
+const (
+	RoleViewer Role = "viewer" // 查看和使用
+	RoleEditor Role = "editor" // 修改配置
+	RoleOwner  Role = "owner"  // 删除和管理共享
+)
+
+// roleLevels 角色等级，高等级包含低等级的权限
+var roleLevels = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// Valid 角色是否有效
+func (r Role) Valid() bool {
+	_, ok := roleLevels[r]
+	return ok
+}
+
+// Includes 当前角色是否包含目标角色的权限
+func (r Role) Includes(target Role) bool {
+	return roleLevels[r] > 0 && roleLevels[r] >= roleLevels[target]
+}
+
+// rolesAtLeast 返回不低于指定等级的所有角色
+func rolesAtLeast(role Role) []string {
+	var roles []string
+	for r, level := range roleLevels {
+		if level >= roleLevels[role] {
+			roles = append(roles, string(r))
+		}
+	}
+	return roles
+}
+
+// SubjectType 被授权对象类型
+type SubjectType string
+
+const (
+	SubjectUser       SubjectType = "user"
+	SubjectDepartment SubjectType = "department"
+)
+
+// Valid 授权对象类型是否有效
+func (s SubjectType) Valid() bool {
+	return s == SubjectUser || s == SubjectDepartment
+}
+
+// ResourceShare 资源共享授权
+type ResourceShare struct {
+	ID           int64        `gorm:"primaryKey;autoIncrement" json:"id"`
+	ResourceType ResourceType `gorm:"type:varchar(32);not null" json:"resource_type"`
+	ResourceID   int64        `gorm:"not null" json:"resource_id"`
+	SubjectType  SubjectType  `gorm:"type:varchar(32);not null" json:"subject_type"`
+	SubjectID    int64        `gorm:"not null" json:"subject_id"`
+	Role         Role         `gorm:"type:varchar(16);not null" json:"role"`
+	GrantedBy    int64        `gorm:"not null" json:"granted_by"`
+	CreatedAt    time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ResourceShare) TableName() string {
+	return "resource_shares"
+}
+
+// Subject 当前请求的授权主体（用户及其所属部门）
+type Subject struct {
+	UserID      int64   `json:"user_id"`
+	Departments []int64 `json:"departments"`
+}
@@ -0,0 +1,660 @@
+package agenttemplates
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/middleware"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/routes/api/agents"
+	"dootask-ai/go-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// RegisterRoutes 注册智能体模板路由
+func RegisterRoutes(router *gin.RouterGroup) {
+	templateGroup := router.Group("/agent-templates")
+	{
+		templateGroup.GET("", ListTemplates)                       // 获取模板列表
+		templateGroup.GET("/categories", ListCategories)           // 获取模板分类和标签
+		templateGroup.GET("/:id", GetTemplate)                     // 获取模板详情
+		templateGroup.POST("/:id/create", CreateAgentFromTemplate) // 从模板创建智能体
+	}
+
+	// 模板发布管理 - 需要管理员权限
+	adminGroup := router.Group("/agent-templates")
+	adminGroup.Use(middleware.UserRoleMiddleware("admin"))
+	{
+		adminGroup.POST("", PublishTemplate)      // 发布模板
+		adminGroup.PUT("/:id", UpdateTemplate)    // 更新模板
+		adminGroup.DELETE("/:id", DeleteTemplate) // 删除模板
+	}
+}
+
+// isAdmin 判断当前用户是否为管理员
+func isAdmin(c *gin.Context) bool {
+	user := global.GetDooTaskUser(c)
+	return user != nil && slices.Contains(user.Identity, "admin")
+}
+
+// ListTemplates 获取模板列表
+func ListTemplates(c *gin.Context) {
+	var req utils.PaginationRequest
+
+	// 绑定查询参数
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "查询参数格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 设置默认排序
+	req.SetDefaultSorts(map[string]bool{
+		"usage_count": true,
+		"id":          false,
+	})
+
+	// 验证参数
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "查询参数验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 解析筛选条件
+	var filters TemplateFilters
+	if err := req.ParseFiltersFromQuery(c, &filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "筛选条件解析失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 验证排序字段
+	allowedFields := GetAllowedSortFields()
+	for _, sort := range req.Sorts {
+		if !utils.ValidateSortField(sort.Key, allowedFields) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "VALIDATION_001",
+				"message": "无效的排序字段: " + sort.Key,
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	// 构建查询（普通用户只能看到已发布的模板）
+	query := global.DB.Model(&AgentTemplate{})
+	if !isAdmin(c) {
+		query = query.Where("is_published = true")
+	}
+
+	// 应用筛选条件
+	if filters.Search != "" {
+		searchTerm := "%" + filters.Search + "%"
+		query = query.Where("name ILIKE ? OR description ILIKE ?", searchTerm, searchTerm)
+	}
+
+	if filters.Category != "" {
+		if filters.Category == "general" {
+			query = query.Where("COALESCE(NULLIF(metadata->>'category', ''), 'general') = ?", filters.Category)
+		} else {
+			query = query.Where("metadata->>'category' = ?", filters.Category)
+		}
+	}
+
+	if filters.Tag != "" {
+		tagJson, _ := json.Marshal([]string{filters.Tag})
+		query = query.Where("metadata->'tags' @> ?::jsonb", string(tagJson))
+	}
+
+	if filters.IsBuiltin != nil {
+		query = query.Where("is_builtin = ?", *filters.IsBuiltin)
+	}
+
+	// 获取总数
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询模板总数失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 分页和排序
+	var templates []AgentTemplate
+	if err := query.
+		Order(req.GetOrderBy()).
+		Limit(req.PageSize).
+		Offset(req.GetOffset()).
+		Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询模板列表失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	for i := range templates {
+		templates[i].fillCategory()
+	}
+
+	data := TemplateListData{
+		Items: templates,
+	}
+
+	response := utils.NewPaginationResponse(req.Page, req.PageSize, total, data)
+	c.JSON(http.StatusOK, response)
+}
+
+// ListCategories 获取模板分类和标签
+func ListCategories(c *gin.Context) {
+	categories := []TemplateCategory{}
+	if err := global.DB.Raw(`
+		SELECT COALESCE(NULLIF(metadata->>'category', ''), 'general') AS category, COUNT(*) AS count
+		FROM agent_templates
+		WHERE is_published = true
+		GROUP BY 1
+		ORDER BY count DESC, category ASC
+	`).Scan(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询模板分类失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	tags := []string{}
+	if err := global.DB.Raw(`
+		SELECT DISTINCT tag
+		FROM agent_templates, jsonb_array_elements_text(
+			CASE WHEN jsonb_typeof(metadata->'tags') = 'array' THEN metadata->'tags' ELSE '[]'::jsonb END
+		) AS tag
+		WHERE is_published = true
+		ORDER BY tag
+	`).Scan(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询模板标签失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TemplateCategoriesResponse{
+		Categories: categories,
+		Tags:       tags,
+	})
+}
+
+// GetTemplate 获取模板详情
+func GetTemplate(c *gin.Context) {
+	template, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, template)
+}
+
+// findTemplate 根据路径参数查询模板，失败时直接写入响应
+func findTemplate(c *gin.Context) (*AgentTemplate, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的模板ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	query := global.DB.Where("id = ?", id)
+	if !isAdmin(c) {
+		query = query.Where("is_published = true")
+	}
+
+	var template AgentTemplate
+	if err := query.First(&template).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "TEMPLATE_001",
+				"message": "模板不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询模板失败",
+				"data":    nil,
+			})
+		}
+		return nil, false
+	}
+	template.fillCategory()
+	return &template, true
+}
+
+// mergeCategory 将分类和标签写入metadata
+func mergeCategory(metadata []byte, category *string, tags []string) (datatypes.JSON, error) {
+	meta := make(map[string]interface{})
+	if len(metadata) > 0 {
+		if err := json.Unmarshal(metadata, &meta); err != nil {
+			return nil, err
+		}
+	}
+	if category != nil {
+		meta["category"] = *category
+	}
+	if tags != nil {
+		meta["tags"] = tags
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
+// PublishTemplate 发布模板（可从已有智能体发布）
+func PublishTemplate(c *gin.Context) {
+	var req PublishTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 验证请求数据
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	userID := int64(global.GetDooTaskUser(c).UserID)
+	template := AgentTemplate{
+		UserID:      &userID,
+		Temperature: 0.7,
+		Metadata:    datatypes.JSON(req.Metadata),
+		IsPublished: true,
+	}
+
+	// 从已有智能体复制配置
+	if req.AgentID != nil {
+		var agent agents.Agent
+		if err := global.DB.Where("id = ?", *req.AgentID).First(&agent).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"code":    "AGENT_002",
+					"message": "智能体不存在",
+					"data":    nil,
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"code":    "DATABASE_001",
+					"message": "查询智能体失败",
+					"data":    nil,
+				})
+			}
+			return
+		}
+		template.Name = agent.Name
+		template.Description = agent.Description
+		template.Prompt = agent.Prompt
+		template.Temperature = agent.Temperature
+		template.SourceAgentID = &agent.ID
+		if req.Metadata == nil {
+			template.Metadata = agent.Metadata
+		}
+	}
+
+	// 请求中的字段覆盖智能体配置
+	if req.Name != nil {
+		template.Name = *req.Name
+	}
+	if req.Description != nil {
+		template.Description = req.Description
+	}
+	if req.Prompt != nil {
+		template.Prompt = *req.Prompt
+	}
+	if req.Temperature != nil {
+		template.Temperature = *req.Temperature
+	}
+	if template.Name == "" || template.Prompt == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "模板名称和提示词不能为空",
+			"data":    nil,
+		})
+		return
+	}
+
+	var category *string
+	if req.Category != "" {
+		category = &req.Category
+	}
+	metadata, err := mergeCategory(template.Metadata, category, req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "元数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	template.Metadata = metadata
+
+	if err := global.DB.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "发布模板失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	template.fillCategory()
+	c.JSON(http.StatusOK, template)
+}
+
+// UpdateTemplate 更新模板
+func UpdateTemplate(c *gin.Context) {
+	template, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 验证请求数据
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 构建更新数据
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Prompt != nil {
+		updates["prompt"] = *req.Prompt
+	}
+	if req.Temperature != nil {
+		updates["temperature"] = *req.Temperature
+	}
+	if req.IsPublished != nil {
+		updates["is_published"] = *req.IsPublished
+	}
+	if req.Category != nil || req.Tags != nil {
+		metadata, err := mergeCategory(template.Metadata, req.Category, req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "VALIDATION_001",
+				"message": "元数据格式错误",
+				"data":    err.Error(),
+			})
+			return
+		}
+		updates["metadata"] = metadata
+	}
+
+	if err := global.DB.Model(template).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "更新模板失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	var updated AgentTemplate
+	if err := global.DB.Where("id = ?", template.ID).First(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询更新的模板失败",
+			"data":    nil,
+		})
+		return
+	}
+	updated.fillCategory()
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteTemplate 删除模板（内置模板只能下架，不能删除）
+func DeleteTemplate(c *gin.Context) {
+	template, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+
+	if template.IsBuiltin {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "TEMPLATE_002",
+			"message": "内置模板不能删除，可以取消发布",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := global.DB.Delete(template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除模板失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "模板删除成功",
+	})
+}
+
+// CreateAgentFromTemplate 从模板创建智能体
+func CreateAgentFromTemplate(c *gin.Context) {
+	template, ok := findTemplate(c)
+	if !ok {
+		return
+	}
+
+	var req CreateFromTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 验证请求数据
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	userID := int64(global.GetDooTaskUser(c).UserID)
+	name := template.Name
+	if req.Name != nil && *req.Name != "" {
+		name = *req.Name
+	}
+
+	// 检查智能体名称是否已存在
+	var existingAgent agents.Agent
+	if err := global.DB.Where("user_id = ? AND name = ?", userID, name).First(&existingAgent).Error; err == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "AGENT_001",
+			"message": "智能体名称已存在",
+			"data":    nil,
+		})
+		return
+	} else if err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "检查智能体名称失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 验证AI模型是否存在
+	if req.AIModelID == nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "AI_MODEL_001",
+			"message": "请选择AI模型",
+			"data":    nil,
+		})
+		return
+	}
+	var modelCount int64
+	if err := global.DB.Model(&struct {
+		ID int64 `gorm:"primaryKey"`
+	}{}).Table("ai_models").Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleViewer)).Where("id = ? AND is_enabled = true", *req.AIModelID).Count(&modelCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "验证AI模型失败",
+			"data":    nil,
+		})
+		return
+	}
+	if modelCount == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "AI_MODEL_001",
+			"message": "指定的AI模型不存在或未启用",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 处理JSONB字段值
+	kbIDsJson := datatypes.JSON([]byte(`[]`))
+	if req.KnowledgeBases != nil {
+		kbIDsJson = datatypes.JSON(req.KnowledgeBases)
+	}
+	toolsJson := datatypes.JSON([]byte(`[]`))
+	if req.Tools != nil {
+		toolsJson = datatypes.JSON(req.Tools)
+	}
+
+	// 在元数据中记录来源模板
+	meta := make(map[string]interface{})
+	if len(template.Metadata) > 0 {
+		json.Unmarshal(template.Metadata, &meta)
+	}
+	meta["template_id"] = template.ID
+	metadataBytes, _ := json.Marshal(meta)
+
+	description := template.Description
+	if req.Description != nil {
+		description = req.Description
+	}
+	temperature := template.Temperature
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+
+	// 创建机器人
+	botID, err := agents.CreateAgentBot(c, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DOOTASK_001",
+			"message": "创建机器人失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 创建智能体
+	agent := agents.Agent{
+		UserID:         userID,
+		Name:           name,
+		Description:    description,
+		Prompt:         template.Prompt,
+		BotID:          &botID,
+		AIModelID:      req.AIModelID,
+		Temperature:    temperature,
+		Tools:          toolsJson,
+		KnowledgeBases: kbIDsJson,
+		Metadata:       datatypes.JSON(metadataBytes),
+		IsActive:       true,
+	}
+
+	if err := global.DB.Create(&agent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "创建智能体失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 更新模板使用次数
+	global.DB.Model(&AgentTemplate{}).Where("id = ?", template.ID).
+		UpdateColumn("usage_count", gorm.Expr("usage_count + 1"))
+
+	// 查询完整的智能体信息（包含关联数据）
+	var createdAgent agents.Agent
+	if err := global.DB.
+		Preload("AIModel").
+		Where("agents.id = ?", agent.ID).
+		First(&createdAgent).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询创建的智能体失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, createdAgent)
+}
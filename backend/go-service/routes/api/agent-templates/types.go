@@ -0,0 +1,119 @@
+package agenttemplates
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AgentTemplate 智能体模板模型
+type AgentTemplate struct {
+	ID            int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID        *int64         `gorm:"index" json:"user_id"`
+	Name          string         `gorm:"type:varchar(255);not null" json:"name" validate:"required,max=255"`
+	Description   *string        `gorm:"type:text" json:"description"`
+	Prompt        string         `gorm:"type:text;not null" json:"prompt"`
+	Temperature   float64        `gorm:"type:decimal(3,2);default:0.7" json:"temperature" validate:"min=0,max=2"`
+	Metadata      datatypes.JSON `gorm:"type:jsonb;default:'{}'" json:"metadata"`
+	SourceAgentID *int64         `gorm:"column:source_agent_id" json:"source_agent_id"`
+	IsBuiltin     bool           `gorm:"default:false" json:"is_builtin"`
+	IsPublished   bool           `gorm:"default:true" json:"is_published"`
+	UsageCount    int64          `gorm:"default:0" json:"usage_count"`
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 从metadata中解析的分类信息
+	Category string   `gorm:"-" json:"category"`
+	Tags     []string `gorm:"-" json:"tags"`
+}
+
+// TableName 指定表名
+func (AgentTemplate) TableName() string {
+	return "agent_templates"
+}
+
+// TemplateMetadata 模板元数据中的分类字段
+type TemplateMetadata struct {
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
+}
+
+// fillCategory 从metadata中填充分类和标签
+func (t *AgentTemplate) fillCategory() {
+	var meta TemplateMetadata
+	if len(t.Metadata) > 0 {
+		json.Unmarshal(t.Metadata, &meta)
+	}
+	t.Category = meta.Category
+	if t.Category == "" {
+		t.Category = "general"
+	}
+	t.Tags = meta.Tags
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+}
+
+// PublishTemplateRequest 发布模板请求（管理员）
+type PublishTemplateRequest struct {
+	AgentID     *int64          `json:"agent_id"` // 从已有智能体发布
+	Name        *string         `json:"name" validate:"omitempty,max=255"`
+	Description *string         `json:"description"`
+	Prompt      *string         `json:"prompt"`
+	Temperature *float64        `json:"temperature" validate:"omitempty,min=0,max=2"`
+	Category    string          `json:"category" validate:"omitempty,max=100"`
+	Tags        []string        `json:"tags" validate:"omitempty,dive,max=50"`
+	Metadata    json.RawMessage `json:"metadata"`
+}
+
+// UpdateTemplateRequest 更新模板请求（管理员）
+type UpdateTemplateRequest struct {
+	Name        *string  `json:"name" validate:"omitempty,max=255"`
+	Description *string  `json:"description"`
+	Prompt      *string  `json:"prompt"`
+	Temperature *float64 `json:"temperature" validate:"omitempty,min=0,max=2"`
+	Category    *string  `json:"category" validate:"omitempty,max=100"`
+	Tags        []string `json:"tags" validate:"omitempty,dive,max=50"`
+	IsPublished *bool    `json:"is_published"`
+}
+
+// CreateFromTemplateRequest 从模板创建智能体请求
+type CreateFromTemplateRequest struct {
+	Name           *string         `json:"name" validate:"omitempty,max=255"`
+	Description    *string         `json:"description"`
+	AIModelID      *int64          `json:"ai_model_id"`
+	Temperature    *float64        `json:"temperature" validate:"omitempty,min=0,max=2"`
+	Tools          json.RawMessage `json:"tools"`
+	KnowledgeBases json.RawMessage `json:"knowledge_bases"`
+}
+
+// TemplateFilters 模板筛选条件
+type TemplateFilters struct {
+	Search    string `json:"search" form:"search"`         // 搜索关键词
+	Category  string `json:"category" form:"category"`     // 分类过滤
+	Tag       string `json:"tag" form:"tag"`               // 标签过滤
+	IsBuiltin *bool  `json:"is_builtin" form:"is_builtin"` // 是否内置
+}
+
+// TemplateListData 模板列表数据结构
+type TemplateListData struct {
+	Items []AgentTemplate `json:"items"`
+}
+
+// TemplateCategory 模板分类统计
+type TemplateCategory struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
+}
+
+// TemplateCategoriesResponse 模板分类和标签响应
+type TemplateCategoriesResponse struct {
+	Categories []TemplateCategory `json:"categories"`
+	Tags       []string           `json:"tags"`
+}
+
+// GetAllowedSortFields 获取允许的排序字段
+func GetAllowedSortFields() []string {
+	return []string{"id", "name", "usage_count", "created_at", "updated_at"}
+}
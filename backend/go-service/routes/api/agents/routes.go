@@ -5,13 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/generation"
 	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
 	knowledgebases "dootask-ai/go-service/routes/api/knowledge-bases"
 	mcp "dootask-ai/go-service/routes/api/mcp-tools"
+	"dootask-ai/go-service/sysconfig"
 	"dootask-ai/go-service/utils"
 
 	dootask "github.com/dootask/tools/server/go"
@@ -35,6 +40,11 @@ func RegisterRoutes(router *gin.RouterGroup) {
 		agentGroup.PATCH("/:id/toggle", ToggleAgentActive) // 切换智能体状态
 		agentGroup.POST("/settings", SetUserConfig)        // 用户配置
 		agentGroup.GET("/settings", GetUserConfig)         // 获取用户配置
+
+		// 对话级生成参数覆盖
+		agentGroup.GET("/:id/dialogs/:dialogId/generation-settings", GetDialogSettings)
+		agentGroup.PUT("/:id/dialogs/:dialogId/generation-settings", SetDialogSettings)
+		agentGroup.DELETE("/:id/dialogs/:dialogId/generation-settings", DeleteDialogSettings)
 	}
 }
 
@@ -96,10 +106,9 @@ func ListAgents(c *gin.Context) {
 	// 构建查询
 	query := global.DB.Model(&Agent{})
 
-	// 检查是否是 /all 路径，如果不是才应用 user_id 筛选
+	// 检查是否是 /all 路径，如果不是才应用权限筛选（自己创建的和共享给自己的）
 	if !strings.HasSuffix(c.Request.URL.Path, "/all") {
-		// 设置默认筛选条件
-		query = query.Where("user_id = ?", global.GetDooTaskUser(c).UserID)
+		query = query.Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleViewer))
 	}
 	// 应用筛选条件
 	if filters.Search != "" {
@@ -327,6 +336,15 @@ func CreateAgent(c *gin.Context) {
 		return
 	}
 
+	if err := validateToolFunctions(req.ToolFunctions); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "函数白名单配置错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
 	// 检查智能体名称是否已存在
 	var existingAgent Agent
 	if err := global.DB.Where("user_id = ? AND name = ?", global.GetDooTaskUser(c).UserID, req.Name).First(&existingAgent).Error; err == nil {
@@ -357,7 +375,7 @@ func CreateAgent(c *gin.Context) {
 	var modelCount int64
 	if err := global.DB.Model(&struct {
 		ID int64 `gorm:"primaryKey"`
-	}{}).Table("ai_models").Where("id = ? AND user_id = ? AND is_enabled = true", *req.AIModelID, global.GetDooTaskUser(c).UserID).Count(&modelCount).Error; err != nil {
+	}{}).Table("ai_models").Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleViewer)).Where("id = ? AND is_enabled = true", *req.AIModelID).Count(&modelCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_001",
 			"message": "验证AI模型失败",
@@ -388,13 +406,13 @@ func CreateAgent(c *gin.Context) {
 		metadataJson = datatypes.JSON(req.Metadata)
 	}
 
+	temperature := sysconfig.Float(sysconfig.KeyDefaultAgentTemperature, 0.7)
+	if req.Temperature != nil {
+		temperature = *req.Temperature
+	}
+
 	// 创建机器人
-	bot, err := global.GetDooTaskClient(c).Client.CreateBot(dootask.CreateBotRequest{
-		Name:       req.Name,
-		Session:    1,
-		ClearDay:   15,
-		WebhookURL: fmt.Sprintf("%s/service/webhook?server_url=%s", "http://nginx/apps/ai-agent", c.GetString("base_url")),
-	})
+	botID, err := CreateAgentBot(c, req.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DOOTASK_001",
@@ -403,7 +421,6 @@ func CreateAgent(c *gin.Context) {
 		})
 		return
 	}
-	botID := int64(bot.ID)
 
 	// 创建智能体
 	agent := Agent{
@@ -413,12 +430,18 @@ func CreateAgent(c *gin.Context) {
 		Prompt:         req.Prompt,
 		BotID:          &botID,
 		AIModelID:      req.AIModelID,
-		Temperature:    req.Temperature,
+		Temperature:    temperature,
 		Tools:          toolsJson,
 		KnowledgeBases: kbIDsJson,
 		Metadata:       metadataJson,
 		IsActive:       true,
 	}
+	if req.GenerationSettings != nil {
+		agent.GenerationSettings = *req.GenerationSettings
+	}
+	if req.ToolFunctions != nil {
+		agent.ToolFunctions = req.ToolFunctions
+	}
 
 	if err := global.DB.Create(&agent).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -442,10 +465,25 @@ func CreateAgent(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceAgent, agent.ID, nil, agent)
 
 	c.JSON(http.StatusOK, createdAgent)
 }
 
+// CreateAgentBot 为智能体创建DooTask机器人，返回机器人ID
+func CreateAgentBot(c *gin.Context, name string) (int64, error) {
+	bot, err := global.GetDooTaskClient(c).Client.CreateBot(dootask.CreateBotRequest{
+		Name:       name,
+		Session:    1,
+		ClearDay:   15,
+		WebhookURL: fmt.Sprintf("%s/service/webhook?server_url=%s", "http://nginx/apps/ai-agent", c.GetString("base_url")),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(bot.ID), nil
+}
+
 // GetAgent 获取智能体详情
 func GetAgent(c *gin.Context) {
 	idStr := c.Param("id")
@@ -462,6 +500,7 @@ func GetAgent(c *gin.Context) {
 	var agent Agent
 	if err := global.DB.
 		Preload("AIModel").
+		Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleViewer)).
 		Where("agents.id = ?", id).
 		First(&agent).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -566,7 +605,7 @@ func UpdateAgent(c *gin.Context) {
 
 	// 检查智能体是否存在
 	var agent Agent
-	if err := global.DB.Where("id = ? AND user_id = ?", id, global.GetDooTaskUser(c).UserID).First(&agent).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleEditor)).Where("id = ?", id).First(&agent).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "AGENT_002",
@@ -583,10 +622,19 @@ func UpdateAgent(c *gin.Context) {
 		return
 	}
 
+	if err := validateToolFunctions(req.ToolFunctions); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "函数白名单配置错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
 	// 检查智能体名称是否已被其他智能体使用
 	if req.Name != nil && *req.Name != agent.Name {
 		var existingAgent Agent
-		if err := global.DB.Where("user_id = ? AND name = ? AND id != ?", global.GetDooTaskUser(c).UserID, *req.Name, id).First(&existingAgent).Error; err == nil {
+		if err := global.DB.Where("user_id = ? AND name = ? AND id != ?", agent.UserID, *req.Name, id).First(&existingAgent).Error; err == nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
 				"code":    "AGENT_001",
 				"message": "智能体名称已存在",
@@ -615,7 +663,7 @@ func UpdateAgent(c *gin.Context) {
 	var modelCount int64
 	if err := global.DB.Model(&struct {
 		ID int64 `gorm:"primaryKey"`
-	}{}).Table("ai_models").Where("id = ? AND user_id = ? AND is_enabled = true", *req.AIModelID, global.GetDooTaskUser(c).UserID).Count(&modelCount).Error; err != nil {
+	}{}).Table("ai_models").Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleViewer)).Where("id = ? AND is_enabled = true", *req.AIModelID).Count(&modelCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_001",
 			"message": "验证AI模型失败",
@@ -647,7 +695,7 @@ func UpdateAgent(c *gin.Context) {
 			var knowledgeBaseCount int64
 			if err := global.DB.Model(&struct {
 				ID int64 `gorm:"primaryKey"`
-			}{}).Table("knowledge_bases").Where("id IN (?) AND user_id = ?", kbIDs, global.GetDooTaskUser(c).UserID).Count(&knowledgeBaseCount).Error; err != nil {
+			}{}).Table("knowledge_bases").Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, permission.RoleViewer)).Where("id IN (?)", kbIDs).Count(&knowledgeBaseCount).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"code":    "DATABASE_001",
 					"message": "验证知识库失败",
@@ -681,7 +729,7 @@ func UpdateAgent(c *gin.Context) {
 			var toolCount int64
 			if err := global.DB.Model(&struct {
 				ID int64 `gorm:"primaryKey"`
-			}{}).Table("mcp_tools").Where("id IN (?) AND user_id = ?", toolIDs, global.GetDooTaskUser(c).UserID).Count(&toolCount).Error; err != nil {
+			}{}).Table("mcp_tools").Scopes(permission.Scope(c, permission.ResourceMCPTool, permission.RoleViewer)).Where("id IN (?)", toolIDs).Count(&toolCount).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"code":    "DATABASE_001",
 					"message": "验证工具失败",
@@ -729,6 +777,12 @@ func UpdateAgent(c *gin.Context) {
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
+	if req.GenerationSettings != nil {
+		updates["generation_settings"] = *req.GenerationSettings
+	}
+	if req.ToolFunctions != nil {
+		updates["tool_functions"] = req.ToolFunctions
+	}
 
 	// 更新机器人
 	if agent.BotID != nil && req.Name != nil {
@@ -748,6 +802,7 @@ func UpdateAgent(c *gin.Context) {
 	}
 
 	// 执行更新
+	before := agent
 	if err := global.DB.Model(&agent).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_002",
@@ -770,6 +825,7 @@ func UpdateAgent(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceAgent, id, before, updatedAgent)
 
 	c.JSON(http.StatusOK, updatedAgent)
 }
@@ -789,7 +845,7 @@ func DeleteAgent(c *gin.Context) {
 
 	// 检查智能体是否存在
 	var agent Agent
-	if err := global.DB.Where("id = ? AND user_id = ?", id, global.GetDooTaskUser(c).UserID).First(&agent).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleOwner)).Where("id = ?", id).First(&agent).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "AGENT_002",
@@ -853,6 +909,8 @@ func DeleteAgent(c *gin.Context) {
 		})
 		return
 	}
+	permission.RemoveShares(global.DB, permission.ResourceAgent, agent.ID)
+	audit.Record(c, audit.ActionDelete, audit.ResourceAgent, agent.ID, agent, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "智能体删除成功",
@@ -886,7 +944,7 @@ func ToggleAgentActive(c *gin.Context) {
 
 	// 检查智能体是否存在
 	var agent Agent
-	if err := global.DB.Where("id = ? AND user_id = ?", id, global.GetDooTaskUser(c).UserID).First(&agent).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleEditor)).Where("id = ?", id).First(&agent).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "AGENT_002",
@@ -904,6 +962,7 @@ func ToggleAgentActive(c *gin.Context) {
 	}
 
 	// 更新状态
+	before := agent
 	if err := global.DB.Model(&agent).Update("is_active", req.IsActive).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_002",
@@ -926,6 +985,7 @@ func ToggleAgentActive(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionToggle, audit.ResourceAgent, id, before, updatedAgent)
 
 	c.JSON(http.StatusOK, updatedAgent)
 }
@@ -951,6 +1011,17 @@ func SetUserConfig(c *gin.Context) {
 	userID := int64(global.GetDooTaskUser(c).UserID)
 	var errors []string
 
+	// 记录修改前的配置用于操作日志
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+	var existing []UserConfig
+	global.DB.Where("user_id = ?", userID).Find(&existing)
+	for _, config := range existing {
+		if _, ok := req[config.Key]; ok {
+			before[config.Key] = config.Value
+		}
+	}
+
 	// 遍历对象中的每个配置项
 	for key, value := range req {
 		// 将值转换为字符串
@@ -977,8 +1048,14 @@ func SetUserConfig(c *gin.Context) {
 
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("配置 %s 保存失败: %v", key, err))
+			continue
+		}
+		after[key] = valueStr
+		if _, ok := before[key]; !ok {
+			before[key] = nil
 		}
 	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceUserSetting, userID, before, after)
 
 	if len(errors) > 0 {
 		c.JSON(500, gin.H{
@@ -1044,3 +1121,167 @@ func GetUserConfig(c *gin.Context) {
 		"data":    response,
 	})
 }
+
+// validateToolFunctions 校验函数白名单（已缓存函数列表的工具会检查函数是否存在）
+func validateToolFunctions(rules ToolFunctions) error {
+	for key, functions := range rules {
+		toolID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return fmt.Errorf("无效的工具ID: %s", key)
+		}
+
+		known := map[string]bool{}
+		for _, function := range mcp.CachedFunctions(toolID) {
+			known[function.Name] = true
+		}
+		for _, function := range functions {
+			if function.Name == "" {
+				return fmt.Errorf("工具 %d 的函数名称不能为空", toolID)
+			}
+			if len(known) > 0 && !known[function.Name] {
+				return fmt.Errorf("工具 %d 没有函数 %s", toolID, function.Name)
+			}
+			switch function.Approval {
+			case "", mcp.ApprovalAuto, mcp.ApprovalConfirm, mcp.ApprovalDeny:
+			default:
+				return fmt.Errorf("函数 %s 的审批策略无效: %s", function.Name, function.Approval)
+			}
+			for arg, constraint := range function.Constraints {
+				if constraint.Pattern == "" {
+					continue
+				}
+				if _, err := regexp.Compile(constraint.Pattern); err != nil {
+					return fmt.Errorf("函数 %s 参数 %s 的正则无效: %v", function.Name, arg, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// dialogAgent 获取当前用户可编辑的智能体，失败时写入错误响应
+func dialogAgent(c *gin.Context) (*Agent, bool) {
+	var agent Agent
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleEditor)).Where("id = ?", c.Param("id")).First(&agent).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "AGENT_002",
+				"message": "智能体不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询智能体失败",
+				"data":    nil,
+			})
+		}
+		return nil, false
+	}
+	return &agent, true
+}
+
+// GetDialogSettings 获取对话级生成参数覆盖及合并后的最终参数
+func GetDialogSettings(c *gin.Context) {
+	agent, ok := dialogAgent(c)
+	if !ok {
+		return
+	}
+
+	var modelSettings generation.Settings
+	if agent.AIModelID != nil {
+		var model struct {
+			Temperature        float64
+			MaxTokens          int
+			GenerationSettings generation.Settings
+		}
+		global.DB.Table("ai_models").Select("temperature, max_tokens, generation_settings").Where("id = ?", *agent.AIModelID).Scan(&model)
+		modelSettings = model.GenerationSettings
+		if modelSettings.Temperature == nil {
+			modelSettings.Temperature = &model.Temperature
+		}
+		if modelSettings.MaxOutputTokens == nil && model.MaxTokens > 0 {
+			modelSettings.MaxOutputTokens = &model.MaxTokens
+		}
+	}
+	dialogSettings := generation.ForDialog(agent.ID, c.Param("dialogId"))
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": dialogSettings,
+		"resolved": generation.Resolve(modelSettings, agent.Settings(), dialogSettings),
+	})
+}
+
+// SetDialogSettings 设置对话级生成参数覆盖
+func SetDialogSettings(c *gin.Context) {
+	agent, ok := dialogAgent(c)
+	if !ok {
+		return
+	}
+
+	var req generation.Settings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	dialogID := c.Param("dialogId")
+	before := generation.ForDialog(agent.ID, dialogID)
+	dialog := generation.DialogSettings{
+		AgentID:   agent.ID,
+		DialogID:  dialogID,
+		Settings:  req,
+		UpdatedBy: int64(global.GetDooTaskUser(c).UserID),
+	}
+	if err := global.DB.
+		Where(generation.DialogSettings{AgentID: agent.ID, DialogID: dialogID}).
+		Assign(generation.DialogSettings{Settings: req, UpdatedBy: dialog.UpdatedBy}).
+		FirstOrCreate(&dialog).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "保存对话生成参数失败",
+			"data":    nil,
+		})
+		return
+	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceDialogSetting, fmt.Sprintf("%d:%s", agent.ID, dialogID), before, req)
+
+	c.JSON(http.StatusOK, dialog)
+}
+
+// DeleteDialogSettings 删除对话级生成参数覆盖
+func DeleteDialogSettings(c *gin.Context) {
+	agent, ok := dialogAgent(c)
+	if !ok {
+		return
+	}
+
+	dialogID := c.Param("dialogId")
+	before := generation.ForDialog(agent.ID, dialogID)
+	if err := global.DB.Where("agent_id = ? AND dialog_id = ?", agent.ID, dialogID).Delete(&generation.DialogSettings{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除对话生成参数失败",
+			"data":    nil,
+		})
+		return
+	}
+	audit.Record(c, audit.ActionDelete, audit.ResourceDialogSetting, fmt.Sprintf("%d:%s", agent.ID, dialogID), before, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "对话生成参数删除成功",
+	})
+}
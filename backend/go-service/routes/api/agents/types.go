@@ -1,10 +1,16 @@
 package agents
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
+	"dootask-ai/go-service/generation"
 	"dootask-ai/go-service/routes/api/conversations"
+	mcp "dootask-ai/go-service/routes/api/mcp-tools"
 
 	"gorm.io/datatypes"
 )
@@ -23,8 +29,13 @@ type Agent struct {
 	KnowledgeBases datatypes.JSON `gorm:"type:jsonb;default:'[]'" json:"knowledge_bases"`
 	Metadata       datatypes.JSON `gorm:"type:jsonb;default:'{}'" json:"metadata"`
 	IsActive       bool           `gorm:"default:true" json:"is_active"`
-	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 生成参数覆盖（temperature 未设置时使用 Temperature 字段）
+	GenerationSettings generation.Settings `gorm:"type:jsonb;default:'{}'" json:"generation_settings"`
+	// MCP函数白名单
+	ToolFunctions ToolFunctions `gorm:"type:jsonb;default:'{}'" json:"tool_functions"`
+	CreatedAt     time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
 
 	// 关联模型
 	AIModel       *AIModel                     `gorm:"foreignKey:AIModelID" json:"ai_model,omitempty"`
@@ -47,6 +58,96 @@ type AgentStatistics struct {
 	SuccessRate         float64 `json:"success_rate"`
 }
 
+// ToolFunctions 按MCP工具ID配置允许调用的函数，未配置的工具允许调用全部函数
+type ToolFunctions map[string][]mcp.FunctionRule
+
+// Value 实现 driver.Valuer，以 JSONB 存储
+func (f ToolFunctions) Value() (driver.Value, error) {
+	if f == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan 实现 sql.Scanner
+func (f *ToolFunctions) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("无法解析函数白名单: %T", value)
+	}
+	*f = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, f)
+}
+
+// FunctionPolicy 工具的函数白名单、参数约束和审批策略
+type FunctionPolicy struct {
+	Allowed     []string
+	Constraints map[string]map[string]mcp.ArgConstraint
+	Approvals   map[string]string
+}
+
+// IsAllowed 函数是否在白名单中
+func (p FunctionPolicy) IsAllowed(name string) bool {
+	return slices.Contains(p.Allowed, name)
+}
+
+// FunctionPolicy 获取工具的函数策略，未配置时返回 false（允许全部函数）
+// interactive 为 false 时（如群聊没有会话线程，无法暂停等待回复）需要确认的函数按禁止处理
+func (a Agent) FunctionPolicy(toolID int64, interactive bool) (FunctionPolicy, bool) {
+	rules, ok := a.ToolFunctions[strconv.FormatInt(toolID, 10)]
+	if !ok {
+		return FunctionPolicy{}, false
+	}
+	policy := FunctionPolicy{
+		Allowed:     make([]string, 0, len(rules)),
+		Constraints: map[string]map[string]mcp.ArgConstraint{},
+		Approvals:   map[string]string{},
+	}
+	for _, rule := range rules {
+		if rule.Approval == mcp.ApprovalDeny || (rule.Approval == mcp.ApprovalConfirm && !interactive) {
+			continue
+		}
+		policy.Allowed = append(policy.Allowed, rule.Name)
+		if len(rule.Constraints) > 0 {
+			policy.Constraints[rule.Name] = rule.Constraints
+		}
+		if rule.Approval == mcp.ApprovalConfirm {
+			policy.Approvals[rule.Name] = rule.Approval
+		}
+	}
+	return policy, true
+}
+
+// ApplyToolFunctions 将工具的函数白名单和审批策略写入发送给AI服务的MCP配置
+func (a Agent) ApplyToolFunctions(toolID int64, config map[string]any, interactive bool) {
+	policy, ok := a.FunctionPolicy(toolID, interactive)
+	if !ok {
+		return
+	}
+	config["allowed_tools"] = policy.Allowed
+	if len(policy.Constraints) > 0 {
+		config["tool_constraints"] = policy.Constraints
+	}
+	if len(policy.Approvals) > 0 {
+		config["tool_approvals"] = policy.Approvals
+	}
+}
+
 // AIModel AI模型简化结构（用于关联查询）
 type AIModel struct {
 	ID       int64  `gorm:"primaryKey" json:"id"`
@@ -59,16 +160,29 @@ func (Agent) TableName() string {
 	return "agents"
 }
 
+// Settings 智能体层的生成参数（未单独设置温度时使用 Temperature 字段）
+func (a Agent) Settings() generation.Settings {
+	settings := a.GenerationSettings
+	if settings.Temperature == nil {
+		temperature := a.Temperature
+		settings.Temperature = &temperature
+	}
+	return settings
+}
+
 // CreateAgentRequest 创建智能体请求
 type CreateAgentRequest struct {
 	Name           string          `json:"name" validate:"required,max=255"`
 	Description    *string         `json:"description"`
 	Prompt         string          `json:"prompt"`
 	AIModelID      *int64          `json:"ai_model_id"`
-	Temperature    float64         `json:"temperature" validate:"min=0,max=2"`
+	Temperature    *float64        `json:"temperature" validate:"omitempty,min=0,max=2"` // 未设置时使用系统默认温度
 	Tools          json.RawMessage `json:"tools"`
 	KnowledgeBases json.RawMessage `json:"knowledge_bases"`
 	Metadata       json.RawMessage `json:"metadata"`
+
+	GenerationSettings *generation.Settings `json:"generation_settings"`
+	ToolFunctions      ToolFunctions        `json:"tool_functions"`
 }
 
 // UpdateAgentRequest 更新智能体请求
@@ -82,6 +196,9 @@ type UpdateAgentRequest struct {
 	KnowledgeBases json.RawMessage `json:"knowledge_bases"`
 	Metadata       json.RawMessage `json:"metadata"`
 	IsActive       *bool           `json:"is_active"`
+
+	GenerationSettings *generation.Settings `json:"generation_settings"`
+	ToolFunctions      ToolFunctions        `json:"tool_functions"`
 }
 
 // AgentFilters 智能体筛选条件
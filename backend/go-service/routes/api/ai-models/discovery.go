@@ -0,0 +1,345 @@
+package aimodels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// discoveryTimeout 查询服务商模型列表的超时时间
+const discoveryTimeout = 20 * time.Second
+
+// defaultBaseURLs 未配置base_url时各服务商模型列表接口的默认地址
+var defaultBaseURLs = map[string]string{
+	"openai":     "https://api.openai.com/v1",
+	"anthropic":  "https://api.anthropic.com/v1",
+	"google":     "https://generativelanguage.googleapis.com/v1beta",
+	"xai":        "https://api.x.ai/v1",
+	"deepseek":   "https://api.deepseek.com/v1",
+	"alibaba":    "https://dashscope.aliyuncs.com/compatible-mode/v1",
+	"openrouter": "https://openrouter.ai/api/v1",
+	"cohere":     "https://api.cohere.ai/v1",
+	"local":      "http://localhost:11434",
+}
+
+// modelCapabilityHints 按模型ID推断能力（服务商未返回能力字段时使用）
+var modelCapabilityHints = []struct {
+	capability string
+	keywords   []string
+}{
+	{CapabilityEmbedding, []string{"embed"}},
+	{CapabilityThinking, []string{"o1", "o3", "o4", "r1", "reason", "think", "qwq"}},
+	{CapabilityVision, []string{"vision", "gpt-4o", "gpt-4.1", "claude-3", "claude-opus", "claude-sonnet", "gemini", "llava", "-vl"}},
+}
+
+// ListProviderModels 使用给定凭据查询服务商的可用模型
+func ListProviderModels(ctx context.Context, provider string, apiKey string, baseURL string, proxyURL string) ([]DiscoveredModel, error) {
+	if baseURL == "" {
+		baseURL = defaultBaseURLs[provider]
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("服务商 %s 需要配置 base_url", provider)
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	client := &http.Client{Timeout: discoveryTimeout}
+	if proxyURL != "" {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("代理地址无效: %w", err)
+		}
+		client.Transport = &http.Transport{Proxy: http.ProxyURL(proxy)}
+	}
+
+	var (
+		models []DiscoveredModel
+		err    error
+	)
+	switch provider {
+	case "azure":
+		return nil, fmt.Errorf("Azure 部署名称需在 Azure 门户中查看，不支持自动发现")
+	case "anthropic":
+		models, err = listAnthropicModels(ctx, client, baseURL, apiKey)
+	case "google":
+		models, err = listGoogleModels(ctx, client, baseURL, apiKey)
+	case "cohere":
+		models, err = listCohereModels(ctx, client, baseURL, apiKey)
+	case "local":
+		models, err = listOllamaModels(ctx, client, baseURL, apiKey)
+	default:
+		// 其余服务商均为 OpenAI 兼容接口
+		models, err = listOpenAIModels(ctx, client, baseURL, apiKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range models {
+		if len(models[i].Capabilities) == 0 {
+			models[i].Capabilities = inferCapabilities(models[i].ID)
+		}
+		if models[i].Name == "" {
+			models[i].Name = models[i].ID
+		}
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models, nil
+}
+
+// listOpenAIModels 查询 OpenAI 兼容接口 GET /models
+func listOpenAIModels(ctx context.Context, client *http.Client, baseURL string, apiKey string) ([]DiscoveredModel, error) {
+	var resp struct {
+		Data []struct {
+			ID            string `json:"id"`
+			Name          string `json:"name"`
+			ContextLength int    `json:"context_length"` // OpenRouter
+			ContextWindow int    `json:"context_window"` // Groq 等
+			MaxModelLen   int    `json:"max_model_len"`  // vLLM
+			TopProvider   struct {
+				MaxCompletionTokens int `json:"max_completion_tokens"`
+			} `json:"top_provider"`
+			Architecture struct {
+				InputModalities []string `json:"input_modalities"`
+			} `json:"architecture"`
+			SupportedParameters []string `json:"supported_parameters"`
+		} `json:"data"`
+	}
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+	if err := getJSON(ctx, client, baseURL+"/models", headers, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make([]DiscoveredModel, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		model := DiscoveredModel{
+			ID:              item.ID,
+			Name:            item.Name,
+			ContextWindow:   max(item.ContextLength, item.ContextWindow, item.MaxModelLen),
+			MaxOutputTokens: item.TopProvider.MaxCompletionTokens,
+		}
+		if len(item.Architecture.InputModalities) > 0 || len(item.SupportedParameters) > 0 {
+			model.Capabilities = []string{CapabilityChat}
+			for _, modality := range item.Architecture.InputModalities {
+				if modality == "image" {
+					model.Capabilities = append(model.Capabilities, CapabilityVision)
+				}
+			}
+			for _, param := range item.SupportedParameters {
+				switch param {
+				case "tools":
+					model.Capabilities = append(model.Capabilities, CapabilityTools)
+				case "reasoning":
+					model.Capabilities = append(model.Capabilities, CapabilityThinking)
+				}
+			}
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// listAnthropicModels 查询 Anthropic GET /v1/models
+func listAnthropicModels(ctx context.Context, client *http.Client, baseURL string, apiKey string) ([]DiscoveredModel, error) {
+	if !strings.HasSuffix(baseURL, "/v1") {
+		baseURL += "/v1"
+	}
+	var resp struct {
+		Data []struct {
+			ID          string `json:"id"`
+			DisplayName string `json:"display_name"`
+		} `json:"data"`
+	}
+	headers := map[string]string{
+		"x-api-key":         apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+	if err := getJSON(ctx, client, baseURL+"/models?limit=1000", headers, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make([]DiscoveredModel, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		models = append(models, DiscoveredModel{
+			ID:            item.ID,
+			Name:          item.DisplayName,
+			ContextWindow: 200000,
+		})
+	}
+	return models, nil
+}
+
+// listGoogleModels 查询 Gemini GET /models
+func listGoogleModels(ctx context.Context, client *http.Client, baseURL string, apiKey string) ([]DiscoveredModel, error) {
+	var resp struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			DisplayName                string   `json:"displayName"`
+			InputTokenLimit            int      `json:"inputTokenLimit"`
+			OutputTokenLimit           int      `json:"outputTokenLimit"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+			Thinking                   bool     `json:"thinking"`
+		} `json:"models"`
+	}
+	endpoint := baseURL + "/models?pageSize=1000&key=" + url.QueryEscape(apiKey)
+	if err := getJSON(ctx, client, endpoint, nil, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make([]DiscoveredModel, 0, len(resp.Models))
+	for _, item := range resp.Models {
+		model := DiscoveredModel{
+			ID:              strings.TrimPrefix(item.Name, "models/"),
+			Name:            item.DisplayName,
+			ContextWindow:   item.InputTokenLimit,
+			MaxOutputTokens: item.OutputTokenLimit,
+		}
+		for _, method := range item.SupportedGenerationMethods {
+			switch method {
+			case "generateContent":
+				model.Capabilities = append(model.Capabilities, CapabilityChat, CapabilityTools)
+			case "embedContent":
+				model.Capabilities = append(model.Capabilities, CapabilityEmbedding)
+			}
+		}
+		if item.Thinking {
+			model.Capabilities = append(model.Capabilities, CapabilityThinking)
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// listCohereModels 查询 Cohere GET /models
+func listCohereModels(ctx context.Context, client *http.Client, baseURL string, apiKey string) ([]DiscoveredModel, error) {
+	var resp struct {
+		Models []struct {
+			Name          string   `json:"name"`
+			ContextLength int      `json:"context_length"`
+			Endpoints     []string `json:"endpoints"`
+			Features      []string `json:"features"`
+		} `json:"models"`
+	}
+	headers := map[string]string{"Authorization": "Bearer " + apiKey}
+	if err := getJSON(ctx, client, baseURL+"/models?page_size=1000", headers, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make([]DiscoveredModel, 0, len(resp.Models))
+	for _, item := range resp.Models {
+		model := DiscoveredModel{
+			ID:            item.Name,
+			ContextWindow: item.ContextLength,
+		}
+		for _, endpoint := range item.Endpoints {
+			switch endpoint {
+			case "chat":
+				model.Capabilities = append(model.Capabilities, CapabilityChat)
+			case "embed":
+				model.Capabilities = append(model.Capabilities, CapabilityEmbedding)
+			}
+		}
+		for _, feature := range item.Features {
+			if feature == "tool_use" || feature == "tools" {
+				model.Capabilities = append(model.Capabilities, CapabilityTools)
+			}
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// listOllamaModels 查询 Ollama GET /api/tags
+func listOllamaModels(ctx context.Context, client *http.Client, baseURL string, apiKey string) ([]DiscoveredModel, error) {
+	var resp struct {
+		Models []struct {
+			Name    string `json:"name"`
+			Details struct {
+				Family        string `json:"family"`
+				ParameterSize string `json:"parameter_size"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	headers := map[string]string{}
+	if apiKey != "" {
+		headers["Authorization"] = "Bearer " + apiKey
+	}
+	if err := getJSON(ctx, client, strings.TrimSuffix(baseURL, "/v1")+"/api/tags", headers, &resp); err != nil {
+		return nil, err
+	}
+
+	models := make([]DiscoveredModel, 0, len(resp.Models))
+	for _, item := range resp.Models {
+		name := item.Name
+		if item.Details.ParameterSize != "" {
+			name += " (" + item.Details.ParameterSize + ")"
+		}
+		models = append(models, DiscoveredModel{
+			ID:   item.Name,
+			Name: name,
+		})
+	}
+	return models, nil
+}
+
+// getJSON 发送GET请求并解析JSON响应
+func getJSON(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求服务商失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(body))
+		if len(message) > 500 {
+			message = message[:500]
+		}
+		return fmt.Errorf("服务商返回状态码 %d: %s", resp.StatusCode, message)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
+
+// inferCapabilities 按模型ID推断能力
+func inferCapabilities(modelID string) []string {
+	lower := strings.ToLower(modelID)
+	for _, keyword := range modelCapabilityHints[0].keywords {
+		if strings.Contains(lower, keyword) {
+			return []string{CapabilityEmbedding}
+		}
+	}
+
+	capabilities := []string{CapabilityChat}
+	for _, hint := range modelCapabilityHints[1:] {
+		for _, keyword := range hint.keywords {
+			if strings.Contains(lower, keyword) {
+				capabilities = append(capabilities, hint.capability)
+				break
+			}
+		}
+	}
+	return capabilities
+}
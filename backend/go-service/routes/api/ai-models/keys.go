@@ -0,0 +1,205 @@
+package aimodels
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"dootask-ai/go-service/global"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 密钥停用冷却时间
+const (
+	UnauthorizedCooldown = 30 * time.Minute // 401：密钥无效或被吊销
+	RateLimitCooldown    = time.Minute      // 429：触发服务商限流
+	usageWindowTTL       = 2 * time.Minute
+)
+
+// 密钥状态
+const (
+	KeyStatusHealthy  = "healthy"
+	KeyStatusLimited  = "limited"
+	KeyStatusBenched  = "benched"
+	KeyStatusInactive = "inactive"
+)
+
+func rpmKey(id int64, minute int64) string {
+	return fmt.Sprintf("ai_model_key:%d:rpm:%d", id, minute)
+}
+
+func tpmKey(id int64, minute int64) string {
+	return fmt.Sprintf("ai_model_key:%d:tpm:%d", id, minute)
+}
+
+func benchKey(id int64) string {
+	return fmt.Sprintf("ai_model_key:%d:bench", id)
+}
+
+// keyUsage 密钥当前分钟的使用情况
+type keyUsage struct {
+	RPM          int
+	TPM          int
+	BenchReason  string
+	BenchedUntil *time.Time
+}
+
+// loadUsage 批量读取密钥的使用量和停用状态
+func loadUsage(ctx context.Context, keys []AIModelKey) map[int64]keyUsage {
+	usage := make(map[int64]keyUsage, len(keys))
+	if len(keys) == 0 {
+		return usage
+	}
+
+	minute := time.Now().Unix() / 60
+	pipe := global.Redis.Pipeline()
+	rpmCmds := make([]*redis.StringCmd, len(keys))
+	tpmCmds := make([]*redis.StringCmd, len(keys))
+	benchCmds := make([]*redis.StringCmd, len(keys))
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		rpmCmds[i] = pipe.Get(ctx, rpmKey(key.ID, minute))
+		tpmCmds[i] = pipe.Get(ctx, tpmKey(key.ID, minute))
+		benchCmds[i] = pipe.Get(ctx, benchKey(key.ID))
+		ttlCmds[i] = pipe.PTTL(ctx, benchKey(key.ID))
+	}
+	pipe.Exec(ctx)
+
+	for i, key := range keys {
+		item := keyUsage{}
+		item.RPM, _ = strconv.Atoi(rpmCmds[i].Val())
+		item.TPM, _ = strconv.Atoi(tpmCmds[i].Val())
+		if reason := benchCmds[i].Val(); reason != "" {
+			item.BenchReason = reason
+			if ttl := ttlCmds[i].Val(); ttl > 0 {
+				until := time.Now().Add(ttl)
+				item.BenchedUntil = &until
+			}
+		}
+		usage[key.ID] = item
+	}
+	return usage
+}
+
+// PickKey 从模型的密钥池中选择一个可用密钥并替换 aiModel.ApiKey
+// 优先选择未停用、未超限且按权重折算后最近使用最少的密钥；没有配置密钥池时返回nil并沿用模型自身的密钥
+func PickKey(aiModel *AIModel) *AIModelKey {
+	var keys []AIModelKey
+	if err := global.DB.Where("ai_model_id = ? AND is_active = true", aiModel.ID).Order("id ASC").Find(&keys).Error; err != nil || len(keys) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	usage := loadUsage(ctx, keys)
+
+	pick := func(respectLimits bool) *AIModelKey {
+		var (
+			best      *AIModelKey
+			bestScore float64
+		)
+		for i := range keys {
+			key := &keys[i]
+			u := usage[key.ID]
+			if u.BenchReason != "" {
+				continue
+			}
+			if respectLimits && ((key.RPMLimit > 0 && u.RPM >= key.RPMLimit) || (key.TPMLimit > 0 && u.TPM >= key.TPMLimit)) {
+				continue
+			}
+			weight := max(key.Weight, 1)
+			score := float64(u.RPM+1) / float64(weight)
+			if best == nil || score < bestScore || (score == bestScore && rand.Intn(2) == 0) {
+				best, bestScore = key, score
+			}
+		}
+		return best
+	}
+
+	key := pick(true)
+	if key == nil && (aiModel.ApiKey == nil || *aiModel.ApiKey == "") {
+		// 全部超出限额时仍选择一个未停用的密钥，由服务商决定是否限流
+		key = pick(false)
+	}
+	if key == nil {
+		log.Printf("AI模型 %d 的密钥池暂无可用密钥，使用模型默认密钥", aiModel.ID)
+		return nil
+	}
+
+	minute := time.Now().Unix() / 60
+	pipe := global.Redis.Pipeline()
+	pipe.Incr(ctx, rpmKey(key.ID, minute))
+	pipe.Expire(ctx, rpmKey(key.ID, minute), usageWindowTTL)
+	pipe.Exec(ctx)
+
+	aiModel.ApiKey = &key.ApiKey
+	return key
+}
+
+// RecordKeyTokens 累计密钥当前分钟的令牌用量
+func RecordKeyTokens(keyID int64, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	ctx := context.Background()
+	minute := time.Now().Unix() / 60
+	pipe := global.Redis.Pipeline()
+	pipe.IncrBy(ctx, tpmKey(keyID, minute), int64(tokens))
+	pipe.Expire(ctx, tpmKey(keyID, minute), usageWindowTTL)
+	pipe.Exec(ctx)
+}
+
+// BenchKey 按服务商返回的状态码临时停用密钥（401长时间停用，429短时间冷却）
+func BenchKey(keyID int64, statusCode int, reason string) {
+	cooldown := RateLimitCooldown
+	if statusCode == 401 {
+		cooldown = UnauthorizedCooldown
+	}
+	if runes := []rune(reason); len(runes) > 200 {
+		reason = string(runes[:200])
+	}
+	value := fmt.Sprintf("%d: %s", statusCode, reason)
+	if err := global.Redis.Set(context.Background(), benchKey(keyID), value, cooldown).Err(); err != nil {
+		log.Printf("停用密钥 %d 失败: %v", keyID, err)
+		return
+	}
+	log.Printf("密钥 %d 已停用 %s（%d）", keyID, cooldown, statusCode)
+}
+
+// UnbenchKey 解除密钥停用
+func UnbenchKey(keyID int64) {
+	global.Redis.Del(context.Background(), benchKey(keyID))
+}
+
+// KeyHealthList 获取模型密钥池的健康状态
+func KeyHealthList(aiModelID int64) []KeyHealth {
+	var keys []AIModelKey
+	global.DB.Where("ai_model_id = ?", aiModelID).Order("id ASC").Find(&keys)
+
+	usage := loadUsage(context.Background(), keys)
+	list := make([]KeyHealth, 0, len(keys))
+	for _, key := range keys {
+		u := usage[key.ID]
+		status := KeyStatusHealthy
+		switch {
+		case !key.IsActive:
+			status = KeyStatusInactive
+		case u.BenchReason != "":
+			status = KeyStatusBenched
+		case (key.RPMLimit > 0 && u.RPM >= key.RPMLimit) || (key.TPMLimit > 0 && u.TPM >= key.TPMLimit):
+			status = KeyStatusLimited
+		}
+		list = append(list, KeyHealth{
+			AIModelKey:   key.Masked(),
+			Status:       status,
+			CurrentRPM:   u.RPM,
+			CurrentTPM:   u.TPM,
+			LastError:    u.BenchReason,
+			BenchedUntil: u.BenchedUntil,
+		})
+	}
+	return list
+}
@@ -2,14 +2,15 @@ package aimodels
 
 import (
 	"database/sql"
+	"dootask-ai/go-service/audit"
 	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
 	"dootask-ai/go-service/routes/api/agents"
 	"dootask-ai/go-service/routes/api/conversations"
+	"dootask-ai/go-service/secret"
 	"dootask-ai/go-service/utils"
 	"net/http"
 
-	"github.com/duke-git/lancet/v2/convertor"
-	"github.com/duke-git/lancet/v2/cryptor"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
@@ -30,6 +31,18 @@ func RegisterRoutes(router *gin.RouterGroup) {
 		aimodels.POST("", handler.CreateAIModel)       // 创建AI模型
 		aimodels.PUT("/:id", handler.UpdateAIModel)    // 更新AI模型
 		aimodels.DELETE("/:id", handler.DeleteAIModel) // 删除AI模型
+
+		// 模型发现
+		aimodels.GET("/:id/discover", handler.DiscoverByModel) // 使用已保存凭据查询服务商可用模型
+		aimodels.POST("/discover", handler.DiscoverModels)     // 使用填写的凭据查询服务商可用模型
+		aimodels.POST("/bulk", handler.BulkCreateAIModels)     // 批量创建AI模型
+
+		// 密钥池
+		aimodels.GET("/:id/keys", handler.ListKeys)               // 获取密钥池及健康状态
+		aimodels.POST("/:id/keys", handler.CreateKey)             // 添加密钥
+		aimodels.PUT("/:id/keys/:keyId", handler.UpdateKey)       // 更新密钥
+		aimodels.DELETE("/:id/keys/:keyId", handler.DeleteKey)    // 删除密钥
+		aimodels.POST("/:id/keys/:keyId/reset", handler.ResetKey) // 解除密钥停用
 	}
 }
 
@@ -91,8 +104,8 @@ func (h *Handler) GetAIModels(c *gin.Context) {
 	// 构建查询
 	db := global.DB.Model(&AIModel{})
 
-	// 设置默认筛选条件
-	db = db.Where("user_id = ?", global.GetDooTaskUser(c).UserID)
+	// 设置默认筛选条件（自己创建的和共享给自己的）
+	db = db.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleViewer))
 
 	// 应用筛选条件
 	if filters.Provider != "" {
@@ -156,7 +169,7 @@ func (h *Handler) GetAIModel(c *gin.Context) {
 	}
 
 	var model AIModel
-	if err := global.DB.First(&model, id).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleViewer)).First(&model, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 				Success: false,
@@ -215,6 +228,9 @@ func (h *Handler) GetAIModel(c *gin.Context) {
 	}
 	model.TokenUsage = tokenUsage.Int64
 
+	// 密钥池健康状态
+	model.Keys = KeyHealthList(model.ID)
+
 	// 隐藏敏感信息
 	if model.ApiKey != nil && *model.ApiKey != "" {
 		masked := "***"
@@ -272,12 +288,8 @@ func (h *Handler) CreateAIModel(c *gin.Context) {
 		}
 	}
 
-	// 加密api_key
+	// api_key 由 secret 序列化器加密存储
 	apiKey := *req.ApiKey
-	appKey := utils.GetEnvWithDefault("API_KEY", "")
-	if appKey != "" {
-		apiKey = convertor.ToStdBase64(string(cryptor.AesGcmEncrypt([]byte(*req.ApiKey), []byte(appKey))))
-	}
 
 	// 创建模型
 	model := AIModel{
@@ -294,6 +306,9 @@ func (h *Handler) CreateAIModel(c *gin.Context) {
 		IsDefault:   req.IsDefault,
 		IsThinking:  req.IsThinking, // 新增字段：是否为思考型模型
 	}
+	if req.GenerationSettings != nil {
+		model.GenerationSettings = *req.GenerationSettings
+	}
 
 	if err := global.DB.Create(&model).Error; err != nil {
 		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
@@ -303,6 +318,7 @@ func (h *Handler) CreateAIModel(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceAIModel, model.ID, nil, model)
 
 	// 隐藏敏感信息
 	if model.ApiKey != nil && *model.ApiKey != "" {
@@ -350,7 +366,7 @@ func (h *Handler) UpdateAIModel(c *gin.Context) {
 
 	// 检查模型是否存在
 	var model AIModel
-	if err := global.DB.Where("user_id = ?", global.GetDooTaskUser(c).UserID).First(&model, id).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleEditor)).First(&model, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 				Success: false,
@@ -370,7 +386,7 @@ func (h *Handler) UpdateAIModel(c *gin.Context) {
 	// 检查名称是否冲突（如果更新了名称）
 	if req.Name != nil && *req.Name != model.Name {
 		var existingModel AIModel
-		if err := global.DB.Where("user_id = ? AND name = ? AND id != ?", global.GetDooTaskUser(c).UserID, *req.Name, id).First(&existingModel).Error; err == nil {
+		if err := global.DB.Where("user_id = ? AND name = ? AND id != ?", model.UserID, *req.Name, id).First(&existingModel).Error; err == nil {
 			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 				Success: false,
 				Error:   "AI模型名称已存在",
@@ -382,7 +398,7 @@ func (h *Handler) UpdateAIModel(c *gin.Context) {
 
 	// 如果设置为默认模型，需要将其他模型的默认状态取消
 	if req.IsDefault != nil && *req.IsDefault {
-		if err := global.DB.Model(&AIModel{}).Where("user_id = ? AND is_default = true AND id != ?", global.GetDooTaskUser(c).UserID, id).Update("is_default", false).Error; err != nil {
+		if err := global.DB.Model(&AIModel{}).Where("user_id = ? AND is_default = true AND id != ?", model.UserID, id).Update("is_default", false).Error; err != nil {
 			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 				Success: false,
 				Error:   "更新默认模型状态失败",
@@ -404,12 +420,17 @@ func (h *Handler) UpdateAIModel(c *gin.Context) {
 		updates["model_name"] = *req.ModelName
 	}
 	if req.ApiKey != nil {
-		apiKey := *req.ApiKey
-		appKey := utils.GetEnvWithDefault("API_KEY", "")
-		if appKey != "" {
-			apiKey = convertor.ToStdBase64(string(cryptor.AesGcmEncrypt([]byte(*req.ApiKey), []byte(appKey))))
+		// map更新不经过序列化器，需要手动加密
+		apiKey, err := secret.Encrypt(*req.ApiKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Success: false,
+				Error:   "加密API密钥失败",
+				Code:    "AI_MODEL_004",
+			})
+			return
 		}
-		updates["api_key"] = &apiKey
+		updates["api_key"] = apiKey
 	}
 	if req.BaseURL != nil {
 		updates["base_url"] = *req.BaseURL
@@ -432,8 +453,12 @@ func (h *Handler) UpdateAIModel(c *gin.Context) {
 	if req.IsThinking != nil {
 		updates["is_thinking"] = *req.IsThinking
 	}
+	if req.GenerationSettings != nil {
+		updates["generation_settings"] = *req.GenerationSettings
+	}
 
 	// 执行更新
+	before := model
 	if err := global.DB.Model(&model).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 			Success: false,
@@ -452,6 +477,7 @@ func (h *Handler) UpdateAIModel(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceAIModel, model.ID, before, model)
 
 	// 隐藏敏感信息
 	if model.ApiKey != nil && *model.ApiKey != "" {
@@ -479,7 +505,7 @@ func (h *Handler) DeleteAIModel(c *gin.Context) {
 
 	// 检查模型是否存在
 	var model AIModel
-	if err := global.DB.Where("user_id = ?", global.GetDooTaskUser(c).UserID).First(&model, id).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleOwner)).First(&model, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 				Success: false,
@@ -496,9 +522,9 @@ func (h *Handler) DeleteAIModel(c *gin.Context) {
 		return
 	}
 
-	// 检查是否有关联的智能体在使用
+	// 检查是否有关联的智能体在使用（包括共享用户的智能体）
 	var agentCount int64
-	if err := global.DB.Table("agents").Where("user_id = ? AND ai_model_id = ?", global.GetDooTaskUser(c).UserID, id).Count(&agentCount).Error; err != nil {
+	if err := global.DB.Table("agents").Where("ai_model_id = ?", id).Count(&agentCount).Error; err != nil {
 		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
 			Success: false,
 			Error:   "检查关联智能体失败",
@@ -525,6 +551,8 @@ func (h *Handler) DeleteAIModel(c *gin.Context) {
 		})
 		return
 	}
+	permission.RemoveShares(global.DB, permission.ResourceAIModel, model.ID)
+	audit.Record(c, audit.ActionDelete, audit.ResourceAIModel, model.ID, model, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -533,3 +561,434 @@ func (h *Handler) DeleteAIModel(c *gin.Context) {
 		},
 	})
 }
+
+// editableModel 获取当前用户可编辑的AI模型，失败时写入错误响应
+func editableModel(c *gin.Context) (*AIModel, bool) {
+	var model AIModel
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleEditor)).First(&model, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Success: false,
+				Error:   "AI模型不存在",
+				Code:    "AI_MODEL_002",
+			})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Success: false,
+				Error:   "查询AI模型失败",
+				Code:    "AI_MODEL_001",
+			})
+		}
+		return nil, false
+	}
+	return &model, true
+}
+
+// modelKey 获取模型下的密钥，失败时写入错误响应
+func modelKey(c *gin.Context, model *AIModel) (*AIModelKey, bool) {
+	var key AIModelKey
+	if err := global.DB.Where("id = ? AND ai_model_id = ?", c.Param("keyId"), model.ID).First(&key).Error; err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "密钥不存在",
+			Code:    "AI_MODEL_KEY_001",
+		})
+		return nil, false
+	}
+	return &key, true
+}
+
+// ListKeys 获取密钥池及健康状态
+func (h *Handler) ListKeys(c *gin.Context) {
+	model, ok := editableModel(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    KeyHealthList(model.ID),
+	})
+}
+
+// CreateKey 添加密钥
+func (h *Handler) CreateKey(c *gin.Context) {
+	model, ok := editableModel(c)
+	if !ok {
+		return
+	}
+
+	var req CreateAIModelKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "请求数据格式错误",
+			Code:    "FORMAT_001",
+		})
+		return
+	}
+	if err := global.Validator.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "数据验证失败: " + err.Error(),
+			Code:    "VALIDATION_001",
+		})
+		return
+	}
+
+	key := AIModelKey{
+		AIModelID: model.ID,
+		Name:      req.Name,
+		ApiKey:    req.ApiKey,
+		Weight:    max(req.Weight, 1),
+		RPMLimit:  req.RPMLimit,
+		TPMLimit:  req.TPMLimit,
+		IsActive:  true,
+	}
+	if err := global.DB.Create(&key).Error; err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "添加密钥失败",
+			Code:    "AI_MODEL_KEY_002",
+		})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceAIModelKey, key.ID, nil, key)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key.Masked(),
+	})
+}
+
+// UpdateKey 更新密钥
+func (h *Handler) UpdateKey(c *gin.Context) {
+	model, ok := editableModel(c)
+	if !ok {
+		return
+	}
+	key, ok := modelKey(c, model)
+	if !ok {
+		return
+	}
+
+	var req UpdateAIModelKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "请求数据格式错误",
+			Code:    "FORMAT_001",
+		})
+		return
+	}
+	if err := global.Validator.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "数据验证失败: " + err.Error(),
+			Code:    "VALIDATION_001",
+		})
+		return
+	}
+
+	before := *key
+	if req.Name != nil {
+		key.Name = *req.Name
+	}
+	if req.ApiKey != nil && *req.ApiKey != "" {
+		key.ApiKey = *req.ApiKey
+	}
+	if req.Weight != nil {
+		key.Weight = *req.Weight
+	}
+	if req.RPMLimit != nil {
+		key.RPMLimit = *req.RPMLimit
+	}
+	if req.TPMLimit != nil {
+		key.TPMLimit = *req.TPMLimit
+	}
+	if req.IsActive != nil {
+		key.IsActive = *req.IsActive
+	}
+
+	// 使用结构体保存，api_key 经过序列化器加密
+	if err := global.DB.Save(key).Error; err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "更新密钥失败",
+			Code:    "AI_MODEL_KEY_002",
+		})
+		return
+	}
+	if req.ApiKey != nil && *req.ApiKey != "" {
+		UnbenchKey(key.ID)
+	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceAIModelKey, key.ID, before, *key)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    key.Masked(),
+	})
+}
+
+// DeleteKey 删除密钥
+func (h *Handler) DeleteKey(c *gin.Context) {
+	model, ok := editableModel(c)
+	if !ok {
+		return
+	}
+	key, ok := modelKey(c, model)
+	if !ok {
+		return
+	}
+
+	if err := global.DB.Delete(key).Error; err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "删除密钥失败",
+			Code:    "AI_MODEL_KEY_002",
+		})
+		return
+	}
+	UnbenchKey(key.ID)
+	audit.Record(c, audit.ActionDelete, audit.ResourceAIModelKey, key.ID, *key, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"message": "密钥删除成功",
+		},
+	})
+}
+
+// ResetKey 手动解除密钥停用
+func (h *Handler) ResetKey(c *gin.Context) {
+	model, ok := editableModel(c)
+	if !ok {
+		return
+	}
+	key, ok := modelKey(c, model)
+	if !ok {
+		return
+	}
+
+	UnbenchKey(key.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"message": "密钥已恢复",
+		},
+	})
+}
+
+// discover 查询服务商可用模型并标记当前用户已添加的模型，失败时写入错误响应
+func discover(c *gin.Context, provider string, apiKey string, baseURL string, proxyURL string) ([]DiscoveredModel, bool) {
+	models, err := ListProviderModels(c.Request.Context(), provider, apiKey, baseURL, proxyURL)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "查询服务商模型列表失败: " + err.Error(),
+			Code:    "AI_MODEL_DISCOVER_001",
+		})
+		return nil, false
+	}
+
+	var configured []string
+	global.DB.Model(&AIModel{}).Where("user_id = ? AND provider = ?", global.GetDooTaskUser(c).UserID, provider).Pluck("model_name", &configured)
+	exists := make(map[string]bool, len(configured))
+	for _, name := range configured {
+		exists[name] = true
+	}
+	for i := range models {
+		models[i].Configured = exists[models[i].ID]
+	}
+	return models, true
+}
+
+// credentials 解析请求中的服务商凭据，未填写api_key时使用ai_model_id对应模型保存的凭据
+func credentials(c *gin.Context, req *DiscoverModelsRequest) (apiKey string, proxyURL string, ok bool) {
+	if req.ApiKey != nil {
+		apiKey = *req.ApiKey
+	}
+	if req.ProxyURL != nil {
+		proxyURL = *req.ProxyURL
+	}
+	if (apiKey == "" || apiKey == "***") && req.AIModelID != nil {
+		var saved AIModel
+		if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleEditor)).First(&saved, *req.AIModelID).Error; err != nil {
+			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Success: false,
+				Error:   "AI模型不存在",
+				Code:    "AI_MODEL_002",
+			})
+			return "", "", false
+		}
+		apiKey = ""
+		if saved.ApiKey != nil {
+			apiKey = *saved.ApiKey
+		}
+		if req.ProxyURL == nil && saved.ProxyURL != nil {
+			proxyURL = *saved.ProxyURL
+		}
+	}
+	return apiKey, proxyURL, true
+}
+
+// DiscoverByModel 使用已保存模型的凭据查询服务商可用模型
+func (h *Handler) DiscoverByModel(c *gin.Context) {
+	model, ok := editableModel(c)
+	if !ok {
+		return
+	}
+	if model.ApiKey == nil || *model.ApiKey == "" {
+		PickKey(model)
+	}
+
+	var apiKey, proxyURL string
+	if model.ApiKey != nil {
+		apiKey = *model.ApiKey
+	}
+	if model.ProxyURL != nil {
+		proxyURL = *model.ProxyURL
+	}
+	models, ok := discover(c, model.Provider, apiKey, model.BaseURL, proxyURL)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    models,
+	})
+}
+
+// DiscoverModels 使用填写的凭据查询服务商可用模型
+func (h *Handler) DiscoverModels(c *gin.Context) {
+	var req DiscoverModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "请求数据格式错误",
+			Code:    "FORMAT_001",
+		})
+		return
+	}
+	if err := global.Validator.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "数据验证失败: " + err.Error(),
+			Code:    "VALIDATION_001",
+		})
+		return
+	}
+
+	apiKey, proxyURL, ok := credentials(c, &req)
+	if !ok {
+		return
+	}
+	models, ok := discover(c, req.Provider, apiKey, req.BaseURL, proxyURL)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    models,
+	})
+}
+
+// BulkCreateAIModels 批量创建AI模型（名称已存在的模型会被跳过）
+func (h *Handler) BulkCreateAIModels(c *gin.Context) {
+	var req BulkCreateAIModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Success: false,
+			Error:   "请求数据格式错误",
+			Code:    "FORMAT_001",
+		})
+		return
+	}
+	if err := global.Validator.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Success: false,
+			Error:   "数据验证失败: " + err.Error(),
+			Code:    "VALIDATION_001",
+		})
+		return
+	}
+
+	apiKey, proxyURL, ok := credentials(c, &req.DiscoverModelsRequest)
+	if !ok {
+		return
+	}
+
+	userID := int64(global.GetDooTaskUser(c).UserID)
+	var existing []string
+	global.DB.Model(&AIModel{}).Where("user_id = ?", userID).Pluck("name", &existing)
+	names := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		names[name] = true
+	}
+
+	result := BulkCreateResult{Created: []AIModel{}, Skipped: []string{}}
+	var models []AIModel
+	for _, item := range req.Models {
+		name := item.Name
+		if name == "" {
+			name = item.ModelName
+		}
+		if names[name] {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+		names[name] = true
+
+		enabled := true
+		model := AIModel{
+			UserID:      userID,
+			Name:        name,
+			Provider:    req.Provider,
+			ModelName:   item.ModelName,
+			ApiKey:      &apiKey,
+			BaseURL:     req.BaseURL,
+			MaxTokens:   item.MaxTokens,
+			Temperature: item.Temperature,
+			IsEnabled:   &enabled,
+			IsThinking:  item.IsThinking,
+		}
+		if model.MaxTokens == 0 {
+			model.MaxTokens = 4000
+		}
+		if proxyURL != "" {
+			model.ProxyURL = &proxyURL
+		}
+		models = append(models, model)
+	}
+
+	if len(models) > 0 {
+		if err := global.DB.Create(&models).Error; err != nil {
+			c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+				Success: false,
+				Error:   "创建AI模型失败",
+				Code:    "AI_MODEL_004",
+			})
+			return
+		}
+	}
+
+	for _, model := range models {
+		audit.Record(c, audit.ActionCreate, audit.ResourceAIModel, model.ID, nil, model)
+
+		// 隐藏敏感信息
+		if model.ApiKey != nil && *model.ApiKey != "" {
+			masked := "***"
+			model.ApiKey = &masked
+		}
+		result.Created = append(result.Created, model)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    result,
+	})
+}
@@ -2,6 +2,8 @@ package aimodels
 
 import (
 	"time"
+
+	"dootask-ai/go-service/generation"
 )
 
 // AIModel AI模型数据结构
@@ -11,7 +13,7 @@ type AIModel struct {
 	Name        string    `json:"name" gorm:"type:varchar(255);not null" validate:"required,min=1,max=255"`
 	Provider    string    `json:"provider" gorm:"type:varchar(100);not null" validate:"required"`
 	ModelName   string    `json:"model_name" gorm:"type:varchar(255);not null" validate:"required,min=1,max=255"`
-	ApiKey      *string   `json:"api_key,omitempty" gorm:"type:text"`
+	ApiKey      *string   `json:"api_key,omitempty" gorm:"type:text;serializer:secret"`
 	BaseURL     string    `json:"base_url" gorm:"type:varchar(500)" validate:"omitempty,url"`
 	ProxyURL    *string   `json:"proxy_url,omitempty" gorm:"type:varchar(500)" validate:"omitempty,url"`
 	MaxTokens   int       `json:"max_tokens" gorm:"default:4000" validate:"min=1"`
@@ -22,9 +24,13 @@ type AIModel struct {
 	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
-	AgentCount        int64 `json:"agent_count" gorm:"-"`
-	ConversationCount int64 `json:"conversation_count" gorm:"-"`
-	TokenUsage        int64 `json:"token_usage" gorm:"-"`
+	// 模型默认生成参数（temperature、max_output_tokens 未设置时使用 Temperature、MaxTokens 字段）
+	GenerationSettings generation.Settings `json:"generation_settings" gorm:"type:jsonb;default:'{}'"`
+
+	AgentCount        int64       `json:"agent_count" gorm:"-"`
+	ConversationCount int64       `json:"conversation_count" gorm:"-"`
+	TokenUsage        int64       `json:"token_usage" gorm:"-"`
+	Keys              []KeyHealth `json:"keys,omitempty" gorm:"-"`
 }
 
 // TableName 设置表名
@@ -32,6 +38,78 @@ func (AIModel) TableName() string {
 	return "ai_models"
 }
 
+// Settings 模型层的默认生成参数（未单独设置时使用 Temperature 和 MaxTokens 字段）
+func (m AIModel) Settings() generation.Settings {
+	settings := m.GenerationSettings
+	if settings.Temperature == nil {
+		temperature := float64(m.Temperature)
+		settings.Temperature = &temperature
+	}
+	if settings.MaxOutputTokens == nil && m.MaxTokens > 0 {
+		maxTokens := m.MaxTokens
+		settings.MaxOutputTokens = &maxTokens
+	}
+	return settings
+}
+
+// AIModelKey 模型密钥池中的密钥
+type AIModelKey struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	AIModelID int64     `json:"ai_model_id" gorm:"column:ai_model_id;not null;index"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	ApiKey    string    `json:"api_key,omitempty" gorm:"type:text;not null;serializer:secret"`
+	Weight    int       `json:"weight" gorm:"not null;default:1"`
+	RPMLimit  int       `json:"rpm_limit" gorm:"column:rpm_limit;not null;default:0"` // 每分钟请求数上限，0表示不限制
+	TPMLimit  int       `json:"tpm_limit" gorm:"column:tpm_limit;not null;default:0"` // 每分钟令牌数上限，0表示不限制
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 设置表名
+func (AIModelKey) TableName() string {
+	return "ai_model_keys"
+}
+
+// Masked 隐藏密钥，只保留首尾字符
+func (k AIModelKey) Masked() AIModelKey {
+	if len(k.ApiKey) > 8 {
+		k.ApiKey = k.ApiKey[:4] + "***" + k.ApiKey[len(k.ApiKey)-4:]
+	} else if k.ApiKey != "" {
+		k.ApiKey = "***"
+	}
+	return k
+}
+
+// KeyHealth 密钥健康状态
+type KeyHealth struct {
+	AIModelKey
+	Status       string     `json:"status"` // healthy/limited/benched/inactive
+	CurrentRPM   int        `json:"current_rpm"`
+	CurrentTPM   int        `json:"current_tpm"`
+	LastError    string     `json:"last_error,omitempty"`
+	BenchedUntil *time.Time `json:"benched_until,omitempty"`
+}
+
+// CreateAIModelKeyRequest 添加密钥请求
+type CreateAIModelKeyRequest struct {
+	Name     string `json:"name" validate:"required,max=100"`
+	ApiKey   string `json:"api_key" validate:"required"`
+	Weight   int    `json:"weight" validate:"omitempty,min=1,max=100"`
+	RPMLimit int    `json:"rpm_limit" validate:"min=0"`
+	TPMLimit int    `json:"tpm_limit" validate:"min=0"`
+}
+
+// UpdateAIModelKeyRequest 更新密钥请求
+type UpdateAIModelKeyRequest struct {
+	Name     *string `json:"name" validate:"omitempty,max=100"`
+	ApiKey   *string `json:"api_key"`
+	Weight   *int    `json:"weight" validate:"omitempty,min=1,max=100"`
+	RPMLimit *int    `json:"rpm_limit" validate:"omitempty,min=0"`
+	TPMLimit *int    `json:"tpm_limit" validate:"omitempty,min=0"`
+	IsActive *bool   `json:"is_active"`
+}
+
 // CreateAIModelRequest 创建AI模型请求
 type CreateAIModelRequest struct {
 	Name        string  `json:"name" validate:"required,min=1,max=255"`
@@ -45,6 +123,8 @@ type CreateAIModelRequest struct {
 	IsEnabled   bool    `json:"is_enabled"`
 	IsDefault   bool    `json:"is_default"`
 	IsThinking  bool    `json:"is_thinking"` // 新增字段：是否为思考型模型
+
+	GenerationSettings *generation.Settings `json:"generation_settings"`
 }
 
 // UpdateAIModelRequest 更新AI模型请求
@@ -60,6 +140,57 @@ type UpdateAIModelRequest struct {
 	IsEnabled   *bool    `json:"is_enabled,omitempty"`
 	IsDefault   *bool    `json:"is_default,omitempty"`
 	IsThinking  *bool    `json:"is_thinking,omitempty"` // 新增字段：是否为思考型模型
+
+	GenerationSettings *generation.Settings `json:"generation_settings,omitempty"`
+}
+
+// 模型能力
+const (
+	CapabilityChat      = "chat"
+	CapabilityTools     = "tools"
+	CapabilityVision    = "vision"
+	CapabilityThinking  = "thinking"
+	CapabilityEmbedding = "embedding"
+)
+
+// DiscoveredModel 服务商返回的可用模型
+type DiscoveredModel struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	ContextWindow   int      `json:"context_window,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	Capabilities    []string `json:"capabilities"`
+	Configured      bool     `json:"configured"` // 当前用户是否已添加该模型
+}
+
+// DiscoverModelsRequest 查询服务商可用模型请求（未填写api_key时使用ai_model_id对应模型保存的凭据）
+type DiscoverModelsRequest struct {
+	Provider  string  `json:"provider" validate:"required"`
+	ApiKey    *string `json:"api_key"`
+	BaseURL   string  `json:"base_url" validate:"omitempty,url"`
+	ProxyURL  *string `json:"proxy_url"`
+	AIModelID *int64  `json:"ai_model_id"`
+}
+
+// BulkCreateAIModelsRequest 批量创建AI模型请求
+type BulkCreateAIModelsRequest struct {
+	DiscoverModelsRequest
+	Models []BulkAIModelItem `json:"models" validate:"required,min=1,max=100,dive"`
+}
+
+// BulkAIModelItem 批量创建的单个模型
+type BulkAIModelItem struct {
+	ModelName   string  `json:"model_name" validate:"required,min=1,max=255"`
+	Name        string  `json:"name" validate:"omitempty,max=255"`
+	MaxTokens   int     `json:"max_tokens" validate:"omitempty,min=1"`
+	Temperature float32 `json:"temperature" validate:"min=0,max=2"`
+	IsThinking  bool    `json:"is_thinking"`
+}
+
+// BulkCreateResult 批量创建结果
+type BulkCreateResult struct {
+	Created []AIModel `json:"created"`
+	Skipped []string  `json:"skipped"` // 名称已存在而跳过的模型
 }
 
 // AIModelFilters AI模型筛选条件
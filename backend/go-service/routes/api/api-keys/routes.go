@@ -0,0 +1,179 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dootask-ai/go-service/global"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// RegisterRoutes 注册API密钥路由
+func RegisterRoutes(router *gin.RouterGroup) {
+	keyGroup := router.Group("/api-keys")
+	{
+		keyGroup.GET("", ListAPIKeys)         // 获取我的API密钥列表
+		keyGroup.POST("", CreateAPIKey)       // 创建API密钥
+		keyGroup.DELETE("/:id", DeleteAPIKey) // 删除API密钥
+	}
+}
+
+// ListAPIKeys 获取当前用户的API密钥列表
+func ListAPIKeys(c *gin.Context) {
+	items := []APIKey{}
+	if err := global.DB.
+		Where("user_id = ?", global.GetDooTaskUser(c).UserID).
+		Order("id DESC").
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询API密钥失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIKeyListData{Items: items})
+}
+
+// CreateAPIKey 创建API密钥，明文密钥只在创建时返回
+func CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "过期时间不能早于当前时间",
+			"data":    nil,
+		})
+		return
+	}
+
+	raw, err := GenerateKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "API_KEY_001",
+			"message": "生成API密钥失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	key := APIKey{
+		UserID:    int64(global.GetDooTaskUser(c).UserID),
+		Name:      req.Name,
+		KeyPrefix: raw[:10],
+		KeyHash:   HashKey(raw),
+		ExpiresAt: req.ExpiresAt,
+		IsActive:  true,
+	}
+	if err := global.DB.Create(&key).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "创建API密钥失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{
+		APIKey: key,
+		Key:    raw,
+	})
+}
+
+// DeleteAPIKey 删除API密钥
+func DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的API密钥ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	result := global.DB.
+		Where("id = ? AND user_id = ?", id, global.GetDooTaskUser(c).UserID).
+		Delete(&APIKey{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除API密钥失败",
+			"data":    nil,
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "API_KEY_002",
+			"message": "API密钥不存在",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API密钥删除成功",
+	})
+}
+
+// GenerateKey 生成新的明文API密钥
+func GenerateKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return KeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// HashKey 计算API密钥的SHA-256哈希
+func HashKey(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate 校验明文API密钥，成功时更新最后使用时间
+func Authenticate(raw string) (*APIKey, error) {
+	var key APIKey
+	if err := global.DB.Where("key_hash = ? AND is_active = ?", HashKey(raw), true).First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("invalid api key")
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && key.ExpiresAt.Before(now) {
+		return nil, errors.New("api key expired")
+	}
+
+	global.DB.Model(&key).UpdateColumn("last_used_at", now)
+	key.LastUsedAt = &now
+	return &key, nil
+}
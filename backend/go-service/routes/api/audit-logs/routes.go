@@ -0,0 +1,145 @@
+package auditlogs
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// RegisterRoutes 注册操作日志路由
+func RegisterRoutes(router *gin.RouterGroup) {
+	auditGroup := router.Group("/audit-logs")
+	{
+		auditGroup.GET("", ListAuditLogs) // 获取操作日志列表
+	}
+}
+
+// ListAuditLogs 获取操作日志列表（管理员可查看全部，其他用户只能查看自己的操作）
+func ListAuditLogs(c *gin.Context) {
+	var req utils.PaginationRequest
+
+	// 绑定查询参数
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "查询参数格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 设置默认排序
+	req.SetDefaultSorts(map[string]bool{
+		"created_at": true,
+		"id":         true,
+	})
+
+	// 验证参数
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "查询参数验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 解析筛选条件
+	var filters AuditLogFilters
+	if err := req.ParseFiltersFromQuery(c, &filters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "筛选条件解析失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 验证排序字段
+	allowedFields := GetAllowedSortFields()
+	for _, sort := range req.Sorts {
+		if !utils.ValidateSortField(sort.Key, allowedFields) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    "VALIDATION_001",
+				"message": "无效的排序字段: " + sort.Key,
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	// 构建查询
+	query := global.DB.Model(&audit.AuditLog{})
+
+	user := global.GetDooTaskUser(c)
+	if slices.Contains(user.Identity, "admin") {
+		if filters.UserID != "" {
+			query = query.Where("user_id = ?", filters.UserID)
+		}
+	} else {
+		query = query.Where("user_id = ?", strconv.Itoa(user.UserID))
+	}
+	if filters.Action != "" {
+		query = query.Where("action = ?", filters.Action)
+	}
+	if filters.ResourceType != "" {
+		query = query.Where("resource_type = ?", filters.ResourceType)
+	}
+	if filters.ResourceID != "" {
+		query = query.Where("resource_id = ?", filters.ResourceID)
+	}
+	if filters.StartDate != nil && *filters.StartDate != "" {
+		if startTime, err := time.Parse("2006-01-02", *filters.StartDate); err == nil {
+			query = query.Where("created_at >= ?", startTime)
+		}
+	}
+	if filters.EndDate != nil && *filters.EndDate != "" {
+		if endTime, err := time.Parse("2006-01-02", *filters.EndDate); err == nil {
+			endTime = endTime.Add(23*time.Hour + 59*time.Minute + 59*time.Second)
+			query = query.Where("created_at <= ?", endTime)
+		}
+	}
+
+	// 获取总数
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询操作日志总数失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 分页查询
+	var logs []audit.AuditLog
+	if err := query.
+		Order(req.GetOrderBy()).
+		Limit(req.PageSize).
+		Offset(req.GetOffset()).
+		Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询操作日志失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	data := AuditLogListData{
+		Items: logs,
+	}
+
+	// 使用统一分页响应格式
+	response := utils.NewPaginationResponse(req.Page, req.PageSize, total, data)
+	c.JSON(http.StatusOK, response)
+}
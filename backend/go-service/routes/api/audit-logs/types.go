@@ -0,0 +1,23 @@
+package auditlogs
+
+import "dootask-ai/go-service/audit"
+
+// AuditLogFilters 操作日志筛选条件
+type AuditLogFilters struct {
+	Action       string  `json:"action" form:"action"`               // 操作类型过滤
+	ResourceType string  `json:"resource_type" form:"resource_type"` // 资源类型过滤
+	ResourceID   string  `json:"resource_id" form:"resource_id"`     // 资源ID过滤
+	UserID       string  `json:"user_id" form:"user_id"`             // 操作人过滤（仅管理员可用）
+	StartDate    *string `json:"start_date" form:"start_date"`       // 开始日期
+	EndDate      *string `json:"end_date" form:"end_date"`           // 结束日期
+}
+
+// AuditLogListData 操作日志列表数据
+type AuditLogListData struct {
+	Items []audit.AuditLog `json:"items"`
+}
+
+// GetAllowedSortFields 获取操作日志允许的排序字段
+func GetAllowedSortFields() []string {
+	return []string{"id", "action", "resource_type", "created_at"}
+}
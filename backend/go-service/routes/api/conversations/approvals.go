@@ -0,0 +1,144 @@
+package conversations
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/global"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 审批状态
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+	ApprovalExpired  = "expired"
+)
+
+// ApprovalTTL 待审批调用的有效期，超时后按拒绝处理
+const ApprovalTTL = 24 * time.Hour
+
+// 批准时回复的内容，其他回复均视为拒绝
+var approveReplies = []string{"同意", "确认", "批准", "approve", "yes", "y", "ok"}
+
+// ToolApproval 工具调用审批记录
+type ToolApproval struct {
+	ID             int64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	ConversationID int64           `gorm:"column:conversation_id;not null" json:"conversation_id"`
+	ThreadID       string          `gorm:"type:varchar(255)" json:"thread_id"` // 发起调用的会话线程
+	AgentID        *int64          `json:"agent_id"`
+	FunctionName   string          `gorm:"type:varchar(255);not null" json:"function_name"`
+	Arguments      json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"arguments"` // 已脱敏
+	Status         string          `gorm:"type:varchar(20);not null;default:pending" json:"status"`
+	Reply          *string         `gorm:"type:text" json:"reply"`
+	DecidedBy      *string         `gorm:"type:varchar(255)" json:"decided_by"`
+	DecidedAt      *time.Time      `json:"decided_at"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ToolApproval) TableName() string {
+	return "tool_approvals"
+}
+
+// IsApproveReply 判断用户回复是否为批准
+func IsApproveReply(reply string) bool {
+	reply = strings.ToLower(strings.Trim(strings.TrimSpace(reply), "。.!！"))
+	for _, item := range approveReplies {
+		if reply == item {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveApprovals 用用户的回复处理线程中待审批的调用，返回转发给AI服务的统一回复（没有待审批调用时返回原内容）
+// 只处理同一线程的调用；超过有效期的调用标记为过期并按拒绝处理
+func ResolveApprovals(conversationID int64, threadID string, userID string, reply string) string {
+	if threadID == "" {
+		return reply
+	}
+	var pending []ToolApproval
+	global.DB.Where("conversation_id = ? AND thread_id = ? AND status = ?", conversationID, threadID, ApprovalPending).Find(&pending)
+	if len(pending) == 0 {
+		return reply
+	}
+
+	now := time.Now()
+	deadline := now.Add(-ApprovalTTL)
+	expired := !slices.ContainsFunc(pending, func(a ToolApproval) bool { return a.CreatedAt.After(deadline) })
+
+	status, normalized := ApprovalRejected, "拒绝"
+	if !expired && IsApproveReply(reply) {
+		status, normalized = ApprovalApproved, "同意"
+	}
+	decide := func(status string, query string, args ...interface{}) {
+		global.DB.Model(&ToolApproval{}).
+			Where("conversation_id = ? AND thread_id = ? AND status = ?", conversationID, threadID, ApprovalPending).
+			Where(query, args...).
+			Updates(map[string]interface{}{
+				"status":     status,
+				"reply":      reply,
+				"decided_by": userID,
+				"decided_at": now,
+			})
+	}
+	decide(ApprovalExpired, "created_at <= ?", deadline)
+	decide(status, "created_at > ?", deadline)
+	return normalized
+}
+
+// ListApprovals 获取对话的工具调用审批记录
+func ListApprovals(c *gin.Context) {
+	conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的对话ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 只能查看自己的对话
+	var conversationCount int64
+	if err := global.DB.Model(&Conversation{}).
+		Where("id = ? AND dootask_user_id = ?", conversationID, strconv.Itoa(int(global.GetDooTaskUser(c).UserID))).
+		Count(&conversationCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询对话失败",
+			"data":    nil,
+		})
+		return
+	}
+	if conversationCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "CONVERSATION_001",
+			"message": "对话不存在",
+			"data":    nil,
+		})
+		return
+	}
+
+	var approvals []ToolApproval
+	if err := global.DB.Where("conversation_id = ?", conversationID).Order("id DESC").Find(&approvals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询审批记录失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": approvals,
+	})
+}
@@ -2,6 +2,7 @@ package conversations
 
 import (
 	"database/sql"
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
@@ -22,10 +23,12 @@ func RegisterRoutes(router *gin.RouterGroup) {
 	// 对话管理路由
 	conversationGroup := router.Group("/conversations")
 	{
-		conversationGroup.GET("", ListConversations)          // 获取对话列表
-		conversationGroup.GET("/:id", GetConversation)        // 获取对话详情
-		conversationGroup.GET("/:id/messages", GetMessages)   // 获取对话消息
-		conversationGroup.GET("/stats", GetConversationStats) // 获取对话统计
+		conversationGroup.GET("", ListConversations)                                     // 获取对话列表
+		conversationGroup.GET("/:id", GetConversation)                                   // 获取对话详情
+		conversationGroup.GET("/:id/messages", GetMessages)                              // 获取对话消息
+		conversationGroup.POST("/:id/messages/:message_id/feedback", SetMessageFeedback) // 评价AI回复
+		conversationGroup.GET("/:id/approvals", ListApprovals)                           // 获取工具调用审批记录
+		conversationGroup.GET("/stats", GetConversationStats)                            // 获取对话统计
 	}
 }
 
@@ -441,6 +444,76 @@ func GetMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SetMessageFeedback 评价AI回复（写入消息元数据，用于效果统计）
+func SetMessageFeedback(c *gin.Context) {
+	conversationID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的对话ID",
+			"data":    nil,
+		})
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的消息ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	feedback, _ := json.Marshal(map[string]interface{}{
+		"rating":  req.Rating,
+		"comment": req.Comment,
+		"user_id": global.GetDooTaskUser(c).UserID,
+	})
+	result := global.DB.Model(&Message{}).
+		Where("id = ? AND conversation_id = ? AND role = ?", messageID, conversationID, "assistant").
+		Update("metadata", gorm.Expr("jsonb_set(COALESCE(metadata, '{}'::jsonb), '{feedback}', ?::jsonb)", string(feedback)))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "保存评价失败",
+			"data":    nil,
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "CONVERSATION_002",
+			"message": "消息不存在",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "评价成功",
+	})
+}
+
 // GetConversationStats 获取对话统计信息
 func GetConversationStats(c *gin.Context) {
 	stats := calculateConversationStatistics(int64(global.GetDooTaskUser(c).UserID))
@@ -76,6 +76,12 @@ type MessageFilters struct {
 	Role string `json:"role" form:"role" validate:"omitempty,oneof=user assistant system"` // 角色过滤
 }
 
+// MessageFeedbackRequest 消息评价请求
+type MessageFeedbackRequest struct {
+	Rating  string `json:"rating" validate:"required,oneof=up down"` // up: 有帮助，down: 没帮助
+	Comment string `json:"comment" validate:"omitempty,max=500"`
+}
+
 // ConversationListData 对话列表数据结构
 type ConversationListData struct {
 	Items      []Conversation         `json:"items"`
@@ -0,0 +1,189 @@
+package evaluations
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// jsonBlockRegex 匹配Markdown中的JSON代码块
+var jsonBlockRegex = regexp.MustCompile("(?s)```(?:json)?\\s*(.*?)```")
+
+// judgeFunc 大模型评判函数，返回是否通过和理由
+type judgeFunc func(question, answer, rubric string) (bool, string, error)
+
+// runChecks 执行用例的全部检查项，全部通过时用例通过
+func runChecks(checks []Check, question, answer string, judge judgeFunc) ([]CheckResult, bool) {
+	results := make([]CheckResult, 0, len(checks))
+	passed := true
+	for _, check := range checks {
+		result := runCheck(check, question, answer, judge)
+		if !result.Passed {
+			passed = false
+		}
+		results = append(results, result)
+	}
+	return results, passed
+}
+
+// runCheck 执行单个检查项
+func runCheck(check Check, question, answer string, judge judgeFunc) CheckResult {
+	result := CheckResult{Type: check.Type}
+	switch check.Type {
+	case CheckContains, CheckNotContains:
+		text, value := answer, check.Value
+		if check.IgnoreCase {
+			text, value = strings.ToLower(text), strings.ToLower(value)
+		}
+		found := strings.Contains(text, value)
+		result.Passed = found == (check.Type == CheckContains)
+		if !result.Passed {
+			if found {
+				result.Detail = fmt.Sprintf("回答中不应包含: %s", check.Value)
+			} else {
+				result.Detail = fmt.Sprintf("回答中未包含: %s", check.Value)
+			}
+		}
+	case CheckRegex:
+		re, err := regexp.Compile(check.Value)
+		if err != nil {
+			result.Detail = "无效的正则表达式: " + err.Error()
+			return result
+		}
+		result.Passed = re.MatchString(answer)
+		if !result.Passed {
+			result.Detail = fmt.Sprintf("回答不匹配: %s", check.Value)
+		}
+	case CheckJSONSchema:
+		var schema map[string]any
+		if err := json.Unmarshal(check.Schema, &schema); err != nil {
+			result.Detail = "无效的JSON Schema: " + err.Error()
+			return result
+		}
+		value, err := extractJSON(answer)
+		if err != nil {
+			result.Detail = err.Error()
+			return result
+		}
+		if err := validateSchema(schema, value, "$"); err != nil {
+			result.Detail = err.Error()
+			return result
+		}
+		result.Passed = true
+	case CheckLLMJudge:
+		if judge == nil {
+			result.Detail = "未配置评判模型"
+			return result
+		}
+		passed, reason, err := judge(question, answer, check.Rubric)
+		if err != nil {
+			result.Detail = "评判失败: " + err.Error()
+			return result
+		}
+		result.Passed = passed
+		result.Detail = reason
+	default:
+		result.Detail = "未知的检查类型: " + check.Type
+	}
+	return result
+}
+
+// extractJSON 从回答中提取JSON（整段、代码块或首个对象/数组）
+func extractJSON(answer string) (any, error) {
+	candidates := []string{strings.TrimSpace(answer)}
+	if m := jsonBlockRegex.FindStringSubmatch(answer); len(m) > 1 {
+		candidates = append(candidates, strings.TrimSpace(m[1]))
+	}
+	for _, pair := range [][2]string{{"{", "}"}, {"[", "]"}} {
+		start, end := strings.Index(answer, pair[0]), strings.LastIndex(answer, pair[1])
+		if start >= 0 && end > start {
+			candidates = append(candidates, answer[start:end+1])
+		}
+	}
+
+	for _, candidate := range candidates {
+		var value any
+		if json.Unmarshal([]byte(candidate), &value) == nil {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("回答中未找到有效的JSON")
+}
+
+// validateSchema 按JSON Schema常用子集（type/required/properties/items/enum/minItems）校验
+func validateSchema(schema map[string]any, value any, path string) error {
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(item any) bool { return fmt.Sprint(item) == fmt.Sprint(value) }) {
+			return fmt.Errorf("%s 的值不在枚举范围内", path)
+		}
+	}
+
+	if typ, ok := schema["type"].(string); ok && !matchType(typ, value) {
+		return fmt.Errorf("%s 应为 %s 类型", path, typ)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, key := range required {
+				if _, exists := v[fmt.Sprint(key)]; !exists {
+					return fmt.Errorf("%s 缺少必填字段 %v", path, key)
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			for key, sub := range properties {
+				subSchema, ok := sub.(map[string]any)
+				if !ok {
+					continue
+				}
+				if field, exists := v[key]; exists {
+					if err := validateSchema(subSchema, field, path+"."+key); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case []any:
+		if minItems, ok := schema["minItems"].(float64); ok && len(v) < int(minItems) {
+			return fmt.Errorf("%s 至少需要 %d 项", path, int(minItems))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchType 判断值是否符合JSON Schema类型
+func matchType(typ string, value any) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == float64(int64(n))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
@@ -0,0 +1,615 @@
+package evaluations
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/routes/api/agents"
+	"dootask-ai/go-service/routes/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// RegisterRoutes 注册智能体评测路由
+func RegisterRoutes(router *gin.RouterGroup) {
+	evalGroup := router.Group("/evaluations")
+	{
+		evalGroup.GET("/suites", ListSuites)                       // 获取评测集列表
+		evalGroup.POST("/suites", CreateSuite)                     // 创建评测集
+		evalGroup.GET("/suites/:id", GetSuite)                     // 获取评测集详情（含用例）
+		evalGroup.PUT("/suites/:id", UpdateSuite)                  // 更新评测集
+		evalGroup.DELETE("/suites/:id", DeleteSuite)               // 删除评测集
+		evalGroup.POST("/suites/:id/cases", CreateCase)            // 添加用例
+		evalGroup.PUT("/suites/:id/cases/:case_id", UpdateCase)    // 更新用例
+		evalGroup.DELETE("/suites/:id/cases/:case_id", DeleteCase) // 删除用例
+		evalGroup.POST("/suites/:id/runs", StartRun)               // 发起评测
+		evalGroup.GET("/suites/:id/runs", ListRuns)                // 获取评测运行列表
+		evalGroup.GET("/runs/compare", CompareRuns)                // 对比两次评测运行
+		evalGroup.GET("/runs/:id", GetRun)                         // 获取评测运行详情（含逐用例结果）
+	}
+}
+
+// ListSuites 获取有权查看的智能体的评测集列表
+func ListSuites(c *gin.Context) {
+	query, args := permission.Condition(c, permission.ResourceAgent, permission.RoleViewer)
+	db := global.DB.Where("agent_id IN (SELECT agents.id FROM agents WHERE "+query+")", args...)
+	if agentID, err := strconv.ParseInt(c.Query("agent_id"), 10, 64); err == nil && agentID > 0 {
+		db = db.Where("agent_id = ?", agentID)
+	}
+
+	suites := []EvalSuite{}
+	if err := db.Order("id DESC").Find(&suites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询评测集失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	for i := range suites {
+		global.DB.Model(&EvalCase{}).Where("suite_id = ?", suites[i].ID).Count(&suites[i].CaseCount)
+		var lastRun EvalRun
+		if err := global.DB.Where("suite_id = ?", suites[i].ID).Order("id DESC").First(&lastRun).Error; err == nil {
+			suites[i].LastRun = &lastRun
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": suites})
+}
+
+// CreateSuite 创建评测集（需要智能体编辑权限）
+func CreateSuite(c *gin.Context) {
+	var req CreateSuiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	if !permission.Can(c, permission.ResourceAgent, req.AgentID, permission.RoleEditor) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "AGENT_002",
+			"message": "智能体不存在或无编辑权限",
+			"data":    nil,
+		})
+		return
+	}
+	if !checkJudgeModel(c, req.JudgeModelID) {
+		return
+	}
+	if req.JudgeModelID != nil && *req.JudgeModelID == 0 {
+		req.JudgeModelID = nil
+	}
+
+	suite := EvalSuite{
+		UserID:       int64(global.GetDooTaskUser(c).UserID),
+		AgentID:      req.AgentID,
+		Name:         req.Name,
+		Description:  req.Description,
+		JudgeModelID: req.JudgeModelID,
+	}
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&suite).Error; err != nil {
+			return err
+		}
+		for _, item := range req.Cases {
+			evalCase := newCase(suite.ID, item)
+			if err := tx.Create(&evalCase).Error; err != nil {
+				return err
+			}
+			suite.Cases = append(suite.Cases, evalCase)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "创建评测集失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	suite.CaseCount = int64(len(suite.Cases))
+	c.JSON(http.StatusCreated, suite)
+}
+
+// GetSuite 获取评测集详情
+func GetSuite(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleViewer)
+	if !ok {
+		return
+	}
+
+	global.DB.Where("suite_id = ?", suite.ID).Order("sort_order ASC, id ASC").Find(&suite.Cases)
+	suite.CaseCount = int64(len(suite.Cases))
+	c.JSON(http.StatusOK, suite)
+}
+
+// UpdateSuite 更新评测集
+func UpdateSuite(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req UpdateSuiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+	if !checkJudgeModel(c, req.JudgeModelID) {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.JudgeModelID != nil {
+		if *req.JudgeModelID == 0 {
+			updates["judge_model_id"] = nil
+		} else {
+			updates["judge_model_id"] = *req.JudgeModelID
+		}
+	}
+	if len(updates) > 0 {
+		if err := global.DB.Model(suite).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_002",
+				"message": "更新评测集失败",
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	global.DB.First(suite, suite.ID)
+	c.JSON(http.StatusOK, suite)
+}
+
+// DeleteSuite 删除评测集（用例和运行记录级联删除）
+func DeleteSuite(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := global.DB.Delete(suite).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除评测集失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "评测集删除成功",
+	})
+}
+
+// CreateCase 添加评测用例
+func CreateCase(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	req, ok := bindCase(c)
+	if !ok {
+		return
+	}
+
+	evalCase := newCase(suite.ID, *req)
+	if err := global.DB.Create(&evalCase).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "添加评测用例失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, evalCase)
+}
+
+// UpdateCase 更新评测用例
+func UpdateCase(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	evalCase, ok := findCase(c, suite.ID)
+	if !ok {
+		return
+	}
+	req, ok := bindCase(c)
+	if !ok {
+		return
+	}
+
+	updated := newCase(suite.ID, *req)
+	if err := global.DB.Model(evalCase).Updates(map[string]interface{}{
+		"question":   updated.Question,
+		"checks":     updated.Checks,
+		"sort_order": updated.SortOrder,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "更新评测用例失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	global.DB.First(evalCase, evalCase.ID)
+	c.JSON(http.StatusOK, evalCase)
+}
+
+// DeleteCase 删除评测用例
+func DeleteCase(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	evalCase, ok := findCase(c, suite.ID)
+	if !ok {
+		return
+	}
+
+	if err := global.DB.Delete(evalCase).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除评测用例失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "评测用例删除成功",
+	})
+}
+
+// StartRun 发起评测运行（后台执行，可临时覆盖提示词、模型和温度）
+func StartRun(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req StartRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	var agent agents.Agent
+	if err := global.DB.First(&agent, suite.AgentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "AGENT_002",
+			"message": "智能体不存在",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 临时模型需要有使用权限
+	modelID := agent.AIModelID
+	if req.AIModelID != nil {
+		if !permission.Can(c, permission.ResourceAIModel, *req.AIModelID, permission.RoleViewer) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"code":    "AI_MODEL_001",
+				"message": "AI模型不存在",
+				"data":    nil,
+			})
+			return
+		}
+		modelID = req.AIModelID
+	}
+	aiModel, err := loadModel(modelID)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "AI_MODEL_001",
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+	if req.Prompt != nil {
+		agent.Prompt = *req.Prompt
+	}
+	if req.Temperature != nil {
+		agent.GenerationSettings.Temperature = req.Temperature
+	}
+
+	userID := int64(global.GetDooTaskUser(c).UserID)
+	run, err := newRun(*suite, agent, aiModel, userID, req.Label)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "EVAL_003",
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	opts := service.AIRequestOptions{
+		UserID:  userID,
+		BaseURL: c.GetString("host"),
+	}
+	if client := global.GetDooTaskClient(c); client != nil {
+		opts.UserToken = client.Token
+	}
+	go ExecuteRun(run, *suite, agent, aiModel, opts)
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// ListRuns 获取评测集的运行记录
+func ListRuns(c *gin.Context) {
+	suite, ok := findSuite(c, permission.RoleViewer)
+	if !ok {
+		return
+	}
+
+	runs := []EvalRun{}
+	if err := global.DB.Where("suite_id = ?", suite.ID).Order("id DESC").Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询评测运行失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": runs})
+}
+
+// GetRun 获取评测运行详情
+func GetRun(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的评测运行ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	run, ok := findRun(c, id)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// CompareRuns 对比两次评测运行的逐用例结果
+func CompareRuns(c *gin.Context) {
+	var req CompareRunsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "查询参数格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请指定要对比的两次评测运行",
+			"data":    nil,
+		})
+		return
+	}
+
+	base, ok := findRun(c, req.BaseRunID)
+	if !ok {
+		return
+	}
+	target, ok := findRun(c, req.TargetRunID)
+	if !ok {
+		return
+	}
+
+	response := CompareRunsResponse{Base: *base, Target: *target, Cases: []CaseCompare{}}
+	targetResults := map[int64]*EvalResult{}
+	for i := range target.Results {
+		targetResults[target.Results[i].CaseID] = &target.Results[i]
+	}
+
+	for i := range base.Results {
+		baseResult := &base.Results[i]
+		item := CaseCompare{CaseID: baseResult.CaseID, Question: baseResult.Question, Base: baseResult, Change: "removed"}
+		if targetResult, exists := targetResults[baseResult.CaseID]; exists {
+			item.Target = targetResult
+			item.LatencyDelta = targetResult.LatencyMs - baseResult.LatencyMs
+			item.TokensDelta = (targetResult.InputTokens + targetResult.OutputTokens) - (baseResult.InputTokens + baseResult.OutputTokens)
+			switch {
+			case !baseResult.Passed && targetResult.Passed:
+				item.Change = "improved"
+				response.Improved++
+			case baseResult.Passed && !targetResult.Passed:
+				item.Change = "regressed"
+				response.Regressed++
+			default:
+				item.Change = "unchanged"
+			}
+			delete(targetResults, baseResult.CaseID)
+		}
+		response.Cases = append(response.Cases, item)
+	}
+	for i := range target.Results {
+		if targetResult, exists := targetResults[target.Results[i].CaseID]; exists {
+			response.Cases = append(response.Cases, CaseCompare{CaseID: targetResult.CaseID, Question: targetResult.Question, Target: targetResult, Change: "added"})
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// findSuite 查询评测集并校验智能体权限，失败时直接写入响应
+func findSuite(c *gin.Context, role permission.Role) (*EvalSuite, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的评测集ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	var suite EvalSuite
+	if err := global.DB.First(&suite, id).Error; err != nil || !permission.Can(c, permission.ResourceAgent, suite.AgentID, role) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "EVAL_001",
+			"message": "评测集不存在或无权访问",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	return &suite, true
+}
+
+// findCase 查询评测集下的用例，失败时直接写入响应
+func findCase(c *gin.Context, suiteID int64) (*EvalCase, bool) {
+	caseID, err := strconv.ParseInt(c.Param("case_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的用例ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	var evalCase EvalCase
+	if err := global.DB.Where("id = ? AND suite_id = ?", caseID, suiteID).First(&evalCase).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "EVAL_002",
+			"message": "评测用例不存在",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	return &evalCase, true
+}
+
+// findRun 查询评测运行（含结果）并校验智能体查看权限，失败时直接写入响应
+func findRun(c *gin.Context, id int64) (*EvalRun, bool) {
+	var run EvalRun
+	if err := global.DB.Preload("Results", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&run, id).Error; err != nil || !permission.Can(c, permission.ResourceAgent, run.AgentID, permission.RoleViewer) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "EVAL_004",
+			"message": "评测运行不存在或无权访问",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	return &run, true
+}
+
+// bindCase 绑定并验证用例请求，失败时直接写入响应
+func bindCase(c *gin.Context) (*CaseRequest, bool) {
+	var req CaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return nil, false
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+// checkJudgeModel 校验评判模型的使用权限（0 表示清除），失败时直接写入响应
+func checkJudgeModel(c *gin.Context, id *int64) bool {
+	if id == nil || *id == 0 {
+		return true
+	}
+	if !permission.Can(c, permission.ResourceAIModel, *id, permission.RoleViewer) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "AI_MODEL_001",
+			"message": "评判模型不存在",
+			"data":    nil,
+		})
+		return false
+	}
+	return true
+}
+
+// newCase 根据请求构建评测用例
+func newCase(suiteID int64, req CaseRequest) EvalCase {
+	checks, _ := json.Marshal(req.Checks)
+	return EvalCase{
+		SuiteID:   suiteID,
+		Question:  req.Question,
+		Checks:    checks,
+		SortOrder: req.SortOrder,
+	}
+}
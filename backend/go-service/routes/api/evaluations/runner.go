@@ -0,0 +1,259 @@
+package evaluations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"dootask-ai/go-service/generation"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/routes/api/agents"
+	aimodels "dootask-ai/go-service/routes/api/ai-models"
+	"dootask-ai/go-service/routes/service"
+)
+
+// judgePrompt 大模型评判提示词
+const judgePrompt = `你是一名严格的评测员。根据给出的评分标准判断回答是否合格。
+只输出JSON，不要输出其他内容，格式为：{"pass": true或false, "reason": "简要理由"}`
+
+// loadModel 查询已启用的AI模型
+func loadModel(id *int64) (aimodels.AIModel, error) {
+	var aiModel aimodels.AIModel
+	if id == nil {
+		return aiModel, errors.New("未配置AI模型")
+	}
+	if err := global.DB.Where("id = ?", *id).First(&aiModel).Error; err != nil {
+		return aiModel, errors.New("AI模型不存在")
+	}
+	if aiModel.IsEnabled == nil || !*aiModel.IsEnabled {
+		return aiModel, errors.New("AI模型未启用")
+	}
+	return aiModel, nil
+}
+
+// newRun 创建运行记录并保存智能体配置快照
+func newRun(suite EvalSuite, agent agents.Agent, aiModel aimodels.AIModel, userID int64, label *string) (*EvalRun, error) {
+	var total int64
+	global.DB.Model(&EvalCase{}).Where("suite_id = ?", suite.ID).Count(&total)
+	if total == 0 {
+		return nil, errors.New("评测集没有用例")
+	}
+
+	snapshot, _ := json.Marshal(RunSnapshot{
+		Prompt:      agent.Prompt,
+		AIModelID:   aiModel.ID,
+		ModelName:   aiModel.ModelName,
+		Temperature: float32(*generation.Resolve(aiModel.Settings(), agent.Settings()).Temperature),
+	})
+	run := &EvalRun{
+		SuiteID:    suite.ID,
+		AgentID:    agent.ID,
+		UserID:     userID,
+		Label:      label,
+		Status:     RunStatusPending,
+		Snapshot:   snapshot,
+		TotalCases: int(total),
+	}
+	if err := global.DB.Create(run).Error; err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// ExecuteRun 依次通过智能体执行全部用例，写入逐用例结果并汇总
+func ExecuteRun(run *EvalRun, suite EvalSuite, agent agents.Agent, aiModel aimodels.AIModel, opts service.AIRequestOptions) {
+	startedAt := time.Now()
+	global.DB.Model(run).Updates(map[string]interface{}{
+		"status":     RunStatusRunning,
+		"started_at": startedAt,
+	})
+
+	var cases []EvalCase
+	if err := global.DB.Where("suite_id = ?", suite.ID).Order("sort_order ASC, id ASC").Find(&cases).Error; err != nil {
+		finishRun(run, RunStatusFailed, err.Error())
+		return
+	}
+
+	// 评判模型默认使用被测模型
+	judgeModel := aiModel
+	if suite.JudgeModelID != nil {
+		if m, err := loadModel(suite.JudgeModelID); err == nil {
+			judgeModel = m
+		}
+	}
+	judge := func(question, answer, rubric string) (bool, string, error) {
+		return judgeAnswer(judgeModel, agent.UserID, question, answer, rubric)
+	}
+
+	var (
+		passed       int
+		totalLatency int
+		totalTokens  int
+	)
+	for _, evalCase := range cases {
+		var checks []Check
+		json.Unmarshal(evalCase.Checks, &checks)
+
+		opts.Message = evalCase.Question
+		opts.ThreadID = ""
+		caseStart := time.Now()
+		answer, inputTokens, outputTokens, err := askAgent(aiModel, agent, opts)
+		result := EvalResult{
+			RunID:        run.ID,
+			CaseID:       evalCase.ID,
+			Question:     evalCase.Question,
+			Answer:       answer,
+			LatencyMs:    int(time.Since(caseStart).Milliseconds()),
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+		}
+		if err != nil {
+			errMsg := err.Error()
+			result.Error = &errMsg
+			result.Checks, _ = json.Marshal([]CheckResult{})
+		} else {
+			checkResults, ok := runChecks(checks, evalCase.Question, answer, judge)
+			result.Passed = ok
+			result.Checks, _ = json.Marshal(checkResults)
+		}
+		if err := global.DB.Create(&result).Error; err != nil {
+			log.Printf("保存评测结果失败: %v", err)
+		}
+
+		if result.Passed {
+			passed++
+		}
+		totalLatency += result.LatencyMs
+		totalTokens += inputTokens + outputTokens
+	}
+
+	avgLatency := 0
+	if len(cases) > 0 {
+		avgLatency = totalLatency / len(cases)
+	}
+	run.TotalCases = len(cases)
+	run.PassedCases = passed
+	run.AvgLatencyMs = avgLatency
+	run.TotalTokens = totalTokens
+	global.DB.Model(run).Updates(map[string]interface{}{
+		"total_cases":    run.TotalCases,
+		"passed_cases":   passed,
+		"avg_latency_ms": avgLatency,
+		"total_tokens":   totalTokens,
+	})
+	finishRun(run, RunStatusCompleted, "")
+}
+
+// finishRun 更新运行状态为结束
+func finishRun(run *EvalRun, status string, errMsg string) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      status,
+		"finished_at": now,
+	}
+	if errMsg != "" {
+		updates["error"] = errMsg
+		run.Error = &errMsg
+	}
+	run.Status = status
+	run.FinishedAt = &now
+	global.DB.Model(run).Updates(updates)
+}
+
+// askAgent 请求智能体并收集完整回答和token使用量
+func askAgent(aiModel aimodels.AIModel, agent agents.Agent, opts service.AIRequestOptions) (string, int, int, error) {
+	resp, err := service.RequestAI(aiModel, agent, opts)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	var (
+		answer       string
+		tokens       string
+		inputTokens  int
+		outputTokens int
+		streamErr    error
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), service.StreamTimeout)
+	defer cancel()
+
+	readErr := service.ReadStreamLines(ctx, resp.Body, func(v service.StreamLineData, _ string) bool {
+		switch v.Type {
+		case "token":
+			tokens += fmt.Sprintf("%v", v.Content)
+		case "error":
+			streamErr = fmt.Errorf("%v", v.Content)
+		case "message":
+			msg, err := service.ParseStreamMessage(v)
+			if err != nil {
+				return true
+			}
+			inputTokens += msg.UsageMetadata.InputTokens
+			outputTokens += msg.UsageMetadata.OutputTokens
+			if msg.Type == "ai" && len(msg.ToolCalls) == 0 {
+				answer = msg.Content
+			}
+		}
+		return true
+	})
+	if answer == "" {
+		answer = tokens
+	}
+	if readErr != nil {
+		return answer, inputTokens, outputTokens, readErr
+	}
+	return answer, inputTokens, outputTokens, streamErr
+}
+
+// judgeAnswer 使用大模型按评分标准判定回答
+func judgeAnswer(aiModel aimodels.AIModel, userID int64, question, answer, rubric string) (bool, string, error) {
+	judge := agents.Agent{UserID: userID, Prompt: judgePrompt}
+	message := fmt.Sprintf("【问题】\n%s\n\n【回答】\n%s\n\n【评分标准】\n%s", question, answer, rubric)
+
+	reply, _, _, err := askAgent(aiModel, judge, service.AIRequestOptions{Message: message})
+	if err != nil {
+		return false, "", err
+	}
+	value, err := extractJSON(reply)
+	if err != nil {
+		return false, "", err
+	}
+	verdict, ok := value.(map[string]any)
+	if !ok {
+		return false, "", errors.New("评判结果格式错误")
+	}
+	pass, _ := verdict["pass"].(bool)
+	reason, _ := verdict["reason"].(string)
+	return pass, reason, nil
+}
+
+// RunSuite 同步执行评测集（命令行使用，以评测集创建者身份运行）
+func RunSuite(suiteID int64, label string) (*EvalRun, error) {
+	var suite EvalSuite
+	if err := global.DB.First(&suite, suiteID).Error; err != nil {
+		return nil, errors.New("评测集不存在")
+	}
+	var agent agents.Agent
+	if err := global.DB.First(&agent, suite.AgentID).Error; err != nil {
+		return nil, errors.New("智能体不存在")
+	}
+	aiModel, err := loadModel(agent.AIModelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var runLabel *string
+	if label != "" {
+		runLabel = &label
+	}
+	run, err := newRun(suite, agent, aiModel, suite.UserID, runLabel)
+	if err != nil {
+		return nil, err
+	}
+	ExecuteRun(run, suite, agent, aiModel, service.AIRequestOptions{UserID: suite.UserID})
+	return run, nil
+}
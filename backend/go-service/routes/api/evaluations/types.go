@@ -0,0 +1,189 @@
+package evaluations
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 检查项类型
+const (
+	CheckContains    = "contains"     // 回答包含预期事实
+	CheckNotContains = "not_contains" // 回答不包含指定内容
+	CheckRegex       = "regex"        // 回答匹配正则表达式
+	CheckJSONSchema  = "json_schema"  // 回答（或其中的JSON）符合JSON Schema
+	CheckLLMJudge    = "llm_judge"    // 由大模型按评分标准判定
+)
+
+// 运行状态
+const (
+	RunStatusPending   = "pending"
+	RunStatusRunning   = "running"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+)
+
+// EvalSuite 评测集模型
+type EvalSuite struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       int64     `gorm:"not null;index" json:"user_id"`
+	AgentID      int64     `gorm:"not null;index" json:"agent_id"`
+	Name         string    `gorm:"type:varchar(255);not null" json:"name"`
+	Description  *string   `gorm:"type:text" json:"description"`
+	JudgeModelID *int64    `gorm:"column:judge_model_id" json:"judge_model_id"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 关联数据
+	Cases     []EvalCase `gorm:"foreignKey:SuiteID" json:"cases,omitempty"`
+	CaseCount int64      `gorm:"-" json:"case_count"`
+	LastRun   *EvalRun   `gorm:"-" json:"last_run,omitempty"`
+}
+
+// EvalCase 评测用例模型
+type EvalCase struct {
+	ID        int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	SuiteID   int64          `gorm:"not null;index" json:"suite_id"`
+	Question  string         `gorm:"type:text;not null" json:"question"`
+	Checks    datatypes.JSON `gorm:"type:jsonb;default:'[]'" json:"checks"`
+	SortOrder int            `gorm:"default:0" json:"sort_order"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// EvalRun 评测运行记录模型
+type EvalRun struct {
+	ID           int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	SuiteID      int64          `gorm:"not null;index" json:"suite_id"`
+	AgentID      int64          `gorm:"not null" json:"agent_id"`
+	UserID       int64          `gorm:"not null" json:"user_id"`
+	Label        *string        `gorm:"type:varchar(255)" json:"label"`
+	Status       string         `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	Snapshot     datatypes.JSON `gorm:"type:jsonb;default:'{}'" json:"snapshot"`
+	TotalCases   int            `gorm:"default:0" json:"total_cases"`
+	PassedCases  int            `gorm:"default:0" json:"passed_cases"`
+	AvgLatencyMs int            `gorm:"column:avg_latency_ms;default:0" json:"avg_latency_ms"`
+	TotalTokens  int            `gorm:"default:0" json:"total_tokens"`
+	Error        *string        `gorm:"type:text" json:"error"`
+	StartedAt    *time.Time     `json:"started_at"`
+	FinishedAt   *time.Time     `json:"finished_at"`
+	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
+
+	// 关联数据
+	Results []EvalResult `gorm:"foreignKey:RunID" json:"results,omitempty"`
+}
+
+// EvalResult 单个用例的评测结果
+type EvalResult struct {
+	ID           int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	RunID        int64          `gorm:"not null;index" json:"run_id"`
+	CaseID       int64          `gorm:"not null" json:"case_id"`
+	Question     string         `gorm:"type:text;not null" json:"question"`
+	Answer       string         `gorm:"type:text" json:"answer"`
+	Passed       bool           `gorm:"default:false" json:"passed"`
+	Checks       datatypes.JSON `gorm:"type:jsonb;default:'[]'" json:"checks"`
+	LatencyMs    int            `gorm:"default:0" json:"latency_ms"`
+	InputTokens  int            `gorm:"default:0" json:"input_tokens"`
+	OutputTokens int            `gorm:"default:0" json:"output_tokens"`
+	Error        *string        `gorm:"type:text" json:"error"`
+	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (EvalSuite) TableName() string {
+	return "eval_suites"
+}
+
+func (EvalCase) TableName() string {
+	return "eval_cases"
+}
+
+func (EvalRun) TableName() string {
+	return "eval_runs"
+}
+
+func (EvalResult) TableName() string {
+	return "eval_results"
+}
+
+// Check 用例检查项
+type Check struct {
+	Type       string          `json:"type" validate:"required,oneof=contains not_contains regex json_schema llm_judge"`
+	Value      string          `json:"value,omitempty"`       // contains/not_contains 的文本，regex 的表达式
+	IgnoreCase bool            `json:"ignore_case,omitempty"` // contains/not_contains 是否忽略大小写
+	Schema     json.RawMessage `json:"schema,omitempty"`      // json_schema 的Schema
+	Rubric     string          `json:"rubric,omitempty"`      // llm_judge 的评分标准
+}
+
+// CheckResult 检查项结果
+type CheckResult struct {
+	Type   string `json:"type"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// RunSnapshot 运行时智能体配置快照（用于对比不同版本）
+type RunSnapshot struct {
+	Prompt      string  `json:"prompt"`
+	AIModelID   int64   `json:"ai_model_id"`
+	ModelName   string  `json:"model_name"`
+	Temperature float32 `json:"temperature"`
+}
+
+// CreateSuiteRequest 创建评测集请求
+type CreateSuiteRequest struct {
+	AgentID      int64         `json:"agent_id" validate:"required,min=1"`
+	Name         string        `json:"name" validate:"required,max=255"`
+	Description  *string       `json:"description"`
+	JudgeModelID *int64        `json:"judge_model_id"`
+	Cases        []CaseRequest `json:"cases" validate:"dive"`
+}
+
+// UpdateSuiteRequest 更新评测集请求
+type UpdateSuiteRequest struct {
+	Name         *string `json:"name" validate:"omitempty,max=255"`
+	Description  *string `json:"description"`
+	JudgeModelID *int64  `json:"judge_model_id"`
+}
+
+// CaseRequest 创建/更新评测用例请求
+type CaseRequest struct {
+	Question  string  `json:"question" validate:"required"`
+	Checks    []Check `json:"checks" validate:"required,min=1,dive"`
+	SortOrder int     `json:"sort_order"`
+}
+
+// StartRunRequest 发起评测请求（可临时覆盖智能体配置以评测候选版本）
+type StartRunRequest struct {
+	Label       *string  `json:"label" validate:"omitempty,max=255"`
+	Prompt      *string  `json:"prompt"`
+	AIModelID   *int64   `json:"ai_model_id"`
+	Temperature *float64 `json:"temperature" validate:"omitempty,min=0,max=2"`
+}
+
+// CompareRunsRequest 对比评测运行请求
+type CompareRunsRequest struct {
+	BaseRunID   int64 `form:"base_run_id" validate:"required,min=1"`
+	TargetRunID int64 `form:"target_run_id" validate:"required,min=1"`
+}
+
+// CompareRunsResponse 评测运行对比结果
+type CompareRunsResponse struct {
+	Base      EvalRun       `json:"base"`
+	Target    EvalRun       `json:"target"`
+	Cases     []CaseCompare `json:"cases"`
+	Improved  int           `json:"improved"`
+	Regressed int           `json:"regressed"`
+}
+
+// CaseCompare 单个用例的对比结果
+type CaseCompare struct {
+	CaseID       int64       `json:"case_id"`
+	Question     string      `json:"question"`
+	Base         *EvalResult `json:"base"`
+	Target       *EvalResult `json:"target"`
+	Change       string      `json:"change"` // improved / regressed / unchanged / added / removed
+	LatencyDelta int         `json:"latency_delta"`
+	TokensDelta  int         `json:"tokens_delta"`
+}
@@ -0,0 +1,102 @@
+package experiments
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/routes/api/agents"
+	aimodels "dootask-ai/go-service/routes/api/ai-models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Selection 当前请求命中的实验变体
+type Selection struct {
+	ExperimentID int64 `json:"experiment_id"`
+	VariantID    int64 `json:"variant_id"`
+}
+
+// Metadata 写入消息元数据的实验信息
+func (s *Selection) Metadata() map[string]any {
+	return map[string]any{
+		"experiment_id": fmt.Sprintf("%d", s.ExperimentID),
+		"variant_id":    fmt.Sprintf("%d", s.VariantID),
+	}
+}
+
+// Apply 为用户选择智能体运行中实验的变体并覆盖提示词、模型和温度，没有运行中的实验时返回nil
+func Apply(agent *agents.Agent, aiModel *aimodels.AIModel, userID int64) *Selection {
+	var experiment Experiment
+	if err := global.DB.
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Where("agent_id = ? AND status = ?", agent.ID, StatusRunning).
+		First(&experiment).Error; err != nil || len(experiment.Variants) == 0 {
+		return nil
+	}
+
+	variant := assign(experiment, userID)
+	if variant.Prompt != nil {
+		agent.Prompt = *variant.Prompt
+	}
+	if variant.AIModelID != nil && *variant.AIModelID != aiModel.ID {
+		var model aimodels.AIModel
+		if err := global.DB.Where("id = ?", *variant.AIModelID).First(&model).Error; err == nil && model.IsEnabled != nil && *model.IsEnabled {
+			*aiModel = model
+		} else {
+			log.Printf("实验变体模型不可用，沿用智能体模型: experiment=%d variant=%d", experiment.ID, variant.ID)
+		}
+	}
+	if variant.Temperature != nil {
+		agent.GenerationSettings.Temperature = variant.Temperature
+	}
+
+	return &Selection{ExperimentID: experiment.ID, VariantID: variant.ID}
+}
+
+// assign 获取用户的固定分组，首次访问时按权重分配（同一用户哈希结果稳定）
+func assign(experiment Experiment, userID int64) Variant {
+	var existing Assignment
+	if err := global.DB.Where("experiment_id = ? AND dootask_user_id = ?", experiment.ID, userID).First(&existing).Error; err == nil {
+		for _, variant := range experiment.Variants {
+			if variant.ID == existing.VariantID {
+				return variant
+			}
+		}
+	}
+
+	variant := pickVariant(experiment, userID)
+	global.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "experiment_id"}, {Name: "dootask_user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"variant_id"}),
+	}).Create(&Assignment{
+		ExperimentID:  experiment.ID,
+		VariantID:     variant.ID,
+		DootaskUserID: userID,
+	})
+	return variant
+}
+
+// pickVariant 按流量权重为用户选择变体
+func pickVariant(experiment Experiment, userID int64) Variant {
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return experiment.Variants[0]
+	}
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d:%d", experiment.ID, userID)
+	point := int(h.Sum32() % uint32(total))
+	for _, variant := range experiment.Variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+	return experiment.Variants[len(experiment.Variants)-1]
+}
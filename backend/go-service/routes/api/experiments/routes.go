@@ -0,0 +1,421 @@
+package experiments
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/routes/api/conversations"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// RegisterRoutes 注册A/B实验路由
+func RegisterRoutes(router *gin.RouterGroup) {
+	experimentGroup := router.Group("/experiments")
+	{
+		experimentGroup.GET("", ListExperiments)            // 获取实验列表
+		experimentGroup.POST("", CreateExperiment)          // 创建实验
+		experimentGroup.GET("/:id", GetExperiment)          // 获取实验详情
+		experimentGroup.PUT("/:id", UpdateExperiment)       // 更新实验
+		experimentGroup.DELETE("/:id", DeleteExperiment)    // 删除实验
+		experimentGroup.POST("/:id/start", StartExperiment) // 开始实验
+		experimentGroup.POST("/:id/stop", StopExperiment)   // 停止实验
+		experimentGroup.GET("/:id/results", GetResults)     // 获取各变体效果统计
+	}
+}
+
+// ListExperiments 获取有权查看的智能体的实验列表
+func ListExperiments(c *gin.Context) {
+	query, args := permission.Condition(c, permission.ResourceAgent, permission.RoleViewer)
+	db := global.DB.Where("agent_id IN (SELECT agents.id FROM agents WHERE "+query+")", args...)
+	if agentID, err := strconv.ParseInt(c.Query("agent_id"), 10, 64); err == nil && agentID > 0 {
+		db = db.Where("agent_id = ?", agentID)
+	}
+
+	items := []Experiment{}
+	if err := db.Preload("Variants").Order("id DESC").Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询实验列表失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// CreateExperiment 创建实验（需要智能体编辑权限）
+func CreateExperiment(c *gin.Context) {
+	var req CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	if !permission.Can(c, permission.ResourceAgent, req.AgentID, permission.RoleEditor) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "AGENT_002",
+			"message": "智能体不存在或无编辑权限",
+			"data":    nil,
+		})
+		return
+	}
+	if !checkVariantModels(c, req.Variants) {
+		return
+	}
+
+	experiment := Experiment{
+		AgentID:     req.AgentID,
+		UserID:      int64(global.GetDooTaskUser(c).UserID),
+		Name:        req.Name,
+		Description: req.Description,
+		Status:      StatusDraft,
+	}
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&experiment).Error; err != nil {
+			return err
+		}
+		variants, err := saveVariants(tx, experiment.ID, req.Variants)
+		experiment.Variants = variants
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "创建实验失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, experiment)
+}
+
+// GetExperiment 获取实验详情
+func GetExperiment(c *gin.Context) {
+	experiment, ok := findExperiment(c, permission.RoleViewer)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, experiment)
+}
+
+// UpdateExperiment 更新实验（变体只能在未运行时修改）
+func UpdateExperiment(c *gin.Context) {
+	experiment, ok := findExperiment(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req UpdateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+	if len(req.Variants) > 0 {
+		if experiment.Status == StatusRunning {
+			c.JSON(http.StatusConflict, gin.H{
+				"code":    "EXPERIMENT_002",
+				"message": "运行中的实验不能修改变体，请先停止实验",
+				"data":    nil,
+			})
+			return
+		}
+		if !checkVariantModels(c, req.Variants) {
+			return
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(experiment).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if len(req.Variants) > 0 {
+			// 替换变体（历史分组随变体级联删除）
+			if err := tx.Where("experiment_id = ?", experiment.ID).Delete(&Variant{}).Error; err != nil {
+				return err
+			}
+			if _, err := saveVariants(tx, experiment.ID, req.Variants); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "更新实验失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	global.DB.Preload("Variants").First(experiment, experiment.ID)
+	c.JSON(http.StatusOK, experiment)
+}
+
+// DeleteExperiment 删除实验
+func DeleteExperiment(c *gin.Context) {
+	experiment, ok := findExperiment(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := global.DB.Delete(experiment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除实验失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "实验删除成功",
+	})
+}
+
+// StartExperiment 开始实验（同一智能体同时只能运行一个实验）
+func StartExperiment(c *gin.Context) {
+	experiment, ok := findExperiment(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	if experiment.Status == StatusRunning {
+		c.JSON(http.StatusOK, experiment)
+		return
+	}
+
+	var running int64
+	global.DB.Model(&Experiment{}).Where("agent_id = ? AND status = ?", experiment.AgentID, StatusRunning).Count(&running)
+	if running > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    "EXPERIMENT_003",
+			"message": "该智能体已有运行中的实验",
+			"data":    nil,
+		})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"status":   StatusRunning,
+		"ended_at": nil,
+	}
+	if experiment.StartedAt == nil {
+		updates["started_at"] = time.Now()
+	}
+	if err := global.DB.Model(experiment).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "开始实验失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	global.DB.Preload("Variants").First(experiment, experiment.ID)
+	c.JSON(http.StatusOK, experiment)
+}
+
+// StopExperiment 停止实验，所有用户恢复使用智能体原配置
+func StopExperiment(c *gin.Context) {
+	experiment, ok := findExperiment(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+
+	if err := global.DB.Model(experiment).Updates(map[string]interface{}{
+		"status":   StatusStopped,
+		"ended_at": time.Now(),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "停止实验失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	global.DB.Preload("Variants").First(experiment, experiment.ID)
+	c.JSON(http.StatusOK, experiment)
+}
+
+// GetResults 按变体统计响应时间、成功率、token使用量和用户反馈
+func GetResults(c *gin.Context) {
+	experiment, ok := findExperiment(c, permission.RoleViewer)
+	if !ok {
+		return
+	}
+
+	var rows []struct {
+		VariantID         string
+		Responses         int64
+		Successes         int64
+		AvgResponseTimeMs float64
+		TotalTokens       int64
+		FeedbackUp        int64
+		FeedbackDown      int64
+	}
+	if err := global.DB.Model(&conversations.Message{}).
+		Select(`metadata->>'variant_id' AS variant_id,
+			COUNT(*) FILTER (WHERE role = 'assistant') AS responses,
+			COUNT(*) FILTER (WHERE role = 'assistant' AND status = 1) AS successes,
+			COALESCE(AVG(response_time_ms) FILTER (WHERE role = 'assistant' AND response_time_ms > 0), 0) AS avg_response_time_ms,
+			COALESCE(SUM(tokens_used), 0) AS total_tokens,
+			COUNT(*) FILTER (WHERE metadata->'feedback'->>'rating' = 'up') AS feedback_up,
+			COUNT(*) FILTER (WHERE metadata->'feedback'->>'rating' = 'down') AS feedback_down`).
+		Where("metadata->>'experiment_id' = ?", strconv.FormatInt(experiment.ID, 10)).
+		Group("metadata->>'variant_id'").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询实验结果失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	var users []struct {
+		VariantID int64
+		Users     int64
+	}
+	global.DB.Model(&Assignment{}).
+		Select("variant_id, COUNT(*) AS users").
+		Where("experiment_id = ?", experiment.ID).
+		Group("variant_id").
+		Scan(&users)
+
+	results := make([]VariantResult, 0, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		result := VariantResult{VariantID: variant.ID, Name: variant.Name, Weight: variant.Weight}
+		for _, u := range users {
+			if u.VariantID == variant.ID {
+				result.Users = u.Users
+			}
+		}
+		for _, row := range rows {
+			if row.VariantID != strconv.FormatInt(variant.ID, 10) {
+				continue
+			}
+			result.Responses = row.Responses
+			result.AvgResponseTimeMs = row.AvgResponseTimeMs
+			result.TotalTokens = row.TotalTokens
+			result.FeedbackUp = row.FeedbackUp
+			result.FeedbackDown = row.FeedbackDown
+			if row.Responses > 0 {
+				result.SuccessRate = float64(row.Successes) / float64(row.Responses) * 100
+				result.AvgTokens = float64(row.TotalTokens) / float64(row.Responses)
+			}
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, ExperimentResultsResponse{
+		Experiment: *experiment,
+		Variants:   results,
+	})
+}
+
+// findExperiment 查询实验（含变体）并校验智能体权限，失败时直接写入响应
+func findExperiment(c *gin.Context, role permission.Role) (*Experiment, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的实验ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	var experiment Experiment
+	if err := global.DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&experiment, id).Error; err != nil || !permission.Can(c, permission.ResourceAgent, experiment.AgentID, role) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "EXPERIMENT_001",
+			"message": "实验不存在或无权访问",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	return &experiment, true
+}
+
+// checkVariantModels 校验变体使用的AI模型权限，失败时直接写入响应
+func checkVariantModels(c *gin.Context, variants []VariantRequest) bool {
+	for _, variant := range variants {
+		if variant.AIModelID == nil {
+			continue
+		}
+		if !permission.Can(c, permission.ResourceAIModel, *variant.AIModelID, permission.RoleViewer) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"code":    "AI_MODEL_001",
+				"message": "AI模型不存在: " + variant.Name,
+				"data":    nil,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// saveVariants 保存实验变体
+func saveVariants(tx *gorm.DB, experimentID int64, requests []VariantRequest) ([]Variant, error) {
+	variants := make([]Variant, 0, len(requests))
+	for _, req := range requests {
+		variants = append(variants, Variant{
+			ExperimentID: experimentID,
+			Name:         req.Name,
+			Prompt:       req.Prompt,
+			AIModelID:    req.AIModelID,
+			Temperature:  req.Temperature,
+			Weight:       req.Weight,
+		})
+	}
+	if err := tx.Create(&variants).Error; err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
@@ -0,0 +1,109 @@
+package experiments
+
+import (
+	"time"
+)
+
+// 实验状态
+const (
+	StatusDraft   = "draft"
+	StatusRunning = "running"
+	StatusStopped = "stopped"
+)
+
+// Experiment 智能体A/B实验模型
+type Experiment struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AgentID     int64      `gorm:"not null;index" json:"agent_id"`
+	UserID      int64      `gorm:"not null" json:"user_id"`
+	Name        string     `gorm:"type:varchar(255);not null" json:"name"`
+	Description *string    `gorm:"type:text" json:"description"`
+	Status      string     `gorm:"type:varchar(20);default:'draft'" json:"status"`
+	StartedAt   *time.Time `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 关联模型
+	Variants []Variant `gorm:"foreignKey:ExperimentID" json:"variants,omitempty"`
+}
+
+// Variant 实验变体模型（未设置的字段沿用智能体配置）
+type Variant struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ExperimentID int64     `gorm:"not null;index" json:"experiment_id"`
+	Name         string    `gorm:"type:varchar(100);not null" json:"name"`
+	Prompt       *string   `gorm:"type:text" json:"prompt"`
+	AIModelID    *int64    `gorm:"column:ai_model_id" json:"ai_model_id"`
+	Temperature  *float64  `gorm:"type:decimal(3,2)" json:"temperature"`
+	Weight       int       `gorm:"not null;default:50" json:"weight"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Assignment 用户分组记录（同一用户在实验期间固定使用同一变体）
+type Assignment struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ExperimentID  int64     `gorm:"not null" json:"experiment_id"`
+	VariantID     int64     `gorm:"not null" json:"variant_id"`
+	DootaskUserID int64     `gorm:"column:dootask_user_id;not null" json:"dootask_user_id"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (Experiment) TableName() string {
+	return "agent_experiments"
+}
+
+func (Variant) TableName() string {
+	return "experiment_variants"
+}
+
+func (Assignment) TableName() string {
+	return "experiment_assignments"
+}
+
+// CreateExperimentRequest 创建实验请求
+type CreateExperimentRequest struct {
+	AgentID     int64            `json:"agent_id" validate:"required,min=1"`
+	Name        string           `json:"name" validate:"required,max=255"`
+	Description *string          `json:"description"`
+	Variants    []VariantRequest `json:"variants" validate:"required,min=2,dive"`
+}
+
+// UpdateExperimentRequest 更新实验请求（运行中的实验不能修改变体）
+type UpdateExperimentRequest struct {
+	Name        *string          `json:"name" validate:"omitempty,max=255"`
+	Description *string          `json:"description"`
+	Variants    []VariantRequest `json:"variants" validate:"omitempty,min=2,dive"`
+}
+
+// VariantRequest 实验变体请求
+type VariantRequest struct {
+	Name        string   `json:"name" validate:"required,max=100"`
+	Prompt      *string  `json:"prompt"`
+	AIModelID   *int64   `json:"ai_model_id"`
+	Temperature *float64 `json:"temperature" validate:"omitempty,min=0,max=2"`
+	Weight      int      `json:"weight" validate:"required,min=1"`
+}
+
+// VariantResult 变体效果统计
+type VariantResult struct {
+	VariantID         int64   `json:"variant_id"`
+	Name              string  `json:"name"`
+	Weight            int     `json:"weight"`
+	Users             int64   `json:"users"`
+	Responses         int64   `json:"responses"`
+	AvgResponseTimeMs float64 `json:"avg_response_time_ms"`
+	SuccessRate       float64 `json:"success_rate"`
+	TotalTokens       int64   `json:"total_tokens"`
+	AvgTokens         float64 `json:"avg_tokens"`
+	FeedbackUp        int64   `json:"feedback_up"`
+	FeedbackDown      int64   `json:"feedback_down"`
+}
+
+// ExperimentResultsResponse 实验结果响应
+type ExperimentResultsResponse struct {
+	Experiment Experiment      `json:"experiment"`
+	Variants   []VariantResult `json:"variants"`
+}
@@ -0,0 +1,661 @@
+package knowledgebases
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"dootask-ai/go-service/sysconfig"
+	"dootask-ai/go-service/utils"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	crawlerUserAgent   = "DooTaskAI-Crawler/1.0"
+	crawlerRobotsAgent = "dootaskai" // robots.txt 中匹配的爬虫名称
+	crawlerTimeout     = 30 * time.Second
+	crawlerMaxRedirect = 5
+	maxSitemapFiles    = 50
+	maxRobotsSize      = 512 * 1024
+)
+
+// errRobotsDisallowed robots.txt 不允许抓取
+var errRobotsDisallowed = errors.New("robots.txt 不允许抓取该页面")
+
+// errPrivateAddress 目标解析到内网或保留地址
+var errPrivateAddress = errors.New("不允许抓取内网或保留地址")
+
+// blockedPrefixes 除 netip 判断的回环、私有、链路本地、组播、未指定地址外，额外禁止的保留地址段
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"),  // 运营商级NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF协议分配
+	netip.MustParsePrefix("198.18.0.0/15"),  // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),    // 保留
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64
+	netip.MustParsePrefix("64:ff9b:1::/48"), // 本地NAT64
+	netip.MustParsePrefix("2001:db8::/32"),  // 文档
+	netip.MustParsePrefix("fec0::/10"),      // 站点本地（已废弃）
+}
+
+// 爬取时跳过的非网页链接
+var skippedExtensions = []string{
+	".pdf", ".zip", ".gz", ".tar", ".rar", ".7z", ".exe", ".dmg", ".apk",
+	".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp", ".ico", ".bmp",
+	".mp3", ".mp4", ".avi", ".mov", ".webm", ".css", ".js", ".json", ".xml",
+	".woff", ".woff2", ".ttf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx",
+}
+
+// crawledPage 抓取到的页面
+type crawledPage struct {
+	URL      string
+	Title    string
+	Markdown string
+	links    []string
+	noindex  bool // 页面要求不被收录
+	nofollow bool // 页面要求不跟踪链接
+}
+
+// crawler 网页抓取器：遵守 robots.txt，对同一站点按间隔顺序请求，只访问公网地址
+type crawler struct {
+	client       *http.Client
+	robotsClient *http.Client
+	interval     time.Duration
+	maxSize      int64
+	robots       map[string]*robotsRules // 按站点（scheme://host）缓存
+	lastHit      map[string]time.Time
+}
+
+// newCrawler 创建抓取器（请求间隔和页面大小上限读取系统配置）
+func newCrawler() *crawler {
+	c := &crawler{
+		interval: time.Duration(sysconfig.Int(sysconfig.KeyKBCrawlRequestInterval, 1000)) * time.Millisecond,
+		maxSize:  int64(sysconfig.Int(sysconfig.KeyMaxFileUploadSize, 50)) * 1024 * 1024,
+		robots:   map[string]*robotsRules{},
+		lastHit:  map[string]time.Time{},
+	}
+	transport := publicTransport()
+	c.client = &http.Client{
+		Transport: transport,
+		Timeout:   crawlerTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= crawlerMaxRedirect {
+				return fmt.Errorf("重定向次数过多")
+			}
+			if !c.allowed(req.Context(), req.URL) {
+				return errRobotsDisallowed
+			}
+			return nil
+		},
+	}
+	c.robotsClient = &http.Client{
+		Transport: transport,
+		Timeout:   crawlerTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= crawlerMaxRedirect {
+				return fmt.Errorf("重定向次数过多")
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+// publicTransport 只连接公网地址的传输：在DNS解析后的每次连接（包括每一跳重定向）检查目标IP，
+// 不使用环境变量中的代理（经代理连接时无法检查实际目标）
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: crawlerTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil || !isPublicAddr(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+// checkPublicHost 提前检查主机是否解析到公网地址，便于给出明确的错误（实际连接时仍会再次检查）
+func checkPublicHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("解析域名失败: %w", err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// isPublicAddr 是否为可抓取的公网地址
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// site 站点标识
+func site(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// wait 等待到允许再次请求该站点的时间（robots.txt 的 Crawl-delay 更长时以其为准）
+func (c *crawler) wait(ctx context.Context, u *url.URL) error {
+	interval := c.interval
+	if rules := c.robots[site(u)]; rules != nil && rules.delay > interval {
+		interval = rules.delay
+	}
+	if last, ok := c.lastHit[site(u)]; ok {
+		if delay := time.Until(last.Add(interval)); delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+	}
+	c.lastHit[site(u)] = time.Now()
+	return nil
+}
+
+// allowed robots.txt 是否允许抓取该地址
+func (c *crawler) allowed(ctx context.Context, u *url.URL) bool {
+	rules, ok := c.robots[site(u)]
+	if !ok {
+		rules = c.fetchRobots(ctx, u)
+		c.robots[site(u)] = rules
+	}
+	return rules.allows(u)
+}
+
+// fetchRobots 读取站点的 robots.txt：不存在时允许全部，服务器错误或无法访问时不允许抓取
+func (c *crawler) fetchRobots(ctx context.Context, u *url.URL) *robotsRules {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	if err := c.wait(ctx, robotsURL); err != nil {
+		return &robotsRules{disallowAll: true}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return &robotsRules{disallowAll: true}
+	}
+	req.Header.Set("User-Agent", crawlerUserAgent)
+	resp, err := c.robotsClient.Do(req)
+	if err != nil {
+		return &robotsRules{disallowAll: true}
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+		return parseRobots(data)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return &robotsRules{}
+	default:
+		return &robotsRules{disallowAll: true}
+	}
+}
+
+// get 请求地址，返回响应内容、响应头和重定向后的地址
+func (c *crawler) get(ctx context.Context, target string) ([]byte, http.Header, *url.URL, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, nil, nil, fmt.Errorf("无效的地址: %s", target)
+	}
+	if err := checkPublicHost(ctx, u.Hostname()); err != nil {
+		return nil, nil, nil, err
+	}
+	if !c.allowed(ctx, u) {
+		return nil, nil, nil, errRobotsDisallowed
+	}
+	if err := c.wait(ctx, u); err != nil {
+		return nil, nil, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	req.Header.Set("User-Agent", crawlerUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain,text/markdown,application/xml;q=0.9,*/*;q=0.8")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxSize+1))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if int64(len(body)) > c.maxSize {
+		return nil, nil, nil, fmt.Errorf("页面大小超过限制(%dMB)", c.maxSize/1024/1024)
+	}
+	return body, resp.Header, resp.Request.URL, nil
+}
+
+// fetchPage 抓取页面并转换为Markdown
+func (c *crawler) fetchPage(ctx context.Context, target string) (*crawledPage, error) {
+	body, header, finalURL, err := c.get(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &crawledPage{URL: finalURL.String()}
+	for _, value := range header.Values("X-Robots-Tag") {
+		page.applyRobotsDirectives(value)
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "":
+		if err := page.parseHTML(finalURL, body); err != nil {
+			return nil, err
+		}
+	case "text/plain", "text/markdown", "text/x-markdown":
+		page.Title = path.Base(finalURL.Path)
+		page.Markdown = string(body)
+	default:
+		return nil, fmt.Errorf("不支持的内容类型: %s", mediaType)
+	}
+	if strings.TrimSpace(page.Markdown) == "" && !page.noindex {
+		return nil, fmt.Errorf("页面没有可收录的内容")
+	}
+	return page, nil
+}
+
+// applyRobotsDirectives 处理 meta robots 和 X-Robots-Tag 中的 noindex、nofollow
+func (p *crawledPage) applyRobotsDirectives(value string) {
+	for _, directive := range strings.Split(strings.ToLower(value), ",") {
+		switch strings.TrimSpace(directive) {
+		case "noindex":
+			p.noindex = true
+		case "nofollow":
+			p.nofollow = true
+		case "none":
+			p.noindex, p.nofollow = true, true
+		}
+	}
+}
+
+// parseHTML 提取标题、链接和正文（有 main 或 article 元素时只转换正文部分）
+func (p *crawledPage) parseHTML(base *url.URL, body []byte) error {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	var content *html.Node
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				if p.Title == "" && n.FirstChild != nil {
+					p.Title = strings.TrimSpace(n.FirstChild.Data)
+				}
+			case atom.Base:
+				if href := attr(n, "href"); href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case atom.Meta:
+				if name := strings.ToLower(attr(n, "name")); name == "robots" || name == crawlerRobotsAgent {
+					p.applyRobotsDirectives(attr(n, "content"))
+				}
+			case atom.A:
+				if attr(n, "rel") != "nofollow" {
+					if u, err := base.Parse(attr(n, "href")); err == nil && attr(n, "href") != "" {
+						p.links = append(p.links, u.String())
+					}
+				}
+			case atom.Main, atom.Article:
+				if content == nil {
+					content = n
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	source := string(body)
+	if content != nil {
+		var buf bytes.Buffer
+		if err := html.Render(&buf, content); err == nil {
+			source = buf.String()
+		}
+	}
+	markdown, err := utils.HTMLToMarkdown(source)
+	if err != nil {
+		return err
+	}
+	p.Markdown = markdown
+	if p.Title == "" {
+		p.Title = path.Base(base.Path)
+	}
+	return nil
+}
+
+// attr 读取元素属性
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// sitemapURLs 读取站点地图中的页面地址（支持站点地图索引和gzip压缩）
+func (c *crawler) sitemapURLs(ctx context.Context, target string, scope func(*url.URL) bool, limit int) ([]string, error) {
+	var pages []string
+	queue := []string{target}
+	visited := map[string]bool{}
+	for len(queue) > 0 && len(visited) < maxSitemapFiles && len(pages) < limit {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		body, _, _, err := c.get(ctx, current)
+		if err != nil {
+			if current == target {
+				return nil, fmt.Errorf("读取站点地图失败: %w", err)
+			}
+			continue
+		}
+		if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+			reader, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				continue
+			}
+			body, _ = io.ReadAll(io.LimitReader(reader, c.maxSize))
+		}
+
+		var sitemap struct {
+			URLs []struct {
+				Loc string `xml:"loc"`
+			} `xml:"url"`
+			Sitemaps []struct {
+				Loc string `xml:"loc"`
+			} `xml:"sitemap"`
+		}
+		if err := xml.Unmarshal(body, &sitemap); err != nil {
+			if current == target {
+				return nil, fmt.Errorf("站点地图格式错误: %w", err)
+			}
+			continue
+		}
+		for _, item := range sitemap.Sitemaps {
+			queue = append(queue, strings.TrimSpace(item.Loc))
+		}
+		for _, item := range sitemap.URLs {
+			u, err := url.Parse(strings.TrimSpace(item.Loc))
+			if err != nil || !scope(u) {
+				continue
+			}
+			u.Fragment = ""
+			if !slices.Contains(pages, u.String()) {
+				pages = append(pages, u.String())
+			}
+			if len(pages) >= limit {
+				break
+			}
+		}
+	}
+	return pages, nil
+}
+
+// crawl 按数据源配置抓取页面：page 只抓取指定页面，sitemap 抓取站点地图中的页面，crawl 从指定页面按深度跟踪链接。
+// 抓取成功的页面调用 onPage，失败的调用 onError（robots.txt 不允许的页面直接跳过）；无法开始抓取时返回错误
+func (c *crawler) crawl(ctx context.Context, cfg URLSourceConfig, maxPages int, onPage func(*crawledPage), onError func(string, error)) error {
+	start, err := url.Parse(cfg.URL)
+	if err != nil || (start.Scheme != "http" && start.Scheme != "https") || start.Host == "" {
+		return fmt.Errorf("无效的地址: %s", cfg.URL)
+	}
+	start.Fragment = ""
+	scope := cfg.scope(start)
+
+	handle := func(target string) (*crawledPage, error) {
+		page, err := c.fetchPage(ctx, target)
+		if err != nil {
+			if !errors.Is(err, errRobotsDisallowed) {
+				onError(target, err)
+			}
+			return nil, err
+		}
+		if !page.noindex {
+			onPage(page)
+		}
+		return page, nil
+	}
+
+	switch cfg.Mode {
+	case URLModeSitemap:
+		pages, err := c.sitemapURLs(ctx, start.String(), scope, maxPages)
+		if err != nil {
+			return err
+		}
+		for _, target := range pages {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			handle(target)
+		}
+	case URLModeCrawl:
+		type item struct {
+			url   string
+			depth int
+		}
+		queue := []item{{url: start.String()}}
+		visited := map[string]bool{start.String(): true}
+		for fetched := 0; len(queue) > 0 && fetched < maxPages; fetched++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			current := queue[0]
+			queue = queue[1:]
+			page, _ := handle(current.url)
+			if page == nil || page.nofollow || current.depth >= cfg.MaxDepth {
+				continue
+			}
+			visited[page.URL] = true
+			for _, link := range page.links {
+				u, err := url.Parse(link)
+				if err != nil || !scope(u) || slices.Contains(skippedExtensions, strings.ToLower(path.Ext(u.Path))) {
+					continue
+				}
+				u.Fragment = ""
+				if !visited[u.String()] {
+					visited[u.String()] = true
+					queue = append(queue, item{url: u.String(), depth: current.depth + 1})
+				}
+			}
+		}
+	default:
+		if _, err := handle(start.String()); errors.Is(err, errRobotsDisallowed) {
+			return err
+		}
+	}
+	return nil
+}
+
+// scope 页面是否在数据源范围内：与起始地址同一站点，路径匹配 include_paths 且不匹配 exclude_paths；
+// 爬取模式未指定 include_paths 时限制在起始页面所在目录下
+func (cfg URLSourceConfig) scope(start *url.URL) func(*url.URL) bool {
+	include := cfg.IncludePaths
+	if len(include) == 0 && cfg.Mode == URLModeCrawl {
+		dir := start.Path
+		if !strings.HasSuffix(dir, "/") {
+			dir = path.Dir(dir)
+		}
+		include = []string{strings.TrimSuffix(dir, "/") + "/"}
+	}
+	hasPrefix := func(p string, prefixes []string) bool {
+		return slices.ContainsFunc(prefixes, func(prefix string) bool {
+			return strings.HasPrefix(p, prefix)
+		})
+	}
+	return func(u *url.URL) bool {
+		if (u.Scheme != "http" && u.Scheme != "https") || !strings.EqualFold(u.Host, start.Host) {
+			return false
+		}
+		p := u.Path
+		if p == "" {
+			p = "/"
+		}
+		if len(include) > 0 && !hasPrefix(p, include) {
+			return false
+		}
+		return !hasPrefix(p, cfg.ExcludePaths)
+	}
+}
+
+// robotsRules robots.txt 中适用于本爬虫的规则
+type robotsRules struct {
+	disallowAll bool
+	rules       []robotsRule
+	delay       time.Duration
+}
+
+// robotsRule 单条 Allow/Disallow 规则
+type robotsRule struct {
+	allow   bool
+	pattern string
+	re      *regexp.Regexp
+}
+
+// parseRobots 解析 robots.txt（RFC 9309），优先使用本爬虫的分组，没有时使用 * 分组
+func parseRobots(data []byte) *robotsRules {
+	groups := map[string]*robotsRules{}
+	var agents []string
+	inRules := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agent := strings.ToLower(value)
+			agents = append(agents, agent)
+			if groups[agent] == nil {
+				groups[agent] = &robotsRules{}
+			}
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value, re: robotsPattern(value)}
+			for _, agent := range agents {
+				groups[agent].rules = append(groups[agent].rules, rule)
+			}
+		case "crawl-delay":
+			inRules = true
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				for _, agent := range agents {
+					groups[agent].delay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+	if rules := groups[crawlerRobotsAgent]; rules != nil {
+		return rules
+	}
+	if rules := groups["*"]; rules != nil {
+		return rules
+	}
+	return &robotsRules{}
+}
+
+// robotsPattern 将规则路径转换为正则（支持 * 通配和 $ 结尾）
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allows 是否允许抓取：匹配最长的规则生效，长度相同时 Allow 优先
+func (r *robotsRules) allows(u *url.URL) bool {
+	if r.disallowAll {
+		return false
+	}
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if target == "/robots.txt" {
+		return true
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+	allowed, matched := true, -1
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(target) {
+			continue
+		}
+		if len(rule.pattern) > matched || (len(rule.pattern) == matched && rule.allow) {
+			allowed, matched = rule.allow, len(rule.pattern)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,305 @@
+package knowledgebases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"dootask-ai/go-service/sysconfig"
+	"dootask-ai/go-service/utils"
+)
+
+// DooTask数据源同步内容
+const (
+	DooTaskKindFile    = "file"    // 文件和文件夹
+	DooTaskKindProject = "project" // 项目任务
+)
+
+// dootaskPageSize 分页接口每页数量
+const dootaskPageSize = 100
+
+// dootaskFileTypes 直接下载原文件处理的文件扩展名（与上传文档支持的类型一致）
+var dootaskFileTypes = []string{"pdf", "docx", "doc", "md", "txt"}
+
+// dootaskFile DooTask文件
+type dootaskFile struct {
+	ID        int64  `json:"id"`
+	Pid       int64  `json:"pid"`
+	Name      string `json:"name"`
+	Type      string `json:"type"` // folder 文件夹，document 在线文档，其他为各类文件
+	Ext       string `json:"ext"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// dootaskProject DooTask项目
+type dootaskProject struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+// dootaskTask DooTask任务
+type dootaskTask struct {
+	ID         int64   `json:"id"`
+	ProjectID  int64   `json:"project_id"`
+	Name       string  `json:"name"`
+	Desc       string  `json:"desc"`
+	StartAt    *string `json:"start_at"`
+	EndAt      *string `json:"end_at"`
+	CompleteAt *string `json:"complete_at"`
+	UpdatedAt  string  `json:"updated_at"`
+}
+
+// dootaskPage DooTask分页接口的数据
+type dootaskPage[T any] struct {
+	Data     []T `json:"data"`
+	LastPage int `json:"last_page"`
+}
+
+// dootaskItem 待同步的文件或任务（文档内容在需要更新时才加载）
+type dootaskItem struct {
+	sourceDocument
+	load func(ctx context.Context) ([]byte, error)
+}
+
+// dootaskSource 以用户身份拉取DooTask内容，只能拉取到该用户有权访问的文件和项目
+type dootaskSource struct {
+	client   utils.DooTaskClient
+	cfg      DooTaskSourceConfig
+	maxItems int
+	maxSize  int64
+}
+
+// newDooTaskSource 创建DooTask内容拉取器（数量和大小上限读取系统配置）
+func newDooTaskSource(token string, cfg DooTaskSourceConfig) *dootaskSource {
+	maxItems := sysconfig.Int(sysconfig.KeyKBDooTaskMaxItems, 1000)
+	if cfg.MaxItems > 0 && cfg.MaxItems < maxItems {
+		maxItems = cfg.MaxItems
+	}
+	return &dootaskSource{
+		client:   utils.NewDooTaskClient(token),
+		cfg:      cfg,
+		maxItems: maxItems,
+		maxSize:  int64(sysconfig.Int(sysconfig.KeyMaxFileUploadSize, 50)) * 1024 * 1024,
+	}
+}
+
+// list 列出需要同步的文件或任务，列表不完整时返回错误
+func (s *dootaskSource) list(ctx context.Context) ([]dootaskItem, error) {
+	if s.cfg.Kind == DooTaskKindProject {
+		return s.listTasks(ctx)
+	}
+	return s.listFiles(ctx)
+}
+
+// listFiles 列出指定文件和文件夹（包含子文件夹）中支持的文件，未指定时从根目录开始
+func (s *dootaskSource) listFiles(ctx context.Context) ([]dootaskItem, error) {
+	var queue []dootaskFile
+	if len(s.cfg.FileIDs) == 0 {
+		files, err := s.folderFiles(ctx, 0)
+		if err != nil {
+			return nil, err
+		}
+		queue = files
+	}
+	for _, id := range s.cfg.FileIDs {
+		var file dootaskFile
+		if err := s.client.Get(ctx, "file/one", map[string]string{"id": strconv.FormatInt(id, 10)}, &file); err != nil {
+			return nil, fmt.Errorf("获取文件(%d)失败: %w", id, err)
+		}
+		queue = append(queue, file)
+	}
+
+	items := []dootaskItem{}
+	visited := map[int64]bool{}
+	for len(queue) > 0 && len(items) < s.maxItems {
+		file := queue[0]
+		queue = queue[1:]
+		if visited[file.ID] {
+			continue
+		}
+		visited[file.ID] = true
+
+		if file.Type == "folder" {
+			files, err := s.folderFiles(ctx, file.ID)
+			if err != nil {
+				return nil, err
+			}
+			queue = append(queue, files...)
+			continue
+		}
+		if item, ok := s.fileItem(file); ok {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// folderFiles 获取文件夹下的文件列表
+func (s *dootaskSource) folderFiles(ctx context.Context, pid int64) ([]dootaskFile, error) {
+	var files []dootaskFile
+	if err := s.client.Get(ctx, "file/lists", map[string]string{"pid": strconv.FormatInt(pid, 10)}, &files); err != nil {
+		return nil, fmt.Errorf("获取文件列表失败: %w", err)
+	}
+	return files, nil
+}
+
+// fileItem 支持的文件转换为同步项：上传的文档下载原文件，在线文档转换为Markdown，其他类型跳过
+func (s *dootaskSource) fileItem(file dootaskFile) (dootaskItem, bool) {
+	params := map[string]string{"id": strconv.FormatInt(file.ID, 10)}
+	item := dootaskItem{sourceDocument: sourceDocument{
+		URL:     fmt.Sprintf("dootask://file/%d", file.ID),
+		Title:   file.Name,
+		Name:    file.Name,
+		Version: file.UpdatedAt,
+	}}
+
+	ext := strings.ToLower(file.Ext)
+	switch {
+	case slices.Contains(dootaskFileTypes, ext):
+		item.FileType = ext
+		item.load = func(ctx context.Context) ([]byte, error) {
+			return s.client.Download(ctx, "file/content", map[string]string{"id": params["id"], "down": "yes"}, s.maxSize)
+		}
+	case file.Type == "document":
+		item.FileType = "md"
+		item.load = func(ctx context.Context) ([]byte, error) {
+			var data struct {
+				Type    string          `json:"type"`
+				Content json.RawMessage `json:"content"`
+			}
+			if err := s.client.Get(ctx, "file/content", params, &data); err != nil {
+				return nil, err
+			}
+			// 内容可能是文本，也可能是 {type, content} 对象
+			var text string
+			if json.Unmarshal(data.Content, &text) != nil {
+				var content struct {
+					Type    string `json:"type"`
+					Content string `json:"content"`
+				}
+				if err := json.Unmarshal(data.Content, &content); err != nil {
+					return nil, fmt.Errorf("解析文档内容失败: %w", err)
+				}
+				data.Type, text = content.Type, content.Content
+			}
+			if data.Type != "md" {
+				markdown, err := utils.HTMLToMarkdown(text)
+				if err != nil {
+					return nil, err
+				}
+				text = markdown
+			}
+			return []byte("# " + file.Name + "\n\n" + text), nil
+		}
+	default:
+		return item, false
+	}
+	return item, true
+}
+
+// listTasks 列出指定项目（未指定时为全部项目）中的任务
+func (s *dootaskSource) listTasks(ctx context.Context) ([]dootaskItem, error) {
+	projects, err := listAllPages[dootaskProject](ctx, s.client, "project/lists", nil, 0)
+	if err != nil {
+		return nil, fmt.Errorf("获取项目列表失败: %w", err)
+	}
+	if len(s.cfg.ProjectIDs) > 0 {
+		for _, id := range s.cfg.ProjectIDs {
+			if !slices.ContainsFunc(projects, func(p dootaskProject) bool { return p.ID == id }) {
+				return nil, fmt.Errorf("项目(%d)不存在或没有访问权限", id)
+			}
+		}
+		projects = slices.DeleteFunc(projects, func(p dootaskProject) bool {
+			return !slices.Contains(s.cfg.ProjectIDs, p.ID)
+		})
+	}
+
+	items := []dootaskItem{}
+	for _, project := range projects {
+		if len(items) >= s.maxItems {
+			break
+		}
+		tasks, err := listAllPages[dootaskTask](ctx, s.client, "project/task/lists", map[string]string{
+			"project_id": strconv.FormatInt(project.ID, 10),
+		}, s.maxItems-len(items))
+		if err != nil {
+			return nil, fmt.Errorf("获取项目(%s)任务列表失败: %w", project.Name, err)
+		}
+		for _, task := range tasks {
+			if len(items) >= s.maxItems {
+				return items, nil
+			}
+			items = append(items, s.taskItem(project, task))
+		}
+	}
+	return items, nil
+}
+
+// taskItem 任务转换为同步项，文档包含任务信息和任务详情
+func (s *dootaskSource) taskItem(project dootaskProject, task dootaskTask) dootaskItem {
+	item := dootaskItem{sourceDocument: sourceDocument{
+		URL:      fmt.Sprintf("dootask://task/%d", task.ID),
+		Title:    task.Name,
+		Name:     project.Name + "-" + task.Name,
+		FileType: "md",
+		Version:  task.UpdatedAt,
+	}}
+	item.load = func(ctx context.Context) ([]byte, error) {
+		var data struct {
+			Content string `json:"content"`
+		}
+		if err := s.client.Get(ctx, "project/task/content", map[string]string{"task_id": strconv.FormatInt(task.ID, 10)}, &data); err != nil {
+			return nil, err
+		}
+		content, err := utils.HTMLToMarkdown(data.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s\n\n", task.Name)
+		fmt.Fprintf(&b, "- 项目: %s\n", project.Name)
+		if task.CompleteAt != nil && *task.CompleteAt != "" {
+			fmt.Fprintf(&b, "- 状态: 已完成（%s）\n", *task.CompleteAt)
+		} else {
+			b.WriteString("- 状态: 未完成\n")
+		}
+		if task.StartAt != nil && task.EndAt != nil && *task.EndAt != "" {
+			fmt.Fprintf(&b, "- 计划时间: %s ~ %s\n", *task.StartAt, *task.EndAt)
+		}
+		if task.Desc != "" {
+			fmt.Fprintf(&b, "\n%s\n", task.Desc)
+		}
+		if strings.TrimSpace(content) != "" {
+			fmt.Fprintf(&b, "\n%s\n", content)
+		}
+		return []byte(b.String()), nil
+	}
+	return item
+}
+
+// listAllPages 读取分页接口的全部数据，limit 大于0时读取到足够数量即停止
+func listAllPages[T any](ctx context.Context, client utils.DooTaskClient, path string, params map[string]string, limit int) ([]T, error) {
+	all := []T{}
+	for page := 1; ; page++ {
+		query := maps.Clone(params)
+		if query == nil {
+			query = map[string]string{}
+		}
+		query["page"] = strconv.Itoa(page)
+		query["pagesize"] = strconv.Itoa(dootaskPageSize)
+
+		var result dootaskPage[T]
+		if err := client.Get(ctx, path, query, &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Data...)
+		if len(result.Data) == 0 || page >= result.LastPage || (limit > 0 && len(all) >= limit) {
+			return all, nil
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package knowledgebases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/secret"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 重建索引任务状态
+const (
+	ReindexStatusPending   = "pending"
+	ReindexStatusRunning   = "running"
+	ReindexStatusCompleted = "completed"
+	ReindexStatusFailed    = "failed"
+	ReindexStatusCancelled = "cancelled"
+)
+
+var (
+	errReindexRunning  = errors.New("知识库正在重建索引")
+	errMissingOriginal = errors.New("缺少原始文件，请重新上传")
+)
+
+// missingOriginalsError 知识库中有文档缺少原始文件（保存原始文件之前上传的文档），
+// 重建索引会删除这些文档的原有向量数据，需要重新上传或删除后才能重建
+type missingOriginalsError struct {
+	count int64
+}
+
+func (e *missingOriginalsError) Error() string {
+	return fmt.Sprintf("%d 个文档缺少原始文件", e.count)
+}
+
+// reindexState 正在进行的重建索引任务
+type reindexState struct {
+	cancel  context.CancelFunc
+	skipped map[int64]bool // 重建期间没有写入当前向量数据、由任务处理的文档
+}
+
+var (
+	reindexMu  sync.Mutex
+	reindexing = map[int64]*reindexState{} // 按知识库ID
+	liveLocks  sync.Map                    // 知识库ID → *sync.RWMutex：写入当前向量数据时持有读锁，替换向量数据时持有写锁
+)
+
+// reindexedDocument 文档写入新向量数据的结果
+type reindexedDocument struct {
+	title     string
+	updatedAt time.Time // 处理时文档的更新时间，之后有变化需要重新处理
+	chunks    int
+	err       error // 缺少原始文件或AI服务无法解析
+}
+
+// GetReindexJob 获取知识库最近一次重建索引任务
+func GetReindexJob(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleViewer)
+	if !ok {
+		return
+	}
+	job := latestReindexJob(kb.ID)
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "KB_REINDEX_002",
+			"message": "没有重建索引任务",
+			"data":    nil,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// StartReindex 使用当前的向量化设置重建索引（如修复失败的任务或历史混合的向量数据）
+func StartReindex(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	job, err := createReindexJob(*kb, int64(global.GetDooTaskUser(c).UserID), currentReindexSettings(*kb))
+	if err != nil {
+		writeReindexError(c, err)
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceKBReindexJob, job.ID, nil, job)
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelReindex 取消正在进行的重建索引任务，原有向量数据和设置保持不变
+func CancelReindex(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	if !stopReindex(kb.ID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "KB_REINDEX_002",
+			"message": "没有进行中的重建索引任务",
+			"data":    nil,
+		})
+		return
+	}
+	if job := latestReindexJob(kb.ID); job != nil {
+		audit.Record(c, audit.ActionDelete, audit.ResourceKBReindexJob, job.ID, job, nil)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    "SUCCESS",
+		"message": "重建索引已取消",
+		"data":    nil,
+	})
+}
+
+// writeReindexError 写入创建重建索引任务失败的响应
+func writeReindexError(c *gin.Context, err error) {
+	if errors.Is(err, errReindexRunning) {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    "KB_REINDEX_001",
+			"message": "知识库正在重建索引，请等待完成或取消后再修改向量化设置",
+			"data":    nil,
+		})
+		return
+	}
+	var missing *missingOriginalsError
+	if errors.As(err, &missing) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "KB_REINDEX_003",
+			"message": fmt.Sprintf("%d 个文档缺少原始文件，重建索引会使其无法被检索，请重新上传或删除这些文档后再修改向量化设置", missing.count),
+			"data":    gin.H{"missing_documents": missing.count},
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"code":    "DATABASE_002",
+		"message": "创建重建索引任务失败",
+		"data":    nil,
+	})
+}
+
+// latestReindexJob 查询知识库最近一次重建索引任务
+func latestReindexJob(kbID int64) *KBReindexJob {
+	var job KBReindexJob
+	if err := global.DB.Where("knowledge_base_id = ?", kbID).Order("id DESC").First(&job).Error; err != nil {
+		return nil
+	}
+	job.fillSettings()
+	return &job
+}
+
+// fillSettings 填充响应中的目标向量化设置（不包含API密钥）
+func (j *KBReindexJob) fillSettings() {
+	var settings ReindexSettings
+	json.Unmarshal(j.Settings, &settings)
+	j.Provider, j.EmbeddingModel, j.ChunkSize, j.ChunkOverlap = settings.Provider, settings.EmbeddingModel, settings.ChunkSize, settings.ChunkOverlap
+}
+
+// currentReindexSettings 知识库当前的向量化设置
+func currentReindexSettings(kb KnowledgeBase) ReindexSettings {
+	return ReindexSettings{
+		Provider:       kb.Provider,
+		EmbeddingModel: kb.EmbeddingModel,
+		ChunkSize:      kb.ChunkSize,
+		ChunkOverlap:   kb.ChunkOverlap,
+		ApiKey:         kb.ApiKey,
+		ProxyURL:       kb.ProxyURL,
+	}
+}
+
+// apply 使用该设置的知识库（用于向量化）
+func (s ReindexSettings) apply(kb KnowledgeBase) KnowledgeBase {
+	kb.Provider, kb.EmbeddingModel, kb.ChunkSize, kb.ChunkOverlap, kb.ApiKey, kb.ProxyURL = s.Provider, s.EmbeddingModel, s.ChunkSize, s.ChunkOverlap, s.ApiKey, s.ProxyURL
+	return kb
+}
+
+// checkOriginals 检查知识库的文档是否都有原始内容，缺少时返回 *missingOriginalsError
+func checkOriginals(kbID int64) error {
+	var count int64
+	if err := global.DB.Model(&KBDocument{}).
+		Where("knowledge_base_id = ? AND COALESCE(content, '') = ''", kbID).
+		Where("NOT EXISTS (SELECT 1 FROM kb_document_files WHERE kb_document_files.document_id = kb_documents.id)").
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return &missingOriginalsError{count: count}
+	}
+	return nil
+}
+
+// createReindexJob 创建重建索引任务并在后台执行，已有进行中的任务时返回 errReindexRunning，
+// 有文档缺少原始文件时返回 *missingOriginalsError
+func createReindexJob(kb KnowledgeBase, userID int64, settings ReindexSettings) (*KBReindexJob, error) {
+	if err := checkOriginals(kb.ID); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	job := KBReindexJob{
+		KnowledgeBaseID: kb.ID,
+		UserID:          userID,
+		Status:          ReindexStatusPending,
+		Settings:        data,
+	}
+
+	reindexMu.Lock()
+	if _, ok := reindexing[kb.ID]; ok {
+		reindexMu.Unlock()
+		return nil, errReindexRunning
+	}
+	if err := global.DB.Create(&job).Error; err != nil {
+		reindexMu.Unlock()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reindexing[kb.ID] = &reindexState{cancel: cancel, skipped: map[int64]bool{}}
+	reindexMu.Unlock()
+
+	go runReindex(ctx, job, settings)
+	job.fillSettings()
+	return &job, nil
+}
+
+// reindexRunning 知识库是否正在重建索引
+func reindexRunning(kbID int64) bool {
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+	_, ok := reindexing[kbID]
+	return ok
+}
+
+// deferToReindex 知识库正在重建索引时记录文档改由重建任务处理，返回是否已记录
+func deferToReindex(kbID int64, docID int64) bool {
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+	state, ok := reindexing[kbID]
+	if ok {
+		state.skipped[docID] = true
+	}
+	return ok
+}
+
+// stopReindex 取消知识库正在进行的重建索引，没有进行中的任务时返回false
+func stopReindex(kbID int64) bool {
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+	state, ok := reindexing[kbID]
+	if ok {
+		state.cancel()
+	}
+	return ok
+}
+
+// endReindex 移除进行中的任务，返回任务期间由任务处理的文档
+func endReindex(kbID int64) map[int64]bool {
+	reindexMu.Lock()
+	defer reindexMu.Unlock()
+	state, ok := reindexing[kbID]
+	if !ok {
+		return nil
+	}
+	state.cancel()
+	delete(reindexing, kbID)
+	return state.skipped
+}
+
+// liveLock 知识库当前向量数据的读写锁
+func liveLock(kbID int64) *sync.RWMutex {
+	lock, _ := liveLocks.LoadOrStore(kbID, &sync.RWMutex{})
+	return lock.(*sync.RWMutex)
+}
+
+// runReindex 将全部文档按新设置写入临时向量集合，处理期间新增或修改的文档继续处理，
+// 完成后在一个事务中用临时集合替换原有向量数据并应用新设置；失败或取消时原有数据和设置不变
+func runReindex(ctx context.Context, job KBReindexJob, settings ReindexSettings) {
+	collection := fmt.Sprintf("kb_reindex_%d", job.ID)
+	now := time.Now()
+	global.DB.Model(&job).Updates(map[string]any{"status": ReindexStatusRunning, "started_at": &now})
+
+	fail := func(err error) {
+		status := ReindexStatusFailed
+		if ctx.Err() != nil {
+			status, err = ReindexStatusCancelled, errors.New("重建索引已取消")
+		}
+		skipped := endReindex(job.KnowledgeBaseID)
+		if err := dropVectorCollection(collection); err != nil {
+			log.Printf("删除临时向量数据失败: %v, job_id: %d", err, job.ID)
+		}
+		finishReindex(job.ID, status, err)
+		resumeDocuments(job.KnowledgeBaseID, skipped)
+	}
+
+	var kb KnowledgeBase
+	if err := global.DB.First(&kb, job.KnowledgeBaseID).Error; err != nil {
+		fail(err)
+		return
+	}
+	target := settings.apply(kb)
+	results := map[int64]reindexedDocument{}
+	for {
+		pending, err := reindexPending(ctx, job.ID, target, collection, results)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if pending == 0 {
+			break
+		}
+	}
+
+	// 替换期间暂停写入当前向量数据，替换前处理最后的变化
+	lock := liveLock(kb.ID)
+	lock.Lock()
+	if _, err := reindexPending(ctx, job.ID, target, collection, results); err != nil {
+		lock.Unlock()
+		fail(err)
+		return
+	}
+	err := swapVectorCollection(job, settings, collection, results)
+	if err != nil {
+		lock.Unlock()
+		fail(err)
+		return
+	}
+	// 解锁前结束任务，之后的文档直接使用新设置写入
+	endReindex(kb.ID)
+	lock.Unlock()
+}
+
+// reindexPending 将新增或修改的文档写入临时向量集合并更新进度，返回本次处理的文档数；
+// 缺少原始文件或内容无法解析的文档记为失败，AI服务不可用等错误使任务失败
+func reindexPending(ctx context.Context, jobID int64, kb KnowledgeBase, collection string, results map[int64]reindexedDocument) (int, error) {
+	var docs []KBDocument
+	if err := global.DB.Select("id", "title", "content", "updated_at").Where("knowledge_base_id = ?", kb.ID).Order("id ASC").Find(&docs).Error; err != nil {
+		return 0, err
+	}
+
+	processed, failed := 0, 0
+	var pending []KBDocument
+	for _, doc := range docs {
+		result, ok := results[doc.ID]
+		switch {
+		case ok && !doc.UpdatedAt.After(result.updatedAt):
+			if result.err == nil {
+				processed++
+			} else {
+				failed++
+			}
+		default:
+			pending = append(pending, doc)
+		}
+	}
+	progress := func() {
+		global.DB.Model(&KBReindexJob{}).Where("id = ?", jobID).Updates(map[string]any{
+			"total_documents":     len(docs),
+			"processed_documents": processed,
+			"failed_documents":    failed,
+		})
+	}
+	progress()
+
+	for _, doc := range pending {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		// 文档在处理后有修改，删除之前写入的向量数据
+		if previous, ok := results[doc.ID]; ok {
+			if err := deleteCollectionDocument(collection, previous.title); err != nil {
+				return 0, err
+			}
+		}
+
+		result := reindexedDocument{title: doc.Title, updatedAt: doc.UpdatedAt}
+		data, err := documentOriginal(doc)
+		if err == nil {
+			result.chunks, err = embedDocument(kb, collection, doc.Title, data)
+		}
+		if err != nil && !errors.Is(err, errMissingOriginal) && !errors.Is(err, errDocumentRejected) {
+			return 0, err
+		}
+		result.err = err
+		results[doc.ID] = result
+		if err != nil {
+			failed++
+			log.Printf("重建索引处理文档失败: %v, doc_id: %d", err, doc.ID)
+		} else {
+			processed++
+		}
+		progress()
+	}
+	return len(pending), nil
+}
+
+// documentOriginal 文档的原始内容（数据源的文本文档保存在 content 字段，其他文档保存在 kb_document_files）
+func documentOriginal(doc KBDocument) ([]byte, error) {
+	if doc.Content != "" {
+		return []byte(doc.Content), nil
+	}
+	var file KBDocumentFile
+	if err := global.DB.Where("document_id = ?", doc.ID).First(&file).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errMissingOriginal
+		}
+		return nil, err
+	}
+	return file.Data, nil
+}
+
+// swapVectorCollection 在一个事务中删除原有向量数据，将临时集合改为知识库的集合，应用新设置并更新文档状态
+func swapVectorCollection(job KBReindexJob, settings ReindexSettings, collection string, results map[int64]reindexedDocument) error {
+	apiKey, err := secret.Encrypt(settings.ApiKey)
+	if err != nil {
+		return err
+	}
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		var kb KnowledgeBase
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&kb, job.KnowledgeBaseID).Error; err != nil {
+			return err
+		}
+
+		if vectorTablesExist(tx) {
+			statements := []struct {
+				sql  string
+				args []any
+			}{
+				{`DELETE FROM langchain_pg_embedding WHERE collection_id IN (SELECT uuid FROM langchain_pg_collection WHERE name = ?) OR cmetadata->>'source' = ?`, []any{kb.Name, kb.Name}},
+				{`DELETE FROM langchain_pg_collection WHERE name = ?`, []any{kb.Name}},
+				{`UPDATE langchain_pg_collection SET name = ? WHERE name = ?`, []any{kb.Name, collection}},
+				{`UPDATE langchain_pg_embedding SET cmetadata = jsonb_set(cmetadata, '{source}', to_jsonb(?::text))
+					WHERE collection_id IN (SELECT uuid FROM langchain_pg_collection WHERE name = ?)`, []any{kb.Name, kb.Name}},
+				// 重建期间删除或改名的文档
+				{`DELETE FROM langchain_pg_embedding WHERE collection_id IN (SELECT uuid FROM langchain_pg_collection WHERE name = ?)
+					AND cmetadata->>'filename' NOT IN (SELECT title FROM kb_documents WHERE knowledge_base_id = ?)`, []any{kb.Name, kb.ID}},
+			}
+			for _, stmt := range statements {
+				if err := tx.Exec(stmt.sql, stmt.args...).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := tx.Model(&kb).Updates(map[string]any{
+			"provider":        settings.Provider,
+			"embedding_model": settings.EmbeddingModel,
+			"chunk_size":      settings.ChunkSize,
+			"chunk_overlap":   settings.ChunkOverlap,
+			"api_key":         apiKey, // map更新不经过序列化器，需要手动加密
+			"proxy_url":       settings.ProxyURL,
+		}).Error; err != nil {
+			return err
+		}
+
+		processed, failed := 0, 0
+		for docID, result := range results {
+			updates := map[string]any{"status": "processed", "chunk_index": result.chunks}
+			if result.err != nil {
+				updates = map[string]any{"status": "failed"}
+			}
+			query := tx.Model(&KBDocument{}).Where("id = ? AND knowledge_base_id = ?", docID, kb.ID).Updates(updates)
+			if query.Error != nil {
+				return query.Error
+			}
+			if query.RowsAffected == 0 {
+				continue
+			}
+			if result.err != nil {
+				failed++
+			} else {
+				processed++
+			}
+		}
+
+		now := time.Now()
+		updates := map[string]any{
+			"status":              ReindexStatusCompleted,
+			"total_documents":     processed + failed,
+			"processed_documents": processed,
+			"failed_documents":    failed,
+			"error":               nil,
+			"finished_at":         &now,
+		}
+		if failed > 0 {
+			updates["error"] = fmt.Sprintf("%d 个文档处理失败（缺少原始文件的文档需要重新上传）", failed)
+		}
+		return tx.Model(&KBReindexJob{}).Where("id = ?", job.ID).Updates(updates).Error
+	})
+}
+
+// finishReindex 记录任务失败或取消
+func finishReindex(jobID int64, status string, err error) {
+	now := time.Now()
+	global.DB.Model(&KBReindexJob{}).Where("id = ?", jobID).Updates(map[string]any{
+		"status":      status,
+		"error":       err.Error(),
+		"finished_at": &now,
+	})
+}
+
+// resumeDocuments 任务失败后，将重建期间由任务接管的文档写入原有向量数据
+func resumeDocuments(kbID int64, docIDs map[int64]bool) {
+	if len(docIDs) == 0 {
+		return
+	}
+	var kb KnowledgeBase
+	if err := global.DB.First(&kb, kbID).Error; err != nil {
+		return
+	}
+	var docs []KBDocument
+	global.DB.Where("knowledge_base_id = ? AND id IN ?", kbID, slices.Collect(maps.Keys(docIDs))).Find(&docs)
+	for _, doc := range docs {
+		data, err := documentOriginal(doc)
+		if err != nil {
+			global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
+			continue
+		}
+		processDocument(kb, doc, doc.Title, data)
+	}
+}
+
+// vectorTablesExist 向量数据表是否已由AI服务创建
+func vectorTablesExist(db *gorm.DB) bool {
+	var exists bool
+	db.Raw(`SELECT to_regclass('langchain_pg_collection') IS NOT NULL`).Scan(&exists)
+	return exists
+}
+
+// dropVectorCollection 删除临时向量集合及其数据
+func dropVectorCollection(collection string) error {
+	if !vectorTablesExist(global.DB) {
+		return nil
+	}
+	return global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM langchain_pg_embedding WHERE collection_id IN (SELECT uuid FROM langchain_pg_collection WHERE name = ?)`, collection).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`DELETE FROM langchain_pg_collection WHERE name = ?`, collection).Error
+	})
+}
+
+// deleteCollectionDocument 删除向量集合中指定文档的向量数据
+func deleteCollectionDocument(collection string, fileName string) error {
+	return global.DB.Exec(`DELETE FROM langchain_pg_embedding WHERE collection_id IN (SELECT uuid FROM langchain_pg_collection WHERE name = ?) AND cmetadata->>'filename' = ?`, collection, fileName).Error
+}
+
+// recoverReindexJobs 服务重启前未完成的重建索引标记为失败，删除临时向量数据并处理被任务接管的文档
+func recoverReindexJobs() {
+	var jobs []KBReindexJob
+	global.DB.Where("status IN ?", []string{ReindexStatusPending, ReindexStatusRunning}).Find(&jobs)
+	for _, job := range jobs {
+		if err := dropVectorCollection(fmt.Sprintf("kb_reindex_%d", job.ID)); err != nil {
+			log.Printf("删除临时向量数据失败: %v, job_id: %d", err, job.ID)
+		}
+		finishReindex(job.ID, ReindexStatusFailed, errors.New("重建索引被服务重启中断"))
+
+		// 任务接管的文档没有写入原有向量数据，状态停留在处理中
+		var docIDs []int64
+		global.DB.Model(&KBDocument{}).Where("knowledge_base_id = ? AND status = ?", job.KnowledgeBaseID, "processing").Pluck("id", &docIDs)
+		skipped := map[int64]bool{}
+		for _, id := range docIDs {
+			skipped[id] = true
+		}
+		go resumeDocuments(job.KnowledgeBaseID, skipped)
+	}
+}
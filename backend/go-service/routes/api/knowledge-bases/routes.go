@@ -4,18 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"dootask-ai/go-service/audit"
 	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/secret"
+	"dootask-ai/go-service/sysconfig"
 	"dootask-ai/go-service/utils"
 
-	"github.com/duke-git/lancet/v2/convertor"
-	"github.com/duke-git/lancet/v2/cryptor"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
@@ -36,7 +40,21 @@ func RegisterRoutes(router *gin.RouterGroup) {
 		kbGroup.GET("/:id/documents", ListDocuments)            // 获取文档列表
 		kbGroup.POST("/:id/documents", UploadDocument)          // 上传文档
 		kbGroup.DELETE("/:id/documents/:docId", DeleteDocument) // 删除文档
+
+		// 数据源管理
+		kbGroup.GET("/:id/sources", ListSources)                // 获取数据源列表
+		kbGroup.POST("/:id/sources", CreateSource)              // 添加数据源
+		kbGroup.PUT("/:id/sources/:sourceId", UpdateSource)     // 更新数据源定时同步设置
+		kbGroup.POST("/:id/sources/:sourceId/sync", SyncSource) // 立即同步数据源
+		kbGroup.DELETE("/:id/sources/:sourceId", DeleteSource)  // 删除数据源
+
+		// 重建索引
+		kbGroup.GET("/:id/reindex", GetReindexJob)    // 获取最近一次重建索引任务
+		kbGroup.POST("/:id/reindex", StartReindex)    // 重建索引
+		kbGroup.DELETE("/:id/reindex", CancelReindex) // 取消重建索引
 	}
+	StartSourceScheduler()
+	recoverReindexJobs()
 }
 
 // ListKnowledgeBases 获取知识库列表
@@ -97,8 +115,8 @@ func ListKnowledgeBases(c *gin.Context) {
 	// 构建查询
 	query := global.DB.Model(&KnowledgeBase{})
 
-	// 设置默认筛选条件
-	query = query.Where("user_id = ?", global.GetDooTaskUser(c).UserID)
+	// 设置默认筛选条件（自己创建的和共享给自己的）
+	query = query.Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, permission.RoleViewer))
 
 	// 应用筛选条件
 	if filters.Search != "" {
@@ -142,6 +160,11 @@ func ListKnowledgeBases(c *gin.Context) {
 		return
 	}
 
+	// 隐藏敏感信息
+	for i := range knowledgeBases {
+		knowledgeBases[i] = knowledgeBases[i].Masked()
+	}
+
 	// 构造响应数据
 	data := KnowledgeBaseListData{
 		Items: knowledgeBases,
@@ -207,13 +230,10 @@ func CreateKnowledgeBase(c *gin.Context) {
 		req.Metadata = []byte("{}")
 	}
 
+	// api_key 由 secret 序列化器加密存储
 	apiKey := ""
 	if req.ApiKey != nil {
 		apiKey = *req.ApiKey
-		appKey := utils.GetEnvWithDefault("API_KEY", "")
-		if appKey != "" {
-			apiKey = convertor.ToStdBase64(string(cryptor.AesGcmEncrypt([]byte(*req.ApiKey), []byte(appKey))))
-		}
 	}
 
 	// 创建知识库
@@ -258,8 +278,9 @@ func CreateKnowledgeBase(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceKnowledgeBase, kb.ID, nil, createdKB)
 
-	c.JSON(http.StatusOK, createdKB)
+	c.JSON(http.StatusOK, createdKB.Masked())
 }
 
 // GetKnowledgeBase 获取知识库详情
@@ -278,6 +299,7 @@ func GetKnowledgeBase(c *gin.Context) {
 	var kb KnowledgeBase
 	if err := global.DB.
 		Select("knowledge_bases.*, (SELECT COUNT(*) FROM kb_documents WHERE knowledge_base_id = knowledge_bases.id AND is_active = true) as documents_count").
+		Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, permission.RoleViewer)).
 		Where("knowledge_bases.id = ?", id).
 		First(&kb).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -317,9 +339,8 @@ func GetKnowledgeBase(c *gin.Context) {
 		First(&lastUpload)
 
 	// 隐藏敏感信息
-	if kb.ApiKey != "" {
-		kb.ApiKey = "***"
-	}
+	kb = kb.Masked()
+	kb.ReindexJob = latestReindexJob(kb.ID)
 
 	// 构造响应
 	response := KnowledgeBaseResponse{
@@ -373,7 +394,7 @@ func UpdateKnowledgeBase(c *gin.Context) {
 
 	// 检查知识库是否存在
 	var kb KnowledgeBase
-	if err := global.DB.Where("user_id = ?", global.GetDooTaskUser(c).UserID).First(&kb, id).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, permission.RoleEditor)).First(&kb, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "KB_002",
@@ -393,7 +414,7 @@ func UpdateKnowledgeBase(c *gin.Context) {
 	// 检查名称唯一性（如果要更新名称）
 	if req.Name != nil && *req.Name != kb.Name {
 		var count int64
-		if err := global.DB.Model(&KnowledgeBase{}).Where("user_id = ? AND name = ? AND id != ?", global.GetDooTaskUser(c).UserID, *req.Name, id).Count(&count).Error; err != nil {
+		if err := global.DB.Model(&KnowledgeBase{}).Where("user_id = ? AND name = ? AND id != ?", kb.UserID, *req.Name, id).Count(&count).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"code":    "DATABASE_001",
 				"message": "检查名称唯一性失败",
@@ -429,12 +450,17 @@ func UpdateKnowledgeBase(c *gin.Context) {
 		updateData["chunk_overlap"] = *req.ChunkOverlap
 	}
 	if req.ApiKey != nil {
-		apiKey := *req.ApiKey
-		appKey := utils.GetEnvWithDefault("API_KEY", "")
-		if appKey != "" {
-			apiKey = convertor.ToStdBase64(string(cryptor.AesGcmEncrypt([]byte(*req.ApiKey), []byte(appKey))))
+		// map更新不经过序列化器，需要手动加密
+		apiKey, err := secret.Encrypt(*req.ApiKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_002",
+				"message": "加密API密钥失败",
+				"data":    nil,
+			})
+			return
 		}
-		updateData["api_key"] = &apiKey
+		updateData["api_key"] = apiKey
 	}
 	if req.Provider != nil {
 		updateData["provider"] = *req.Provider
@@ -449,7 +475,57 @@ func UpdateKnowledgeBase(c *gin.Context) {
 		updateData["is_active"] = *req.IsActive
 	}
 
+	// 重建索引期间不能修改向量化设置
+	embeddingChanged := (req.EmbeddingModel != nil && *req.EmbeddingModel != kb.EmbeddingModel) ||
+		(req.Provider != nil && *req.Provider != kb.Provider) ||
+		(req.ChunkSize != nil && *req.ChunkSize != kb.ChunkSize) ||
+		(req.ChunkOverlap != nil && *req.ChunkOverlap != kb.ChunkOverlap)
+	if (embeddingChanged || req.ApiKey != nil || req.ProxyURL != nil) && reindexRunning(kb.ID) {
+		writeReindexError(c, errReindexRunning)
+		return
+	}
+
+	// 已有文档时向量化设置的变化需要重建索引，新设置（包括API密钥和代理地址）在重建完成后生效
+	var reindexSettings *ReindexSettings
+	if embeddingChanged {
+		var count int64
+		global.DB.Model(&KBDocument{}).Where("knowledge_base_id = ?", kb.ID).Count(&count)
+		if count > 0 {
+			settings := currentReindexSettings(kb)
+			if req.EmbeddingModel != nil {
+				settings.EmbeddingModel = *req.EmbeddingModel
+			}
+			if req.Provider != nil {
+				settings.Provider = *req.Provider
+			}
+			if req.ChunkSize != nil {
+				settings.ChunkSize = *req.ChunkSize
+			}
+			if req.ChunkOverlap != nil {
+				settings.ChunkOverlap = *req.ChunkOverlap
+			}
+			if req.ApiKey != nil {
+				settings.ApiKey = *req.ApiKey
+			}
+			if req.ProxyURL != nil {
+				settings.ProxyURL = *req.ProxyURL
+			}
+			for _, key := range []string{"embedding_model", "provider", "chunk_size", "chunk_overlap", "api_key", "proxy_url"} {
+				delete(updateData, key)
+			}
+			reindexSettings = &settings
+		}
+	}
+	// 有文档缺少原始文件时不修改设置，避免重建索引删除其原有向量数据
+	if reindexSettings != nil {
+		if err := checkOriginals(kb.ID); err != nil {
+			writeReindexError(c, err)
+			return
+		}
+	}
+
 	// 更新知识库
+	before := kb
 	if err := global.DB.Model(&kb).Updates(updateData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_002",
@@ -472,8 +548,19 @@ func UpdateKnowledgeBase(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceKnowledgeBase, updatedKB.ID, before, updatedKB)
 
-	c.JSON(http.StatusOK, updatedKB)
+	if reindexSettings != nil {
+		job, err := createReindexJob(updatedKB, int64(global.GetDooTaskUser(c).UserID), *reindexSettings)
+		if err != nil {
+			writeReindexError(c, err)
+			return
+		}
+		audit.Record(c, audit.ActionCreate, audit.ResourceKBReindexJob, job.ID, nil, job)
+		updatedKB.ReindexJob = job
+	}
+
+	c.JSON(http.StatusOK, updatedKB.Masked())
 }
 
 // DeleteKnowledgeBase 删除知识库
@@ -491,7 +578,7 @@ func DeleteKnowledgeBase(c *gin.Context) {
 
 	// 检查知识库是否存在
 	var kb KnowledgeBase
-	if err := global.DB.Where("user_id = ?", global.GetDooTaskUser(c).UserID).First(&kb, id).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, permission.RoleOwner)).First(&kb, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "KB_002",
@@ -532,6 +619,10 @@ func DeleteKnowledgeBase(c *gin.Context) {
 		return
 	}
 
+	// 停止正在进行的数据源同步和重建索引
+	stopKnowledgeBaseSyncs(id)
+	stopReindex(id)
+
 	// 开始事务
 	tx := global.DB.Begin()
 
@@ -559,6 +650,8 @@ func DeleteKnowledgeBase(c *gin.Context) {
 
 	// 提交事务
 	tx.Commit()
+	permission.RemoveShares(global.DB, permission.ResourceKnowledgeBase, id)
+	audit.Record(c, audit.ActionDelete, audit.ResourceKnowledgeBase, id, kb, nil)
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    "SUCCESS",
@@ -635,7 +728,7 @@ func ListDocuments(c *gin.Context) {
 
 	// 检查知识库是否存在
 	var kb KnowledgeBase
-	if err := global.DB.First(&kb, kbId).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, permission.RoleViewer)).First(&kb, kbId).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "KB_002",
@@ -722,7 +815,7 @@ func UploadDocument(c *gin.Context) {
 
 	// 检查知识库是否存在
 	var kb KnowledgeBase
-	if err := global.DB.First(&kb, kbId).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, permission.RoleEditor)).First(&kb, kbId).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "KB_002",
@@ -767,22 +860,33 @@ func UploadDocument(c *gin.Context) {
 		return
 	}
 
-	// 验证文件大小 (限制为50MB)
-	maxSize := int64(50 * 1024 * 1024)
-	if fileSize > maxSize {
+	// 验证文件大小（系统配置 max_file_upload_size，单位MB）
+	maxSizeMB := sysconfig.Int(sysconfig.KeyMaxFileUploadSize, 50)
+	if fileSize > int64(maxSizeMB)*1024*1024 {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    "VALIDATION_001",
-			"message": "文件大小超过限制(50MB)",
+			"message": fmt.Sprintf("文件大小超过限制(%dMB)", maxSizeMB),
 			"data":    nil,
 		})
 		return
 	}
 
+	// 读取文件内容
+	fileBytes, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "读取上传文件失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
 	// 创建文档记录
 	doc := KBDocument{
 		KnowledgeBaseID: kbId,
 		Title:           fileName,
-		Content:         "", // 不再存储内容，直接传给AI服务
+		Content:         "", // 原始文件保存在 kb_document_files，用于重建索引
 		FilePath:        nil,
 		FileType:        fileType,
 		FileSize:        fileSize,
@@ -791,7 +895,12 @@ func UploadDocument(c *gin.Context) {
 		Status:          "processing",
 	}
 
-	if err := global.DB.Create(&doc).Error; err != nil {
+	if err := global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&doc).Error; err != nil {
+			return err
+		}
+		return tx.Create(&KBDocumentFile{DocumentID: doc.ID, Data: fileBytes}).Error
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_002",
 			"message": "保存文档记录失败",
@@ -799,8 +908,50 @@ func UploadDocument(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceDocument, doc.ID, nil, doc)
 
-	// 调用AI服务处理文档
+	// 启动异步处理文档
+	go processDocument(kb, doc, fileName, fileBytes)
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// processDocument 将文档上传到AI服务进行切分和向量化，并更新文档状态，返回是否处理成功；
+// 知识库正在重建索引时文档由重建任务写入新的向量数据
+func processDocument(kb KnowledgeBase, doc KBDocument, fileName string, file []byte) bool {
+	lock := liveLock(kb.ID)
+	lock.RLock()
+	defer lock.RUnlock()
+	if deferToReindex(kb.ID, doc.ID) {
+		return true
+	}
+
+	// 重建索引完成后向量化设置会变化，使用最新的设置
+	if err := global.DB.First(&kb, kb.ID).Error; err != nil {
+		global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
+		return false
+	}
+	chunks, err := embedDocument(kb, kb.Name, fileName, file)
+	if err != nil {
+		// 更新文档状态为处理失败
+		global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
+		log.Printf("%v, doc_id: %d\n", err, doc.ID)
+		return false
+	}
+
+	// 更新文档状态为已处理
+	global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Updates(map[string]interface{}{
+		"chunk_index": chunks,
+		"status":      "processed",
+	})
+	return true
+}
+
+// errDocumentRejected AI服务无法解析文档内容（与服务不可用等错误区分）
+var errDocumentRejected = errors.New("AI服务无法处理该文档")
+
+// embedDocument 按知识库的向量化设置将文档上传到AI服务，写入指定的向量集合，返回分块数量
+func embedDocument(kb KnowledgeBase, collection string, fileName string, file []byte) (int, error) {
 	baseURL := utils.GetEnvWithDefault("AI_BASE_URL", fmt.Sprintf("http://localhost:%s", utils.GetEnvWithDefault("PYTHON_AI_SERVICE_PORT", "8001")))
 	requestTimeout, _ := strconv.Atoi(utils.GetEnvWithDefault("AI_REQUEST_TIMEOUT", "60"))
 
@@ -809,84 +960,53 @@ func UploadDocument(c *gin.Context) {
 		utils.WithTimeout(time.Duration(requestTimeout)*time.Second),
 	)
 
-	// 启动异步处理文档
-	go func(doc KBDocument, file []byte) {
-		// 准备上传参数
-		additionalParams := map[string]string{
-			"knowledge_base": kb.Name,
-			"provider":       kb.Provider,
-			"model":          kb.EmbeddingModel,
-			"api_key":        kb.ApiKey,
-			"proxy_url":      kb.ProxyURL,
-			"chunk_size":     strconv.Itoa(kb.ChunkSize),
-			"chunk_overlap":  strconv.Itoa(kb.ChunkOverlap),
-		}
-
-		log.Printf("%v", doc.ID)
-		reader := bytes.NewReader(file)
-		// 上传到AI服务
-		response, err := httpClient.UploadFileWithReader(
-			context.Background(),
-			"/documents/upload",
-			nil,
-			nil,
-			"POST",
-			reader,
-			fileName,
-			"files",
-			additionalParams,
-		)
-
-		if err != nil {
-			// 更新文档状态为处理失败
-			global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
-			log.Printf("上传文档到AI服务失败: %v, doc_id: %d\n", err, doc.ID)
-			return
-		}
-
-		if response.StatusCode != http.StatusOK {
-			// 更新文档状态为处理失败
-			global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
-			log.Printf("AI服务返回错误: status=%d, body=%s\n", response.StatusCode, string(response.Body))
-			return
-		}
-
-		var uploadDocumentResponse UploadDocumentResponse
-		if err := json.Unmarshal(response.Body, &uploadDocumentResponse); err != nil {
-			// 更新文档状态为处理失败
-			global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
-			log.Printf("解析上传文档响应失败: %v\n", err)
-			return
-		}
+	// 准备上传参数
+	additionalParams := map[string]string{
+		"knowledge_base": collection,
+		"provider":       kb.Provider,
+		"model":          kb.EmbeddingModel,
+		"api_key":        secret.Transport(kb.ApiKey),
+		"proxy_url":      kb.ProxyURL,
+		"chunk_size":     strconv.Itoa(kb.ChunkSize),
+		"chunk_overlap":  strconv.Itoa(kb.ChunkOverlap),
+	}
+
+	reader := bytes.NewReader(file)
+	// 上传到AI服务
+	response, err := httpClient.UploadFileWithReader(
+		context.Background(),
+		"/documents/upload",
+		nil,
+		nil,
+		"POST",
+		reader,
+		fileName,
+		"files",
+		additionalParams,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("上传文档到AI服务失败: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("AI服务返回错误: status=%d, body=%s", response.StatusCode, string(response.Body))
+	}
 
-		if len(uploadDocumentResponse.ProcessedFiles) == 0 {
-			// 更新文档状态为处理失败
-			global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
-			log.Printf("上传文档到AI服务失败，没有处理的文件: %v, doc_id: %d\n", uploadDocumentResponse, doc.ID)
-			return
-		}
+	var uploadDocumentResponse UploadDocumentResponse
+	if err := json.Unmarshal(response.Body, &uploadDocumentResponse); err != nil {
+		return 0, fmt.Errorf("解析上传文档响应失败: %v", err)
+	}
+	if len(uploadDocumentResponse.ProcessedFiles) == 0 {
+		return 0, fmt.Errorf("上传文档到AI服务失败，没有处理的文件: %v", uploadDocumentResponse)
+	}
 
-		for _, processedFile := range uploadDocumentResponse.ProcessedFiles {
-			if processedFile.Status == "success" {
-				// 更新文档状态为已处理
-				global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Updates(map[string]interface{}{
-					"chunk_index": processedFile.Chunks,
-					"status":      "processed",
-				})
-			} else {
-				// 更新文档状态为处理失败
-				global.DB.Model(&KBDocument{}).Where("id = ?", doc.ID).Update("status", "failed")
-			}
+	chunks := 0
+	for _, processedFile := range uploadDocumentResponse.ProcessedFiles {
+		if processedFile.Status != "success" {
+			return 0, fmt.Errorf("%w: %s", errDocumentRejected, processedFile.Error)
 		}
-	}(doc, func() []byte {
-		// 读取文件内容
-		fileBytes := make([]byte, fileSize)
-		file.Seek(0, 0) // 重置文件指针
-		file.Read(fileBytes)
-		return fileBytes
-	}())
-
-	c.JSON(http.StatusOK, doc)
+		chunks += processedFile.Chunks
+	}
+	return chunks, nil
 }
 
 // DeleteDocument 删除文档
@@ -913,6 +1033,16 @@ func DeleteDocument(c *gin.Context) {
 		return
 	}
 
+	// 检查是否有知识库的编辑权限
+	if !permission.Can(c, permission.ResourceKnowledgeBase, kbId, permission.RoleEditor) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "KB_002",
+			"message": "知识库不存在",
+			"data":    nil,
+		})
+		return
+	}
+
 	// 检查文档是否存在且属于指定的知识库
 	var doc KBDocument
 	if err := global.DB.Where("id = ? AND knowledge_base_id = ?", docId, kbId).First(&doc).Error; err != nil {
@@ -959,6 +1089,7 @@ func DeleteDocument(c *gin.Context) {
 
 	// 提交事务
 	tx.Commit()
+	audit.Record(c, audit.ActionDelete, audit.ResourceDocument, doc.ID, doc, nil)
 	// 提交事务后，删除PGVector中的向量数据
 	if err := deleteVectorEmbeddings(kbId, doc.Title); err != nil {
 		// 记录日志，但不影响主流程
@@ -0,0 +1,707 @@
+package knowledgebases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/sysconfig"
+	"dootask-ai/go-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 数据源类型
+const (
+	SourceTypeURL     = "url"     // 网页
+	SourceTypeDooTask = "dootask" // DooTask文件或项目任务
+)
+
+// 网页数据源抓取方式
+const (
+	URLModePage    = "page"
+	URLModeSitemap = "sitemap"
+	URLModeCrawl   = "crawl"
+)
+
+// 数据源同步状态
+const (
+	SourceStatusPending   = "pending"
+	SourceStatusSyncing   = "syncing"
+	SourceStatusCompleted = "completed"
+	SourceStatusFailed    = "failed"
+)
+
+// fileNamePattern 数据源文档名称中需要替换的字符
+var fileNamePattern = regexp.MustCompile(`[^\p{L}\p{N}._-]+`)
+
+var (
+	syncMu  sync.Mutex
+	syncing = map[int64]context.CancelFunc{} // 正在同步的数据源
+)
+
+// ListSources 获取知识库的数据源列表
+func ListSources(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleViewer)
+	if !ok {
+		return
+	}
+
+	sources := []KBSource{}
+	if err := global.DB.
+		Select("kb_sources.*, (SELECT COUNT(*) FROM kb_documents WHERE source_id = kb_sources.id) as documents_count").
+		Where("knowledge_base_id = ?", kb.ID).
+		Order("id ASC").
+		Find(&sources).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询数据源失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SourceListData{Items: sources})
+}
+
+// CreateSource 添加数据源并开始同步
+func CreateSource(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var req CreateSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+	config, err := normalizeSourceConfig(req.Type, req.Config)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据源配置验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	source := KBSource{
+		KnowledgeBaseID: kb.ID,
+		Type:            req.Type,
+		Config:          config,
+		Status:          SourceStatusPending,
+		SyncInterval:    req.SyncInterval,
+	}
+	if !authorizeSource(c, &source) {
+		return
+	}
+	if err := global.DB.Create(&source).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "保存数据源失败",
+			"data":    nil,
+		})
+		return
+	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceKBSource, source.ID, nil, source)
+
+	startSync(source)
+	source.Status = SourceStatusSyncing
+	c.JSON(http.StatusOK, source)
+}
+
+// SyncSource 立即重新同步数据源
+func SyncSource(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	source, ok := findSource(c, kb.ID)
+	if !ok {
+		return
+	}
+	if !authorizeSource(c, source) {
+		return
+	}
+	if source.Type == SourceTypeDooTask {
+		global.DB.Model(source).Select("user_id", "credential").Updates(source)
+	}
+	if !startSync(*source) {
+		c.JSON(http.StatusConflict, gin.H{
+			"code":    "KB_SOURCE_002",
+			"message": "数据源正在同步中",
+			"data":    nil,
+		})
+		return
+	}
+
+	source.Status = SourceStatusSyncing
+	c.JSON(http.StatusOK, source)
+}
+
+// UpdateSource 更新数据源的定时同步设置
+func UpdateSource(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	source, ok := findSource(c, kb.ID)
+	if !ok {
+		return
+	}
+
+	var req UpdateSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	before := *source
+	if req.SyncInterval != nil {
+		source.SyncInterval = *req.SyncInterval
+	}
+	// 开启定时同步的用户成为之后同步使用的身份
+	if !authorizeSource(c, source) {
+		return
+	}
+	if err := global.DB.Model(source).Select("sync_interval", "user_id", "credential").Updates(source).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "更新数据源失败",
+			"data":    nil,
+		})
+		return
+	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceKBSource, source.ID, before, source)
+
+	c.JSON(http.StatusOK, source)
+}
+
+// DeleteSource 删除数据源及其生成的文档和向量数据
+func DeleteSource(c *gin.Context) {
+	kb, ok := findKnowledgeBase(c, permission.RoleEditor)
+	if !ok {
+		return
+	}
+	source, ok := findSource(c, kb.ID)
+	if !ok {
+		return
+	}
+
+	stopSync(source.ID)
+	var docs []KBDocument
+	global.DB.Where("source_id = ?", source.ID).Find(&docs)
+	if err := global.DB.Delete(source).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除数据源失败",
+			"data":    nil,
+		})
+		return
+	}
+	// 文档由外键级联删除，向量数据需要单独删除
+	for _, doc := range docs {
+		if err := deleteVectorEmbeddings(kb.ID, doc.Title); err != nil {
+			log.Printf("删除向量数据失败: %v", err)
+		}
+	}
+	audit.Record(c, audit.ActionDelete, audit.ResourceKBSource, source.ID, source, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    "SUCCESS",
+		"message": "数据源删除成功",
+		"data":    nil,
+	})
+}
+
+// findKnowledgeBase 按路由参数查询有指定权限的知识库，失败时写入错误响应
+func findKnowledgeBase(c *gin.Context, role permission.Role) (*KnowledgeBase, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的知识库ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	var kb KnowledgeBase
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceKnowledgeBase, role)).First(&kb, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "KB_002",
+				"message": "知识库不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询知识库失败",
+				"data":    nil,
+			})
+		}
+		return nil, false
+	}
+	return &kb, true
+}
+
+// findSource 按路由参数查询知识库的数据源，失败时写入错误响应
+func findSource(c *gin.Context, kbID int64) (*KBSource, bool) {
+	id, err := strconv.ParseInt(c.Param("sourceId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的数据源ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	var source KBSource
+	if err := global.DB.Where("id = ? AND knowledge_base_id = ?", id, kbID).First(&source).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "KB_SOURCE_001",
+				"message": "数据源不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询数据源失败",
+				"data":    nil,
+			})
+		}
+		return nil, false
+	}
+	return &source, true
+}
+
+// authorizeSource DooTask数据源记录当前用户的身份，同步时只拉取该用户有权访问的内容，失败时写入错误响应
+func authorizeSource(c *gin.Context, source *KBSource) bool {
+	if source.Type != SourceTypeDooTask {
+		return true
+	}
+	client := global.GetDooTaskClient(c)
+	user := global.GetDooTaskUser(c)
+	if client == nil || user == nil || client.Token == "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    "KB_SOURCE_003",
+			"message": "DooTask数据源需要登录DooTask后操作",
+			"data":    nil,
+		})
+		return false
+	}
+	userID := int64(user.UserID)
+	source.UserID = &userID
+	source.Credential = &client.Token
+	return true
+}
+
+// normalizeSourceConfig 校验数据源配置并补全默认值
+func normalizeSourceConfig(sourceType string, raw json.RawMessage) (json.RawMessage, error) {
+	switch sourceType {
+	case SourceTypeURL:
+		var cfg URLSourceConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, err
+		}
+		cfg.URL = strings.TrimSpace(cfg.URL)
+		if cfg.Mode == "" {
+			cfg.Mode = URLModePage
+		}
+		if err := validator.New().Struct(&cfg); err != nil {
+			return nil, err
+		}
+		if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("只支持 http 和 https 地址")
+		}
+		return json.Marshal(cfg)
+	case SourceTypeDooTask:
+		var cfg DooTaskSourceConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, err
+		}
+		if cfg.Kind == "" {
+			cfg.Kind = DooTaskKindFile
+		}
+		if err := validator.New().Struct(&cfg); err != nil {
+			return nil, err
+		}
+		return json.Marshal(cfg)
+	default:
+		return nil, fmt.Errorf("不支持的数据源类型: %s", sourceType)
+	}
+}
+
+// startSync 在后台同步数据源，数据源正在同步时返回false
+func startSync(source KBSource) bool {
+	syncMu.Lock()
+	if _, ok := syncing[source.ID]; ok {
+		syncMu.Unlock()
+		return false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	syncing[source.ID] = cancel
+	syncMu.Unlock()
+
+	global.DB.Model(&KBSource{}).Where("id = ?", source.ID).Update("status", SourceStatusSyncing)
+	go func() {
+		defer func() {
+			syncMu.Lock()
+			delete(syncing, source.ID)
+			syncMu.Unlock()
+			cancel()
+		}()
+		syncSource(ctx, source)
+	}()
+	return true
+}
+
+// StartSourceScheduler 按同步间隔定时同步数据源
+func StartSourceScheduler() {
+	// 服务重启前未完成的同步不会继续，标记为失败
+	global.DB.Model(&KBSource{}).Where("status = ?", SourceStatusSyncing).Updates(map[string]any{
+		"status":     SourceStatusFailed,
+		"last_error": "同步被服务重启中断",
+	})
+
+	go func() {
+		for range time.Tick(time.Minute) {
+			syncDueSources()
+		}
+	}()
+}
+
+// syncDueSources 同步距上次同步已超过同步间隔的数据源
+func syncDueSources() {
+	var sources []KBSource
+	if err := global.DB.Where("sync_interval > 0 AND status <> ?", SourceStatusSyncing).Find(&sources).Error; err != nil {
+		log.Printf("查询定时同步数据源失败: %v", err)
+		return
+	}
+	for _, source := range sources {
+		if source.LastSyncedAt == nil || time.Since(*source.LastSyncedAt) >= time.Duration(source.SyncInterval)*time.Minute {
+			startSync(source)
+		}
+	}
+}
+
+// stopSync 取消数据源正在进行的同步
+func stopSync(sourceID int64) {
+	syncMu.Lock()
+	defer syncMu.Unlock()
+	if cancel, ok := syncing[sourceID]; ok {
+		cancel()
+	}
+}
+
+// stopKnowledgeBaseSyncs 取消知识库所有数据源正在进行的同步
+func stopKnowledgeBaseSyncs(kbID int64) {
+	var sourceIDs []int64
+	global.DB.Model(&KBSource{}).Where("knowledge_base_id = ?", kbID).Pluck("id", &sourceIDs)
+	for _, id := range sourceIDs {
+		stopSync(id)
+	}
+}
+
+// syncSource 同步数据源，内容未变化的文档跳过，同步完整结束后删除数据源中已不存在的文档
+func syncSource(ctx context.Context, source KBSource) {
+	var kb KnowledgeBase
+	if err := global.DB.First(&kb, source.KnowledgeBaseID).Error; err != nil {
+		return
+	}
+
+	var synced, failed int
+	var err error
+	switch source.Type {
+	case SourceTypeDooTask:
+		synced, failed, err = syncDooTaskSource(ctx, kb, source)
+	default:
+		synced, failed, err = syncURLSource(ctx, kb, source)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	finishSync(source.ID, synced, failed, err)
+}
+
+// syncURLSource 抓取网页并更新文档（本次抓取失败的页面保留）
+func syncURLSource(ctx context.Context, kb KnowledgeBase, source KBSource) (int, int, error) {
+	var cfg URLSourceConfig
+	if err := json.Unmarshal(source.Config, &cfg); err != nil {
+		return 0, 0, err
+	}
+	maxPages := sysconfig.Int(sysconfig.KeyKBCrawlMaxPages, 200)
+	if cfg.MaxPages > 0 && cfg.MaxPages < maxPages {
+		maxPages = cfg.MaxPages
+	}
+
+	seen := map[string]bool{}
+	failedURLs := map[string]bool{}
+	synced, failed := 0, 0
+	err := newCrawler().crawl(ctx, cfg, maxPages, func(page *crawledPage) {
+		seen[page.URL] = true
+		if saveDocument(kb, source.ID, pageDocument(page)) {
+			synced++
+		} else {
+			failed++
+		}
+	}, func(target string, err error) {
+		failedURLs[target] = true
+		failed++
+		log.Printf("抓取页面失败: %s, source_id: %d, %v", target, source.ID, err)
+	})
+	if err == nil && ctx.Err() == nil {
+		removeStaleDocuments(kb.ID, source.ID, func(pageURL string) bool {
+			return seen[pageURL] || failedURLs[pageURL]
+		})
+	}
+	return synced, failed, err
+}
+
+// syncDooTaskSource 以最近一次发起同步的用户身份拉取DooTask文件或任务并更新文档，
+// 更新时间未变化的跳过；已删除或无权访问的文件、任务在列表完整时删除对应文档和向量数据
+func syncDooTaskSource(ctx context.Context, kb KnowledgeBase, source KBSource) (int, int, error) {
+	var cfg DooTaskSourceConfig
+	if err := json.Unmarshal(source.Config, &cfg); err != nil {
+		return 0, 0, err
+	}
+	if source.Credential == nil || *source.Credential == "" {
+		return 0, 0, fmt.Errorf("缺少DooTask授权，请手动同步一次")
+	}
+
+	items, err := newDooTaskSource(*source.Credential, cfg).list(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	keep := map[string]bool{}
+	synced, failed := 0, 0
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return synced, failed, ctx.Err()
+		}
+		keep[item.URL] = true
+		if documentUnchanged(source.ID, item.URL, item.Version) {
+			synced++
+			continue
+		}
+		data, err := item.load(ctx)
+		if err != nil {
+			failed++
+			log.Printf("获取DooTask内容失败: %s, source_id: %d, %v", item.URL, source.ID, err)
+			continue
+		}
+		document := item.sourceDocument
+		document.Data = data
+		if saveDocument(kb, source.ID, document) {
+			synced++
+		} else {
+			failed++
+		}
+	}
+	removeStaleDocuments(kb.ID, source.ID, func(itemURL string) bool {
+		return keep[itemURL]
+	})
+	return synced, failed, nil
+}
+
+// finishSync 记录同步结果
+func finishSync(sourceID int64, synced int, failed int, err error) {
+	now := time.Now()
+	updates := map[string]any{
+		"status":         SourceStatusCompleted,
+		"synced_count":   synced,
+		"failed_count":   failed,
+		"last_error":     nil,
+		"last_synced_at": &now,
+	}
+	if err != nil {
+		updates["status"] = SourceStatusFailed
+		updates["last_error"] = err.Error()
+	}
+	global.DB.Model(&KBSource{}).Where("id = ?", sourceID).Updates(updates)
+}
+
+// sourceDocument 数据源同步得到的文档
+type sourceDocument struct {
+	URL      string // 在数据源内唯一标识文档（网页地址或 dootask://file/ID、dootask://task/ID）
+	Title    string
+	Name     string // 文档名称，不含扩展名
+	FileType string
+	Version  string // 内容版本（网页为内容哈希，DooTask内容为更新时间），未变化时跳过
+	Data     []byte
+}
+
+// sourceMetadata 数据源文档的元数据
+type sourceMetadata struct {
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// pageDocument 抓取到的页面转换为文档（地址带查询参数时名称追加哈希区分）
+func pageDocument(page *crawledPage) sourceDocument {
+	u, _ := url.Parse(page.URL)
+	name := u.Host + u.Path
+	if u.RawQuery != "" {
+		name += "-" + utils.MD5(page.URL)[:8]
+	}
+	return sourceDocument{
+		URL:      page.URL,
+		Title:    page.Title,
+		Name:     name,
+		FileType: "md",
+		Version:  utils.MD5(page.Markdown),
+		Data:     []byte(page.Markdown),
+	}
+}
+
+// documentUnchanged 数据源中该文档的内容版本未变化且已处理完成
+func documentUnchanged(sourceID int64, itemURL string, version string) bool {
+	var doc KBDocument
+	if err := global.DB.Where("source_id = ? AND metadata->>'url' = ?", sourceID, itemURL).First(&doc).Error; err != nil {
+		return false
+	}
+	var metadata sourceMetadata
+	json.Unmarshal(doc.Metadata, &metadata)
+	return version != "" && metadata.Version == version && doc.Status == "processed"
+}
+
+// saveDocument 保存数据源文档并上传到AI服务，内容未变化且已处理的文档直接跳过
+func saveDocument(kb KnowledgeBase, sourceID int64, item sourceDocument) bool {
+	if documentUnchanged(sourceID, item.URL, item.Version) {
+		return true
+	}
+	metadata, _ := json.Marshal(sourceMetadata{URL: item.URL, Title: item.Title, Version: item.Version})
+	content := ""
+	if item.FileType == "md" || item.FileType == "txt" {
+		content = string(item.Data)
+	}
+
+	var doc KBDocument
+	err := global.DB.Where("source_id = ? AND metadata->>'url' = ?", sourceID, item.URL).First(&doc).Error
+	if err == nil {
+		// 内容变化后删除旧的向量数据重新处理（名称可能随标题变化）
+		if err := deleteVectorEmbeddings(kb.ID, doc.Title); err != nil {
+			log.Printf("删除向量数据失败: %v", err)
+		}
+		doc.Title = documentFileName(kb.ID, doc.ID, item)
+		doc.Content, doc.FileType, doc.FileSize, doc.Metadata, doc.Status = content, item.FileType, int64(len(item.Data)), metadata, "processing"
+		if err := global.DB.Model(&doc).Updates(map[string]any{
+			"title":     doc.Title,
+			"content":   doc.Content,
+			"file_type": doc.FileType,
+			"file_size": doc.FileSize,
+			"metadata":  doc.Metadata,
+			"status":    doc.Status,
+		}).Error; err != nil {
+			return false
+		}
+	} else {
+		doc = KBDocument{
+			KnowledgeBaseID: kb.ID,
+			Title:           documentFileName(kb.ID, 0, item),
+			Content:         content,
+			FileType:        item.FileType,
+			FileSize:        int64(len(item.Data)),
+			Metadata:        metadata,
+			SourceID:        &sourceID,
+			IsActive:        true,
+			Status:          "processing",
+		}
+		if err := global.DB.Create(&doc).Error; err != nil {
+			log.Printf("保存数据源文档失败: %v, url: %s", err, item.URL)
+			return false
+		}
+	}
+	// 非文本文档保存原始文件，用于重建索引
+	if content == "" {
+		if err := global.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "document_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"data", "updated_at"}),
+		}).Create(&KBDocumentFile{DocumentID: doc.ID, Data: item.Data}).Error; err != nil {
+			log.Printf("保存原始文件失败: %v, doc_id: %d", err, doc.ID)
+		}
+	}
+	return processDocument(kb, doc, doc.Title, item.Data)
+}
+
+// documentFileName 数据源文档名称（作为向量数据的文件名，需要在知识库内唯一，扩展名决定AI服务的解析方式），
+// 与其他文档重名时追加地址哈希
+func documentFileName(kbID int64, docID int64, item sourceDocument) string {
+	name := strings.Trim(fileNamePattern.ReplaceAllString(item.Name, "-"), "-")
+	if len([]rune(name)) > 200 {
+		name = string([]rune(name)[:200])
+	}
+	if name == "" {
+		name = "document"
+	}
+	var count int64
+	global.DB.Model(&KBDocument{}).Where("knowledge_base_id = ? AND title = ? AND id <> ?", kbID, name+"."+item.FileType, docID).Count(&count)
+	if count > 0 {
+		name += "-" + utils.MD5(item.URL)[:8]
+	}
+	return name + "." + item.FileType
+}
+
+// removeStaleDocuments 删除数据源中不再保留的文档及其向量数据
+func removeStaleDocuments(kbID int64, sourceID int64, keep func(itemURL string) bool) {
+	var docs []KBDocument
+	global.DB.Where("source_id = ?", sourceID).Find(&docs)
+	for _, doc := range docs {
+		var metadata sourceMetadata
+		json.Unmarshal(doc.Metadata, &metadata)
+		if keep(metadata.URL) {
+			continue
+		}
+		if err := global.DB.Delete(&doc).Error; err != nil {
+			continue
+		}
+		if err := deleteVectorEmbeddings(kbID, doc.Title); err != nil {
+			log.Printf("删除向量数据失败: %v", err)
+		}
+	}
+}
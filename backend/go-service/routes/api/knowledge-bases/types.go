@@ -14,7 +14,7 @@ type KnowledgeBase struct {
 	EmbeddingModel string          `gorm:"type:varchar(100);default:'text-embedding-ada-002'" json:"embedding_model" validate:"required"`
 	ChunkSize      int             `gorm:"default:1000" json:"chunk_size" validate:"min=100,max=4000"`
 	ChunkOverlap   int             `gorm:"default:200" json:"chunk_overlap" validate:"min=0,max=1000"`
-	ApiKey         string          `gorm:"type:text" json:"api_key"` // 不返回给前端
+	ApiKey         string          `gorm:"type:text;serializer:secret" json:"api_key"` // 不返回给前端
 	Provider       string          `gorm:"type:varchar(100);default:''" json:"provider"`
 	ProxyURL       string          `gorm:"type:varchar(500);default:''" json:"proxy_url"`
 	Metadata       json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"metadata"`
@@ -24,6 +24,16 @@ type KnowledgeBase struct {
 
 	// 关联查询字段
 	DocumentsCount int `gorm:"->:migration" json:"documents_count"`
+
+	ReindexJob *KBReindexJob `gorm:"-" json:"reindex_job,omitempty"` // 最近一次重建索引任务
+}
+
+// Masked 隐藏API密钥（用于响应）
+func (kb KnowledgeBase) Masked() KnowledgeBase {
+	if kb.ApiKey != "" {
+		kb.ApiKey = "***"
+	}
+	return kb
 }
 
 // KBDocument 知识库文档模型
@@ -38,6 +48,7 @@ type KBDocument struct {
 	Metadata        json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"metadata"`
 	ChunkIndex      int             `gorm:"default:0" json:"chunk_index"`
 	ParentDocID     *int64          `gorm:"column:parent_doc_id" json:"parent_doc_id"`
+	SourceID        *int64          `gorm:"column:source_id" json:"source_id"` // 由数据源同步生成的文档
 	Status          string          `gorm:"type:varchar(20);default:'processing'" json:"status"`
 	IsActive        bool            `gorm:"default:true" json:"is_active"`
 	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
@@ -47,6 +58,101 @@ type KBDocument struct {
 	ChunksCount int `gorm:"-" json:"chunks_count"`
 }
 
+// KBSource 知识库数据源，同步时每个页面（或DooTask文件、任务）生成一个文档
+type KBSource struct {
+	ID              int64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	KnowledgeBaseID int64           `gorm:"column:knowledge_base_id;not null" json:"knowledge_base_id"`
+	Type            string          `gorm:"type:varchar(20);not null" json:"type"`
+	Config          json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"config"`
+	Status          string          `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	SyncedCount     int             `gorm:"not null;default:0" json:"synced_count"` // 最近一次同步成功的页面数
+	FailedCount     int             `gorm:"not null;default:0" json:"failed_count"` // 最近一次同步失败的页面数
+	LastError       *string         `gorm:"type:text" json:"last_error"`
+	LastSyncedAt    *time.Time      `json:"last_synced_at"`
+	SyncInterval    int             `gorm:"not null;default:0" json:"sync_interval"` // 定时同步间隔（分钟），0表示只手动同步
+	UserID          *int64          `gorm:"column:user_id" json:"user_id"`           // DooTask数据源拉取内容使用的用户
+	Credential      *string         `gorm:"type:text;serializer:secret" json:"-"`    // 该用户的DooTask令牌
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 关联查询字段
+	DocumentsCount int `gorm:"->:migration" json:"documents_count"`
+}
+
+// TableName 指定表名
+func (KBSource) TableName() string {
+	return "kb_sources"
+}
+
+// KBDocumentFile 上传文档的原始文件，重建索引时重新处理
+type KBDocumentFile struct {
+	DocumentID int64     `gorm:"primaryKey;autoIncrement:false"`
+	Data       []byte    `gorm:"type:bytea;not null"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (KBDocumentFile) TableName() string {
+	return "kb_document_files"
+}
+
+// KBReindexJob 知识库重建索引任务，使用新的向量化设置重新处理全部文档，完成后替换原有向量数据
+type KBReindexJob struct {
+	ID                 int64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	KnowledgeBaseID    int64           `gorm:"column:knowledge_base_id;not null" json:"knowledge_base_id"`
+	UserID             int64           `gorm:"not null" json:"user_id"`
+	Status             string          `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Settings           json.RawMessage `gorm:"type:jsonb;serializer:secretjson" json:"-"` // 完成后应用的向量化设置（ReindexSettings）
+	TotalDocuments     int             `gorm:"not null;default:0" json:"total_documents"`
+	ProcessedDocuments int             `gorm:"not null;default:0" json:"processed_documents"`
+	FailedDocuments    int             `gorm:"not null;default:0" json:"failed_documents"` // 处理失败或缺少原始文件的文档数
+	Error              *string         `gorm:"type:text" json:"error"`
+	StartedAt          *time.Time      `json:"started_at"`
+	FinishedAt         *time.Time      `json:"finished_at"`
+	CreatedAt          time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 响应字段
+	EmbeddingModel string `gorm:"-" json:"embedding_model"`
+	Provider       string `gorm:"-" json:"provider"`
+	ChunkSize      int    `gorm:"-" json:"chunk_size"`
+	ChunkOverlap   int    `gorm:"-" json:"chunk_overlap"`
+}
+
+// TableName 指定表名
+func (KBReindexJob) TableName() string {
+	return "kb_reindex_jobs"
+}
+
+// ReindexSettings 重建索引使用的向量化设置
+type ReindexSettings struct {
+	Provider       string `json:"provider"`
+	EmbeddingModel string `json:"embedding_model"`
+	ChunkSize      int    `json:"chunk_size"`
+	ChunkOverlap   int    `json:"chunk_overlap"`
+	ApiKey         string `json:"api_key"`
+	ProxyURL       string `json:"proxy_url"`
+}
+
+// URLSourceConfig 网页数据源配置
+type URLSourceConfig struct {
+	URL          string   `json:"url" validate:"required,url,max=2000"`
+	Mode         string   `json:"mode" validate:"required,oneof=page sitemap crawl"` // page 单个页面，sitemap 站点地图，crawl 按深度爬取
+	MaxDepth     int      `json:"max_depth" validate:"min=0,max=5"`                  // crawl 跟踪链接的深度
+	MaxPages     int      `json:"max_pages" validate:"min=0,max=1000"`               // 最多抓取的页面数，0表示使用系统配置
+	IncludePaths []string `json:"include_paths" validate:"max=50,dive,startswith=/"` // 只抓取这些路径前缀下的页面
+	ExcludePaths []string `json:"exclude_paths" validate:"max=50,dive,startswith=/"` // 不抓取这些路径前缀下的页面
+}
+
+// DooTaskSourceConfig DooTask数据源配置
+type DooTaskSourceConfig struct {
+	Kind       string  `json:"kind" validate:"required,oneof=file project"` // file 文件和文件夹，project 项目任务
+	FileIDs    []int64 `json:"file_ids" validate:"max=100,dive,min=1"`      // 同步的文件或文件夹（包含子文件夹），为空时同步全部文件
+	ProjectIDs []int64 `json:"project_ids" validate:"max=100,dive,min=1"`   // 同步的项目，为空时同步全部项目
+	MaxItems   int     `json:"max_items" validate:"min=0,max=5000"`         // 最多同步的文件或任务数，0表示使用系统配置
+}
+
 // 请求类型
 
 // CreateKnowledgeBaseRequest 创建知识库请求
@@ -86,6 +192,18 @@ type UploadDocumentRequest struct {
 	Metadata json.RawMessage `json:"metadata"`
 }
 
+// CreateSourceRequest 添加数据源请求
+type CreateSourceRequest struct {
+	Type         string          `json:"type" validate:"required,oneof=url dootask"`
+	Config       json.RawMessage `json:"config" validate:"required"`
+	SyncInterval int             `json:"sync_interval" validate:"omitempty,min=30,max=10080"` // 定时同步间隔（分钟）
+}
+
+// UpdateSourceRequest 更新数据源请求
+type UpdateSourceRequest struct {
+	SyncInterval *int `json:"sync_interval" validate:"omitempty,eq=0|min=30,max=10080"` // 0表示关闭定时同步
+}
+
 // 响应类型
 
 // KnowledgeBaseFilters 知识库筛选条件
@@ -112,6 +230,11 @@ type DocumentListData struct {
 	Items []KBDocument `json:"items"`
 }
 
+// SourceListData 数据源列表数据结构
+type SourceListData struct {
+	Items []KBSource `json:"items"`
+}
+
 // KnowledgeBaseResponse 知识库详情响应（包含统计信息）
 type KnowledgeBaseResponse struct {
 	KnowledgeBase
@@ -134,6 +257,7 @@ type ProcessedFile struct {
 	Filename string `json:"filename"`
 	Chunks   int    `json:"chunks"`
 	Status   string `json:"status"`
+	Error    string `json:"error"`
 }
 
 // 辅助方法
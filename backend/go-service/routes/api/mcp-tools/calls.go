@@ -0,0 +1,61 @@
+package mcptools
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ToolCall 智能体的一次MCP函数调用记录
+type ToolCall struct {
+	ID             int64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	AgentID        *int64          `json:"agent_id"`
+	ConversationID *int64          `json:"conversation_id"`
+	MCPToolID      *int64          `gorm:"column:mcp_tool_id" json:"mcp_tool_id"`
+	FunctionName   string          `gorm:"type:varchar(255);not null" json:"function_name"`
+	ToolCallID     string          `gorm:"type:varchar(255)" json:"tool_call_id"`
+	Arguments      json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"arguments"` // 已脱敏
+	ResultSize     int             `json:"result_size"`                              // 结果字节数
+	LatencyMs      int             `json:"latency_ms"`
+	Error          *string         `gorm:"type:text" json:"error"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// TableName 指定表名
+func (ToolCall) TableName() string {
+	return "tool_calls"
+}
+
+// CallStats 工具调用统计
+type CallStats struct {
+	TotalCalls   int64
+	TodayCalls   int64
+	FailedCalls  int64
+	AvgLatencyMs float64
+}
+
+// SuccessRate 调用成功率（没有调用记录时为1）
+func (s CallStats) SuccessRate() float64 {
+	if s.TotalCalls == 0 {
+		return 1
+	}
+	return float64(s.TotalCalls-s.FailedCalls) / float64(s.TotalCalls)
+}
+
+// AvgResponseTime 平均响应时间（秒）
+func (s CallStats) AvgResponseTime() float64 {
+	return s.AvgLatencyMs / 1000
+}
+
+// aggregateCalls 按条件汇总调用记录
+func aggregateCalls(query *gorm.DB) CallStats {
+	var stats CallStats
+	query.Model(&ToolCall{}).Select(
+		"COUNT(*) AS total_calls, " +
+			"COUNT(*) FILTER (WHERE created_at >= CURRENT_DATE) AS today_calls, " +
+			"COUNT(*) FILTER (WHERE error IS NOT NULL) AS failed_calls, " +
+			"COALESCE(AVG(latency_ms), 0) AS avg_latency_ms",
+	).Scan(&stats)
+	return stats
+}
@@ -0,0 +1,154 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/mcpclient"
+	"dootask-ai/go-service/mcpsupervisor"
+	usersecrets "dootask-ai/go-service/routes/api/user-secrets"
+	"dootask-ai/go-service/sysconfig"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Transport 配置类型对应的传输方式
+func (t MCPTool) Transport() string {
+	switch t.ConfigType {
+	case ConfigTypeWebSocket:
+		return mcpclient.TransportWebSocket
+	case ConfigTypeSSE:
+		return mcpclient.TransportSSE
+	case ConfigTypeSTDIO:
+		return mcpclient.TransportSTDIO
+	default:
+		return mcpclient.TransportStreamableHTTP
+	}
+}
+
+// ClientConfig 将工具配置转换为MCP客户端配置（兼容 url/baseUrl、apiKey、timeout 等写法）
+func (t MCPTool) ClientConfig() (mcpclient.Config, error) {
+	var raw map[string]any
+	if len(t.Config) > 0 {
+		if err := json.Unmarshal(t.Config, &raw); err != nil {
+			return mcpclient.Config{}, fmt.Errorf("工具配置格式错误: %w", err)
+		}
+	}
+
+	cfg := mcpclient.Config{
+		Transport: t.Transport(),
+		URL:       stringValue(raw, "url", "baseUrl", "base_url"),
+		Headers:   stringMap(raw["headers"]),
+		Protocols: stringSlice(raw["protocols"]),
+		Command:   stringValue(raw, "command"),
+		Args:      stringSlice(raw["args"]),
+		Env:       stringMap(raw["env"]),
+		Dir:       stringValue(raw, "cwd"),
+		Timeout:   time.Duration(sysconfig.Int(sysconfig.KeyMCPToolTimeout, 60)) * time.Second,
+	}
+	if timeout, ok := raw["timeout"].(float64); ok && timeout > 0 {
+		// 配置中的 timeout 以毫秒为单位
+		cfg.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+	if apiKey := stringValue(raw, "apiKey", "api_key"); apiKey != "" {
+		if cfg.Headers == nil {
+			cfg.Headers = map[string]string{}
+		}
+		if _, ok := cfg.Headers["Authorization"]; !ok {
+			cfg.Headers["Authorization"] = "Bearer " + apiKey
+		}
+	}
+
+	if cfg.Transport == mcpclient.TransportSTDIO {
+		if cfg.Command == "" {
+			return cfg, fmt.Errorf("stdio 工具缺少 command 配置")
+		}
+	} else if cfg.URL == "" {
+		return cfg, fmt.Errorf("工具缺少 url 配置")
+	}
+	return cfg, nil
+}
+
+// connect 连接工具，返回的 release 在使用结束后执行
+// stdio 工具统一使用托管的进程池（隔离环境变量并受进程数上限约束），其他工具直接建立连接
+func connect(ctx context.Context, toolID int64, cfg mcpclient.Config) (*mcpclient.Client, func(), error) {
+	if cfg.Transport == mcpclient.TransportSTDIO {
+		lease, err := mcpsupervisor.Acquire(toolID, cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return lease.Client, lease.Release, nil
+	}
+	client, err := mcpclient.Connect(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, func() { client.Close() }, nil
+}
+
+// requestConfig 当前请求使用的客户端配置（DooTask MCP 使用当前用户的Token鉴权，配置中的密钥占位符和OAuth令牌使用当前用户的）
+func requestConfig(c *gin.Context, tool MCPTool) (mcpclient.Config, error) {
+	cfg, err := tool.ClientConfig()
+	if err != nil {
+		return cfg, err
+	}
+	var userID int64
+	if user := global.GetDooTaskUser(c); user != nil {
+		userID = int64(user.UserID)
+	}
+	if missing := usersecrets.ResolveConfig(userID, tool.UserID, &cfg); len(missing) > 0 {
+		return cfg, fmt.Errorf("请先设置用户密钥: %s", strings.Join(missing, ", "))
+	}
+	if err := ApplyOAuth(tool, userID, &cfg); err != nil {
+		return cfg, err
+	}
+	if tool.Category == "dootask" {
+		if client := global.GetDooTaskClient(c); client != nil {
+			if cfg.Headers == nil {
+				cfg.Headers = map[string]string{}
+			}
+			cfg.Headers["Authorization"] = "Bearer " + client.Token
+		}
+	}
+	return cfg, nil
+}
+
+// stringValue 读取第一个非空的字符串字段
+func stringValue(m map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := m[key].(string); ok && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// stringMap 将JSON对象转换为字符串映射
+func stringMap(v any) map[string]string {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil
+	}
+	result := make(map[string]string, len(m))
+	for key, value := range m {
+		result[key] = fmt.Sprintf("%v", value)
+	}
+	return result
+}
+
+// stringSlice 将JSON数组转换为字符串切片
+func stringSlice(v any) []string {
+	list, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		result = append(result, fmt.Sprintf("%v", item))
+	}
+	return result
+}
@@ -0,0 +1,278 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/mcpclient"
+	"dootask-ai/go-service/permission"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MCPToolFunction MCP服务提供的函数（缓存）
+type MCPToolFunction struct {
+	ID          int64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	MCPToolID   int64           `gorm:"column:mcp_tool_id;not null" json:"mcp_tool_id"`
+	Name        string          `gorm:"type:varchar(255);not null" json:"name"`
+	Description *string         `gorm:"type:text" json:"description"`
+	InputSchema json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"input_schema"`
+	SyncedAt    time.Time       `json:"synced_at"`
+}
+
+// TableName 指定表名
+func (MCPToolFunction) TableName() string {
+	return "mcp_tool_functions"
+}
+
+// 函数审批策略
+const (
+	ApprovalAuto    = "auto"    // 直接调用
+	ApprovalConfirm = "confirm" // 调用前暂停并等待用户确认
+	ApprovalDeny    = "deny"    // 禁止调用
+)
+
+// FunctionRule 允许调用的函数及参数约束
+type FunctionRule struct {
+	Name        string                   `json:"name"`
+	Constraints map[string]ArgConstraint `json:"constraints,omitempty"` // 参数名 → 约束
+	Approval    string                   `json:"approval,omitempty"`    // 审批策略，为空时等同 auto
+}
+
+// ArgConstraint 参数约束，由AI服务在调用前校验（MCP网关和工具测试在Go中校验）
+type ArgConstraint struct {
+	Const   any      `json:"const,omitempty"`   // 固定值
+	Enum    []any    `json:"enum,omitempty"`    // 可选值
+	Pattern string   `json:"pattern,omitempty"` // 正则（完整匹配）
+	Prefix  string   `json:"prefix,omitempty"`  // 前缀
+	Minimum *float64 `json:"minimum,omitempty"` // 最小值
+	Maximum *float64 `json:"maximum,omitempty"` // 最大值
+}
+
+// CheckArguments 校验调用参数是否满足约束（与AI服务的 check_constraints 规则一致）
+func CheckArguments(constraints map[string]ArgConstraint, arguments map[string]any) error {
+	for name, rule := range constraints {
+		value, ok := arguments[name]
+		if !ok {
+			if rule.Const != nil {
+				return fmt.Errorf("参数 %s 必须为 %v", name, rule.Const)
+			}
+			continue
+		}
+		if rule.Const != nil && !reflect.DeepEqual(value, normalize(rule.Const)) {
+			return fmt.Errorf("参数 %s 必须为 %v", name, rule.Const)
+		}
+		if len(rule.Enum) > 0 && !slices.ContainsFunc(rule.Enum, func(item any) bool { return reflect.DeepEqual(value, normalize(item)) }) {
+			return fmt.Errorf("参数 %s 只能为 %v 之一", name, rule.Enum)
+		}
+		text := fmt.Sprintf("%v", value)
+		if rule.Pattern != "" {
+			if re, err := regexp.Compile("^(?:" + rule.Pattern + ")$"); err != nil || !re.MatchString(text) {
+				return fmt.Errorf("参数 %s 不符合格式 %s", name, rule.Pattern)
+			}
+		}
+		if rule.Prefix != "" && !strings.HasPrefix(text, rule.Prefix) {
+			return fmt.Errorf("参数 %s 必须以 %s 开头", name, rule.Prefix)
+		}
+		if number, ok := value.(float64); ok {
+			if rule.Minimum != nil && number < *rule.Minimum {
+				return fmt.Errorf("参数 %s 不能小于 %v", name, *rule.Minimum)
+			}
+			if rule.Maximum != nil && number > *rule.Maximum {
+				return fmt.Errorf("参数 %s 不能大于 %v", name, *rule.Maximum)
+			}
+		}
+	}
+	return nil
+}
+
+// normalize 将约束值转换为JSON解码后的类型（数字统一为float64），便于与调用参数比较
+func normalize(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// agentFunctionRule 获取智能体对工具函数的白名单配置，智能体未限制该工具时 limited 为 false
+func agentFunctionRule(c *gin.Context, agentID int64, toolID int64, name string) (rule *FunctionRule, limited bool, err error) {
+	var agent struct {
+		ToolFunctions json.RawMessage
+	}
+	if err := global.DB.Table("agents").
+		Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleViewer)).
+		Select("tool_functions").
+		Where("agents.id = ?", agentID).
+		Take(&agent).Error; err != nil {
+		return nil, false, err
+	}
+	var rules map[string][]FunctionRule
+	if len(agent.ToolFunctions) > 0 {
+		if err := json.Unmarshal(agent.ToolFunctions, &rules); err != nil {
+			return nil, false, err
+		}
+	}
+	functions, ok := rules[strconv.FormatInt(toolID, 10)]
+	if !ok {
+		return nil, false, nil
+	}
+	for i := range functions {
+		if functions[i].Name == name && functions[i].Approval != ApprovalDeny {
+			return &functions[i], true, nil
+		}
+	}
+	return nil, true, nil
+}
+
+// CachedFunctions 获取缓存的函数列表
+func CachedFunctions(toolID int64) []MCPToolFunction {
+	var functions []MCPToolFunction
+	global.DB.Where("mcp_tool_id = ?", toolID).Order("name ASC").Find(&functions)
+	return functions
+}
+
+// RefreshFunctions 连接MCP服务获取函数列表并替换缓存
+func RefreshFunctions(ctx context.Context, tool MCPTool, cfg mcpclient.Config) ([]MCPToolFunction, error) {
+	client, release, err := connect(ctx, tool.ID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	functions := make([]MCPToolFunction, 0, len(tools))
+	for _, item := range tools {
+		function := MCPToolFunction{
+			MCPToolID:   tool.ID,
+			Name:        item.Name,
+			InputSchema: item.InputSchema,
+			SyncedAt:    now,
+		}
+		if item.Description != "" {
+			description := item.Description
+			function.Description = &description
+		}
+		if len(function.InputSchema) == 0 {
+			function.InputSchema = json.RawMessage(`{}`)
+		}
+		functions = append(functions, function)
+	}
+
+	err = global.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("mcp_tool_id = ?", tool.ID).Delete(&MCPToolFunction{}).Error; err != nil {
+			return err
+		}
+		if len(functions) == 0 {
+			return nil
+		}
+		return tx.Create(&functions).Error
+	})
+	return functions, err
+}
+
+// findTool 查询工具，失败时写入错误响应
+func findTool(c *gin.Context, scope func(db *gorm.DB) *gorm.DB) (*MCPTool, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的工具ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	var tool MCPTool
+	if err := global.DB.Scopes(scope).Where("id = ?", id).First(&tool).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "MCP_TOOL_002",
+				"message": "工具不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询工具失败",
+				"data":    nil,
+			})
+		}
+		return nil, false
+	}
+	return &tool, true
+}
+
+// refresh 重新获取函数列表，失败时写入错误响应
+func refresh(c *gin.Context, tool MCPTool) ([]MCPToolFunction, bool) {
+	cfg, err := requestConfig(c, tool)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cfg.Timeout+10*time.Second)
+		defer cancel()
+		var functions []MCPToolFunction
+		if functions, err = RefreshFunctions(ctx, tool, cfg); err == nil {
+			return functions, true
+		}
+	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"code":    "MCP_TOOL_005",
+		"message": "获取工具函数失败",
+		"data":    err.Error(),
+	})
+	return nil, false
+}
+
+// ListFunctions 获取工具提供的函数（没有缓存时连接服务获取）
+func ListFunctions(c *gin.Context) {
+	tool, ok := findTool(c, viewableScope(c))
+	if !ok {
+		return
+	}
+
+	functions := CachedFunctions(tool.ID)
+	if len(functions) == 0 {
+		if functions, ok = refresh(c, *tool); !ok {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": functions})
+}
+
+// SyncFunctions 重新获取工具提供的函数
+func SyncFunctions(c *gin.Context) {
+	// 可编辑的工具和DooTask内置工具
+	tool, ok := findTool(c, func(db *gorm.DB) *gorm.DB {
+		query, args := permission.Condition(c, permission.ResourceMCPTool, permission.RoleEditor)
+		return db.Where(query+" OR mcp_tools.category = ?", append(args, "dootask")...)
+	})
+	if !ok {
+		return
+	}
+
+	functions, ok := refresh(c, *tool)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": functions})
+}
@@ -0,0 +1,218 @@
+package mcptools
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/global"
+	usersecrets "dootask-ai/go-service/routes/api/user-secrets"
+	"dootask-ai/go-service/sysconfig"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 健康状态
+const (
+	HealthUnknown   = "unknown"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+const (
+	defaultHealthInterval  = 300 // 秒
+	defaultHealthThreshold = 2
+	healthCheckConcurrency = 5
+	healthHistoryRetention = 7 * 24 * time.Hour
+)
+
+// errMissingSecrets 缺少检查所需的用户密钥或OAuth授权，不记录检查结果
+var errMissingSecrets = errors.New("缺少用户密钥")
+
+// HealthCheck MCP服务健康检查记录
+type HealthCheck struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	MCPToolID int64     `gorm:"column:mcp_tool_id;not null" json:"mcp_tool_id"`
+	Healthy   bool      `gorm:"not null" json:"healthy"`
+	LatencyMs int       `json:"latency_ms"`
+	Error     *string   `gorm:"type:text" json:"error"`
+	CheckedAt time.Time `gorm:"autoCreateTime" json:"checked_at"`
+}
+
+// TableName 指定表名
+func (HealthCheck) TableName() string {
+	return "mcp_tool_health_checks"
+}
+
+// HealthyScope 排除被标记为不健康的工具
+func HealthyScope(db *gorm.DB) *gorm.DB {
+	return db.Where("health_status <> ?", HealthUnhealthy)
+}
+
+// StartHealthMonitor 启动MCP服务健康检查（启动时执行一次，之后按 mcp_health_check_interval 执行）
+func StartHealthMonitor() {
+	go func() {
+		for {
+			CheckAllHealth()
+			interval := sysconfig.Int(sysconfig.KeyMCPHealthCheckInterval, defaultHealthInterval)
+			if interval <= 0 {
+				interval = defaultHealthInterval
+			}
+			time.Sleep(time.Duration(interval) * time.Second)
+		}
+	}()
+}
+
+// CheckAllHealth 检查所有启用的MCP工具，并清理过期的检查记录
+func CheckAllHealth() {
+	var tools []MCPTool
+	if err := global.DB.Where("is_active = ?", true).Find(&tools).Error; err != nil {
+		log.Printf("查询MCP工具失败: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, healthCheckConcurrency)
+	for _, tool := range tools {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(tool MCPTool) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			CheckHealth(tool)
+		}(tool)
+	}
+	wg.Wait()
+
+	global.DB.Where("checked_at < ?", time.Now().Add(-healthHistoryRetention)).Delete(&HealthCheck{})
+}
+
+// CheckHealth 与MCP服务握手并更新健康状态，连续失败达到阈值后标记为不健康
+func CheckHealth(tool MCPTool) HealthCheck {
+	start := time.Now()
+	err := probe(tool)
+	if errors.Is(err, errMissingSecrets) {
+		return HealthCheck{MCPToolID: tool.ID}
+	}
+	check := HealthCheck{
+		MCPToolID: tool.ID,
+		Healthy:   err == nil,
+		LatencyMs: int(time.Since(start).Milliseconds()),
+	}
+	if err != nil {
+		errMsg := err.Error()
+		check.Error = &errMsg
+	}
+	if err := global.DB.Create(&check).Error; err != nil {
+		log.Printf("写入MCP健康检查记录失败: %v", err)
+	}
+
+	updates := map[string]interface{}{
+		"health_checked_at": time.Now(),
+		"health_latency_ms": check.LatencyMs,
+		"health_error":      check.Error,
+	}
+	if check.Healthy {
+		updates["health_status"] = HealthHealthy
+		updates["health_failures"] = 0
+	} else {
+		threshold := sysconfig.Int(sysconfig.KeyMCPHealthFailureThreshold, defaultHealthThreshold)
+		failures := tool.HealthFailures + 1
+		updates["health_failures"] = failures
+		if failures >= threshold {
+			updates["health_status"] = HealthUnhealthy
+			if tool.HealthStatus != HealthUnhealthy {
+				log.Printf("MCP工具 %s(%d) 连续 %d 次健康检查失败，已标记为不健康: %s", tool.Name, tool.ID, failures, *check.Error)
+			}
+		}
+	}
+	// 使用 UpdateColumns 避免更新 updated_at
+	global.DB.Model(&MCPTool{}).Where("id = ?", tool.ID).UpdateColumns(updates)
+	return check
+}
+
+// probe 检查单个工具：DooTask MCP 需要用户Token，使用健康检查接口；其他工具完成握手后发送 ping（stdio 工具使用进程池中的进程）
+func probe(tool MCPTool) error {
+	if tool.Category == "dootask" {
+		if !checkHealthStatus() {
+			return errors.New("DooTask MCP 健康检查失败")
+		}
+		return nil
+	}
+
+	cfg, err := tool.ClientConfig()
+	if err != nil {
+		return err
+	}
+	// 使用用户密钥或OAuth的工具以创建者的密钥和授权检查，创建者未设置时跳过
+	if missing := usersecrets.ResolveConfig(tool.UserID, tool.UserID, &cfg); len(missing) > 0 {
+		return errMissingSecrets
+	}
+	if err := ApplyOAuth(tool, tool.UserID, &cfg); err != nil {
+		if errors.Is(err, ErrOAuthRequired) {
+			return errMissingSecrets
+		}
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	client, release, err := connect(ctx, tool.ID, cfg)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return client.Ping(ctx)
+}
+
+// GetHealth 获取工具的健康状态和检查历史
+func GetHealth(c *gin.Context) {
+	tool, ok := findTool(c, viewableScope(c))
+	if !ok {
+		return
+	}
+	writeHealth(c, tool.ID)
+}
+
+// RunHealthCheck 立即检查工具健康状态
+func RunHealthCheck(c *gin.Context) {
+	tool, ok := findTool(c, viewableScope(c))
+	if !ok {
+		return
+	}
+	CheckHealth(*tool)
+	writeHealth(c, tool.ID)
+}
+
+// writeHealth 返回工具当前的健康状态和最近的检查记录
+func writeHealth(c *gin.Context, toolID int64) {
+	var tool MCPTool
+	if err := global.DB.Where("id = ?", toolID).First(&tool).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询工具失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	var history []HealthCheck
+	global.DB.Where("mcp_tool_id = ?", tool.ID).Order("checked_at DESC").Limit(limit).Find(&history)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     tool.HealthStatus,
+		"checked_at": tool.HealthCheckedAt,
+		"latency_ms": tool.HealthLatencyMs,
+		"error":      tool.HealthError,
+		"failures":   tool.HealthFailures,
+		"history":    history,
+	})
+}
@@ -0,0 +1,512 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/mcpclient"
+	"dootask-ai/go-service/mcpoauth"
+	"dootask-ai/go-service/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	oauthStateTTL    = 30 * time.Minute
+	oauthRefreshSkew = time.Minute // 访问令牌在过期前提前刷新
+	oauthTimeout     = 30 * time.Second
+)
+
+// ErrOAuthRequired 用户尚未完成工具的OAuth授权（或授权已失效）
+var ErrOAuthRequired = errors.New("请先完成工具的OAuth授权")
+
+// oauthRefreshMu 串行刷新令牌，避免并发请求使用同一个刷新令牌
+var oauthRefreshMu sync.Mutex
+
+// OAuthSettings 工具配置中的 oauth 设置：true 表示自动发现并动态注册客户端，
+// 也可以是 {"client_id": "...", "client_secret": "...", "scope": "..."} 指定预先注册的客户端
+type OAuthSettings struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Scope        string `json:"scope"`
+}
+
+// OAuthClient 在授权服务器注册的客户端（按回调地址区分）
+type OAuthClient struct {
+	ID           int64           `gorm:"primaryKey;autoIncrement" json:"id"`
+	MCPToolID    int64           `gorm:"column:mcp_tool_id;not null" json:"mcp_tool_id"`
+	RedirectURI  string          `gorm:"type:varchar(1000);not null" json:"redirect_uri"`
+	ClientID     string          `gorm:"type:varchar(500);not null" json:"client_id"`
+	ClientSecret string          `gorm:"type:text;serializer:secret" json:"-"`
+	Server       json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"server"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OAuthClient) TableName() string {
+	return "mcp_oauth_clients"
+}
+
+// OAuthToken 用户的OAuth令牌
+type OAuthToken struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MCPToolID     int64      `gorm:"column:mcp_tool_id;not null" json:"mcp_tool_id"`
+	UserID        int64      `gorm:"not null" json:"user_id"`
+	OAuthClientID int64      `gorm:"column:oauth_client_id;not null" json:"oauth_client_id"`
+	AccessToken   string     `gorm:"type:text;not null;serializer:secret" json:"-"`
+	RefreshToken  string     `gorm:"type:text;serializer:secret" json:"-"`
+	TokenType     string     `gorm:"type:varchar(50);not null;default:'Bearer'" json:"token_type"`
+	Scope         string     `gorm:"type:text" json:"scope"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (OAuthToken) TableName() string {
+	return "mcp_oauth_tokens"
+}
+
+// OAuthStatus 当前用户对工具的授权状态
+type OAuthStatus struct {
+	Enabled      bool       `json:"enabled"`
+	Authorized   bool       `json:"authorized"`
+	Scope        string     `json:"scope"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	AuthorizedAt *time.Time `json:"authorized_at"`
+}
+
+// oauthState 授权请求的上下文（state 对应的Redis记录）
+type oauthState struct {
+	ToolID      int64  `json:"tool_id"`
+	UserID      int64  `json:"user_id"`
+	ClientID    int64  `json:"client_id"`
+	Verifier    string `json:"verifier"`
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// OAuthSettings 读取工具的 oauth 设置，未启用时返回false（stdio 和 DooTask 工具不支持）
+func (t MCPTool) OAuthSettings() (*OAuthSettings, bool) {
+	if t.ConfigType == ConfigTypeSTDIO || t.Category == "dootask" || len(t.Config) == 0 {
+		return nil, false
+	}
+	var raw struct {
+		OAuth json.RawMessage `json:"oauth"`
+	}
+	if err := json.Unmarshal(t.Config, &raw); err != nil || len(raw.OAuth) == 0 {
+		return nil, false
+	}
+	var enabled bool
+	if json.Unmarshal(raw.OAuth, &enabled) == nil {
+		return &OAuthSettings{}, enabled
+	}
+	var settings OAuthSettings
+	if err := json.Unmarshal(raw.OAuth, &settings); err != nil {
+		return nil, false
+	}
+	return &settings, true
+}
+
+// oauthRedirectURI OAuth回调地址（默认通过DooTask插件代理访问Go服务）
+func oauthRedirectURI(baseURL string) string {
+	if redirectURL := utils.GetEnvWithDefault("MCP_OAUTH_REDIRECT_URL", ""); redirectURL != "" {
+		return redirectURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/apps/ai-agent/service/mcp-oauth/callback"
+}
+
+func oauthStateKey(state string) string {
+	return fmt.Sprintf("mcp_oauth_state:%s", state)
+}
+
+// AuthorizeURL 为用户发起工具授权，返回授权地址（baseURL 为DooTask地址，用于生成回调地址）
+func AuthorizeURL(ctx context.Context, tool MCPTool, userID int64, baseURL string) (string, error) {
+	settings, ok := tool.OAuthSettings()
+	if !ok {
+		return "", fmt.Errorf("工具未启用OAuth授权")
+	}
+	redirectURI := oauthRedirectURI(baseURL)
+	client, server, err := oauthClient(ctx, tool, settings, redirectURI)
+	if err != nil {
+		return "", err
+	}
+
+	state, verifier := mcpoauth.NewState(), mcpoauth.NewVerifier()
+	data, _ := json.Marshal(oauthState{
+		ToolID:      tool.ID,
+		UserID:      userID,
+		ClientID:    client.ID,
+		Verifier:    verifier,
+		RedirectURI: redirectURI,
+	})
+	if err := global.Redis.Set(ctx, oauthStateKey(state), data, oauthStateTTL).Err(); err != nil {
+		return "", err
+	}
+	return mcpoauth.AuthorizationURL(*server, client.credentials(), redirectURI, state, verifier, settings.Scope), nil
+}
+
+// oauthClient 获取工具在该回调地址下的客户端，没有时发现授权服务器并注册
+func oauthClient(ctx context.Context, tool MCPTool, settings *OAuthSettings, redirectURI string) (*OAuthClient, *mcpoauth.Server, error) {
+	var client OAuthClient
+	err := global.DB.Where("mcp_tool_id = ? AND redirect_uri = ?", tool.ID, redirectURI).First(&client).Error
+	if err == nil && (settings.ClientID == "" || settings.ClientID == client.ClientID && settings.ClientSecret == client.ClientSecret) {
+		if server, err := client.server(); err == nil && server.Metadata.TokenEndpoint != "" {
+			return &client, &server, nil
+		}
+	} else if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, nil, err
+	}
+
+	cfg, err := tool.ClientConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	server, err := mcpoauth.Discover(ctx, cfg.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	credentials := &mcpoauth.Client{ID: settings.ClientID, Secret: settings.ClientSecret}
+	if credentials.ID == "" {
+		if credentials, err = mcpoauth.Register(ctx, server.Metadata, redirectURI); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	serverData, _ := json.Marshal(server)
+	client = OAuthClient{
+		MCPToolID:    tool.ID,
+		RedirectURI:  redirectURI,
+		ClientID:     credentials.ID,
+		ClientSecret: credentials.Secret,
+		Server:       serverData,
+	}
+	// 更换客户端后原有令牌无法刷新，由外键级联删除
+	global.DB.Where("mcp_tool_id = ? AND redirect_uri = ?", tool.ID, redirectURI).Delete(&OAuthClient{})
+	if err := global.DB.Create(&client).Error; err != nil {
+		return nil, nil, err
+	}
+	return &client, server, nil
+}
+
+// credentials 客户端凭据
+func (c OAuthClient) credentials() mcpoauth.Client {
+	return mcpoauth.Client{ID: c.ClientID, Secret: c.ClientSecret}
+}
+
+// server 客户端对应的授权服务器
+func (c OAuthClient) server() (mcpoauth.Server, error) {
+	var server mcpoauth.Server
+	err := json.Unmarshal(c.Server, &server)
+	return server, err
+}
+
+// CompleteOAuth 处理授权回调：校验 state，使用授权码换取令牌并保存
+func CompleteOAuth(ctx context.Context, state string, code string) (*MCPTool, error) {
+	data, err := global.Redis.GetDel(ctx, oauthStateKey(state)).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("授权请求已过期，请重新发起授权")
+	}
+	var s oauthState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	var tool MCPTool
+	if err := global.DB.Where("id = ?", s.ToolID).First(&tool).Error; err != nil {
+		return nil, fmt.Errorf("工具不存在")
+	}
+	var client OAuthClient
+	if err := global.DB.Where("id = ?", s.ClientID).First(&client).Error; err != nil {
+		return nil, fmt.Errorf("授权客户端已失效，请重新发起授权")
+	}
+	server, err := client.server()
+	if err != nil {
+		return nil, err
+	}
+	token, err := mcpoauth.Exchange(ctx, server, client.credentials(), code, s.Verifier, s.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+
+	item := OAuthToken{
+		MCPToolID:     tool.ID,
+		UserID:        s.UserID,
+		OAuthClientID: client.ID,
+		AccessToken:   token.AccessToken,
+		RefreshToken:  token.RefreshToken,
+		TokenType:     token.TokenType,
+		Scope:         token.Scope,
+		ExpiresAt:     token.ExpiresAt(time.Now()),
+	}
+	err = global.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "mcp_tool_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"oauth_client_id", "access_token", "refresh_token", "token_type", "scope", "expires_at", "updated_at",
+		}),
+	}).Create(&item).Error
+	return &tool, err
+}
+
+// OAuthAccessToken 获取用户对工具的有效访问令牌，即将过期时自动刷新；
+// 未授权或刷新令牌失效时返回 ErrOAuthRequired
+func OAuthAccessToken(tool MCPTool, userID int64) (string, error) {
+	if userID == 0 {
+		return "", ErrOAuthRequired
+	}
+	token, err := findOAuthToken(tool.ID, userID)
+	if err != nil {
+		return "", err
+	}
+	if !token.expiring() {
+		return token.AccessToken, nil
+	}
+
+	oauthRefreshMu.Lock()
+	defer oauthRefreshMu.Unlock()
+	// 等待期间可能已被其他请求刷新
+	if token, err = findOAuthToken(tool.ID, userID); err != nil {
+		return "", err
+	}
+	if !token.expiring() {
+		return token.AccessToken, nil
+	}
+	if token.RefreshToken == "" {
+		global.DB.Delete(token)
+		return "", ErrOAuthRequired
+	}
+
+	var client OAuthClient
+	if err := global.DB.Where("id = ?", token.OAuthClientID).First(&client).Error; err != nil {
+		return "", ErrOAuthRequired
+	}
+	server, err := client.server()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), oauthTimeout)
+	defer cancel()
+	refreshed, err := mcpoauth.Refresh(ctx, server, client.credentials(), token.RefreshToken)
+	if err != nil {
+		var oauthErr *mcpoauth.Error
+		if errors.As(err, &oauthErr) && oauthErr.Status < http.StatusInternalServerError {
+			// 刷新令牌被拒绝（过期或已撤销），需要用户重新授权
+			log.Printf("刷新工具 %s 用户 %d 的OAuth令牌失败: %v", tool.McpName, userID, err)
+			global.DB.Delete(token)
+			return "", ErrOAuthRequired
+		}
+		return "", fmt.Errorf("刷新OAuth令牌失败: %w", err)
+	}
+
+	token.AccessToken = refreshed.AccessToken
+	token.RefreshToken = refreshed.RefreshToken
+	token.TokenType = refreshed.TokenType
+	token.ExpiresAt = refreshed.ExpiresAt(time.Now())
+	if refreshed.Scope != "" {
+		token.Scope = refreshed.Scope
+	}
+	if err := global.DB.Save(token).Error; err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// findOAuthToken 查询用户的令牌，没有时返回 ErrOAuthRequired
+func findOAuthToken(toolID int64, userID int64) (*OAuthToken, error) {
+	var token OAuthToken
+	if err := global.DB.Where("mcp_tool_id = ? AND user_id = ?", toolID, userID).First(&token).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrOAuthRequired
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// expiring 访问令牌是否已过期或即将过期
+func (t OAuthToken) expiring() bool {
+	return t.ExpiresAt != nil && time.Until(*t.ExpiresAt) < oauthRefreshSkew
+}
+
+// ApplyOAuth 为启用OAuth的工具在客户端配置中加入用户的访问令牌
+func ApplyOAuth(tool MCPTool, userID int64, cfg *mcpclient.Config) error {
+	if _, ok := tool.OAuthSettings(); !ok {
+		return nil
+	}
+	accessToken, err := OAuthAccessToken(tool, userID)
+	if err != nil {
+		return err
+	}
+	headers := make(map[string]string, len(cfg.Headers)+1)
+	for key, value := range cfg.Headers {
+		headers[key] = value
+	}
+	headers["Authorization"] = "Bearer " + accessToken
+	cfg.Headers = headers
+	return nil
+}
+
+// ApplyOAuthMap 为启用OAuth的工具在发送给AI服务的MCP配置中加入用户的访问令牌（同时移除 oauth 设置）
+func ApplyOAuthMap(tool MCPTool, userID int64, config map[string]any) error {
+	delete(config, "oauth")
+	if _, ok := tool.OAuthSettings(); !ok {
+		return nil
+	}
+	accessToken, err := OAuthAccessToken(tool, userID)
+	if err != nil {
+		return err
+	}
+	headers, ok := config["headers"].(map[string]any)
+	if !ok {
+		headers = map[string]any{}
+		config["headers"] = headers
+	}
+	headers["Authorization"] = "Bearer " + accessToken
+	return nil
+}
+
+// ResetOAuth 删除工具的OAuth客户端和所有用户的令牌（服务地址或授权设置变更后需要重新授权）
+func ResetOAuth(toolID int64) {
+	global.DB.Where("mcp_tool_id = ?", toolID).Delete(&OAuthClient{})
+}
+
+// oauthChanged 服务地址或 oauth 设置是否变更
+func oauthChanged(before MCPTool, after MCPTool) bool {
+	beforeSettings, beforeEnabled := before.OAuthSettings()
+	afterSettings, afterEnabled := after.OAuthSettings()
+	if beforeEnabled != afterEnabled {
+		return true
+	}
+	if !beforeEnabled {
+		return false
+	}
+	beforeCfg, _ := before.ClientConfig()
+	afterCfg, _ := after.ClientConfig()
+	return beforeCfg.URL != afterCfg.URL || *beforeSettings != *afterSettings
+}
+
+// GetOAuthStatus 获取当前用户对工具的授权状态
+func GetOAuthStatus(c *gin.Context) {
+	tool, ok := findTool(c, viewableScope(c))
+	if !ok {
+		return
+	}
+	status := OAuthStatus{}
+	if _, status.Enabled = tool.OAuthSettings(); status.Enabled {
+		if token, err := findOAuthToken(tool.ID, int64(global.GetDooTaskUser(c).UserID)); err == nil {
+			status.Authorized = true
+			status.Scope = token.Scope
+			status.ExpiresAt = token.ExpiresAt
+			status.AuthorizedAt = &token.UpdatedAt
+		}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// AuthorizeOAuth 发起当前用户对工具的授权，返回授权地址
+func AuthorizeOAuth(c *gin.Context) {
+	tool, ok := findTool(c, viewableScope(c))
+	if !ok {
+		return
+	}
+	if _, enabled := tool.OAuthSettings(); !enabled {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "MCP_TOOL_006",
+			"message": "工具未启用OAuth授权",
+			"data":    nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), oauthTimeout)
+	defer cancel()
+	authorizationURL, err := AuthorizeURL(ctx, *tool, int64(global.GetDooTaskUser(c).UserID), c.GetString("host"))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "MCP_TOOL_007",
+			"message": "发起授权失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"authorization_url": authorizationURL,
+	})
+}
+
+// RevokeOAuth 删除当前用户对工具的授权
+func RevokeOAuth(c *gin.Context) {
+	tool, ok := findTool(c, viewableScope(c))
+	if !ok {
+		return
+	}
+	result := global.DB.
+		Where("mcp_tool_id = ? AND user_id = ?", tool.ID, global.GetDooTaskUser(c).UserID).
+		Delete(&OAuthToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除授权失败",
+			"data":    nil,
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "MCP_TOOL_008",
+			"message": "尚未授权",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "授权已删除",
+	})
+}
+
+// OAuthCallback 授权服务器回调（通过 state 识别用户，不需要登录）
+func OAuthCallback(c *gin.Context) {
+	if errCode := c.Query("error"); errCode != "" {
+		global.Redis.Del(c.Request.Context(), oauthStateKey(c.Query("state")))
+		writeOAuthPage(c, http.StatusBadRequest, "授权失败", strings.TrimSpace(errCode+" "+c.Query("error_description")))
+		return
+	}
+	if c.Query("state") == "" || c.Query("code") == "" {
+		writeOAuthPage(c, http.StatusBadRequest, "授权失败", "缺少 state 或 code 参数")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), oauthTimeout)
+	defer cancel()
+	tool, err := CompleteOAuth(ctx, c.Query("state"), c.Query("code"))
+	if err != nil {
+		writeOAuthPage(c, http.StatusBadRequest, "授权失败", err.Error())
+		return
+	}
+	writeOAuthPage(c, http.StatusOK, "授权成功", fmt.Sprintf("已完成工具「%s」的授权，可以关闭此页面并返回对话。", tool.Name))
+}
+
+// writeOAuthPage 输出授权结果页面
+func writeOAuthPage(c *gin.Context, status int, title string, message string) {
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>%[1]s</title></head>
+<body style="font-family: sans-serif; text-align: center; padding: 48px 16px;">
+<h2>%[1]s</h2>
+<p>%[2]s</p>
+</body>
+</html>`, html.EscapeString(title), html.EscapeString(message))
+	c.Data(status, "text/html; charset=utf-8", []byte(page))
+}
@@ -0,0 +1,50 @@
+package mcptools
+
+import (
+	"net/http"
+	"strconv"
+
+	"dootask-ai/go-service/mcpsupervisor"
+	"dootask-ai/go-service/permission"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProcesses 获取stdio工具托管进程的运行状态和错误输出
+func GetProcesses(c *gin.Context) {
+	tool, ok := findTool(c, viewableScope(c))
+	if !ok {
+		return
+	}
+	if !requireSTDIO(c, tool) {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "200"))
+	c.JSON(http.StatusOK, mcpsupervisor.GetStatus(tool.ID, limit))
+}
+
+// RestartProcesses 重启stdio工具的托管进程
+func RestartProcesses(c *gin.Context) {
+	tool, ok := findTool(c, permission.Scope(c, permission.ResourceMCPTool, permission.RoleEditor))
+	if !ok {
+		return
+	}
+	if !requireSTDIO(c, tool) {
+		return
+	}
+	mcpsupervisor.Restart(tool.ID)
+	c.JSON(http.StatusOK, mcpsupervisor.GetStatus(tool.ID, 200))
+}
+
+// requireSTDIO 只有stdio工具由Go服务托管进程
+func requireSTDIO(c *gin.Context, tool *MCPTool) bool {
+	if tool.ConfigType != ConfigTypeSTDIO {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "MCP_TOOL_004",
+			"message": "只有stdio工具由服务托管进程",
+			"data":    nil,
+		})
+		return false
+	}
+	return true
+}
@@ -1,14 +1,20 @@
 package mcptools
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"dootask-ai/go-service/audit"
 	"dootask-ai/go-service/global"
-	"dootask-ai/go-service/routes/api/conversations"
+	"dootask-ai/go-service/mcpclient"
+	"dootask-ai/go-service/mcpsupervisor"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/secret"
 	"dootask-ai/go-service/utils"
 
 	"github.com/gin-gonic/gin"
@@ -21,16 +27,31 @@ func RegisterRoutes(router *gin.RouterGroup) {
 	// MCP工具管理
 	mcpToolGroup := router.Group("/mcp-tools")
 	{
-		mcpToolGroup.GET("", ListMCPTools)                     // 获取工具列表
-		mcpToolGroup.POST("", CreateMCPTool)                   // 创建工具
-		mcpToolGroup.GET("/:id", GetMCPTool)                   // 获取工具详情
-		mcpToolGroup.PUT("/:id", UpdateMCPTool)                // 更新工具
-		mcpToolGroup.DELETE("/:id", DeleteMCPTool)             // 删除工具
-		mcpToolGroup.PATCH("/:id/toggle", ToggleMCPToolActive) // 切换工具状态
-		mcpToolGroup.POST("/:id/test", TestMCPTool)            // 测试工具
-		mcpToolGroup.GET("/stats", GetMCPToolStats)            // 获取统计信息
+		mcpToolGroup.GET("", ListMCPTools)                            // 获取工具列表
+		mcpToolGroup.POST("", CreateMCPTool)                          // 创建工具
+		mcpToolGroup.GET("/:id", GetMCPTool)                          // 获取工具详情
+		mcpToolGroup.PUT("/:id", UpdateMCPTool)                       // 更新工具
+		mcpToolGroup.DELETE("/:id", DeleteMCPTool)                    // 删除工具
+		mcpToolGroup.PATCH("/:id/toggle", ToggleMCPToolActive)        // 切换工具状态
+		mcpToolGroup.POST("/:id/test", TestMCPTool)                   // 测试工具
+		mcpToolGroup.GET("/:id/functions", ListFunctions)             // 获取工具提供的函数
+		mcpToolGroup.POST("/:id/functions/sync", SyncFunctions)       // 重新获取工具提供的函数
+		mcpToolGroup.GET("/:id/health", GetHealth)                    // 获取健康状态和检查历史
+		mcpToolGroup.POST("/:id/health/check", RunHealthCheck)        // 立即检查健康状态
+		mcpToolGroup.GET("/:id/processes", GetProcesses)              // 获取stdio托管进程状态和日志
+		mcpToolGroup.POST("/:id/processes/restart", RestartProcesses) // 重启stdio托管进程
+		mcpToolGroup.GET("/:id/oauth", GetOAuthStatus)                // 获取当前用户的OAuth授权状态
+		mcpToolGroup.POST("/:id/oauth/authorize", AuthorizeOAuth)     // 发起当前用户的OAuth授权
+		mcpToolGroup.DELETE("/:id/oauth", RevokeOAuth)                // 删除当前用户的OAuth授权
+		mcpToolGroup.GET("/stats", GetMCPToolStats)                   // 获取统计信息
 	}
 	InitMCPScheduler()
+	StartHealthMonitor()
+}
+
+// RegisterCallbackRoutes 注册OAuth授权回调路由（授权服务器重定向，通过 state 识别用户）
+func RegisterCallbackRoutes(router *gin.RouterGroup) {
+	router.GET("/service/mcp-oauth/callback", OAuthCallback)
 }
 
 // ListMCPTools 获取MCP工具列表
@@ -91,8 +112,8 @@ func ListMCPTools(c *gin.Context) {
 	// 构建查询
 	query := global.DB.Model(&MCPTool{})
 
-	// 设置默认筛选条件
-	query = query.Where("user_id = ? OR category = ?", global.GetDooTaskUser(c).UserID, "dootask")
+	// 设置默认筛选条件（自己创建的、共享给自己的和DooTask内置工具）
+	query = query.Scopes(viewableScope(c))
 
 	// 应用筛选条件
 	if filters.Search != "" {
@@ -144,28 +165,20 @@ func ListMCPTools(c *gin.Context) {
 	var activeTools int64
 	global.DB.Model(&MCPTool{}).Where("user_id = ? AND is_active = ?", global.GetDooTaskUser(c).UserID, true).Count(&activeTools)
 
-	// 统计对话消息中使用MCP工具的次数
-	var messages []conversations.Message
-	global.DB.Model(&conversations.Conversation{}).Joins(
-		"LEFT JOIN messages ON conversations.id = messages.conversation_id",
-	).Where("conversations.dootask_user_id = ?", strconv.Itoa(int(global.GetDooTaskUser(c).UserID))).
-		Where("messages.mcp_used IS NOT NULL").Select("messages.*").Find(&messages)
+	// 统计用户工具的调用记录
+	calls := aggregateCalls(global.DB.Where("mcp_tool_id IN (SELECT id FROM mcp_tools WHERE user_id = ?)", global.GetDooTaskUser(c).UserID))
 
-	var stats MCPToolStatsResponse
-	for _, message := range messages {
-		if message.McpUsed != nil {
-			var mcpUsed []string
-			if err := json.Unmarshal(message.McpUsed, &mcpUsed); err == nil {
-				stats.TotalCalls += int64(len(mcpUsed))
-			}
-		}
-		if message.ResponseTimeMs != nil {
-			stats.AvgResponseTime += float64(*message.ResponseTimeMs)
-		}
+	// 没有编辑权限的用户不返回配置中的密钥
+	ids := make([]int64, len(tools))
+	for i, tool := range tools {
+		ids[i] = tool.ID
 	}
-
-	if len(messages) > 0 {
-		stats.AvgResponseTime = stats.AvgResponseTime / float64(len(messages)) / 1000
+	var editable []int64
+	global.DB.Model(&MCPTool{}).Scopes(permission.Scope(c, permission.ResourceMCPTool, permission.RoleEditor)).Where("id IN ?", ids).Pluck("id", &editable)
+	for i := range tools {
+		if !slices.Contains(editable, tools[i].ID) {
+			maskConfig(&tools[i])
+		}
 	}
 
 	// 构造响应数据
@@ -175,8 +188,8 @@ func ListMCPTools(c *gin.Context) {
 			Total:           totalTools,
 			Active:          activeTools,
 			Inactive:        totalTools - activeTools,
-			TotalCalls:      stats.TotalCalls,
-			AvgResponseTime: stats.AvgResponseTime,
+			TotalCalls:      calls.TotalCalls,
+			AvgResponseTime: calls.AvgResponseTime(),
 		},
 	}
 
@@ -276,10 +289,19 @@ func CreateMCPTool(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionCreate, audit.ResourceMCPTool, tool.ID, nil, tool)
 
 	c.JSON(http.StatusOK, tool)
 }
 
+// viewableScope 当前用户可查看的工具（包括DooTask内置工具）
+func viewableScope(c *gin.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		query, args := permission.Condition(c, permission.ResourceMCPTool, permission.RoleViewer)
+		return db.Where(query+" OR mcp_tools.category = ?", append(args, "dootask")...)
+	}
+}
+
 // GetMCPTool 获取MCP工具详情
 func GetMCPTool(c *gin.Context) {
 	idStr := c.Param("id")
@@ -294,7 +316,7 @@ func GetMCPTool(c *gin.Context) {
 	}
 
 	var tool MCPTool
-	if err := global.DB.Where("id = ?", id).First(&tool).Error; err != nil {
+	if err := global.DB.Scopes(viewableScope(c)).Where("id = ?", id).First(&tool).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "MCP_TOOL_002",
@@ -311,7 +333,8 @@ func GetMCPTool(c *gin.Context) {
 		return
 	}
 
-	// 查询使用统计 (模拟数据，实际应该从调用日志表查询)
+	// 查询使用统计
+	calls := aggregateCalls(global.DB.Where("mcp_tool_id = ?", tool.ID))
 	var associatedAgents int64
 	global.DB.Model(&struct {
 		ID int64 `gorm:"primaryKey"`
@@ -338,12 +361,16 @@ func GetMCPTool(c *gin.Context) {
 		}
 	}
 
+	if !permission.Can(c, permission.ResourceMCPTool, tool.ID, permission.RoleEditor) {
+		maskConfig(&tool)
+	}
+
 	response := MCPToolResponse{
 		MCPTool:             &tool,
-		TotalCalls:          0,   // TODO: 从调用日志查询
-		TodayCalls:          0,   // TODO: 从调用日志查询
-		AverageResponseTime: 0.0, // TODO: 从调用日志计算
-		SuccessRate:         1.0, // TODO: 从调用日志计算
+		TotalCalls:          calls.TotalCalls,
+		TodayCalls:          calls.TodayCalls,
+		AverageResponseTime: calls.AvgResponseTime(),
+		SuccessRate:         calls.SuccessRate(),
 		AssociatedAgents:    associatedAgents,
 		ConfigInfo:          configInfo,
 	}
@@ -380,9 +407,34 @@ func sanitizeConfigData(configData map[string]interface{}) map[string]interface{
 		}
 	}
 
+	// 请求头和环境变量的值可能是密钥，只保留名称
+	for _, key := range []string{"headers", "env"} {
+		if values, ok := configData[key].(map[string]interface{}); ok {
+			masked := make(map[string]interface{}, len(values))
+			for name := range values {
+				masked[name] = "***"
+			}
+			sanitized[key] = masked
+		}
+	}
+
 	return sanitized
 }
 
+// maskConfig 将工具配置替换为不包含敏感信息的配置（用于没有编辑权限的用户）
+func maskConfig(tool *MCPTool) {
+	var configData map[string]interface{}
+	if err := json.Unmarshal(tool.Config, &configData); err != nil {
+		tool.Config = json.RawMessage("{}")
+		return
+	}
+	if data, err := json.Marshal(sanitizeConfigData(configData)); err == nil {
+		tool.Config = data
+	} else {
+		tool.Config = json.RawMessage("{}")
+	}
+}
+
 // UpdateMCPTool 更新MCP工具
 func UpdateMCPTool(c *gin.Context) {
 	idStr := c.Param("id")
@@ -419,7 +471,7 @@ func UpdateMCPTool(c *gin.Context) {
 
 	// 检查工具是否存在
 	var tool MCPTool
-	if err := global.DB.Where("id = ? AND user_id = ?", id, global.GetDooTaskUser(c).UserID).First(&tool).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceMCPTool, permission.RoleEditor)).Where("id = ?", id).First(&tool).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "MCP_TOOL_002",
@@ -439,7 +491,7 @@ func UpdateMCPTool(c *gin.Context) {
 	// 检查工具名称是否已被其他工具使用
 	if req.Name != nil && *req.Name != tool.Name {
 		var existingTool MCPTool
-		if err := global.DB.Where("user_id = ? AND name = ? AND id != ?", global.GetDooTaskUser(c).UserID, *req.Name, id).First(&existingTool).Error; err == nil {
+		if err := global.DB.Where("user_id = ? AND name = ? AND id != ?", tool.UserID, *req.Name, id).First(&existingTool).Error; err == nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
 				"code":    "MCP_TOOL_001",
 				"message": "工具名称已存在",
@@ -459,7 +511,7 @@ func UpdateMCPTool(c *gin.Context) {
 	// 检查MCP工具标识是否已被其他工具使用
 	if req.McpName != nil && *req.McpName != tool.McpName {
 		var existingTool MCPTool
-		if err := global.DB.Where("user_id = ? AND mcp_name = ? AND id != ?", global.GetDooTaskUser(c).UserID, *req.McpName, id).First(&existingTool).Error; err == nil {
+		if err := global.DB.Where("user_id = ? AND mcp_name = ? AND id != ?", tool.UserID, *req.McpName, id).First(&existingTool).Error; err == nil {
 			c.JSON(http.StatusUnprocessableEntity, gin.H{
 				"code":    "MCP_TOOL_003",
 				"message": "MCP工具标识已存在",
@@ -494,13 +546,24 @@ func UpdateMCPTool(c *gin.Context) {
 		updates["config_type"] = *req.ConfigType
 	}
 	if req.Config != nil {
-		updates["config"] = req.Config
+		// map更新不经过序列化器，需要手动加密敏感字段
+		config, err := secret.EncryptJSON(req.Config)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_002",
+				"message": "加密工具配置失败",
+				"data":    nil,
+			})
+			return
+		}
+		updates["config"] = string(config)
 	}
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
 
 	// 执行更新
+	before := tool
 	if err := global.DB.Model(&tool).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_002",
@@ -509,6 +572,10 @@ func UpdateMCPTool(c *gin.Context) {
 		})
 		return
 	}
+	if req.Config != nil || req.ConfigType != nil {
+		// 连接配置变更后函数缓存可能失效，下次查询时重新获取
+		global.DB.Where("mcp_tool_id = ?", tool.ID).Delete(&MCPToolFunction{})
+	}
 
 	// 查询更新后的工具信息
 	var updatedTool MCPTool
@@ -520,6 +587,24 @@ func UpdateMCPTool(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceMCPTool, id, before, updatedTool)
+
+	if req.Config != nil || req.ConfigType != nil {
+		// 连接配置变更后重置健康状态并立即重新检查，避免旧配置的失败记录导致新配置被跳过
+		global.DB.Model(&MCPTool{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+			"health_status":   HealthUnknown,
+			"health_failures": 0,
+		})
+		updatedTool.HealthStatus, updatedTool.HealthFailures = HealthUnknown, 0
+		go CheckHealth(updatedTool)
+	}
+	if req.Config != nil || req.ConfigType != nil || !updatedTool.IsActive {
+		mcpsupervisor.Stop(id)
+	}
+	if oauthChanged(before, updatedTool) {
+		// 服务地址或授权设置变更后原有客户端和令牌失效，用户需要重新授权
+		ResetOAuth(id)
+	}
 
 	c.JSON(http.StatusOK, updatedTool)
 }
@@ -539,7 +624,7 @@ func DeleteMCPTool(c *gin.Context) {
 
 	// 检查工具是否存在
 	var tool MCPTool
-	if err := global.DB.Where("id = ? AND user_id = ?", id, global.GetDooTaskUser(c).UserID).First(&tool).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceMCPTool, permission.RoleOwner)).Where("id = ?", id).First(&tool).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "MCP_TOOL_002",
@@ -588,6 +673,9 @@ func DeleteMCPTool(c *gin.Context) {
 		})
 		return
 	}
+	permission.RemoveShares(global.DB, permission.ResourceMCPTool, tool.ID)
+	audit.Record(c, audit.ActionDelete, audit.ResourceMCPTool, tool.ID, tool, nil)
+	mcpsupervisor.Stop(tool.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "工具删除成功",
@@ -619,7 +707,7 @@ func ToggleMCPToolActive(c *gin.Context) {
 
 	// 检查工具是否存在
 	var tool MCPTool
-	if err := global.DB.Where("id = ? AND user_id = ?", id, global.GetDooTaskUser(c).UserID).First(&tool).Error; err != nil {
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceMCPTool, permission.RoleEditor)).Where("id = ?", id).First(&tool).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "MCP_TOOL_002",
@@ -637,6 +725,7 @@ func ToggleMCPToolActive(c *gin.Context) {
 	}
 
 	// 更新状态
+	before := tool
 	if err := global.DB.Model(&tool).Update("is_active", req.IsActive).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    "DATABASE_002",
@@ -656,6 +745,10 @@ func ToggleMCPToolActive(c *gin.Context) {
 		})
 		return
 	}
+	audit.Record(c, audit.ActionToggle, audit.ResourceMCPTool, id, before, updatedTool)
+	if !updatedTool.IsActive {
+		mcpsupervisor.Stop(id)
+	}
 
 	c.JSON(http.StatusOK, updatedTool)
 }
@@ -685,7 +778,7 @@ func TestMCPTool(c *gin.Context) {
 
 	// 检查工具是否存在
 	var tool MCPTool
-	if err := global.DB.Where("id = ?", id).First(&tool).Error; err != nil {
+	if err := global.DB.Scopes(viewableScope(c)).Where("id = ?", id).First(&tool).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    "MCP_TOOL_002",
@@ -702,26 +795,164 @@ func TestMCPTool(c *gin.Context) {
 		return
 	}
 
-	// 模拟工具测试 (实际实现应该根据工具类型进行真实测试)
+	if req.ToolName != "" && !checkTestCall(c, tool, req) {
+		return
+	}
+
+	cfg, err := requestConfig(c, tool)
+	if err != nil {
+		c.JSON(http.StatusOK, TestMCPToolResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, testTool(c.Request.Context(), tool, cfg, req))
+}
+
+// checkTestCall 校验测试时调用工具函数的权限，失败时写入错误响应
+// 调用函数使用工具所有者的凭证，只允许编辑者调用（DooTask内置工具使用当前用户的Token）；
+// 指定智能体时按智能体的函数白名单和参数约束校验
+func checkTestCall(c *gin.Context, tool MCPTool, req TestMCPToolRequest) bool {
+	if tool.Category != "dootask" && !permission.Can(c, permission.ResourceMCPTool, tool.ID, permission.RoleEditor) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    "MCP_TOOL_009",
+			"message": "只有工具编辑者可以调用工具函数",
+			"data":    nil,
+		})
+		return false
+	}
+	if req.AgentID == nil {
+		return true
+	}
+
+	rule, limited, err := agentFunctionRule(c, *req.AgentID, tool.ID, req.ToolName)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "AGENT_002",
+				"message": "智能体不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询智能体失败",
+				"data":    nil,
+			})
+		}
+		return false
+	}
+	if limited && rule == nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    "MCP_TOOL_010",
+			"message": "智能体不允许调用该函数",
+			"data":    nil,
+		})
+		return false
+	}
+	if rule != nil {
+		if err := CheckArguments(rule.Constraints, req.TestData); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"code":    "MCP_TOOL_010",
+				"message": "调用参数不满足智能体的约束",
+				"data":    err.Error(),
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// testTool 连接MCP服务完成握手，列出工具、资源和提示词，并按需调用指定工具
+func testTool(ctx context.Context, tool MCPTool, cfg mcpclient.Config, req TestMCPToolRequest) TestMCPToolResponse {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout+10*time.Second)
+	defer cancel()
+
+	result := map[string]interface{}{
+		"tool_name": tool.Name,
+		"transport": cfg.Transport,
+		"test_time": time.Now().Format("2006-01-02 15:04:05"),
+	}
+	elapsed := func(start time.Time) float64 {
+		return float64(time.Since(start).Microseconds()) / 1000.0
+	}
+
 	startTime := time.Now()
+	client, release, err := connect(ctx, tool.ID, cfg)
+	result["handshake_ms"] = elapsed(startTime)
+	if err != nil {
+		return TestMCPToolResponse{
+			Success:      false,
+			Message:      "连接MCP服务失败: " + err.Error(),
+			ResponseTime: elapsed(startTime),
+			TestResult:   result,
+		}
+	}
+	defer release()
 
-	// TODO: 根据工具类型执行实际测试逻辑
-	time.Sleep(100 * time.Millisecond) // 模拟测试耗时
+	init := client.Initialize()
+	result["server_info"] = init.ServerInfo
+	result["protocol_version"] = init.ProtocolVersion
 
-	responseTime := float64(time.Since(startTime).Nanoseconds()) / 1000000.0 // 转换为毫秒
+	var errs []string
+	if client.HasCapability("tools") || req.ToolName != "" {
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			errs = append(errs, "获取工具列表失败: "+err.Error())
+		}
+		list := make([]map[string]string, 0, len(tools))
+		for _, item := range tools {
+			list = append(list, map[string]string{"name": item.Name, "description": item.Description})
+		}
+		result["tools"] = list
+	}
+	if client.HasCapability("resources") {
+		resources, err := client.ListResources(ctx)
+		if err != nil {
+			errs = append(errs, "获取资源列表失败: "+err.Error())
+		}
+		result["resources"] = resources
+	}
+	if client.HasCapability("prompts") {
+		prompts, err := client.ListPrompts(ctx)
+		if err != nil {
+			errs = append(errs, "获取提示词列表失败: "+err.Error())
+		}
+		result["prompts"] = prompts
+	}
+
+	if req.ToolName != "" {
+		callStart := time.Now()
+		callResult, err := client.CallTool(ctx, req.ToolName, req.TestData)
+		call := map[string]interface{}{
+			"name":       req.ToolName,
+			"latency_ms": elapsed(callStart),
+		}
+		if err != nil {
+			call["error"] = err.Error()
+			errs = append(errs, "调用工具失败: "+err.Error())
+		} else {
+			call["is_error"] = callResult.IsError
+			call["content"] = callResult.Content
+			if callResult.IsError {
+				errs = append(errs, "工具返回错误: "+callResult.Text())
+			}
+		}
+		result["tool_call"] = call
+	}
 
 	response := TestMCPToolResponse{
-		Success:      true,
+		Success:      len(errs) == 0,
 		Message:      "工具测试成功",
-		ResponseTime: responseTime,
-		TestResult: map[string]interface{}{
-			"tool_name":     tool.Name,
-			"tool_category": tool.Category,
-			"test_time":     time.Now().Format("2006-01-02 15:04:05"),
-		},
+		ResponseTime: elapsed(startTime),
+		TestResult:   result,
 	}
-
-	c.JSON(http.StatusOK, response)
+	if len(errs) > 0 {
+		response.Message = strings.Join(errs, "；")
+	}
+	return response
 }
 
 // GetMCPToolStats 获取MCP工具统计信息
@@ -737,9 +968,10 @@ func GetMCPToolStats(c *gin.Context) {
 	global.DB.Model(&MCPTool{}).Where("category = 'dootask'").Count(&stats.DooTaskTools)
 	global.DB.Model(&MCPTool{}).Where("category = 'external'").Count(&stats.ExternalTools)
 
-	// TODO: 从调用日志表统计使用数据
-	stats.TotalCalls = 0
-	stats.AvgResponseTime = 0.0
+	// 调用统计
+	calls := aggregateCalls(global.DB)
+	stats.TotalCalls = calls.TotalCalls
+	stats.AvgResponseTime = calls.AvgResponseTime()
 
 	c.JSON(http.StatusOK, stats)
 }
@@ -29,10 +29,17 @@ type MCPTool struct {
 	Description *string         `gorm:"type:text" json:"description"`
 	Category    string          `gorm:"type:varchar(50);not null;default:'external'" json:"category" validate:"required,oneof=dootask external"`
 	ConfigType  int8            `gorm:"type:smallint;default:0" json:"config_type"`
-	Config      json.RawMessage `gorm:"type:jsonb;default:'{}'" json:"config"`
+	Config      json.RawMessage `gorm:"type:jsonb;default:'{}';serializer:secretjson" json:"config"`
 	IsActive    bool            `gorm:"default:true" json:"is_active"`
 	CreatedAt   time.Time       `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt   time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// 健康状态（由后台健康检查维护）
+	HealthStatus    string     `gorm:"type:varchar(20);not null;default:'unknown'" json:"health_status"`
+	HealthCheckedAt *time.Time `json:"health_checked_at"`
+	HealthLatencyMs *int       `json:"health_latency_ms"`
+	HealthError     *string    `gorm:"type:text" json:"health_error"`
+	HealthFailures  int        `gorm:"not null;default:0" json:"health_failures"`
 }
 
 // TableName 指定表名
@@ -106,7 +113,9 @@ type ToggleMCPToolRequest struct {
 
 // TestMCPToolRequest 测试工具请求
 type TestMCPToolRequest struct {
-	TestData map[string]interface{} `json:"test_data"`
+	ToolName string                 `json:"tool_name"` // 要调用的工具名称，为空时只做握手和列表检查
+	TestData map[string]interface{} `json:"test_data"` // 调用工具的参数
+	AgentID  *int64                 `json:"agent_id"`  // 按智能体的函数白名单和参数约束校验调用
 }
 
 // TestMCPToolResponse 测试工具响应
@@ -0,0 +1,232 @@
+package shares
+
+import (
+	"net/http"
+	"strconv"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RegisterRoutes 注册资源共享路由
+func RegisterRoutes(router *gin.RouterGroup) {
+	shareGroup := router.Group("/shares")
+	{
+		shareGroup.GET("", ListShares)         // 获取资源的共享列表
+		shareGroup.POST("", CreateShare)       // 添加共享授权
+		shareGroup.PUT("/:id", UpdateShare)    // 修改共享角色
+		shareGroup.DELETE("/:id", DeleteShare) // 取消共享授权
+	}
+}
+
+// ListShares 获取资源的共享列表（需要查看权限）
+func ListShares(c *gin.Context) {
+	var req ListSharesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "查询参数格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil || !req.ResourceType.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的资源类型或资源ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	role, err := permission.RoleOf(c, req.ResourceType, req.ResourceID)
+	if err != nil || !role.Includes(permission.RoleViewer) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "SHARE_001",
+			"message": "资源不存在或无权访问",
+			"data":    nil,
+		})
+		return
+	}
+
+	items := []permission.ResourceShare{}
+	if err := global.DB.
+		Where("resource_type = ? AND resource_id = ?", req.ResourceType, req.ResourceID).
+		Order("id ASC").
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询共享列表失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ShareListResponse{
+		MyRole: role,
+		Items:  items,
+	})
+}
+
+// CreateShare 添加共享授权（需要所有者权限，已存在时更新角色）
+func CreateShare(c *gin.Context) {
+	var req CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+	if !req.ResourceType.Valid() || !req.SubjectType.Valid() || !req.Role.Valid() {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "无效的资源类型、授权对象类型或角色",
+			"data":    nil,
+		})
+		return
+	}
+
+	if !permission.Can(c, req.ResourceType, req.ResourceID, permission.RoleOwner) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    "SHARE_002",
+			"message": "只有所有者可以管理共享",
+			"data":    nil,
+		})
+		return
+	}
+
+	share := permission.ResourceShare{
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		SubjectType:  req.SubjectType,
+		SubjectID:    req.SubjectID,
+		Role:         req.Role,
+		GrantedBy:    int64(global.GetDooTaskUser(c).UserID),
+	}
+	if err := global.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "resource_type"}, {Name: "resource_id"}, {Name: "subject_type"}, {Name: "subject_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "granted_by", "updated_at"}),
+	}).Create(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "添加共享授权失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	var created permission.ResourceShare
+	global.DB.Where("resource_type = ? AND resource_id = ? AND subject_type = ? AND subject_id = ?",
+		req.ResourceType, req.ResourceID, req.SubjectType, req.SubjectID).First(&created)
+	c.JSON(http.StatusOK, created)
+}
+
+// UpdateShare 修改共享角色（需要所有者权限）
+func UpdateShare(c *gin.Context) {
+	share, ok := findOwnedShare(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !req.Role.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的角色",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := global.DB.Model(share).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "修改共享角色失败",
+			"data":    nil,
+		})
+		return
+	}
+	share.Role = req.Role
+	c.JSON(http.StatusOK, share)
+}
+
+// DeleteShare 取消共享授权（需要所有者权限）
+func DeleteShare(c *gin.Context) {
+	share, ok := findOwnedShare(c)
+	if !ok {
+		return
+	}
+
+	if err := global.DB.Delete(share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "取消共享授权失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "共享授权已取消",
+	})
+}
+
+// findOwnedShare 查询共享授权并校验当前用户为资源所有者，失败时直接写入响应
+func findOwnedShare(c *gin.Context) (*permission.ResourceShare, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的共享ID",
+			"data":    nil,
+		})
+		return nil, false
+	}
+
+	var share permission.ResourceShare
+	if err := global.DB.First(&share, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "SHARE_001",
+				"message": "共享授权不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询共享授权失败",
+				"data":    nil,
+			})
+		}
+		return nil, false
+	}
+
+	if !permission.Can(c, share.ResourceType, share.ResourceID, permission.RoleOwner) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code":    "SHARE_002",
+			"message": "只有所有者可以管理共享",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	return &share, true
+}
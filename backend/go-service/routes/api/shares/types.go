@@ -0,0 +1,29 @@
+package shares
+
+import "dootask-ai/go-service/permission"
+
+// ListSharesRequest 查询资源共享列表请求
+type ListSharesRequest struct {
+	ResourceType permission.ResourceType `form:"resource_type" validate:"required"`
+	ResourceID   int64                   `form:"resource_id" validate:"required,min=1"`
+}
+
+// CreateShareRequest 创建共享授权请求
+type CreateShareRequest struct {
+	ResourceType permission.ResourceType `json:"resource_type" validate:"required"`
+	ResourceID   int64                   `json:"resource_id" validate:"required,min=1"`
+	SubjectType  permission.SubjectType  `json:"subject_type" validate:"required"`
+	SubjectID    int64                   `json:"subject_id" validate:"required,min=1"`
+	Role         permission.Role         `json:"role" validate:"required"`
+}
+
+// UpdateShareRequest 更新共享角色请求
+type UpdateShareRequest struct {
+	Role permission.Role `json:"role" validate:"required"`
+}
+
+// ShareListResponse 资源共享列表响应
+type ShareListResponse struct {
+	MyRole permission.Role            `json:"my_role"`
+	Items  []permission.ResourceShare `json:"items"`
+}
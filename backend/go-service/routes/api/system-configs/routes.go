@@ -0,0 +1,226 @@
+package systemconfigs
+
+import (
+	"net/http"
+
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/middleware"
+	"dootask-ai/go-service/sysconfig"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// maskedValue 加密配置的展示值
+const maskedValue = "******"
+
+// RegisterRoutes 注册系统配置路由（仅管理员）
+func RegisterRoutes(router *gin.RouterGroup) {
+	configGroup := router.Group("/system-configs")
+	configGroup.Use(middleware.UserRoleMiddleware("admin"))
+	{
+		configGroup.GET("", ListSystemConfigs)        // 获取系统配置列表
+		configGroup.PUT("", BatchUpdateSystemConfigs) // 批量更新系统配置
+		configGroup.GET("/:key", GetSystemConfig)     // 获取单个系统配置
+		configGroup.PUT("/:key", UpdateSystemConfig)  // 更新单个系统配置
+	}
+}
+
+// toItem 转换为响应项
+func toItem(config sysconfig.Config) SystemConfigItem {
+	if config.IsEncrypted && config.Value != "" {
+		config.Value = maskedValue
+	}
+	configType := sysconfig.Types[config.Key]
+	if configType == "" {
+		configType = sysconfig.TypeString
+	}
+	return SystemConfigItem{Config: config, Type: configType}
+}
+
+// ListSystemConfigs 获取系统配置列表
+func ListSystemConfigs(c *gin.Context) {
+	var configs []sysconfig.Config
+	if err := global.DB.Order("key ASC").Find(&configs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询系统配置失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	items := make([]SystemConfigItem, 0, len(configs))
+	for _, config := range configs {
+		items = append(items, toItem(config))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    "SUCCESS",
+		"message": "获取成功",
+		"data":    items,
+	})
+}
+
+// GetSystemConfig 获取单个系统配置
+func GetSystemConfig(c *gin.Context) {
+	var config sysconfig.Config
+	if err := global.DB.Where("key = ?", c.Param("key")).First(&config).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "CONFIG_001",
+				"message": "系统配置不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询系统配置失败",
+				"data":    nil,
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    "SUCCESS",
+		"message": "获取成功",
+		"data":    toItem(config),
+	})
+}
+
+// UpdateSystemConfig 更新单个系统配置
+func UpdateSystemConfig(c *gin.Context) {
+	var req UpdateSystemConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	key := c.Param("key")
+	if err := sysconfig.Validate(key, req.Value); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	config, ok := setConfig(c, key, req.Value)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    "SUCCESS",
+		"message": "更新成功",
+		"data":    toItem(*config),
+	})
+}
+
+// BatchUpdateSystemConfigs 批量更新系统配置（先全部校验，再逐项更新）
+func BatchUpdateSystemConfigs(c *gin.Context) {
+	var req BatchUpdateSystemConfigsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	for key, value := range req.Configs {
+		if err := sysconfig.Validate(key, value); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"code":    "VALIDATION_002",
+				"message": err.Error(),
+				"data":    nil,
+			})
+			return
+		}
+	}
+
+	items := make([]SystemConfigItem, 0, len(req.Configs))
+	for key, value := range req.Configs {
+		config, ok := setConfig(c, key, value)
+		if !ok {
+			return
+		}
+		items = append(items, toItem(*config))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    "SUCCESS",
+		"message": "更新成功",
+		"data":    items,
+	})
+}
+
+// setConfig 更新配置并记录操作日志，失败时写入错误响应
+func setConfig(c *gin.Context, key string, value string) (*sysconfig.Config, bool) {
+	var before sysconfig.Config
+	if err := global.DB.Where("key = ?", key).First(&before).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "CONFIG_001",
+				"message": "系统配置不存在: " + key,
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询系统配置失败",
+				"data":    nil,
+			})
+		}
+		return nil, false
+	}
+
+	config, err := sysconfig.Set(key, value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "更新系统配置失败",
+			"data":    nil,
+		})
+		return nil, false
+	}
+	audit.Record(c, audit.ActionUpdate, audit.ResourceSystemConfig, key, maskConfig(before), maskConfig(*config))
+	return config, true
+}
+
+// maskConfig 加密配置在操作日志中只记录掩码
+func maskConfig(config sysconfig.Config) sysconfig.Config {
+	if config.IsEncrypted && config.Value != "" {
+		config.Value = maskedValue
+	}
+	return config
+}
@@ -0,0 +1,19 @@
+package systemconfigs
+
+import "dootask-ai/go-service/sysconfig"
+
+// SystemConfigItem 系统配置项（加密配置不返回明文）
+type SystemConfigItem struct {
+	sysconfig.Config
+	Type string `json:"type"`
+}
+
+// UpdateSystemConfigRequest 更新系统配置请求
+type UpdateSystemConfigRequest struct {
+	Value string `json:"value" validate:"required"`
+}
+
+// BatchUpdateSystemConfigsRequest 批量更新系统配置请求
+type BatchUpdateSystemConfigsRequest struct {
+	Configs map[string]string `json:"configs" validate:"required,min=1"`
+}
@@ -0,0 +1,120 @@
+package usersecrets
+
+import (
+	"net/http"
+
+	"dootask-ai/go-service/global"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm/clause"
+)
+
+// RegisterRoutes 注册用户密钥路由
+func RegisterRoutes(router *gin.RouterGroup) {
+	secretGroup := router.Group("/user-secrets")
+	{
+		secretGroup.GET("", ListSecrets)           // 获取我的密钥列表（不含密钥值）
+		secretGroup.PUT("/:name", SetSecret)       // 设置密钥
+		secretGroup.DELETE("/:name", DeleteSecret) // 删除密钥
+	}
+}
+
+// ListSecrets 获取当前用户的密钥列表
+func ListSecrets(c *gin.Context) {
+	items := []UserSecret{}
+	if err := global.DB.
+		Where("user_id = ?", global.GetDooTaskUser(c).UserID).
+		Order("name ASC").
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_001",
+			"message": "查询密钥失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, UserSecretListData{Items: items})
+}
+
+// SetSecret 设置当前用户的密钥（已存在时覆盖）
+func SetSecret(c *gin.Context) {
+	name := c.Param("name")
+	if !namePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "密钥名称只能包含字母、数字、下划线和中划线",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req SetSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	item, err := Save(int64(global.GetDooTaskUser(c).UserID), name, req.Value)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "保存密钥失败",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteSecret 删除当前用户的密钥
+func DeleteSecret(c *gin.Context) {
+	result := global.DB.
+		Where("user_id = ? AND name = ?", global.GetDooTaskUser(c).UserID, c.Param("name")).
+		Delete(&UserSecret{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    "DATABASE_002",
+			"message": "删除密钥失败",
+			"data":    nil,
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    "USER_SECRET_001",
+			"message": "密钥不存在",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "密钥删除成功",
+	})
+}
+
+// Save 保存用户密钥（已存在时覆盖）
+func Save(userID int64, name string, value string) (*UserSecret, error) {
+	item := UserSecret{UserID: userID, Name: name, Value: value}
+	err := global.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&item).Error
+	return &item, err
+}
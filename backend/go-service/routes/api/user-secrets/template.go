@@ -0,0 +1,214 @@
+package usersecrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/mcpclient"
+)
+
+// placeholderPattern 配置中的用户密钥占位符，如 {{user_secret.github_token}}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*user_secret\.([A-Za-z0-9_-]+)\s*\}\}`)
+
+const (
+	requestTTL = 30 * time.Minute
+	skipTTL    = 24 * time.Hour
+)
+
+// 回复这些内容时不提供密钥
+var cancelReplies = []string{"取消", "跳过", "cancel", "skip"}
+
+// resolver 按用户填充占位符，记录缺少的密钥
+type resolver struct {
+	userID  int64
+	values  map[string]string
+	missing []string
+}
+
+// newResolver 读取模板中引用的用户密钥
+func newResolver(userID int64, templates []string) *resolver {
+	r := &resolver{userID: userID, values: map[string]string{}}
+	var names []string
+	for _, template := range templates {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+			if !slices.Contains(names, match[1]) {
+				names = append(names, match[1])
+			}
+		}
+	}
+	if len(names) == 0 || userID == 0 {
+		r.missing = names
+		return r
+	}
+
+	var secrets []UserSecret
+	global.DB.Where("user_id = ? AND name IN (?)", userID, names).Find(&secrets)
+	for _, secret := range secrets {
+		r.values[secret.Name] = secret.Value
+	}
+	for _, name := range names {
+		if _, ok := r.values[name]; !ok {
+			r.missing = append(r.missing, name)
+		}
+	}
+	return r
+}
+
+// fill 替换字符串中的占位符
+func (r *resolver) fill(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := r.values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// 占位符只在请求头（包括转换为 Authorization 请求头的 apiKey）中填充；环境变量只在用户使用自己创建的工具时填充。
+// 地址、命令、参数由工具创建者控制，填充后共享工具的创建者可以拿到其他用户的密钥，其中的占位符保持原样
+
+// ResolveMap 填充MCP配置（JSON对象）中的占位符，返回缺少的密钥名称
+func ResolveMap(userID int64, ownerID int64, config map[string]any) []string {
+	keys := []string{"apiKey", "api_key"}
+	maps := []string{"headers"}
+	if userID == ownerID {
+		maps = append(maps, "env")
+	}
+
+	var templates []string
+	for _, key := range keys {
+		if value, ok := config[key].(string); ok {
+			templates = append(templates, value)
+		}
+	}
+	for _, key := range maps {
+		if values, ok := config[key].(map[string]any); ok {
+			for _, item := range values {
+				if value, ok := item.(string); ok {
+					templates = append(templates, value)
+				}
+			}
+		}
+	}
+	r := newResolver(userID, templates)
+	if len(r.missing) > 0 {
+		return r.missing
+	}
+
+	for _, key := range keys {
+		if value, ok := config[key].(string); ok {
+			config[key] = r.fill(value)
+		}
+	}
+	for _, key := range maps {
+		if values, ok := config[key].(map[string]any); ok {
+			filled := make(map[string]any, len(values))
+			for name, item := range values {
+				if value, ok := item.(string); ok {
+					item = r.fill(value)
+				}
+				filled[name] = item
+			}
+			config[key] = filled
+		}
+	}
+	return nil
+}
+
+// ResolveConfig 填充MCP客户端配置中的占位符，返回缺少的密钥名称
+func ResolveConfig(userID int64, ownerID int64, cfg *mcpclient.Config) []string {
+	var templates []string
+	for _, value := range cfg.Headers {
+		templates = append(templates, value)
+	}
+	ownTool := userID == ownerID
+	if ownTool {
+		for _, value := range cfg.Env {
+			templates = append(templates, value)
+		}
+	}
+	r := newResolver(userID, templates)
+	if len(r.missing) > 0 {
+		return r.missing
+	}
+
+	cfg.Headers = fillMap(r, cfg.Headers)
+	if ownTool {
+		cfg.Env = fillMap(r, cfg.Env)
+	}
+	return nil
+}
+
+// fillMap 替换映射值中的占位符（返回新的映射，不修改原配置）
+func fillMap(r *resolver, m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	result := make(map[string]string, len(m))
+	for key, value := range m {
+		result[key] = r.fill(value)
+	}
+	return result
+}
+
+func requestKey(agentID int64, dialogID string, userID int64) string {
+	return fmt.Sprintf("user_secret_request:%d:%s:%d", agentID, dialogID, userID)
+}
+
+func skipKey(dialogID string, userID int64, name string) string {
+	return fmt.Sprintf("user_secret_skip:%s:%d:%s", dialogID, userID, name)
+}
+
+// SaveRequest 记录等待用户在对话中提供的密钥
+func SaveRequest(agentID int64, dialogID string, userID int64, req Request) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	return global.Redis.Set(context.Background(), requestKey(agentID, dialogID, userID), data, requestTTL).Err()
+}
+
+// HasRequest 是否正在等待用户提供密钥（不取出）
+func HasRequest(agentID int64, dialogID string, userID int64) bool {
+	n, _ := global.Redis.Exists(context.Background(), requestKey(agentID, dialogID, userID)).Result()
+	return n > 0
+}
+
+// TakeRequest 取出等待提供的密钥，没有时返回nil
+func TakeRequest(agentID int64, dialogID string, userID int64) *Request {
+	data, err := global.Redis.GetDel(context.Background(), requestKey(agentID, dialogID, userID)).Bytes()
+	if err != nil {
+		return nil
+	}
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil
+	}
+	return &req
+}
+
+// Reply 处理用户对密钥提示的回复：回复取消时在该对话中暂不再提示，否则保存为密钥值
+func Reply(dialogID string, userID int64, req *Request, reply string) error {
+	reply = strings.TrimSpace(reply)
+	if reply == "" || slices.Contains(cancelReplies, strings.ToLower(reply)) {
+		return global.Redis.Set(context.Background(), skipKey(dialogID, userID, req.Name), 1, skipTTL).Err()
+	}
+	_, err := Save(userID, req.Name, reply)
+	return err
+}
+
+// IsSkipped 用户是否在该对话中取消过提供该密钥
+func IsSkipped(dialogID string, userID int64, name string) bool {
+	n, _ := global.Redis.Exists(context.Background(), skipKey(dialogID, userID, name)).Result()
+	return n > 0
+}
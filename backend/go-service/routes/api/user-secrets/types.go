@@ -0,0 +1,41 @@
+package usersecrets
+
+import (
+	"regexp"
+	"time"
+)
+
+// namePattern 密钥名称（与配置占位符 {{user_secret.名称}} 中的名称一致）
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+
+// UserSecret 用户密钥模型（值加密存储，不返回给前端）
+type UserSecret struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int64     `gorm:"not null" json:"user_id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Value     string    `gorm:"type:text;not null;serializer:secret" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UserSecret) TableName() string {
+	return "user_secrets"
+}
+
+// SetSecretRequest 设置密钥请求
+type SetSecretRequest struct {
+	Value string `json:"value" validate:"required,max=4096"`
+}
+
+// UserSecretListData 密钥列表数据
+type UserSecretListData struct {
+	Items []UserSecret `json:"items"`
+}
+
+// Request 等待用户在对话中提供的密钥
+type Request struct {
+	Name    string `json:"name"`
+	Tool    string `json:"tool"`
+	Message string `json:"message"` // 提示前用户发送的消息，保存密钥后继续处理
+}
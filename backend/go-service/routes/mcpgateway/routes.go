@@ -0,0 +1,366 @@
+package mcpgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/mcpclient"
+	"dootask-ai/go-service/mcpsupervisor"
+	"dootask-ai/go-service/routes/api/agents"
+	mcptools "dootask-ai/go-service/routes/api/mcp-tools"
+	usersecrets "dootask-ai/go-service/routes/api/user-secrets"
+	"dootask-ai/go-service/sysconfig"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册MCP网关路由（使用网关会话令牌认证）
+func RegisterRoutes(r *gin.RouterGroup) {
+	mcp := r.Group("/mcp/agents/:id")
+	{
+		mcp.POST("", Serve)           // streamable HTTP MCP端点
+		mcp.GET("", MethodNotAllowed) // 网关不提供服务端推送流
+		mcp.DELETE("", Close)         // 结束会话并关闭上游连接
+	}
+}
+
+// upstreamTool 会话中可用的上游MCP服务
+type upstreamTool struct {
+	tool      mcptools.MCPTool
+	cfg       mcpclient.Config
+	policy    agents.FunctionPolicy
+	hasPolicy bool
+}
+
+// allows 函数是否允许调用
+func (u upstreamTool) allows(name string) bool {
+	return !u.hasPolicy || u.policy.IsAllowed(name)
+}
+
+// gateway 一次网关请求的上下文
+type gateway struct {
+	token   string
+	session *Session
+	agent   agents.Agent
+	tools   []upstreamTool
+}
+
+// authenticate 校验会话令牌，失败时写入错误响应
+func authenticate(c *gin.Context) (string, *Session, bool) {
+	token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	session, err := loadSession(token)
+	if token == "" || err != nil || strconv.FormatInt(session.AgentID, 10) != c.Param("id") {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"code":    "AUTH_001",
+			"message": "无效的网关令牌",
+			"data":    nil,
+		})
+		return "", nil, false
+	}
+	return token, session, true
+}
+
+// Serve 处理MCP JSON-RPC请求（支持批量），只有通知时返回202
+func Serve(c *gin.Context) {
+	token, session, ok := authenticate(c)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRequestBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+		return
+	}
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	var requests []rpcRequest
+	if batch {
+		err = json.Unmarshal(body, &requests)
+	} else {
+		var req rpcRequest
+		err = json.Unmarshal(body, &req)
+		requests = []rpcRequest{req}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "无法解析JSON-RPC请求"}})
+		return
+	}
+
+	g, err := newGateway(token, session)
+	if err != nil {
+		c.JSON(http.StatusOK, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeInternalError, Message: err.Error()}})
+		return
+	}
+
+	var responses []rpcResponse
+	for _, req := range requests {
+		// 通知和客户端对服务端请求的响应无需应答
+		if len(req.ID) == 0 || req.Method == "" {
+			continue
+		}
+		responses = append(responses, g.handle(c.Request.Context(), req))
+	}
+
+	if len(responses) == 0 {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	if batch {
+		c.JSON(http.StatusOK, responses)
+	} else {
+		c.JSON(http.StatusOK, responses[0])
+	}
+}
+
+// MethodNotAllowed 网关不支持GET建立的服务端推送流
+func MethodNotAllowed(c *gin.Context) {
+	c.Header("Allow", "POST, DELETE")
+	c.Status(http.StatusMethodNotAllowed)
+}
+
+// Close 结束网关会话
+func Close(c *gin.Context) {
+	token, _, ok := authenticate(c)
+	if !ok {
+		return
+	}
+	upstreams.release(token)
+	c.Status(http.StatusOK)
+}
+
+// newGateway 加载会话的智能体和可用的上游服务
+func newGateway(token string, session *Session) (*gateway, error) {
+	g := &gateway{token: token, session: session}
+	if err := global.DB.Where("id = ?", session.AgentID).First(&g.agent).Error; err != nil {
+		return nil, fmt.Errorf("智能体不存在")
+	}
+
+	var tools []mcptools.MCPTool
+	if len(session.ToolIDs) > 0 {
+		global.DB.Where("id IN (?) AND is_active = ?", session.ToolIDs, true).Order("id ASC").Find(&tools)
+	}
+	for _, tool := range tools {
+		cfg, err := tool.ClientConfig()
+		if tool.Category == "dootask" {
+			// DooTask MCP 通过当前DooTask地址访问，并使用用户Token鉴权
+			cfg.Transport = mcpclient.TransportStreamableHTTP
+			cfg.URL = fmt.Sprintf("%s/apps/mcp_server/mcp", session.BaseURL)
+			if cfg.Headers == nil {
+				cfg.Headers = map[string]string{}
+			}
+			cfg.Headers["Authorization"] = "Bearer " + session.UserToken
+			err = nil
+		}
+		if err != nil {
+			log.Printf("MCP网关跳过工具 %s: %v", tool.McpName, err)
+			continue
+		}
+		if missing := usersecrets.ResolveConfig(session.UserID, tool.UserID, &cfg); len(missing) > 0 {
+			log.Printf("MCP网关跳过工具 %s: 缺少用户密钥 %v", tool.McpName, missing)
+			continue
+		}
+		if err := mcptools.ApplyOAuth(tool, session.UserID, &cfg); err != nil {
+			log.Printf("MCP网关跳过工具 %s: %v", tool.McpName, err)
+			continue
+		}
+		policy, hasPolicy := g.agent.FunctionPolicy(tool.ID, session.Interactive)
+		g.tools = append(g.tools, upstreamTool{tool: tool, cfg: cfg, policy: policy, hasPolicy: hasPolicy})
+	}
+	return g, nil
+}
+
+// handle 处理单个JSON-RPC请求
+func (g *gateway) handle(ctx context.Context, req rpcRequest) rpcResponse {
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" {
+		resp.Error = &rpcError{Code: codeInvalidRequest, Message: "Invalid Request"}
+		return resp
+	}
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		version := supportedVersions[0]
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		resp.Result = map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+			"serverInfo":      map[string]any{"name": "dootask-ai-gateway", "version": "1.0.0"},
+		}
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = map[string]any{"tools": g.listTools(ctx)}
+	case "tools/call":
+		var params callParams
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			resp.Error = &rpcError{Code: codeInvalidParams, Message: "缺少工具名称"}
+			return resp
+		}
+		resp.Result = g.callTool(ctx, params)
+	default:
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: "Method not found: " + req.Method}
+	}
+	return resp
+}
+
+// listTools 并发获取各上游服务的函数列表，按白名单过滤并加上服务前缀；获取失败的服务跳过
+func (g *gateway) listTools(ctx context.Context) []gatewayTool {
+	results := make([][]gatewayTool, len(g.tools))
+	var wg sync.WaitGroup
+	for i, u := range g.tools {
+		wg.Add(1)
+		go func(i int, u upstreamTool) {
+			defer wg.Done()
+			client, release, err := g.connect(u)
+			if err != nil {
+				log.Printf("MCP网关连接 %s 失败: %v", u.tool.McpName, err)
+				return
+			}
+			defer release()
+			tools, err := client.ListTools(ctx)
+			if err != nil {
+				log.Printf("MCP网关获取 %s 函数列表失败: %v", u.tool.McpName, err)
+				upstreams.drop(g.token, u.tool.ID)
+				return
+			}
+			for _, tool := range tools {
+				if !u.allows(tool.Name) {
+					continue
+				}
+				schema := tool.InputSchema
+				if len(schema) == 0 {
+					schema = json.RawMessage(`{"type":"object"}`)
+				}
+				results[i] = append(results[i], gatewayTool{
+					Name:        ToolName(u.tool.McpName, tool.Name),
+					Description: tool.Description,
+					InputSchema: schema,
+				})
+			}
+		}(i, u)
+	}
+	wg.Wait()
+
+	tools := []gatewayTool{}
+	for _, items := range results {
+		tools = append(tools, items...)
+	}
+	return tools
+}
+
+// callTool 将调用转发给上游服务，校验白名单和参数约束并记录调用
+func (g *gateway) callTool(ctx context.Context, params callParams) *mcpclient.CallToolResult {
+	serverName, function, _ := strings.Cut(params.Name, NamespaceSeparator)
+	idx := slices.IndexFunc(g.tools, func(u upstreamTool) bool { return u.tool.McpName == serverName })
+	if idx < 0 || function == "" {
+		return errorResult(fmt.Sprintf("工具 %s 不存在", params.Name))
+	}
+	u := g.tools[idx]
+
+	start := time.Now()
+	result, err := g.forward(ctx, u, function, params.Arguments)
+	g.record(u.tool.ID, function, params.Arguments, result, err, time.Since(start))
+	if err != nil {
+		return errorResult(err.Error())
+	}
+	return result
+}
+
+// forward 校验并调用上游函数
+func (g *gateway) forward(ctx context.Context, u upstreamTool, function string, arguments map[string]any) (*mcpclient.CallToolResult, error) {
+	if !u.allows(function) {
+		return nil, fmt.Errorf("调用被拒绝：函数 %s 未被允许", function)
+	}
+	if err := mcptools.CheckArguments(u.policy.Constraints[function], arguments); err != nil {
+		return nil, fmt.Errorf("调用被拒绝：%v", err)
+	}
+	if arguments == nil {
+		arguments = map[string]any{}
+	}
+
+	client, release, err := g.connect(u)
+	if err != nil {
+		return nil, fmt.Errorf("连接 %s 失败: %v", u.tool.McpName, err)
+	}
+	defer release()
+	result, err := client.CallTool(ctx, function, arguments)
+	if err != nil && client.Err() != nil {
+		upstreams.drop(g.token, u.tool.ID)
+	}
+	return result, err
+}
+
+// connect 获取上游连接，返回的 release 在调用结束后执行
+// stdio 服务使用托管的进程池，其他服务按会话复用连接
+func (g *gateway) connect(u upstreamTool) (*mcpclient.Client, func(), error) {
+	if u.cfg.Transport == mcpclient.TransportSTDIO {
+		lease, err := mcpsupervisor.Acquire(u.tool.ID, u.cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return lease.Client, lease.Release, nil
+	}
+	client, err := upstreams.get(g.token, u.tool, u.cfg)
+	return client, func() {}, err
+}
+
+// record 写入工具调用记录
+func (g *gateway) record(toolID int64, function string, arguments map[string]any, result *mcpclient.CallToolResult, callErr error, latency time.Duration) {
+	if !sysconfig.Bool(sysconfig.KeyEnableToolLogging, true) {
+		return
+	}
+	args, _ := json.Marshal(audit.Redact(arguments))
+	call := mcptools.ToolCall{
+		AgentID:      &g.session.AgentID,
+		MCPToolID:    &toolID,
+		FunctionName: function,
+		Arguments:    args,
+		LatencyMs:    int(latency.Milliseconds()),
+	}
+	if g.session.ConversationID > 0 {
+		call.ConversationID = &g.session.ConversationID
+	}
+	switch {
+	case callErr != nil:
+		errMsg := callErr.Error()
+		call.Error = &errMsg
+	case result != nil:
+		call.ResultSize = len(result.Text())
+		if result.IsError {
+			errMsg := result.Text()
+			if runes := []rune(errMsg); len(runes) > 1000 {
+				errMsg = string(runes[:1000]) + "..."
+			}
+			call.Error = &errMsg
+		}
+	}
+	if err := global.DB.Create(&call).Error; err != nil {
+		log.Printf("写入工具调用记录失败: %v", err)
+	}
+}
+
+// errorResult 以工具错误结果返回，由模型决定如何处理
+func errorResult(message string) *mcpclient.CallToolResult {
+	return &mcpclient.CallToolResult{
+		Content: []mcpclient.Content{{Type: "text", Text: message}},
+		IsError: true,
+	}
+}
@@ -0,0 +1,81 @@
+package mcpgateway
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/sysconfig"
+	"dootask-ai/go-service/utils"
+)
+
+func sessionKey(token string) string {
+	return fmt.Sprintf("mcp_gateway:%s", token)
+}
+
+// Enabled 是否通过网关访问MCP工具
+func Enabled() bool {
+	return sysconfig.Bool(sysconfig.KeyEnableMCPGateway, true)
+}
+
+// Endpoint 智能体的网关地址（AI服务访问Go服务使用的地址）
+func Endpoint(agentID int64) string {
+	baseURL := utils.GetEnvWithDefault("MCP_GATEWAY_URL", fmt.Sprintf("http://localhost:%s", utils.GetEnvWithDefault("GO_SERVICE_PORT", "8000")))
+	return fmt.Sprintf("%s/mcp/agents/%d", baseURL, agentID)
+}
+
+// ToolName 网关中的工具名称
+func ToolName(mcpName string, function string) string {
+	return mcpName + NamespaceSeparator + function
+}
+
+// NewSession 创建网关会话并返回发送给AI服务的MCP配置
+// approvals 为需要用户确认的工具（网关中的名称），由AI服务在调用前暂停等待确认
+func NewSession(session Session, approvals map[string]string) (map[string]any, error) {
+	token, err := newSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := global.Redis.Set(context.Background(), sessionKey(token), data, sessionTTL).Err(); err != nil {
+		return nil, err
+	}
+
+	config := map[string]any{
+		"transport": "streamable_http",
+		"url":       Endpoint(session.AgentID),
+		"headers":   map[string]any{"Authorization": "Bearer " + token},
+	}
+	if len(approvals) > 0 {
+		config["tool_approvals"] = approvals
+	}
+	return config, nil
+}
+
+// newSessionToken 生成网关会话令牌（令牌即访问凭证，使用加密安全的随机数）
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// loadSession 根据令牌读取网关会话
+func loadSession(token string) (*Session, error) {
+	data, err := global.Redis.Get(context.Background(), sessionKey(token)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,75 @@
+package mcpgateway
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// NamespaceSeparator 工具名称中服务名与函数名的分隔符，如 github__create_issue
+	NamespaceSeparator = "__"
+	// ServerName 网关在AI服务MCP配置中的名称
+	ServerName = "dootaskai"
+
+	sessionTTL          = 15 * time.Minute
+	upstreamIdleTimeout = 2 * time.Minute
+	maxRequestBodySize  = 4 << 20
+)
+
+// 网关支持的MCP协议版本（按新旧排列，客户端请求的版本不支持时使用第一个）
+var supportedVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// Session 一次智能体运行的网关会话，由请求AI服务前创建，AI服务凭令牌访问网关
+type Session struct {
+	AgentID        int64   `json:"agent_id"`
+	UserID         int64   `json:"user_id"`
+	ConversationID int64   `json:"conversation_id"`
+	ToolIDs        []int64 `json:"tool_ids"`
+	UserToken      string  `json:"user_token"` // DooTask MCP 使用当前用户的Token鉴权
+	BaseURL        string  `json:"base_url"`   // DooTask 地址
+	Interactive    bool    `json:"interactive"`
+}
+
+// rpcRequest JSON-RPC 请求或通知
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse JSON-RPC 响应
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError JSON-RPC 错误
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC 错误码
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// gatewayTool 网关工具（上游服务提供的函数，名称带服务前缀）
+type gatewayTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// callParams tools/call 参数
+type callParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
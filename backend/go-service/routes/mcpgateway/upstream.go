@@ -0,0 +1,115 @@
+package mcpgateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/mcpclient"
+	mcptools "dootask-ai/go-service/routes/api/mcp-tools"
+)
+
+// upstream 到上游MCP服务的连接
+type upstream struct {
+	client   *mcpclient.Client
+	lastUsed time.Time
+}
+
+// pool 按会话复用上游连接（AI服务每次调用工具都会重新握手，上游连接在会话内保持）
+type pool struct {
+	mu      sync.Mutex
+	clients map[string]*upstream
+}
+
+var upstreams = newPool()
+
+func newPool() *pool {
+	p := &pool{clients: map[string]*upstream{}}
+	go p.sweep()
+	return p
+}
+
+func upstreamKey(token string, toolID int64) string {
+	return fmt.Sprintf("%s:%d", token, toolID)
+}
+
+// get 获取或建立上游连接
+func (p *pool) get(token string, tool mcptools.MCPTool, cfg mcpclient.Config) (*mcpclient.Client, error) {
+	key := upstreamKey(token, tool.ID)
+	p.mu.Lock()
+	if u, ok := p.clients[key]; ok && u.client.Err() == nil {
+		u.lastUsed = time.Now()
+		p.mu.Unlock()
+		return u.client, nil
+	}
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	client, err := mcpclient.Connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if u, ok := p.clients[key]; ok && u.client.Err() == nil {
+		// 并发请求已建立连接，使用已有连接
+		client.Close()
+		u.lastUsed = time.Now()
+		return u.client, nil
+	}
+	p.clients[key] = &upstream{client: client, lastUsed: time.Now()}
+	return client, nil
+}
+
+// drop 关闭并移除上游连接
+func (p *pool) drop(token string, toolID int64) {
+	key := upstreamKey(token, toolID)
+	p.mu.Lock()
+	u, ok := p.clients[key]
+	delete(p.clients, key)
+	p.mu.Unlock()
+	if ok {
+		u.client.Close()
+	}
+}
+
+// release 关闭会话的全部上游连接
+func (p *pool) release(token string) {
+	p.mu.Lock()
+	var closing []*upstream
+	for key, u := range p.clients {
+		if strings.HasPrefix(key, token+":") {
+			closing = append(closing, u)
+			delete(p.clients, key)
+		}
+	}
+	p.mu.Unlock()
+	for _, u := range closing {
+		u.client.Close()
+	}
+}
+
+// sweep 定期关闭空闲的上游连接
+func (p *pool) sweep() {
+	for range time.Tick(time.Minute) {
+		p.mu.Lock()
+		var closing []*upstream
+		for key, u := range p.clients {
+			if time.Since(u.lastUsed) > upstreamIdleTimeout || u.client.Err() != nil {
+				closing = append(closing, u)
+				delete(p.clients, key)
+			}
+		}
+		p.mu.Unlock()
+		for _, u := range closing {
+			if err := u.client.Close(); err != nil {
+				log.Printf("关闭MCP上游连接失败: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/routes/api/agents"
+	aimodels "dootask-ai/go-service/routes/api/ai-models"
+	apikeys "dootask-ai/go-service/routes/api/api-keys"
+	"dootask-ai/go-service/routes/api/conversations"
+	"dootask-ai/go-service/routes/service"
+
+	dootask "github.com/dootask/tools/server/go"
+	"github.com/duke-git/lancet/v2/random"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ctxKeyAPIKey = "apiKey"
+	modelPrefix  = "agent-"
+)
+
+// RegisterRoutes 注册OpenAI兼容路由（使用个人API密钥鉴权）
+func RegisterRoutes(router *gin.RouterGroup) {
+	v1 := router.Group("/v1")
+	v1.Use(AuthMiddleware())
+	{
+		v1.GET("/models", ListModels)                 // 获取可用模型（智能体）列表
+		v1.POST("/chat/completions", ChatCompletions) // 对话补全
+	}
+}
+
+// AuthMiddleware 个人API密钥鉴权，成功后以密钥所属用户身份访问
+//
+// 限制：API密钥请求没有DooTask用户Token，无法获取用户所属部门，也无法调用DooTask接口，
+// 因此只能使用按用户授权（不含按部门共享）的智能体和知识库，DooTask MCP工具也不可用
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !strings.HasPrefix(raw, apikeys.KeyPrefix) {
+			writeError(c, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "缺少有效的API密钥")
+			c.Abort()
+			return
+		}
+
+		key, err := apikeys.Authenticate(raw)
+		if err != nil {
+			writeError(c, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "API密钥无效或已过期")
+			c.Abort()
+			return
+		}
+
+		c.Set(ctxKeyAPIKey, key)
+		c.Set(global.CtxKeyDooTaskUser, &dootask.UserInfo{UserID: int(key.UserID)})
+		c.Set(global.CtxKeyAuthError, nil)
+		c.Next()
+	}
+}
+
+// ListModels 获取当前用户可用的智能体列表
+func ListModels(c *gin.Context) {
+	var items []agents.Agent
+	if err := global.DB.
+		Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleViewer)).
+		Where("is_active = ?", true).
+		Order("id ASC").
+		Find(&items).Error; err != nil {
+		writeError(c, http.StatusInternalServerError, "server_error", "database_error", "查询智能体失败")
+		return
+	}
+
+	models := make([]Model, 0, len(items))
+	for _, agent := range items {
+		model := Model{
+			ID:      modelPrefix + strconv.FormatInt(agent.ID, 10),
+			Object:  "model",
+			Created: agent.CreatedAt.Unix(),
+			OwnedBy: strconv.FormatInt(agent.UserID, 10),
+			Name:    agent.Name,
+		}
+		if agent.Description != nil {
+			model.Description = *agent.Description
+		}
+		models = append(models, model)
+	}
+
+	c.JSON(http.StatusOK, ModelList{Object: "list", Data: models})
+}
+
+// ChatCompletions 对话补全，支持流式（SSE）和非流式两种响应
+func ChatCompletions(c *gin.Context) {
+	var req ChatCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request_error", "invalid_json", "请求数据格式错误: "+err.Error())
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		writeError(c, http.StatusBadRequest, "invalid_request_error", "invalid_value", "数据验证失败: "+err.Error())
+		return
+	}
+
+	agent, err := findAgent(c, req.Model)
+	if err != nil {
+		writeError(c, http.StatusNotFound, "invalid_request_error", "model_not_found", "模型不存在或无权访问: "+req.Model)
+		return
+	}
+
+	var aiModel aimodels.AIModel
+	if err := global.DB.Where("id = ?", agent.AIModelID).First(&aiModel).Error; err != nil || aiModel.IsEnabled == nil || !*aiModel.IsEnabled {
+		writeError(c, http.StatusUnprocessableEntity, "invalid_request_error", "model_unavailable", "智能体未配置可用的AI模型")
+		return
+	}
+
+	system, message := buildPrompt(req.Messages)
+	if strings.TrimSpace(message) == "" {
+		writeError(c, http.StatusBadRequest, "invalid_request_error", "invalid_value", "消息内容不能为空")
+		return
+	}
+	if system != "" {
+		agent.Prompt = strings.TrimSpace(agent.Prompt + "\n\n" + system)
+	}
+
+	key := c.MustGet(ctxKeyAPIKey).(*apikeys.APIKey)
+	settings := req.Settings()
+	opts := service.AIRequestOptions{
+		Message:  message,
+		UserID:   key.UserID,
+		BaseURL:  c.GetString("host"),
+		Settings: &settings,
+	}
+
+	startTime := time.Now()
+	resp, err := service.RequestAI(aiModel, *agent, opts)
+	if err != nil {
+		writeError(c, http.StatusBadGateway, "server_error", "ai_service_error", "请求AI服务失败: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	completion := ChatCompletion{
+		ID:      "chatcmpl-" + random.RandString(24),
+		Created: startTime.Unix(),
+		Model:   req.Model,
+	}
+
+	var (
+		answer    strings.Builder
+		reasoning strings.Builder
+		usage     Usage
+		status    = 1
+		errMsg    string
+		toolsUsed = []string{}
+		recorder  = service.NewToolCallRecorder()
+	)
+
+	if req.Stream {
+		c.Header("Content-Type", "text/event-stream; charset=utf-8")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		writeChunk(c.Writer, completion, &ChatCompletionMessage{Role: "assistant"}, nil, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), service.StreamTimeout)
+	defer cancel()
+
+	readErr := service.ReadStreamLines(ctx, resp.Body, func(v service.StreamLineData, _ string) bool {
+		switch v.Type {
+		case "token":
+			content := fmt.Sprintf("%v", v.Content)
+			answer.WriteString(content)
+			if req.Stream {
+				writeChunk(c.Writer, completion, &ChatCompletionMessage{Content: content}, nil, nil)
+			}
+		case "thinking":
+			content := fmt.Sprintf("%v", v.Content)
+			reasoning.WriteString(content)
+			if req.Stream {
+				writeChunk(c.Writer, completion, &ChatCompletionMessage{ReasoningContent: content}, nil, nil)
+			}
+		case "error":
+			status = 0
+			errMsg = fmt.Sprintf("%v", v.Content)
+		case "message":
+			msg, err := service.ParseStreamMessage(v)
+			if err != nil {
+				return true
+			}
+			usage.PromptTokens += msg.UsageMetadata.InputTokens
+			usage.CompletionTokens += msg.UsageMetadata.OutputTokens
+			recorder.Observe(msg)
+			if msg.Type == "ai" {
+				for _, toolCall := range msg.ToolCalls {
+					toolsUsed = append(toolsUsed, toolCall.Name)
+				}
+				// 未产生token流时（如模型不支持流式）以最终消息作为回答
+				if len(msg.ToolCalls) == 0 && answer.Len() == 0 && msg.Content != "" {
+					answer.WriteString(msg.Content)
+					if req.Stream {
+						writeChunk(c.Writer, completion, &ChatCompletionMessage{Content: msg.Content}, nil, nil)
+					}
+				}
+			}
+		}
+		return true
+	})
+	if readErr != nil {
+		status = 0
+		errMsg = readErr.Error()
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	recordUsage(key, agent, aiModel, req, message, answer.String(), usage, status, toolsUsed, recorder, startTime)
+
+	finishReason := "stop"
+	if req.Stream {
+		if status == 0 && answer.Len() == 0 {
+			payload, _ := json.Marshal(ErrorResponse{Error: ErrorBody{Message: errMsg, Type: "server_error", Code: "ai_service_error"}})
+			fmt.Fprintf(c.Writer, "data: %s\n\n", payload)
+		} else {
+			writeChunk(c.Writer, completion, &ChatCompletionMessage{}, &finishReason, nil)
+		}
+		if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+			writeChunk(c.Writer, completion, nil, nil, &usage)
+		}
+		fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+		c.Writer.Flush()
+		return
+	}
+
+	if status == 0 && answer.Len() == 0 {
+		writeError(c, http.StatusBadGateway, "server_error", "ai_service_error", errMsg)
+		return
+	}
+	completion.Object = "chat.completion"
+	completion.Choices = []ChatCompletionChoice{{
+		Index: 0,
+		Message: &ChatCompletionMessage{
+			Role:             "assistant",
+			Content:          answer.String(),
+			ReasoningContent: reasoning.String(),
+		},
+		FinishReason: &finishReason,
+	}}
+	completion.Usage = &usage
+	c.JSON(http.StatusOK, completion)
+}
+
+// findAgent 根据模型名称查找可用智能体（支持 agent-<id> 或智能体名称）
+func findAgent(c *gin.Context, model string) (*agents.Agent, error) {
+	query := global.DB.
+		Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleViewer)).
+		Where("is_active = ?", true)
+	if idStr, ok := strings.CutPrefix(model, modelPrefix); ok {
+		if id, err := strconv.ParseInt(idStr, 10, 64); err == nil {
+			query = query.Where("id = ?", id)
+		} else {
+			query = query.Where("name = ?", model)
+		}
+	} else {
+		query = query.Where("name = ?", model).Order("id ASC")
+	}
+
+	var agent agents.Agent
+	if err := query.First(&agent).Error; err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// buildPrompt 将OpenAI消息列表转换为附加系统提示词和本次用户消息（历史消息以文本形式附带）
+func buildPrompt(messages []ChatMessage) (string, string) {
+	var (
+		systems []string
+		history []ChatMessage
+	)
+	for _, m := range messages {
+		if m.Role == "system" || m.Role == "developer" {
+			systems = append(systems, m.Text())
+			continue
+		}
+		history = append(history, m)
+	}
+	if len(history) == 0 {
+		return strings.Join(systems, "\n\n"), ""
+	}
+
+	last := history[len(history)-1].Text()
+	if len(history) == 1 {
+		return strings.Join(systems, "\n\n"), last
+	}
+
+	var b strings.Builder
+	b.WriteString("<conversation_history>\n")
+	for _, m := range history[:len(history)-1] {
+		fmt.Fprintf(&b, "%s: %s\n", m.Role, m.Text())
+	}
+	b.WriteString("</conversation_history>\n\n")
+	b.WriteString(last)
+	return strings.Join(systems, "\n\n"), b.String()
+}
+
+// recordUsage 记录对话和消息（与机器人消息一致，用于统计）
+func recordUsage(key *apikeys.APIKey, agent *agents.Agent, aiModel aimodels.AIModel, req ChatCompletionRequest, message string, answer string, usage Usage, status int, toolsUsed []string, recorder *service.ToolCallRecorder, startTime time.Time) {
+	chatID := fmt.Sprintf("api:%d", key.ID)
+	if req.User != "" {
+		chatID += ":" + req.User
+	}
+	conversation := conversations.Conversation{
+		AgentID:       agent.ID,
+		DootaskChatID: chatID,
+		DootaskUserID: strconv.FormatInt(key.UserID, 10),
+		IsActive:      true,
+	}
+	if err := global.DB.
+		Where("agent_id = ? AND dootask_chat_id = ? AND dootask_user_id = ?", agent.ID, chatID, conversation.DootaskUserID).
+		FirstOrCreate(&conversation).Error; err != nil {
+		return
+	}
+
+	metadata, _ := json.Marshal(map[string]any{"source": "openai_api", "api_key_id": key.ID})
+	mcpUsed, _ := json.Marshal(toolsUsed)
+	responseTimeMs := int(time.Since(startTime).Milliseconds())
+	global.DB.Create(&[]conversations.Message{
+		{
+			ConversationID: conversation.ID,
+			Role:           "user",
+			Content:        message,
+			Metadata:       metadata,
+			TokensUsed:     usage.PromptTokens,
+		},
+		{
+			ConversationID: conversation.ID,
+			Role:           "assistant",
+			Content:        answer,
+			Metadata:       metadata,
+			TokensUsed:     usage.CompletionTokens,
+			ModelUsed:      &aiModel.ModelName,
+			McpUsed:        mcpUsed,
+			ResponseTimeMs: &responseTimeMs,
+			Status:         status,
+		},
+	})
+	recorder.Save(*agent, &conversation.ID)
+}
+
+// writeChunk 写入一条流式响应块
+func writeChunk(w io.Writer, completion ChatCompletion, delta *ChatCompletionMessage, finishReason *string, usage *Usage) {
+	completion.Object = "chat.completion.chunk"
+	completion.Choices = []ChatCompletionChoice{}
+	if delta != nil {
+		completion.Choices = append(completion.Choices, ChatCompletionChoice{Index: 0, Delta: delta, FinishReason: finishReason})
+	}
+	completion.Usage = usage
+
+	payload, err := json.Marshal(completion)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", payload)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeError 写入OpenAI格式的错误响应
+func writeError(c *gin.Context, status int, errType string, code string, message string) {
+	c.JSON(status, ErrorResponse{Error: ErrorBody{
+		Message: message,
+		Type:    errType,
+		Code:    code,
+	}})
+}
@@ -0,0 +1,161 @@
+package openai
+
+import (
+	"encoding/json"
+	"strings"
+
+	"dootask-ai/go-service/generation"
+)
+
+// ChatCompletionRequest OpenAI兼容对话请求（model 为智能体）
+type ChatCompletionRequest struct {
+	Model         string         `json:"model" validate:"required"`
+	Messages      []ChatMessage  `json:"messages" validate:"required,min=1,dive"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *StreamOptions `json:"stream_options"`
+	Temperature   *float64       `json:"temperature" validate:"omitempty,min=0,max=2"`
+	TopP          *float64       `json:"top_p" validate:"omitempty,min=0,max=1"`
+	MaxTokens     *int           `json:"max_tokens" validate:"omitempty,min=1"`
+	MaxCompletion *int           `json:"max_completion_tokens" validate:"omitempty,min=1"`
+	Stop          StopSequences  `json:"stop" validate:"omitempty,max=4"`
+	Reasoning     *string        `json:"reasoning_effort" validate:"omitempty,oneof=minimal low medium high"`
+	User          string         `json:"user" validate:"omitempty,max=100"` // 终端用户标识，用于区分对话记录
+}
+
+// StopSequences 停止序列（兼容字符串和字符串数组）
+type StopSequences []string
+
+// UnmarshalJSON 实现 json.Unmarshaler
+func (s *StopSequences) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = StopSequences{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// Settings 转换为单次请求的生成参数
+func (r ChatCompletionRequest) Settings() generation.Settings {
+	settings := generation.Settings{
+		Temperature:     r.Temperature,
+		TopP:            r.TopP,
+		MaxOutputTokens: r.MaxTokens,
+		Stop:            r.Stop,
+		ReasoningEffort: r.Reasoning,
+	}
+	if r.MaxCompletion != nil {
+		settings.MaxOutputTokens = r.MaxCompletion
+	}
+	return settings
+}
+
+// StreamOptions 流式输出选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// ChatMessage 请求消息
+type ChatMessage struct {
+	Role    string          `json:"role" validate:"required,oneof=system user assistant tool developer"`
+	Content json.RawMessage `json:"content"`
+}
+
+// ContentPart 多模态消息片段
+type ContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+// Text 提取消息文本（兼容字符串和多模态数组，图片转换为Markdown）
+func (m ChatMessage) Text() string {
+	var text string
+	if json.Unmarshal(m.Content, &text) == nil {
+		return text
+	}
+
+	var parts []ContentPart
+	if json.Unmarshal(m.Content, &parts) != nil {
+		return ""
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			texts = append(texts, part.Text)
+		case "image_url":
+			if part.ImageURL.URL != "" {
+				texts = append(texts, "![]("+part.ImageURL.URL+")")
+			}
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ChatCompletion 对话响应（非流式为 chat.completion，流式为 chat.completion.chunk）
+type ChatCompletion struct {
+	ID      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   *Usage                 `json:"usage,omitempty"`
+}
+
+// ChatCompletionChoice 对话响应选项
+type ChatCompletionChoice struct {
+	Index        int                    `json:"index"`
+	Message      *ChatCompletionMessage `json:"message,omitempty"`
+	Delta        *ChatCompletionMessage `json:"delta,omitempty"`
+	FinishReason *string                `json:"finish_reason"`
+}
+
+// ChatCompletionMessage 响应消息
+type ChatCompletionMessage struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+// Usage token使用量
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ModelList 模型列表响应
+type ModelList struct {
+	Object string  `json:"object"`
+	Data   []Model `json:"data"`
+}
+
+// Model 模型（对应一个智能体）
+type Model struct {
+	ID          string `json:"id"`
+	Object      string `json:"object"`
+	Created     int64  `json:"created"`
+	OwnedBy     string `json:"owned_by"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// ErrorResponse OpenAI格式错误响应
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody 错误详情
+type ErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+}
@@ -2,14 +2,24 @@ package routes
 
 import (
 	"dootask-ai/go-service/middleware"
+	agenttemplates "dootask-ai/go-service/routes/api/agent-templates"
 	"dootask-ai/go-service/routes/api/agents"
 	aimodels "dootask-ai/go-service/routes/api/ai-models"
+	apikeys "dootask-ai/go-service/routes/api/api-keys"
+	auditlogs "dootask-ai/go-service/routes/api/audit-logs"
 	"dootask-ai/go-service/routes/api/conversations"
 	"dootask-ai/go-service/routes/api/dashboard"
+	"dootask-ai/go-service/routes/api/evaluations"
+	"dootask-ai/go-service/routes/api/experiments"
 	knowledgebases "dootask-ai/go-service/routes/api/knowledge-bases"
 	mcptools "dootask-ai/go-service/routes/api/mcp-tools"
+	"dootask-ai/go-service/routes/api/shares"
+	systemconfigs "dootask-ai/go-service/routes/api/system-configs"
 	"dootask-ai/go-service/routes/api/test"
+	usersecrets "dootask-ai/go-service/routes/api/user-secrets"
 	"dootask-ai/go-service/routes/health"
+	"dootask-ai/go-service/routes/mcpgateway"
+	"dootask-ai/go-service/routes/openai"
 	"dootask-ai/go-service/routes/service"
 
 	"github.com/gin-gonic/gin"
@@ -21,6 +31,15 @@ func RegisterRoutes(r *gin.Engine) {
 	health.RegisterRoutes(root)
 	service.RegisterRoutes(root)
 
+	// 注册MCP工具OAuth回调路由（通过授权请求的state识别用户）
+	mcptools.RegisterCallbackRoutes(root)
+
+	// 注册OpenAI兼容路由（使用个人API密钥认证）
+	openai.RegisterRoutes(root)
+
+	// 注册MCP网关路由（使用网关会话令牌认证）
+	mcpgateway.RegisterRoutes(root)
+
 	// 注册API路由（需要认证）
 	api := r.Group("/api")
 	api.Use(middleware.UserRoleMiddleware())
@@ -34,6 +53,18 @@ func RegisterRoutes(r *gin.Engine) {
 		// 导入智能体管理路由
 		agents.RegisterRoutes(api)
 
+		// 导入智能体模板路由
+		agenttemplates.RegisterRoutes(api)
+
+		// 导入智能体调试路由
+		service.RegisterAPIRoutes(api)
+
+		// 导入智能体评测路由
+		evaluations.RegisterRoutes(api)
+
+		// 导入A/B实验路由
+		experiments.RegisterRoutes(api)
+
 		// 导入知识库管理路由
 		knowledgebases.RegisterRoutes(api)
 
@@ -45,5 +76,20 @@ func RegisterRoutes(r *gin.Engine) {
 
 		// 导入仪表板路由
 		dashboard.RegisterRoutes(api)
+
+		// 导入资源共享路由
+		shares.RegisterRoutes(api)
+
+		// 导入API密钥路由
+		apikeys.RegisterRoutes(api)
+
+		// 导入用户密钥路由
+		usersecrets.RegisterRoutes(api)
+
+		// 导入操作日志路由
+		auditlogs.RegisterRoutes(api)
+
+		// 导入系统配置路由
+		systemconfigs.RegisterRoutes(api)
 	}
 }
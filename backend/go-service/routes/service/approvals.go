@@ -0,0 +1,58 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/routes/api/conversations"
+	"dootask-ai/go-service/utils"
+)
+
+// approvalRequest 工具调用审批中断（由AI服务以 custom 消息返回）
+type approvalRequest struct {
+	Tool      string `json:"tool"`
+	Arguments any    `json:"arguments"`
+}
+
+// parseApproval 解析审批中断，不是审批数据时返回nil
+func parseApproval(data map[string]any) *approvalRequest {
+	if data["type"] != "tool_approval" {
+		return nil
+	}
+	var req approvalRequest
+	payload, _ := json.Marshal(data)
+	if err := json.Unmarshal(payload, &req); err != nil || req.Tool == "" {
+		return nil
+	}
+	req.Arguments = audit.Redact(req.Arguments)
+	return &req
+}
+
+// Prompt 发送给用户的确认提示
+func (a *approvalRequest) Prompt(lang string) string {
+	args, _ := json.MarshalIndent(a.Arguments, "", "  ")
+	return utils.T(lang, utils.TranslationKeyToolApproval, a.Tool, args)
+}
+
+// Key 审批调用的唯一标识（子图和父图会重复上报同一个中断）
+func (a *approvalRequest) Key() string {
+	args, _ := json.Marshal(a.Arguments)
+	return a.Tool + ":" + string(args)
+}
+
+// recordApproval 在对话下记录线程中待审批的调用
+func recordApproval(agentID int64, conversationID int64, threadID string, req *approvalRequest) {
+	args, _ := json.Marshal(req.Arguments)
+	if err := global.DB.Create(&conversations.ToolApproval{
+		ConversationID: conversationID,
+		ThreadID:       threadID,
+		AgentID:        &agentID,
+		FunctionName:   req.Tool,
+		Arguments:      args,
+		Status:         conversations.ApprovalPending,
+	}).Error; err != nil {
+		log.Printf("记录工具调用审批失败: %v", err)
+	}
+}
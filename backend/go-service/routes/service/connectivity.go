@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/generation"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/routes/api/agents"
+	aimodels "dootask-ai/go-service/routes/api/ai-models"
+
+	"github.com/duke-git/lancet/v2/random"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// modelTestTimeout 连通性测试超时时间
+	modelTestTimeout = 60 * time.Second
+	// modelTestPrompt 连通性测试使用的最小请求
+	modelTestPrompt  = "ping"
+	modelTestSystem  = "You are a connectivity check. Reply with the single word: pong"
+	modelTestMaxEcho = 200
+	// modelTestMaxTokens 连通性测试的最大输出token数，避免测试消耗过多额度
+	modelTestMaxTokens = 16
+)
+
+// 连通性测试错误类型
+const (
+	TestErrorAuth           = "auth"            // 密钥无效
+	TestErrorRateLimit      = "rate_limit"      // 触发限流或额度不足
+	TestErrorModelNotFound  = "model_not_found" // 模型名称错误
+	TestErrorNetwork        = "network"         // 地址、代理或DNS不可达
+	TestErrorTimeout        = "timeout"         // 请求超时
+	TestErrorInvalidRequest = "invalid_request" // 参数不被服务商接受
+	TestErrorAIService      = "ai_service"      // AI服务不可用
+	TestErrorEmptyResponse  = "empty_response"  // 没有返回任何内容
+	TestErrorUnknown        = "unknown"
+)
+
+var testErrorPatterns = []struct {
+	category string
+	pattern  *regexp.Regexp
+}{
+	{TestErrorAuth, unauthorizedPattern},
+	{TestErrorRateLimit, rateLimitPattern},
+	{TestErrorModelNotFound, regexp.MustCompile(`(?i)\b404\b|model[_ ]not[_ ]found|does not exist|unknown model|no such model`)},
+	{TestErrorTimeout, regexp.MustCompile(`(?i)time[d]?[ _-]?out|deadline exceeded`)},
+	{TestErrorNetwork, regexp.MustCompile(`(?i)connection|connect|proxy|dns|name resolution|no route|unreachable|ssl|certificate|eof`)},
+	{TestErrorInvalidRequest, regexp.MustCompile(`(?i)\b400\b|\b422\b|invalid|bad request|unsupported`)},
+}
+
+// ModelTestRequest 保存前测试AI模型配置的请求
+type ModelTestRequest struct {
+	Provider   string  `json:"provider" validate:"required"`
+	ModelName  string  `json:"model_name" validate:"required"`
+	ApiKey     *string `json:"api_key"`
+	BaseURL    string  `json:"base_url" validate:"omitempty,url"`
+	ProxyURL   *string `json:"proxy_url"`
+	IsThinking bool    `json:"is_thinking"`
+	AIModelID  *int64  `json:"ai_model_id"` // 编辑已有模型时未修改密钥，沿用该模型保存的密钥
+}
+
+// ModelTestResult 连通性测试结果
+type ModelTestResult struct {
+	Success       bool   `json:"success"`
+	LatencyMs     int64  `json:"latency_ms"`               // 总耗时
+	FirstTokenMs  *int64 `json:"first_token_ms,omitempty"` // 首个token耗时
+	ModelID       string `json:"model_id,omitempty"`       // 服务商返回的模型ID
+	Streaming     bool   `json:"streaming"`                // 是否收到流式token
+	Thinking      bool   `json:"thinking"`                 // 是否输出思考内容
+	Reply         string `json:"reply,omitempty"`          // 模型回复（截断）
+	InputTokens   int    `json:"input_tokens"`
+	OutputTokens  int    `json:"output_tokens"`
+	ErrorCategory string `json:"error_category,omitempty"` // 错误类型
+	Error         string `json:"error,omitempty"`          // 原始错误信息
+}
+
+// TestAIModel 测试已保存AI模型的连通性（配置了密钥池时按负载选择密钥）
+func (h *Handler) TestAIModel(c *gin.Context) {
+	var aiModel aimodels.AIModel
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleEditor)).First(&aiModel, c.Param("id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnprocessableEntity, aimodels.ErrorResponse{
+				Success: false,
+				Error:   "AI模型不存在",
+				Code:    "AI_MODEL_002",
+			})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, aimodels.ErrorResponse{
+				Success: false,
+				Error:   "查询AI模型失败",
+				Code:    "AI_MODEL_001",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    testModel(aiModel),
+	})
+}
+
+// TestAIModelConfig 保存前测试AI模型配置
+func (h *Handler) TestAIModelConfig(c *gin.Context) {
+	var req ModelTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, aimodels.ErrorResponse{
+			Success: false,
+			Error:   "请求数据格式错误",
+			Code:    "FORMAT_001",
+		})
+		return
+	}
+	if err := global.Validator.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, aimodels.ErrorResponse{
+			Success: false,
+			Error:   "数据验证失败: " + err.Error(),
+			Code:    "VALIDATION_001",
+		})
+		return
+	}
+
+	aiModel := aimodels.AIModel{
+		Provider:    req.Provider,
+		ModelName:   req.ModelName,
+		ApiKey:      req.ApiKey,
+		BaseURL:     req.BaseURL,
+		ProxyURL:    req.ProxyURL,
+		Temperature: 0,
+		IsThinking:  req.IsThinking,
+	}
+	if (req.ApiKey == nil || *req.ApiKey == "") && req.AIModelID != nil {
+		var saved aimodels.AIModel
+		if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleEditor)).First(&saved, *req.AIModelID).Error; err != nil {
+			c.JSON(http.StatusUnprocessableEntity, aimodels.ErrorResponse{
+				Success: false,
+				Error:   "AI模型不存在",
+				Code:    "AI_MODEL_002",
+			})
+			return
+		}
+		aiModel.ApiKey = saved.ApiKey
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    testModel(aiModel),
+	})
+}
+
+// testModel 通过AI服务向服务商发送最小请求，统计耗时并检查流式和思考输出
+func testModel(aiModel aimodels.AIModel) ModelTestResult {
+	var result ModelTestResult
+	agent := agents.Agent{Prompt: modelTestSystem}
+	maxTokens := modelTestMaxTokens
+	opts := AIRequestOptions{
+		Message:  modelTestPrompt,
+		ThreadID: "model_test_" + random.RandString(12),
+		Settings: &generation.Settings{MaxOutputTokens: &maxTokens},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), modelTestTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	resp, err := RequestAI(aiModel, agent, opts)
+	if err != nil {
+		result.LatencyMs = time.Since(startTime).Milliseconds()
+		result.ErrorCategory = TestErrorAIService
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		result.LatencyMs = time.Since(startTime).Milliseconds()
+		result.ErrorCategory = TestErrorAIService
+		result.Error = "AI服务返回状态码 " + strconv.Itoa(resp.StatusCode)
+		return result
+	}
+
+	var (
+		reply    strings.Builder
+		errorMsg string
+	)
+	readErr := ReadStreamLines(ctx, resp.Body, func(v StreamLineData, line string) bool {
+		switch v.Type {
+		case "token", "thinking":
+			if result.FirstTokenMs == nil {
+				elapsed := time.Since(startTime).Milliseconds()
+				result.FirstTokenMs = &elapsed
+			}
+			if v.Type == "thinking" {
+				result.Thinking = true
+			} else {
+				result.Streaming = true
+				reply.WriteString(fmt.Sprintf("%v", v.Content))
+			}
+		case "message":
+			msg, err := ParseStreamMessage(v)
+			if err != nil || msg.Type != "ai" {
+				return true
+			}
+			result.InputTokens += msg.UsageMetadata.InputTokens
+			result.OutputTokens += msg.UsageMetadata.OutputTokens
+			if reply.Len() == 0 {
+				reply.WriteString(msg.Content)
+			}
+			if result.ModelID == "" {
+				result.ModelID = responseModelID(v.Content)
+			}
+		case "error":
+			errorMsg = fmt.Sprintf("%v", v.Content)
+			return false
+		}
+		return true
+	})
+	result.LatencyMs = time.Since(startTime).Milliseconds()
+	result.Reply = truncateRunes(reply.String(), modelTestMaxEcho)
+
+	switch {
+	case errorMsg != "":
+		result.ErrorCategory = classifyTestError(errorMsg)
+		result.Error = errorMsg
+	case readErr != nil:
+		result.ErrorCategory = classifyTestError(readErr.Error())
+		result.Error = readErr.Error()
+	case result.Reply == "" && !result.Thinking:
+		result.ErrorCategory = TestErrorEmptyResponse
+		result.Error = "模型没有返回内容"
+	default:
+		result.Success = true
+	}
+	return result
+}
+
+// responseModelID 从消息的 response_metadata 中读取服务商返回的模型ID（不同服务商字段名不同）
+func responseModelID(content any) string {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return ""
+	}
+	var msg struct {
+		ResponseMetadata map[string]any `json:"response_metadata"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return ""
+	}
+	for _, key := range []string{"model_name", "model", "model_id"} {
+		if value, ok := msg.ResponseMetadata[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// classifyTestError 将错误信息归类
+func classifyTestError(message string) string {
+	if strings.Contains(message, context.DeadlineExceeded.Error()) {
+		return TestErrorTimeout
+	}
+	for _, item := range testErrorPatterns {
+		if item.pattern.MatchString(message) {
+			return item.category
+		}
+	}
+	return TestErrorUnknown
+}
+
+// truncateRunes 按字符截断
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	aimodels "dootask-ai/go-service/routes/api/ai-models"
+)
+
+var (
+	unauthorizedPattern = regexp.MustCompile(`(?i)\b401\b|unauthorized|invalid[ _-]?api[ _-]?key|incorrect api key|authentication[ _]error`)
+	rateLimitPattern    = regexp.MustCompile(`(?i)\b429\b|rate[ _-]?limit|too many requests|quota`)
+)
+
+// keyTrackingBody 包装AI服务的流式响应，统计所选密钥的令牌用量并在服务商返回401/429时停用密钥
+type keyTrackingBody struct {
+	io.ReadCloser
+	keyID   int64
+	pending []byte
+	tokens  int
+	benched bool
+}
+
+// trackKey 为使用密钥池的请求包装响应体（服务商错误由AI服务以error消息返回）
+func trackKey(resp *http.Response, key *aimodels.AIModelKey) {
+	if key == nil {
+		return
+	}
+	resp.Body = &keyTrackingBody{ReadCloser: resp.Body, keyID: key.ID}
+}
+
+func (b *keyTrackingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.pending = append(b.pending, p[:n]...)
+		for {
+			idx := bytes.IndexByte(b.pending, '\n')
+			if idx < 0 {
+				break
+			}
+			b.observe(string(b.pending[:idx]))
+			b.pending = b.pending[idx+1:]
+		}
+	}
+	return n, err
+}
+
+func (b *keyTrackingBody) Close() error {
+	if len(b.pending) > 0 {
+		b.observe(string(b.pending))
+		b.pending = nil
+	}
+	aimodels.RecordKeyTokens(b.keyID, b.tokens)
+	return b.ReadCloser.Close()
+}
+
+// observe 解析单行流式数据
+func (b *keyTrackingBody) observe(line string) {
+	if after, ok := strings.CutPrefix(line, "data:"); ok {
+		line = after
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || line == "[DONE]" {
+		return
+	}
+
+	var v StreamLineData
+	if err := json.Unmarshal([]byte(line), &v); err != nil {
+		return
+	}
+	switch v.Type {
+	case "message":
+		if data, err := ParseStreamMessage(v); err == nil {
+			b.tokens += data.UsageMetadata.InputTokens + data.UsageMetadata.OutputTokens
+		}
+	case "error":
+		if b.benched {
+			return
+		}
+		content := fmt.Sprintf("%v", v.Content)
+		switch {
+		case unauthorizedPattern.MatchString(content):
+			aimodels.BenchKey(b.keyID, http.StatusUnauthorized, content)
+			b.benched = true
+		case rateLimitPattern.MatchString(content):
+			aimodels.BenchKey(b.keyID, http.StatusTooManyRequests, content)
+			b.benched = true
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"dootask-ai/go-service/global"
 	"dootask-ai/go-service/routes/api/agents"
 	"dootask-ai/go-service/routes/api/conversations"
+	"dootask-ai/go-service/routes/api/experiments"
 	"dootask-ai/go-service/utils"
 	"encoding/json"
 	"fmt"
@@ -23,8 +24,9 @@ import (
 
 // MessageHandler 消息处理器
 type MessageHandler struct {
-	db     *gorm.DB
-	client *dootask.Client
+	db         *gorm.DB
+	client     *dootask.Client
+	experiment *experiments.Selection // 命中的A/B实验变体
 }
 
 // NewMessageHandler 创建消息处理器
@@ -241,13 +243,20 @@ func (h *MessageHandler) createMessage(createMessage CreateMessage) {
 	if createMessage.McpUsed != nil {
 		message.McpUsed = *createMessage.McpUsed
 	}
+	userUpdates := map[string]interface{}{
+		"tokens_used": createMessage.InputTokens,
+	}
+	// 记录A/B实验变体，用于按变体统计效果
+	if h.experiment != nil {
+		metadata, _ := json.Marshal(h.experiment.Metadata())
+		message.Metadata = metadata
+		userUpdates["metadata"] = gorm.Expr("COALESCE(metadata, '{}'::jsonb) || ?::jsonb", string(metadata))
+	}
 	h.db.Create(&message)
 	// 更新用户提问消息的token使用量
 	h.db.Model(&conversations.Message{}).
 		Where("conversation_id = ? AND role = ? AND send_id = ?", conversation.ID, "user", createMessage.Req.SendId).
-		Updates(map[string]interface{}{
-			"tokens_used": createMessage.InputTokens,
-		})
+		Updates(userUpdates)
 }
 
 // logError 统一错误日志格式
@@ -271,10 +280,15 @@ func (h *MessageHandler) writeAIResponseToRedis(ctx context.Context, body io.Rea
 		global.Redis.Expire(context.Background(), redisKey, 10*time.Minute)
 	}()
 
-	reader := bufio.NewReader(body)
-
 	var tokenBuffer []string
 	var currentMessageType string = "token" // 默认消息类型
+	recorder := NewToolCallRecorder()
+	approvals := map[string]bool{}
+	// 获取用户语言，默认为 "zh"
+	userLang := req.MsgUser.Lang
+	if userLang == "" {
+		userLang = "zh"
+	}
 	lastCompressTime := time.Now()
 	// 获取流间隔时间
 	streamInterval, _ := strconv.Atoi(utils.GetEnvWithDefault("AI_STREAM_INTERVAL", "100"))
@@ -298,23 +312,137 @@ func (h *MessageHandler) writeAIResponseToRedis(ctx context.Context, body io.Rea
 		}
 	}
 
+	err := ReadStreamLines(ctx, body, func(v StreamLineData, line string) bool {
+		if v.Type == "token" || v.Type == "thinking" {
+			if content, ok := v.Content.(string); ok {
+				currentMessageType = v.Type // 更新当前消息类型
+				tokenBuffer = append(tokenBuffer, content)
+				if time.Since(lastCompressTime) >= compressInterval {
+					compressAndWrite(v.Type)
+					lastCompressTime = time.Now()
+				}
+			}
+			return true
+		}
+		if v.Type == "message" {
+			if toolData, err := ParseStreamMessage(v); err == nil {
+				recorder.Observe(toolData)
+				// 工具调用需要用户确认时，在回复中提示用户并记录待审批调用
+				if approval := parseApproval(toolData.CustomData); toolData.Type == "custom" && approval != nil {
+					if !approvals[approval.Key()] {
+						approvals[approval.Key()] = true
+						h.requestApproval(req, approval)
+						compressAndWrite(currentMessageType)
+						currentMessageType = "token"
+						tokenBuffer = append(tokenBuffer, approval.Prompt(userLang))
+					}
+					return true
+				}
+				// 工具结果和自定义数据（如RAG检索结果）不发送给机器人
+				if toolData.Type == "tool" || toolData.Type == "custom" {
+					return true
+				}
+				if toolData.Type == "ai" && len(toolData.ToolCalls) > 0 {
+					currentMessageType = "tool"
+					mcpUsed := []string{}
+					for _, toolCall := range toolData.ToolCalls {
+						content := utils.T(userLang, utils.TranslationKeyMcpToolCallWithName, toolCall.Name)
+						tokenBuffer = append(tokenBuffer, content)
+						if time.Since(lastCompressTime) >= compressInterval {
+							compressAndWrite("tool")
+							lastCompressTime = time.Now()
+						}
+						mcpUsed = append(mcpUsed, toolCall.Name)
+					}
+					mcpUsedJson, _ := json.Marshal(mcpUsed)
+					h.createMessage(CreateMessage{
+						Req:          req,
+						Content:      utils.T(userLang, utils.TranslationKeyMcpToolCall),
+						StartTime:    startTime,
+						Status:       1,
+						InputTokens:  toolData.UsageMetadata.InputTokens,
+						OutputTokens: toolData.UsageMetadata.OutputTokens,
+						McpUsed:      (*json.RawMessage)(&mcpUsedJson),
+					})
+					return true
+				}
+			}
+		}
+		// 在写入非 token/thinking 的消息前，先刷新已缓冲的 token，保证顺序正确
+		compressAndWrite(currentMessageType)
+		key := fmt.Sprintf("stream_message:%s", req.StreamId)
+		channel := fmt.Sprintf("stream_message_pub:%s", req.StreamId)
+		global.Redis.LPush(context.Background(), key, line)
+		global.Redis.Publish(context.Background(), channel, line)
+		return true
+	})
+	compressAndWrite(currentMessageType)
+	if err != nil {
+		if ctx.Err() != nil {
+			logError("AI响应读取超时", nil, "stream_id:", req.StreamId)
+		} else {
+			logError("读取数据失败", err)
+		}
+	}
+	h.saveToolCalls(recorder, req)
+}
+
+// saveToolCalls 保存本次回复中的工具调用记录
+func (h *MessageHandler) saveToolCalls(recorder *ToolCallRecorder, req WebhookRequest) {
+	if len(recorder.calls) == 0 {
+		return
+	}
+	agent, conversation, err := h.findConversation(req)
+	if agent == nil {
+		logError("查询智能体失败", err, "bot_id:", fmt.Sprintf("%d", req.BotUid))
+		return
+	}
+	var conversationID *int64
+	if conversation != nil {
+		conversationID = &conversation.ID
+	}
+	recorder.Save(*agent, conversationID)
+}
+
+// requestApproval 记录等待用户确认的工具调用
+func (h *MessageHandler) requestApproval(req WebhookRequest, approval *approvalRequest) {
+	agent, conversation, err := h.findConversation(req)
+	if conversation == nil {
+		logError("查询对话失败", err, "dialog_id:", fmt.Sprintf("%d", req.DialogId), "user_id:", fmt.Sprintf("%d", req.MsgUid))
+		return
+	}
+	recordApproval(agent.ID, conversation.ID, webhookThreadID(req), approval)
+}
+
+// findConversation 查询消息对应的智能体和对话
+func (h *MessageHandler) findConversation(req WebhookRequest) (*agents.Agent, *conversations.Conversation, error) {
+	var agent agents.Agent
+	if err := h.db.Where("bot_id = ?", req.BotUid).First(&agent).Error; err != nil {
+		return nil, nil, err
+	}
+	var conversation conversations.Conversation
+	if err := h.db.Where("agent_id = ? AND dootask_user_id = ? AND dootask_chat_id = ?", agent.ID, strconv.Itoa(int(req.MsgUid)), strconv.Itoa(int(req.DialogId))).First(&conversation).Error; err != nil {
+		return &agent, nil, err
+	}
+	return &agent, &conversation, nil
+}
+
+// ReadStreamLines 逐行解析AI服务的流式响应，遇到[DONE]或回调返回false时结束
+func ReadStreamLines(ctx context.Context, body io.Reader, handle func(v StreamLineData, line string) bool) error {
+	reader := bufio.NewReader(body)
 	for {
 		select {
 		case <-ctx.Done():
-			compressAndWrite(currentMessageType)
-			logError("AI响应读取超时", nil, "stream_id:", req.StreamId)
-			return
+			return ctx.Err()
 		default:
 		}
 
 		line, err := reader.ReadString('\n')
 		if err != nil {
-			if err.Error() == "EOF" {
-				compressAndWrite(currentMessageType)
-				break
+			if err == io.EOF {
+				return nil
 			}
-			logError("读取数据失败", err)
-			break
+			return err
 		}
 
 		if after, ok := strings.CutPrefix(line, "data:"); ok {
@@ -322,8 +450,7 @@ func (h *MessageHandler) writeAIResponseToRedis(ctx context.Context, body io.Rea
 		}
 
 		if line == "[DONE]" {
-			compressAndWrite(currentMessageType)
-			break
+			return nil
 		}
 
 		var v StreamLineData
@@ -335,68 +462,25 @@ func (h *MessageHandler) writeAIResponseToRedis(ctx context.Context, body io.Rea
 			continue
 		}
 
-		if v.Type == "token" || v.Type == "thinking" {
-			if content, ok := v.Content.(string); ok {
-				currentMessageType = v.Type // 更新当前消息类型
-				tokenBuffer = append(tokenBuffer, content)
-				if time.Since(lastCompressTime) >= compressInterval {
-					compressAndWrite(v.Type)
-					lastCompressTime = time.Now()
-				}
-			}
-		} else {
-			if v.Type == "message" {
-				contentJson, _ := json.Marshal(v.Content)
-				var toolData StreamToolData
-				if err := json.Unmarshal(contentJson, &toolData); err == nil {
-					if toolData.Type == "tool" {
-						continue
-					}
-					if toolData.Type == "ai" {
-						if len(toolData.ToolCalls) > 0 {
-							currentMessageType = "tool"
-							mcpUsed := []string{}
-							// 获取用户语言，默认为 "en-US"
-							userLang := req.MsgUser.Lang
-							if userLang == "" {
-								userLang = "zh"
-							}
-							for _, toolCall := range toolData.ToolCalls {
-								content := utils.T(userLang, utils.TranslationKeyMcpToolCallWithName, toolCall.Name)
-								tokenBuffer = append(tokenBuffer, content)
-								if time.Since(lastCompressTime) >= compressInterval {
-									v.Type = "tool"
-									v.Content = content
-									compressAndWrite(v.Type)
-									lastCompressTime = time.Now()
-								}
-								mcpUsed = append(mcpUsed, toolCall.Name)
-							}
-							mcpUsedJson, _ := json.Marshal(mcpUsed)
-							h.createMessage(CreateMessage{
-								Req:          req,
-								Content:      utils.T(userLang, utils.TranslationKeyMcpToolCall),
-								StartTime:    startTime,
-								Status:       1,
-								InputTokens:  toolData.UsageMetadata.InputTokens,
-								OutputTokens: toolData.UsageMetadata.OutputTokens,
-								McpUsed:      (*json.RawMessage)(&mcpUsedJson),
-							})
-							continue
-						}
-					}
-				}
-			}
-			// 在写入非 token/thinking 的消息前，先刷新已缓冲的 token，保证顺序正确
-			compressAndWrite(currentMessageType)
-			key := fmt.Sprintf("stream_message:%s", req.StreamId)
-			channel := fmt.Sprintf("stream_message_pub:%s", req.StreamId)
-			global.Redis.LPush(context.Background(), key, line)
-			global.Redis.Publish(context.Background(), channel, line)
+		if !handle(v, line) {
+			return nil
 		}
 	}
 }
 
+// ParseStreamMessage 解析message类型的流式数据（ai、tool、custom消息）
+func ParseStreamMessage(v StreamLineData) (*StreamToolData, error) {
+	contentJson, err := json.Marshal(v.Content)
+	if err != nil {
+		return nil, err
+	}
+	var toolData StreamToolData
+	if err := json.Unmarshal(contentJson, &toolData); err != nil {
+		return nil, err
+	}
+	return &toolData, nil
+}
+
 // processHTMLContent 处理可能包含HTML的内容，转换为Markdown
 func (h *MessageHandler) processHTMLContent(content string) string {
 	// 检查内容是否包含HTML标签
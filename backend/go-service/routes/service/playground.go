@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"dootask-ai/go-service/generation"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/permission"
+	"dootask-ai/go-service/routes/api/agents"
+	aimodels "dootask-ai/go-service/routes/api/ai-models"
+	"dootask-ai/go-service/routes/api/conversations"
+
+	"github.com/duke-git/lancet/v2/random"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// RegisterAPIRoutes 注册需要认证的服务路由
+func RegisterAPIRoutes(r *gin.RouterGroup) {
+	handler := &Handler{}
+	r.POST("/agents/:id/chat", handler.Playground)       // 智能体调试对话（无需DooTask机器人）
+	r.POST("/ai-models/test", handler.TestAIModelConfig) // 保存前测试AI模型配置
+	r.POST("/ai-models/:id/test", handler.TestAIModel)   // 测试AI模型连通性
+}
+
+// PlaygroundRequest 智能体调试对话请求
+type PlaygroundRequest struct {
+	Message     string   `json:"message" validate:"required"`
+	Prompt      *string  `json:"prompt"`                                       // 临时提示词（不保存）
+	AIModelID   *int64   `json:"ai_model_id"`                                  // 临时AI模型（不保存）
+	Temperature *float64 `json:"temperature" validate:"omitempty,min=0,max=2"` // 临时温度（不保存）
+	ThreadID    string   `json:"thread_id" validate:"omitempty,max=100"`       // 多轮对话线程ID，为空时新建
+	Persist     bool     `json:"persist"`                                      // 是否保存到对话记录，默认不保存
+
+	GenerationSettings *generation.Settings `json:"generation_settings"` // 临时生成参数（不保存）
+}
+
+// Playground 智能体调试对话，以SSE返回token、工具调用和RAG检索过程
+func (h *Handler) Playground(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "无效的智能体ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req PlaygroundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    "VALIDATION_001",
+			"message": "请求数据格式错误",
+			"data":    err.Error(),
+		})
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "VALIDATION_002",
+			"message": "数据验证失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+
+	// 检查智能体是否存在
+	var agent agents.Agent
+	if err := global.DB.Scopes(permission.Scope(c, permission.ResourceAgent, permission.RoleViewer)).Where("id = ?", id).First(&agent).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    "AGENT_002",
+				"message": "智能体不存在",
+				"data":    nil,
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_001",
+				"message": "查询智能体失败",
+				"data":    nil,
+			})
+		}
+		return
+	}
+
+	// 检查AI模型（临时模型需要有使用权限）
+	modelQuery := global.DB.Where("id = ?", agent.AIModelID)
+	if req.AIModelID != nil {
+		modelQuery = global.DB.Scopes(permission.Scope(c, permission.ResourceAIModel, permission.RoleViewer)).Where("id = ?", *req.AIModelID)
+	}
+	var aiModel aimodels.AIModel
+	if err := modelQuery.First(&aiModel).Error; err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "AI_MODEL_001",
+			"message": "AI模型不存在",
+			"data":    nil,
+		})
+		return
+	}
+	if aiModel.IsEnabled == nil || !*aiModel.IsEnabled {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"code":    "AI_MODEL_001",
+			"message": "AI模型未启用",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 应用临时覆盖配置
+	if req.Prompt != nil {
+		agent.Prompt = *req.Prompt
+	}
+	settings := generation.Settings{}
+	if req.GenerationSettings != nil {
+		settings = *req.GenerationSettings
+	}
+	if req.Temperature != nil {
+		settings.Temperature = req.Temperature
+	}
+	if req.ThreadID == "" {
+		req.ThreadID = random.RandString(12)
+	}
+
+	// 客户端传入的线程ID只作为后缀，按智能体和用户加上命名空间，避免读取他人的对话状态
+	user := global.GetDooTaskUser(c)
+	threadID := fmt.Sprintf("playground_%d_%d_%s", agent.ID, user.UserID, req.ThreadID)
+	opts := AIRequestOptions{
+		Message:  req.Message,
+		ThreadID: threadID,
+		UserID:   int64(user.UserID),
+		BaseURL:  c.GetString("host"),
+		Settings: &settings,
+	}
+	if client := global.GetDooTaskClient(c); client != nil {
+		opts.UserToken = client.Token
+	}
+
+	// 按需保存对话记录
+	var conversation *conversations.Conversation
+	if req.Persist {
+		conversation, err = playgroundConversation(agent.ID, int64(user.UserID), req.ThreadID, req.Message)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    "DATABASE_002",
+				"message": "保存对话失败",
+				"data":    nil,
+			})
+			return
+		}
+		// 有待确认的工具调用时，本条消息作为审批结果
+		opts.Message = conversations.ResolveApprovals(conversation.ID, threadID, strconv.Itoa(user.UserID), req.Message)
+		opts.ConversationID = conversation.ID
+	}
+
+	startTime := time.Now()
+	resp, err := RequestAI(aiModel, agent, opts)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"code":    "AI_SERVICE_001",
+			"message": "请求AI服务失败",
+			"data":    err.Error(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	// 设置响应头
+	c.Header("Content-Type", "text/event-stream; charset=utf-8")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	writePlaygroundEvent(w, "start", gin.H{"thread_id": req.ThreadID, "model": aiModel.ModelName})
+
+	var (
+		answer       string
+		status       = 1
+		inputTokens  int
+		outputTokens int
+		toolsUsed    = []string{}
+		recorder     = NewToolCallRecorder()
+		approvals    = map[string]bool{}
+	)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), StreamTimeout)
+	defer cancel()
+
+	readErr := ReadStreamLines(ctx, resp.Body, func(v StreamLineData, _ string) bool {
+		switch v.Type {
+		case "token", "thinking":
+			writePlaygroundEvent(w, v.Type, gin.H{"content": v.Content})
+		case "error":
+			status = 0
+			writePlaygroundEvent(w, "error", gin.H{"content": v.Content})
+		case "message":
+			msg, err := ParseStreamMessage(v)
+			if err != nil {
+				return true
+			}
+			inputTokens += msg.UsageMetadata.InputTokens
+			outputTokens += msg.UsageMetadata.OutputTokens
+			recorder.Observe(msg)
+			switch msg.Type {
+			case "ai":
+				if len(msg.ToolCalls) > 0 {
+					for _, toolCall := range msg.ToolCalls {
+						toolsUsed = append(toolsUsed, toolCall.Name)
+						writePlaygroundEvent(w, "tool_call", toolCall)
+					}
+					return true
+				}
+				answer = msg.Content
+				writePlaygroundEvent(w, "message", gin.H{"content": msg.Content, "usage_metadata": msg.UsageMetadata})
+			case "tool":
+				writePlaygroundEvent(w, "tool_result", gin.H{"tool_call_id": msg.ToolCallID, "content": msg.Content})
+			case "custom":
+				if msg.CustomData["type"] == "rag_retrieval" {
+					writePlaygroundEvent(w, "rag", msg.CustomData)
+				}
+				if approval := parseApproval(msg.CustomData); approval != nil && !approvals[approval.Key()] {
+					approvals[approval.Key()] = true
+					if conversation != nil {
+						recordApproval(agent.ID, conversation.ID, threadID, approval)
+					}
+					writePlaygroundEvent(w, "approval", approval)
+				}
+			}
+		}
+		return true
+	})
+	if readErr != nil {
+		status = 0
+		writePlaygroundEvent(w, "error", gin.H{"content": readErr.Error()})
+	}
+
+	responseTimeMs := int(time.Since(startTime).Milliseconds())
+	var conversationID *int64
+	if conversation != nil {
+		conversationID = &conversation.ID
+	}
+	recorder.Save(agent, conversationID)
+	if conversation != nil {
+		mcpUsed, _ := json.Marshal(toolsUsed)
+		global.DB.Create(&conversations.Message{
+			ConversationID: conversation.ID,
+			Role:           "assistant",
+			Content:        answer,
+			TokensUsed:     outputTokens,
+			ModelUsed:      &aiModel.ModelName,
+			McpUsed:        mcpUsed,
+			ResponseTimeMs: &responseTimeMs,
+			Status:         status,
+		})
+	}
+
+	writePlaygroundEvent(w, "done", gin.H{
+		"thread_id":        req.ThreadID,
+		"response_time_ms": responseTimeMs,
+		"input_tokens":     inputTokens,
+		"output_tokens":    outputTokens,
+		"tools_used":       toolsUsed,
+	})
+}
+
+// playgroundConversation 获取或创建调试对话，并写入用户消息
+func playgroundConversation(agentID int64, userID int64, threadID string, message string) (*conversations.Conversation, error) {
+	chatID := "playground:" + threadID
+	conversation := conversations.Conversation{
+		AgentID:       agentID,
+		DootaskChatID: chatID,
+		DootaskUserID: strconv.FormatInt(userID, 10),
+		IsActive:      true,
+	}
+	if err := global.DB.
+		Where("agent_id = ? AND dootask_chat_id = ? AND dootask_user_id = ?", agentID, chatID, conversation.DootaskUserID).
+		FirstOrCreate(&conversation).Error; err != nil {
+		return nil, err
+	}
+	if err := global.DB.Create(&conversations.Message{
+		ConversationID: conversation.ID,
+		Role:           "user",
+		Content:        message,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// writePlaygroundEvent 写入一条SSE事件
+func writePlaygroundEvent(w io.Writer, event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -2,12 +2,18 @@ package service
 
 import (
 	"context"
+	"dootask-ai/go-service/generation"
 	"dootask-ai/go-service/global"
 	"dootask-ai/go-service/routes/api/agents"
 	aimodels "dootask-ai/go-service/routes/api/ai-models"
 	"dootask-ai/go-service/routes/api/conversations"
+	"dootask-ai/go-service/routes/api/experiments"
 	knowledgebases "dootask-ai/go-service/routes/api/knowledge-bases"
 	mcptools "dootask-ai/go-service/routes/api/mcp-tools"
+	usersecrets "dootask-ai/go-service/routes/api/user-secrets"
+	"dootask-ai/go-service/routes/mcpgateway"
+	"dootask-ai/go-service/secret"
+	"dootask-ai/go-service/sysconfig"
 	"dootask-ai/go-service/utils"
 	"encoding/json"
 	"fmt"
@@ -120,6 +126,9 @@ func (h *Handler) Webhook(c *gin.Context) {
 		return
 	}
 
+	// 是否为对密钥提示的回复（需在通知流式服务前检查，流式处理会取出等待中的提示）
+	secretReply := req.DialogType != "group" && usersecrets.HasRequest(agent.ID, strconv.FormatInt(req.DialogId, 10), req.MsgUid)
+
 	// 创建一条消息
 	var response map[string]any
 	global.DooTaskClient.Client.SendMessage(dootask.SendMessageRequest{
@@ -185,6 +194,10 @@ func (h *Handler) Webhook(c *gin.Context) {
 	if len(runes) > 200 {
 		text = string(runes[:200]) + "..."
 	}
+	if secretReply {
+		// 本条消息是对密钥提示的回复，不保存明文
+		text = secretReplyMask
+	}
 
 	message := conversations.Message{
 		ConversationID: conversation.ID,
@@ -272,9 +285,17 @@ func (h *Handler) Stream(c *gin.Context) {
 		}
 		req.Extras["base_url"] = c.GetString("host")
 
+		// 命中A/B实验时使用对应变体的配置
+		selection := experiments.Apply(&agent, &aiModel, req.MsgUid)
+
 		// 请求AI
-		resp, err := h.requestAI(aiModel, agent, req)
+		resp, prompt, err := h.requestAI(aiModel, agent, req)
 
+		if prompt != "" {
+			// 缺少工具需要的用户密钥，提示用户提供
+			writeStreamPrompt(streamId, prompt)
+			return
+		}
 		if err != nil {
 			errorMsg := fmt.Sprintf(`{"type":"error","content":"%s"}`, err.Error())
 			key := fmt.Sprintf("stream_message:%s", streamId)
@@ -291,6 +312,7 @@ func (h *Handler) Stream(c *gin.Context) {
 		global.DooTaskClient = &client
 
 		handler := NewMessageHandler(global.DB, global.DooTaskClient.Client)
+		handler.experiment = selection
 		startTime := time.Now()
 
 		// 写入AI响应到Redis
@@ -482,46 +504,128 @@ func (h *Handler) parseWebhookResponse(response map[string]any) (*WebhookRespons
 	return &webhookResponse, nil
 }
 
-// 请求AI
-func (h *Handler) requestAI(aiModel aimodels.AIModel, agent agents.Agent, req WebhookRequest) (*http.Response, error) {
+// 请求AI（需要用户先提供密钥时不请求AI服务，返回提示内容）
+func (h *Handler) requestAI(aiModel aimodels.AIModel, agent agents.Agent, req WebhookRequest) (*http.Response, string, error) {
+	threadId := webhookThreadID(req)
+
+	text, err := h.buildUserMessage(req)
+	if err != nil {
+		log.Printf("requestAI buildUserMessage error: %v", err)
+		return nil, "", err
+	}
+
+	var conversation conversations.Conversation
+	dialogID := strconv.FormatInt(req.DialogId, 10)
+	userID := strconv.FormatInt(req.MsgUid, 10)
+	conversationErr := global.DB.Where("agent_id = ? AND dootask_chat_id = ? AND dootask_user_id = ?", agent.ID, dialogID, userID).First(&conversation).Error
+
+	var pending *usersecrets.Request
+	if req.DialogType != "group" {
+		pending = usersecrets.TakeRequest(agent.ID, dialogID, req.MsgUid)
+	}
+	if pending != nil {
+		// 正在等待用户提供密钥时，本条回复作为密钥值保存，然后继续处理提示前的消息
+		if err := usersecrets.Reply(dialogID, req.MsgUid, pending, req.Text); err != nil {
+			log.Printf("保存用户密钥失败: %v", err)
+		}
+		text = pending.Message
+	} else if conversationErr == nil {
+		// 有待确认的工具调用时，本条回复作为审批结果
+		text = conversations.ResolveApprovals(conversation.ID, threadId, userID, text)
+	}
+
+	// 私聊中缺少工具需要的用户密钥或授权时提示用户；群聊中不提示（避免密钥发送给其他成员），直接跳过该工具
+	if req.DialogType != "group" {
+		if missing := findMissingSecret(agent, req.MsgUid, dialogID); missing != nil {
+			return nil, requestSecret(agent, req, dialogID, missing, text), nil
+		}
+		if prompt := requestOAuth(agent, req, dialogID, fmt.Sprintf("%v", req.Extras["base_url"])); prompt != "" {
+			return nil, prompt, nil
+		}
+	}
+
+	resp, err := RequestAI(aiModel, agent, AIRequestOptions{
+		Message:        text,
+		ThreadID:       threadId,
+		UserID:         req.MsgUid,
+		UserToken:      req.MsgUser.Token,
+		BaseURL:        fmt.Sprintf("%v", req.Extras["base_url"]),
+		DialogID:       dialogID,
+		ConversationID: conversation.ID,
+	})
+	return resp, "", err
+}
+
+// webhookThreadID 机器人消息的会话线程ID（群聊不保留线程）
+func webhookThreadID(req WebhookRequest) string {
+	if req.DialogType == "group" {
+		return ""
+	}
+	return fmt.Sprintf("%d_%d", req.DialogId, req.SessionId)
+}
+
+// RequestAI 向Python AI服务发起流式请求
+func RequestAI(aiModel aimodels.AIModel, agent agents.Agent, opts AIRequestOptions) (*http.Response, error) {
 	baseURL := utils.GetEnvWithDefault("AI_BASE_URL", fmt.Sprintf("http://localhost:%s", utils.GetEnvWithDefault("PYTHON_AI_SERVICE_PORT", "8001")))
+	// 系统配置优先，未配置时使用环境变量
 	requestTimeout, _ := strconv.Atoi(utils.GetEnvWithDefault("AI_REQUEST_TIMEOUT", "60"))
+	if timeout := sysconfig.Int(sysconfig.KeyWebhookTimeout, 0); timeout > 0 {
+		requestTimeout = timeout
+	}
 
 	httpClient := utils.NewHTTPClient(
 		baseURL,
 		utils.WithTimeout(time.Duration(requestTimeout)*time.Second),
 	)
 
+	// 配置了密钥池时按负载选择密钥
+	key := aimodels.PickKey(&aiModel)
+
+	path, data := BuildAIRequest(aiModel, agent, opts)
+	resp, err := httpClient.Stream(context.Background(), path, nil, nil, http.MethodPost, data, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	trackKey(resp, key)
+	return resp, nil
+}
+
+// BuildAIRequest 构建AI请求的路径和请求体（根据知识库和工具选择智能体类型）
+func BuildAIRequest(aiModel aimodels.AIModel, agent agents.Agent, opts AIRequestOptions) (string, map[string]any) {
+	apiKey := ""
+	if aiModel.ApiKey != nil {
+		apiKey = secret.Transport(*aiModel.ApiKey)
+	}
 	agentConfig := map[string]any{
-		"api_key":     aiModel.ApiKey,
-		"api_version": "",
+		"api_key":     apiKey,
 		"base_url":    aiModel.BaseURL,
-		"credentials": "",
 		"proxy_url":   aiModel.ProxyURL,
 		"prompt":      agent.Prompt,
 		"spicy_level": 0,
 	}
-	if !aiModel.IsThinking {
-		agentConfig["temperature"] = aiModel.Temperature
-	}
 
-	threadId := fmt.Sprintf("%d_%d", req.DialogId, req.SessionId)
-	if req.DialogType == "group" {
-		threadId = ""
+	// 生成参数按 模型默认 → 智能体 → 对话 → 单次请求 的顺序覆盖
+	layers := []generation.Settings{aiModel.Settings(), agent.Settings(), generation.ForDialog(agent.ID, opts.DialogID)}
+	if opts.Settings != nil {
+		layers = append(layers, *opts.Settings)
 	}
-
-	text, err := h.buildUserMessage(req)
-	if err != nil {
-		log.Printf("requestAI buildUserMessage error: %v", err)
-		return nil, err
+	settings := generation.Resolve(layers...)
+	if settings.MaxOutputTokens != nil && aiModel.MaxTokens > 0 && *settings.MaxOutputTokens > aiModel.MaxTokens {
+		// 不超过模型配置的最大token数
+		settings.MaxOutputTokens = &aiModel.MaxTokens
 	}
+	if limit := sysconfig.Int(sysconfig.KeyMaxTokensPerRequest, 0); limit > 0 && (settings.MaxOutputTokens == nil || *settings.MaxOutputTokens > limit) {
+		// 不超过系统配置的单次请求最大token数
+		settings.MaxOutputTokens = &limit
+	}
+	settings.Apply(agentConfig, aiModel.Provider, aiModel.IsThinking)
 
 	// 发送POST请求获取流式响应
 	data := map[string]any{
-		"message":       text,
+		"message":       opts.Message,
 		"provider":      aiModel.Provider,
 		"model":         aiModel.ModelName,
-		"thread_id":     threadId,
+		"thread_id":     opts.ThreadID,
 		"user_id":       strconv.Itoa(int(agent.UserID)),
 		"agent_config":  agentConfig,
 		"stream_tokens": true,
@@ -546,7 +650,7 @@ func (h *Handler) requestAI(aiModel aimodels.AIModel, agent agents.Agent, req We
 			path = "/rag_agent/stream"
 			for _, kb := range kbs {
 				ragConfig = append(ragConfig, map[string]any{
-					"api_key":        kb.ApiKey,
+					"api_key":        secret.Transport(kb.ApiKey),
 					"model":          kb.EmbeddingModel,
 					"provider":       kb.Provider,
 					"proxy_url":      kb.ProxyURL,
@@ -558,55 +662,86 @@ func (h *Handler) requestAI(aiModel aimodels.AIModel, agent agents.Agent, req We
 	}
 	var dootaskMcp []mcptools.MCPTool
 	var userConfig []agents.UserConfig
+	var gatewayTools []mcptools.MCPTool
 
-	global.DB.Where("user_id = ? AND is_active = ? AND category = ?", 0, true, "dootask").Find(&dootaskMcp)
-	if req.MsgUid != 0 {
-		global.DB.Where("user_id = ? AND key = ? AND value = ?", req.MsgUid, "autoAssignMCP", "1").Find(&userConfig)
+	// 跳过健康检查失败的MCP服务，避免一个不可用的服务导致整个请求失败
+	global.DB.Scopes(mcptools.HealthyScope).Where("user_id = ? AND is_active = ? AND category = ?", 0, true, "dootask").Find(&dootaskMcp)
+	if opts.UserID != 0 {
+		global.DB.Where("user_id = ? AND key = ? AND value = ?", opts.UserID, "autoAssignMCP", "1").Find(&userConfig)
 	}
 	// 检查是否使用MCP
 	if agent.Tools != nil {
 		var mcpTools []mcptools.MCPTool
 		var mcpToolIds []int64
 		json.Unmarshal([]byte(agent.Tools), &mcpToolIds)
-		global.DB.Where("id in (?) AND is_active = ?", mcpToolIds, true).Find(&mcpTools)
-		if len(mcpTools) > 0 {
+		global.DB.Scopes(mcptools.HealthyScope).Where("id in (?) AND is_active = ?", mcpToolIds, true).Find(&mcpTools)
+		for _, mcpTool := range mcpTools {
+			var config map[string]any
+			json.Unmarshal(mcpTool.Config, &config)
+			// 按请求用户填充配置中的密钥，缺少密钥的工具本次不可用
+			if missing := usersecrets.ResolveMap(opts.UserID, mcpTool.UserID, config); len(missing) > 0 {
+				log.Printf("MCP工具 %s 缺少用户 %d 的密钥 %v，已跳过", mcpTool.McpName, opts.UserID, missing)
+				continue
+			}
+			// 启用OAuth的工具使用请求用户的访问令牌，未授权时本次不可用
+			if err := mcptools.ApplyOAuthMap(mcpTool, opts.UserID, config); err != nil {
+				log.Printf("MCP工具 %s 用户 %d 的OAuth令牌不可用，已跳过: %v", mcpTool.McpName, opts.UserID, err)
+				continue
+			}
 			isUseTool = true
 			path = "/mcp_agent/stream"
-			for _, mcpTool := range mcpTools {
-				var config map[string]any
-				json.Unmarshal(mcpTool.Config, &config)
-				transport := ""
-				switch mcpTool.ConfigType {
-				case 0:
-					transport = "streamable_http"
-				case 1:
-					transport = "websocket"
-				case 2:
-					transport = "sse"
-				case 3:
-					transport = "stdio"
-				default:
-					transport = "streamable_http"
-				}
-				config["transport"] = transport
-				mcpConfig[mcpTool.McpName] = config
+			transport := ""
+			switch mcpTool.ConfigType {
+			case 0:
+				transport = "streamable_http"
+			case 1:
+				transport = "websocket"
+			case 2:
+				transport = "sse"
+			case 3:
+				transport = "stdio"
+			default:
+				transport = "streamable_http"
 			}
+			config["transport"] = transport
+			agent.ApplyToolFunctions(mcpTool.ID, config, opts.ThreadID != "")
+			mcpConfig[mcpTool.McpName] = config
+			gatewayTools = append(gatewayTools, mcpTool)
 		}
 	}
 	if len(dootaskMcp) > 0 && len(userConfig) > 0 {
 		var dootaskConfig map[string]any
 		json.Unmarshal(dootaskMcp[0].Config, &dootaskConfig)
 		dootaskConfig["transport"] = "streamable_http"
-		dootaskConfig["url"] = fmt.Sprintf("%s/apps/mcp_server/mcp", req.Extras["base_url"])
+		dootaskConfig["url"] = fmt.Sprintf("%s/apps/mcp_server/mcp", opts.BaseURL)
 		if headers, ok := dootaskConfig["headers"].(map[string]any); ok {
-			headers["Authorization"] = fmt.Sprintf("Bearer %s", req.MsgUser.Token)
+			headers["Authorization"] = fmt.Sprintf("Bearer %s", opts.UserToken)
 		} else {
-			dootaskConfig["headers"] = map[string]any{"Authorization": fmt.Sprintf("Bearer %s", req.MsgUser.Token)}
+			dootaskConfig["headers"] = map[string]any{"Authorization": fmt.Sprintf("Bearer %s", opts.UserToken)}
 		}
-		if req.MsgUser.Token != "" {
+		agent.ApplyToolFunctions(dootaskMcp[0].ID, dootaskConfig, opts.ThreadID != "")
+		if opts.UserToken != "" {
 			isUseTool = true
 			path = "/mcp_agent/stream"
 			mcpConfig[dootaskMcp[0].McpName] = dootaskConfig
+			gatewayTools = append(gatewayTools, dootaskMcp[0])
+		}
+	}
+	// 启用MCP网关时，AI服务只连接网关，由网关转发到各上游服务并统一校验和记录调用
+	// stdio 工具由Go服务托管进程，始终通过网关访问
+	if !mcpgateway.Enabled() {
+		gatewayTools = slices.DeleteFunc(gatewayTools, func(tool mcptools.MCPTool) bool {
+			return tool.ConfigType != mcptools.ConfigTypeSTDIO
+		})
+	}
+	if len(gatewayTools) > 0 {
+		if config, err := gatewayConfig(agent, gatewayTools, opts); err != nil {
+			log.Printf("创建MCP网关会话失败，直接连接MCP服务: %v", err)
+		} else {
+			for _, tool := range gatewayTools {
+				delete(mcpConfig, tool.McpName)
+			}
+			mcpConfig[mcpgateway.ServerName] = config
 		}
 	}
 	if len(mcpConfig) > 0 {
@@ -617,12 +752,30 @@ func (h *Handler) requestAI(aiModel aimodels.AIModel, agent agents.Agent, req We
 		path = "/supervisor_agent/stream"
 	}
 
-	resp, err := httpClient.Stream(context.Background(), path, nil, nil, http.MethodPost, data, "application/json")
-	if err != nil {
-		return nil, err
-	}
+	return path, data
+}
 
-	return resp, nil
+// gatewayConfig 创建MCP网关会话，需要确认的函数按网关中的名称传给AI服务
+func gatewayConfig(agent agents.Agent, tools []mcptools.MCPTool, opts AIRequestOptions) (map[string]any, error) {
+	interactive := opts.ThreadID != ""
+	session := mcpgateway.Session{
+		AgentID:        agent.ID,
+		UserID:         opts.UserID,
+		ConversationID: opts.ConversationID,
+		UserToken:      opts.UserToken,
+		BaseURL:        opts.BaseURL,
+		Interactive:    interactive,
+	}
+	approvals := map[string]string{}
+	for _, tool := range tools {
+		session.ToolIDs = append(session.ToolIDs, tool.ID)
+		if policy, ok := agent.FunctionPolicy(tool.ID, interactive); ok {
+			for name, approval := range policy.Approvals {
+				approvals[mcpgateway.ToolName(tool.McpName, name)] = approval
+			}
+		}
+	}
+	return mcpgateway.NewSession(session, approvals)
 }
 
 // 构建用户消息
@@ -631,7 +784,7 @@ func (h *Handler) buildUserMessage(req WebhookRequest) (string, error) {
 	if req.DialogType == "group" {
 		messageList, err := global.DooTaskClient.Client.GetMessageList(dootask.GetMessageListRequest{
 			DialogID: int(req.DialogId),
-			Take:     10,
+			Take:     sysconfig.Int(sysconfig.KeyMaxConversationHistory, 10),
 		})
 		if err != nil {
 			log.Printf("获取消息列表失败: %v", err)
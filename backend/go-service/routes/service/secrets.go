@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/routes/api/agents"
+	mcptools "dootask-ai/go-service/routes/api/mcp-tools"
+	usersecrets "dootask-ai/go-service/routes/api/user-secrets"
+	"dootask-ai/go-service/utils"
+)
+
+// missingSecret 智能体工具缺少的用户密钥
+type missingSecret struct {
+	Tool string
+	Name string
+}
+
+// oauthPromptTTL 同一对话中未授权工具的提示间隔
+const oauthPromptTTL = 24 * time.Hour
+
+// secretReplyMask 回复密钥提示的消息在对话记录中的内容（不保存密钥明文）
+const secretReplyMask = "******"
+
+// agentTools 智能体可用的MCP工具
+func agentTools(agent agents.Agent) []mcptools.MCPTool {
+	if agent.Tools == nil {
+		return nil
+	}
+	var toolIDs []int64
+	json.Unmarshal([]byte(agent.Tools), &toolIDs)
+	if len(toolIDs) == 0 {
+		return nil
+	}
+
+	var tools []mcptools.MCPTool
+	global.DB.Scopes(mcptools.HealthyScope).Where("id in (?) AND is_active = ?", toolIDs, true).Order("id ASC").Find(&tools)
+	return tools
+}
+
+// findMissingSecret 查找智能体工具缺少的第一个用户密钥（用户在该对话中取消过的除外）
+func findMissingSecret(agent agents.Agent, userID int64, dialogID string) *missingSecret {
+	for _, tool := range agentTools(agent) {
+		cfg, err := tool.ClientConfig()
+		if err != nil {
+			continue
+		}
+		for _, name := range usersecrets.ResolveConfig(userID, tool.UserID, &cfg) {
+			if !usersecrets.IsSkipped(dialogID, userID, name) {
+				return &missingSecret{Tool: tool.Name, Name: name}
+			}
+		}
+	}
+	return nil
+}
+
+// requestSecret 记录等待用户提供的密钥，返回发送给用户的提示
+func requestSecret(agent agents.Agent, req WebhookRequest, dialogID string, missing *missingSecret, message string) string {
+	if err := usersecrets.SaveRequest(agent.ID, dialogID, req.MsgUid, usersecrets.Request{
+		Name:    missing.Name,
+		Tool:    missing.Tool,
+		Message: message,
+	}); err != nil {
+		log.Printf("记录密钥请求失败: %v", err)
+	}
+	lang := req.MsgUser.Lang
+	if lang == "" {
+		lang = "zh"
+	}
+	return utils.T(lang, utils.TranslationKeySecretRequired, missing.Tool, missing.Name)
+}
+
+// requestOAuth 智能体工具需要用户OAuth授权时返回带授权链接的提示；
+// 每个对话只提示一次，用户不授权直接发送消息时跳过该工具继续处理
+func requestOAuth(agent agents.Agent, req WebhookRequest, dialogID string, baseURL string) string {
+	for _, tool := range agentTools(agent) {
+		if _, ok := tool.OAuthSettings(); !ok {
+			continue
+		}
+		if _, err := mcptools.OAuthAccessToken(tool, req.MsgUid); !errors.Is(err, mcptools.ErrOAuthRequired) {
+			continue
+		}
+		key := fmt.Sprintf("mcp_oauth_prompt:%s:%d:%d", dialogID, req.MsgUid, tool.ID)
+		if ok, _ := global.Redis.SetNX(context.Background(), key, 1, oauthPromptTTL).Result(); !ok {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		authorizationURL, err := mcptools.AuthorizeURL(ctx, tool, req.MsgUid, baseURL)
+		cancel()
+		if err != nil {
+			log.Printf("发起工具 %s 的OAuth授权失败: %v", tool.McpName, err)
+			continue
+		}
+		lang := req.MsgUser.Lang
+		if lang == "" {
+			lang = "zh"
+		}
+		return utils.T(lang, utils.TranslationKeyOAuthRequired, tool.Name, authorizationURL)
+	}
+	return ""
+}
+
+// writeStreamPrompt 不请求AI服务，直接将提示写入流
+func writeStreamPrompt(streamId string, prompt string) {
+	key := fmt.Sprintf("stream_message:%s", streamId)
+	channel := fmt.Sprintf("stream_message_pub:%s", streamId)
+	if data, err := json.Marshal(StreamLineData{Type: "token", Content: prompt}); err == nil {
+		global.Redis.LPush(context.Background(), key, string(data))
+		global.Redis.Publish(context.Background(), channel, string(data))
+	}
+	global.Redis.LPush(context.Background(), key, "[DONE]")
+	global.Redis.Publish(context.Background(), channel, "[DONE]")
+	global.Redis.Expire(context.Background(), key, 10*time.Minute)
+}
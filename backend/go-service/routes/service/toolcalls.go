@@ -0,0 +1,126 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"dootask-ai/go-service/audit"
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/routes/api/agents"
+	mcptools "dootask-ai/go-service/routes/api/mcp-tools"
+	"dootask-ai/go-service/routes/mcpgateway"
+	"dootask-ai/go-service/sysconfig"
+)
+
+// ToolCallRecorder 根据流式事件记录智能体的工具调用（ai消息发起调用，tool消息返回结果）
+type ToolCallRecorder struct {
+	pending map[string]*mcptools.ToolCall
+	started map[string]time.Time
+	calls   []*mcptools.ToolCall
+}
+
+// NewToolCallRecorder 创建工具调用记录器
+func NewToolCallRecorder() *ToolCallRecorder {
+	return &ToolCallRecorder{
+		pending: map[string]*mcptools.ToolCall{},
+		started: map[string]time.Time{},
+	}
+}
+
+// Observe 处理一条message类型的流式数据
+func (r *ToolCallRecorder) Observe(msg *StreamToolData) {
+	switch msg.Type {
+	case "ai":
+		for _, toolCall := range msg.ToolCalls {
+			args, _ := json.Marshal(audit.Redact(toolCall.Args))
+			call := &mcptools.ToolCall{
+				FunctionName: toolCall.Name,
+				ToolCallID:   toolCall.ID,
+				Arguments:    args,
+			}
+			r.calls = append(r.calls, call)
+			r.pending[toolCall.ID] = call
+			r.started[toolCall.ID] = time.Now()
+		}
+	case "tool":
+		call, ok := r.pending[msg.ToolCallID]
+		if !ok {
+			return
+		}
+		delete(r.pending, msg.ToolCallID)
+		call.ResultSize = len(msg.Content)
+		call.LatencyMs = int(time.Since(r.started[msg.ToolCallID]).Milliseconds())
+		if msg.ResponseMetadata["status"] == "error" {
+			errMsg := truncateRunes(msg.Content, 1000)
+			call.Error = &errMsg
+		}
+	}
+}
+
+// Save 写入调用记录，未返回结果的调用记为失败
+func (r *ToolCallRecorder) Save(agent agents.Agent, conversationID *int64) {
+	// 启用MCP网关时调用记录由网关写入
+	if len(r.calls) == 0 || mcpgateway.Enabled() || !sysconfig.Bool(sysconfig.KeyEnableToolLogging, true) {
+		return
+	}
+	for id, call := range r.pending {
+		errMsg := "未返回结果"
+		call.Error = &errMsg
+		call.LatencyMs = int(time.Since(r.started[id]).Milliseconds())
+	}
+
+	toolIDs := agentToolIDs(agent)
+	if len(toolIDs) > 0 {
+		// stdio 工具始终通过网关访问，调用记录由网关写入
+		var stdioNames []string
+		global.DB.Model(&mcptools.MCPTool{}).Where("id IN (?) AND config_type = ?", toolIDs, mcptools.ConfigTypeSTDIO).Pluck("mcp_name", &stdioNames)
+		r.calls = slices.DeleteFunc(r.calls, func(call *mcptools.ToolCall) bool {
+			server, _, ok := strings.Cut(call.FunctionName, mcpgateway.NamespaceSeparator)
+			return ok && slices.Contains(stdioNames, server)
+		})
+		if len(r.calls) == 0 {
+			return
+		}
+	}
+
+	names := make([]string, 0, len(r.calls))
+	for _, call := range r.calls {
+		names = append(names, call.FunctionName)
+	}
+	functionTools := map[string]int64{}
+	if len(toolIDs) > 0 {
+		var functions []mcptools.MCPToolFunction
+		global.DB.Where("mcp_tool_id IN (?) AND name IN (?)", toolIDs, names).Find(&functions)
+		for _, function := range functions {
+			functionTools[function.Name] = function.MCPToolID
+		}
+	}
+
+	for _, call := range r.calls {
+		call.AgentID = &agent.ID
+		call.ConversationID = conversationID
+		if toolID, ok := functionTools[call.FunctionName]; ok {
+			call.MCPToolID = &toolID
+		} else if len(toolIDs) == 1 {
+			// 函数列表未缓存时，只关联了一个工具的智能体可以直接确定来源
+			call.MCPToolID = &toolIDs[0]
+		}
+	}
+	if err := global.DB.Create(r.calls).Error; err != nil {
+		log.Printf("写入工具调用记录失败: %v", err)
+	}
+}
+
+// agentToolIDs 智能体可调用的MCP工具ID（包括DooTask内置工具）
+func agentToolIDs(agent agents.Agent) []int64 {
+	var ids []int64
+	if agent.Tools != nil {
+		json.Unmarshal([]byte(agent.Tools), &ids)
+	}
+	var dootaskIDs []int64
+	global.DB.Model(&mcptools.MCPTool{}).Where("user_id = ? AND is_active = ? AND category = ?", 0, true, "dootask").Pluck("id", &dootaskIDs)
+	return append(ids, dootaskIDs...)
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"encoding/json"
 	"time"
+
+	"dootask-ai/go-service/generation"
 )
 
 // WebhookRequest 机器人webhook请求
@@ -49,6 +51,20 @@ type WebhookResponse struct {
 	Msg        map[string]any `json:"msg"`
 }
 
+// AIRequestOptions 请求AI服务的参数
+type AIRequestOptions struct {
+	Message   string // 用户消息
+	ThreadID  string // 会话线程ID（为空时不保留上下文）
+	UserID    int64  // 发起请求的DooTask用户ID
+	UserToken string // 发起请求的DooTask用户Token（用于DooTask MCP鉴权）
+	BaseURL   string // DooTask访问地址
+	DialogID  string // DooTask对话ID（用于读取对话级生成参数）
+
+	ConversationID int64 // 对话记录ID（MCP网关记录工具调用时关联）
+
+	Settings *generation.Settings // 单次请求的生成参数覆盖（优先级最高）
+}
+
 // StreamLineData 流式消息数据结构
 type StreamLineData struct {
 	Type    string
@@ -78,8 +94,13 @@ type StreamErrorData struct {
 // StreamToolData 工具数据结构
 type StreamToolData struct {
 	Type          string              `json:"type"`
+	Content       string              `json:"content"`
 	UsageMetadata StreamUsageMetadata `json:"usage_metadata"`
 	ToolCalls     []StreamToolCall    `json:"tool_calls"`
+	ToolCallID    string              `json:"tool_call_id"`
+	CustomData    map[string]any      `json:"custom_data"`
+
+	ResponseMetadata map[string]any `json:"response_metadata"` // 工具消息中包含执行状态 status（success/error）
 }
 
 // StreamToolCall 工具调用
@@ -0,0 +1,110 @@
+package secret
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// secretFields JSON配置中需要加密的字段名（忽略大小写和分隔符）
+var secretFields = map[string]bool{
+	"apikey":       true,
+	"token":        true,
+	"accesstoken":  true,
+	"secret":       true,
+	"clientsecret": true,
+	"password":     true,
+}
+
+// secretContainers 其下所有字符串值都需要加密的字段（如请求头、环境变量）
+var secretContainers = map[string]bool{
+	"headers": true,
+	"env":     true,
+}
+
+// EncryptJSON 加密JSON配置中的敏感字段
+func EncryptJSON(data []byte) ([]byte, error) {
+	return transformJSON(data, func(value string, sensitive bool) (string, error) {
+		if !sensitive {
+			return value, nil
+		}
+		return Encrypt(value)
+	})
+}
+
+// DecryptJSON 解密JSON配置中的所有密文
+func DecryptJSON(data []byte) ([]byte, error) {
+	return transformJSON(data, func(value string, sensitive bool) (string, error) {
+		if !IsEncrypted(value) {
+			return value, nil
+		}
+		return Decrypt(value)
+	})
+}
+
+// RotateJSON 使用活跃主密钥重新加密JSON配置中的敏感字段，返回新值和是否发生变化
+func RotateJSON(data []byte) ([]byte, bool, error) {
+	changed := false
+	result, err := transformJSON(data, func(value string, sensitive bool) (string, error) {
+		if !sensitive && !IsEncrypted(value) {
+			return value, nil
+		}
+		rotated, ok, err := Rotate(value)
+		changed = changed || ok
+		return rotated, err
+	})
+	if err != nil || !changed {
+		return data, false, err
+	}
+	return result, true, nil
+}
+
+// transformJSON 遍历JSON中的字符串值，非对象/数组的JSON原样返回
+func transformJSON(data []byte, fn func(value string, sensitive bool) (string, error)) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	var root interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return data, nil
+	}
+	result, err := walk(root, false, fn)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func walk(v interface{}, sensitive bool, fn func(value string, sensitive bool) (string, error)) (interface{}, error) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			name := normalize(key)
+			transformed, err := walk(item, sensitive || secretFields[name] || secretContainers[name], fn)
+			if err != nil {
+				return nil, err
+			}
+			value[key] = transformed
+		}
+		return value, nil
+	case []interface{}:
+		for i, item := range value {
+			transformed, err := walk(item, sensitive, fn)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = transformed
+		}
+		return value, nil
+	case string:
+		if value == "" {
+			return value, nil
+		}
+		return fn(value, sensitive)
+	}
+	return v, nil
+}
+
+// normalize 统一字段名格式（api_key、apiKey、api-key 视为相同）
+func normalize(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
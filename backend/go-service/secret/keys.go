@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"strings"
+	"sync"
+
+	"dootask-ai/go-service/utils"
+)
+
+// legacyKeyID 由 API_KEY 派生的主密钥ID（未配置 SECRET_MASTER_KEYS 时使用）
+const legacyKeyID = "default"
+
+// keyring 主密钥环（KEK），按密钥ID索引
+type keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+var (
+	ringOnce sync.Once
+	ring     *keyring
+)
+
+// loadKeyring 从环境变量加载主密钥
+//
+//	SECRET_MASTER_KEYS=k2:<base64 32字节>,k1:<base64 32字节>
+//	SECRET_ACTIVE_KEY_ID=k2（为空时使用列表中的第一个）
+//
+// 轮换主密钥时，将新密钥加入列表并设为活跃密钥，执行 reencrypt 命令后再移除旧密钥。
+func loadKeyring() *keyring {
+	ringOnce.Do(func() {
+		ring = &keyring{keys: map[string][]byte{}}
+
+		for _, item := range strings.Split(utils.GetEnvWithDefault("SECRET_MASTER_KEYS", ""), ",") {
+			id, encoded, ok := strings.Cut(strings.TrimSpace(item), ":")
+			if !ok || id == "" {
+				continue
+			}
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil || len(key) != 32 {
+				log.Printf("主密钥 %s 格式错误（需要base64编码的32字节密钥），已忽略", id)
+				continue
+			}
+			ring.keys[id] = key
+			if ring.active == "" {
+				ring.active = id
+			}
+		}
+
+		// 兼容只配置了 API_KEY 的部署
+		if appKey := utils.GetEnvWithDefault("API_KEY", ""); appKey != "" {
+			if _, exists := ring.keys[legacyKeyID]; !exists {
+				derived := sha256.Sum256([]byte(appKey))
+				ring.keys[legacyKeyID] = derived[:]
+				if ring.active == "" {
+					ring.active = legacyKeyID
+				}
+			}
+		}
+
+		if activeID := utils.GetEnvWithDefault("SECRET_ACTIVE_KEY_ID", ""); activeID != "" {
+			if _, exists := ring.keys[activeID]; exists {
+				ring.active = activeID
+			} else {
+				log.Printf("活跃主密钥 %s 不存在，使用 %s", activeID, ring.active)
+			}
+		}
+
+		if ring.active == "" {
+			log.Printf("未配置 SECRET_MASTER_KEYS 或 API_KEY，密钥将以明文存储")
+		}
+	})
+	return ring
+}
+
+// ActiveKeyID 当前用于加密的主密钥ID（未配置时为空）
+func ActiveKeyID() string {
+	return loadKeyring().active
+}
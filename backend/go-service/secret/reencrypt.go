@@ -0,0 +1,102 @@
+package secret
+
+import (
+	"log"
+
+	"dootask-ai/go-service/global"
+)
+
+// target 存储密钥的数据库字段
+type target struct {
+	Table  string
+	Column string
+	JSON   bool   // JSON字段只处理其中的敏感值
+	Where  string // 额外的筛选条件
+}
+
+// targets 所有需要加密存储的字段
+var targets = []target{
+	{Table: "ai_models", Column: "api_key"},
+	{Table: "ai_model_keys", Column: "api_key"},
+	{Table: "knowledge_bases", Column: "api_key"},
+	{Table: "mcp_tools", Column: "config", JSON: true},
+	{Table: "system_configs", Column: "value", Where: "is_encrypted = true"},
+	{Table: "user_secrets", Column: "value"},
+	{Table: "mcp_oauth_clients", Column: "client_secret"},
+	{Table: "mcp_oauth_tokens", Column: "access_token"},
+	{Table: "mcp_oauth_tokens", Column: "refresh_token"},
+	{Table: "kb_sources", Column: "credential"},
+	{Table: "kb_reindex_jobs", Column: "settings", JSON: true},
+}
+
+// ReencryptResult 单个字段的重新加密结果
+type ReencryptResult struct {
+	Table   string `json:"table"`
+	Column  string `json:"column"`
+	Scanned int    `json:"scanned"`
+	Updated int    `json:"updated"`
+	Failed  int    `json:"failed"`
+}
+
+// ReencryptAll 使用活跃主密钥重新加密所有密钥字段（明文和旧版密文会被加密，其他主密钥的密文会重新包装数据密钥）
+func ReencryptAll(dryRun bool) ([]ReencryptResult, error) {
+	results := make([]ReencryptResult, 0, len(targets))
+	for _, t := range targets {
+		result, err := reencrypt(t, dryRun)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func reencrypt(t target, dryRun bool) (ReencryptResult, error) {
+	result := ReencryptResult{Table: t.Table, Column: t.Column}
+
+	var rows []struct {
+		ID    int64
+		Value string
+	}
+	query := global.DB.Table(t.Table).Select("id, " + t.Column + "::text AS value").Where(t.Column + " IS NOT NULL")
+	if t.Where != "" {
+		query = query.Where(t.Where)
+	}
+	if err := query.Order("id ASC").Scan(&rows).Error; err != nil {
+		return result, err
+	}
+
+	for _, row := range rows {
+		result.Scanned++
+
+		var (
+			value   string
+			changed bool
+			err     error
+		)
+		if t.JSON {
+			var data []byte
+			data, changed, err = RotateJSON([]byte(row.Value))
+			value = string(data)
+		} else {
+			value, changed, err = Rotate(row.Value)
+		}
+		if err != nil {
+			result.Failed++
+			log.Printf("重新加密失败 %s.%s id=%d: %v", t.Table, t.Column, row.ID, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		result.Updated++
+		if dryRun {
+			continue
+		}
+		if err := global.DB.Table(t.Table).Where("id = ?", row.ID).Update(t.Column, value).Error; err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,206 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"dootask-ai/go-service/utils"
+)
+
+// prefix 信封加密密文前缀，格式：enc:v1:<密钥ID>:<加密的数据密钥>:<加密的数据>
+const prefix = "enc:v1:"
+
+// ErrUnknownKey 密文使用的主密钥不在密钥环中
+var ErrUnknownKey = errors.New("unknown master key")
+
+// IsEncrypted 判断值是否为信封加密密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID 返回密文使用的主密钥ID，非密文返回空
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id
+}
+
+// Encrypt 使用随机数据密钥加密明文，数据密钥再由活跃主密钥加密（未配置主密钥时原样返回）
+func Encrypt(plain string) (string, error) {
+	if plain == "" || IsEncrypted(plain) {
+		return plain, nil
+	}
+	ring := loadKeyring()
+	if ring.active == "" {
+		return plain, nil
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := seal(dek, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(ring.keys[ring.active], dek)
+	if err != nil {
+		return "", err
+	}
+	return format(ring.active, wrapped, data), nil
+}
+
+// Decrypt 解密信封密文；兼容旧版 API_KEY 直接加密的值，无法解密的值视为明文
+func Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		if plain, ok := decryptLegacy(value); ok {
+			return plain, nil
+		}
+		return value, nil
+	}
+
+	id, wrapped, data, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	kek, exists := loadKeyring().keys[id]
+	if !exists {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	dek, err := open(kek, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(dek, data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// Rotate 使用活跃主密钥重新加密，返回新值和是否发生变化
+// 已是信封密文的只重新包装数据密钥，明文和旧版密文会完整加密
+func Rotate(value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	ring := loadKeyring()
+	if ring.active == "" {
+		return value, false, nil
+	}
+
+	if !IsEncrypted(value) {
+		plain, _ := Decrypt(value)
+		encrypted, err := Encrypt(plain)
+		return encrypted, err == nil, err
+	}
+
+	id, wrapped, data, err := parse(value)
+	if err != nil {
+		return value, false, err
+	}
+	if id == ring.active {
+		return value, false, nil
+	}
+	kek, exists := ring.keys[id]
+	if !exists {
+		return value, false, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	dek, err := open(kek, wrapped)
+	if err != nil {
+		return value, false, err
+	}
+	rewrapped, err := seal(ring.keys[ring.active], dek)
+	if err != nil {
+		return value, false, err
+	}
+	return format(ring.active, rewrapped, data), true, nil
+}
+
+// Transport 按Python AI服务的约定加密传输（使用 API_KEY，未配置时返回明文）
+func Transport(plain string) string {
+	appKey := utils.GetEnvWithDefault("API_KEY", "")
+	if appKey == "" || plain == "" {
+		return plain
+	}
+	encrypted, err := seal([]byte(appKey), []byte(plain))
+	if err != nil {
+		return plain
+	}
+	return base64.StdEncoding.EncodeToString(encrypted)
+}
+
+// decryptLegacy 解密旧版使用 API_KEY 直接加密的值
+func decryptLegacy(value string) (string, bool) {
+	appKey := utils.GetEnvWithDefault("API_KEY", "")
+	if appKey == "" || value == "" {
+		return "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", false
+	}
+	plain, err := open([]byte(appKey), raw)
+	if err != nil {
+		return "", false
+	}
+	return string(plain), true
+}
+
+// seal AES-GCM加密，输出 nonce+密文
+func seal(key, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+// open AES-GCM解密 nonce+密文
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func format(id string, wrapped, data []byte) string {
+	return prefix + id + ":" + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(data)
+}
+
+func parse(value string) (id string, wrapped []byte, data []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("invalid ciphertext format")
+	}
+	if wrapped, err = base64.StdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, err
+	}
+	if data, err = base64.StdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, err
+	}
+	return parts[0], wrapped, data, nil
+}
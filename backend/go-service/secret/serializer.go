@@ -0,0 +1,105 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+func init() {
+	schema.RegisterSerializer("secret", StringSerializer{})
+	schema.RegisterSerializer("secretjson", JSONSerializer{})
+}
+
+// StringSerializer 字符串字段的透明加解密（支持 string 和 *string）
+//
+//	ApiKey *string `gorm:"type:text;serializer:secret"`
+type StringSerializer struct{}
+
+// Scan 读取时解密
+func (StringSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := field.ReflectValueOf(ctx, dst)
+	if dbValue == nil {
+		fieldValue.Set(reflect.Zero(field.FieldType))
+		return nil
+	}
+
+	var raw string
+	switch v := dbValue.(type) {
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	default:
+		return fmt.Errorf("unsupported secret value type: %T", dbValue)
+	}
+
+	plain, err := Decrypt(raw)
+	if err != nil {
+		return fmt.Errorf("decrypt %s: %w", field.Name, err)
+	}
+	if field.FieldType.Kind() == reflect.Ptr {
+		fieldValue.Set(reflect.ValueOf(&plain))
+	} else {
+		fieldValue.SetString(plain)
+	}
+	return nil
+}
+
+// Value 写入时加密
+func (StringSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	switch v := fieldValue.(type) {
+	case string:
+		return Encrypt(v)
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+		return Encrypt(*v)
+	}
+	return nil, fmt.Errorf("unsupported secret field type: %T", fieldValue)
+}
+
+// JSONSerializer JSON字段中敏感值的透明加解密（字段类型为 []byte 系列，如 json.RawMessage）
+//
+//	Config json.RawMessage `gorm:"type:jsonb;serializer:secretjson"`
+type JSONSerializer struct{}
+
+// Scan 读取时解密
+func (JSONSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var raw []byte
+	switch v := dbValue.(type) {
+	case nil:
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("unsupported secret json value type: %T", dbValue)
+	}
+
+	plain, err := DecryptJSON(raw)
+	if err != nil {
+		return fmt.Errorf("decrypt %s: %w", field.Name, err)
+	}
+	field.ReflectValueOf(ctx, dst).Set(reflect.ValueOf(plain).Convert(field.FieldType))
+	return nil
+}
+
+// Value 写入时加密
+func (JSONSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(fieldValue)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, fmt.Errorf("unsupported secret json field type: %T", fieldValue)
+	}
+	if rv.Len() == 0 {
+		return nil, nil
+	}
+	encrypted, err := EncryptJSON(rv.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return string(encrypted), nil
+}
@@ -0,0 +1,177 @@
+package sysconfig
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dootask-ai/go-service/global"
+	"dootask-ai/go-service/secret"
+)
+
+const (
+	// ChangeChannel 配置变更通知频道（多实例部署时通知其他实例刷新缓存）
+	ChangeChannel = "system_configs:changed"
+	// cacheTTL 缓存有效期（Redis通知丢失时的兜底刷新）
+	cacheTTL = 5 * time.Minute
+)
+
+var (
+	cacheMu       sync.RWMutex
+	cacheValues   map[string]string
+	cacheLoadedAt time.Time
+)
+
+// String 读取字符串配置，未配置时返回默认值
+func String(key string, defaultValue string) string {
+	if value, ok := lookup(key); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Int 读取整数配置，未配置或格式错误时返回默认值
+func Int(key string, defaultValue int) int {
+	if value, ok := lookup(key); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// Float 读取浮点数配置，未配置或格式错误时返回默认值
+func Float(key string, defaultValue float64) float64 {
+	if value, ok := lookup(key); ok {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// Bool 读取布尔配置，未配置或格式错误时返回默认值
+func Bool(key string, defaultValue bool) bool {
+	if value, ok := lookup(key); ok {
+		if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// Validate 按配置键的类型校验值
+func Validate(key string, value string) error {
+	switch Types[key] {
+	case TypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("配置 %s 需要整数值", key)
+		}
+	case TypeFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("配置 %s 需要数字值", key)
+		}
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("配置 %s 需要布尔值(true/false)", key)
+		}
+	}
+	return nil
+}
+
+// Set 更新配置值（is_encrypted 的配置加密存储），清除本地缓存并通知其他实例
+func Set(key string, value string) (*Config, error) {
+	var config Config
+	if err := global.DB.Where("key = ?", key).First(&config).Error; err != nil {
+		return nil, err
+	}
+	stored := value
+	if config.IsEncrypted {
+		encrypted, err := secret.Encrypt(value)
+		if err != nil {
+			return nil, err
+		}
+		stored = encrypted
+	}
+	if err := global.DB.Model(&config).Update("value", stored).Error; err != nil {
+		return nil, err
+	}
+	config.Value = value
+
+	Invalidate()
+	Notify(key)
+	return &config, nil
+}
+
+// Invalidate 清除本地缓存，下次读取时重新加载
+func Invalidate() {
+	cacheMu.Lock()
+	cacheValues = nil
+	cacheMu.Unlock()
+}
+
+// Notify 通过Redis发布配置变更通知
+func Notify(key string) {
+	if global.Redis == nil {
+		return
+	}
+	if err := global.Redis.Publish(context.Background(), ChangeChannel, key).Err(); err != nil {
+		log.Printf("发布配置变更通知失败: %v", err)
+	}
+}
+
+// Watch 订阅配置变更通知，收到通知后清除本地缓存
+func Watch() {
+	if global.Redis == nil {
+		return
+	}
+	go func() {
+		pubsub := global.Redis.Subscribe(context.Background(), ChangeChannel)
+		defer pubsub.Close()
+		for msg := range pubsub.Channel() {
+			log.Printf("系统配置已变更: %s", msg.Payload)
+			Invalidate()
+		}
+	}()
+}
+
+// lookup 从缓存读取配置，缓存为空或过期时从数据库加载全部配置
+func lookup(key string) (string, bool) {
+	cacheMu.RLock()
+	if cacheValues != nil && time.Since(cacheLoadedAt) < cacheTTL {
+		value, ok := cacheValues[key]
+		cacheMu.RUnlock()
+		return value, ok
+	}
+	cacheMu.RUnlock()
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if cacheValues == nil || time.Since(cacheLoadedAt) >= cacheTTL {
+		var configs []Config
+		if err := global.DB.Find(&configs).Error; err != nil {
+			log.Printf("加载系统配置失败: %v", err)
+			return "", false
+		}
+		cacheValues = make(map[string]string, len(configs))
+		for _, config := range configs {
+			value := config.Value
+			if config.IsEncrypted {
+				plain, err := secret.Decrypt(value)
+				if err != nil {
+					log.Printf("解密系统配置 %s 失败: %v", config.Key, err)
+					continue
+				}
+				value = plain
+			}
+			cacheValues[config.Key] = value
+		}
+		cacheLoadedAt = time.Now()
+	}
+	value, ok := cacheValues[key]
+	return value, ok
+}
@@ -0,0 +1,89 @@
+package sysconfig
+
+import "time"
+
+// 配置值类型
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+)
+
+// 系统配置键（migrations/014 初始化）
+const (
+	KeyMaxTokensPerRequest         = "max_tokens_per_request"
+	KeyMaxConversationHistory      = "max_conversation_history"
+	KeyEnableAuditLog              = "enable_audit_log"
+	KeyWebhookTimeout              = "webhook_timeout"
+	KeyDefaultAgentTemperature     = "default_agent_temperature"
+	KeyMCPToolTimeout              = "mcp_tool_timeout"
+	KeyKnowledgeBaseChunkBatchSize = "knowledge_base_chunk_batch_size"
+	KeyAIResponseCacheTTL          = "ai_response_cache_ttl"
+	KeyMaxFileUploadSize           = "max_file_upload_size"
+	KeyEnableToolLogging           = "enable_tool_logging"
+	KeyDefaultEmbeddingModel       = "default_embedding_model"
+	KeyWebhookRetryDelay           = "webhook_retry_delay"
+	KeySessionCleanupInterval      = "session_cleanup_interval"
+	KeyAuditLogRetentionDays       = "audit_log_retention_days"
+	KeyToolRateLimitPerMinute      = "tool_rate_limit_per_minute"
+	KeyMCPHealthCheckInterval      = "mcp_health_check_interval"    // migrations/040
+	KeyMCPHealthFailureThreshold   = "mcp_health_failure_threshold" // migrations/040
+	KeyEnableMCPGateway            = "enable_mcp_gateway"           // migrations/041
+	KeyMCPStdioWarmProcesses       = "mcp_stdio_warm_processes"     // migrations/042
+	KeyMCPStdioMaxProcesses        = "mcp_stdio_max_processes"      // migrations/042
+	KeyMCPStdioMemoryLimitMB       = "mcp_stdio_memory_limit_mb"    // migrations/042
+	KeyMCPStdioCPULimitSeconds     = "mcp_stdio_cpu_limit_seconds"  // migrations/042
+	KeyMCPStdioMaxLifetime         = "mcp_stdio_max_lifetime"       // migrations/042
+	KeyMCPStdioIdleTimeout         = "mcp_stdio_idle_timeout"       // migrations/042
+	KeyKBCrawlMaxPages             = "kb_crawl_max_pages"           // migrations/045
+	KeyKBCrawlRequestInterval      = "kb_crawl_request_interval"    // migrations/045
+	KeyKBDooTaskMaxItems           = "kb_dootask_max_items"         // migrations/046
+)
+
+// Types 已知配置键的值类型（用于更新时校验，未列出的键按字符串处理）
+var Types = map[string]string{
+	KeyMaxTokensPerRequest:         TypeInt,
+	KeyMaxConversationHistory:      TypeInt,
+	KeyEnableAuditLog:              TypeBool,
+	KeyWebhookTimeout:              TypeInt,
+	KeyDefaultAgentTemperature:     TypeFloat,
+	KeyMCPToolTimeout:              TypeInt,
+	KeyKnowledgeBaseChunkBatchSize: TypeInt,
+	KeyAIResponseCacheTTL:          TypeInt,
+	KeyMaxFileUploadSize:           TypeInt,
+	KeyEnableToolLogging:           TypeBool,
+	KeyDefaultEmbeddingModel:       TypeString,
+	KeyWebhookRetryDelay:           TypeInt,
+	KeySessionCleanupInterval:      TypeInt,
+	KeyAuditLogRetentionDays:       TypeInt,
+	KeyToolRateLimitPerMinute:      TypeInt,
+	KeyMCPHealthCheckInterval:      TypeInt,
+	KeyMCPHealthFailureThreshold:   TypeInt,
+	KeyEnableMCPGateway:            TypeBool,
+	KeyMCPStdioWarmProcesses:       TypeInt,
+	KeyMCPStdioMaxProcesses:        TypeInt,
+	KeyMCPStdioMemoryLimitMB:       TypeInt,
+	KeyMCPStdioCPULimitSeconds:     TypeInt,
+	KeyMCPStdioMaxLifetime:         TypeInt,
+	KeyMCPStdioIdleTimeout:         TypeInt,
+	KeyKBCrawlMaxPages:             TypeInt,
+	KeyKBCrawlRequestInterval:      TypeInt,
+	KeyKBDooTaskMaxItems:           TypeInt,
+}
+
+// Config 系统配置模型
+type Config struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key         string    `gorm:"type:varchar(255);not null;uniqueIndex" json:"key"`
+	Value       string    `gorm:"type:text;not null" json:"value"`
+	Description *string   `gorm:"type:text" json:"description"`
+	IsEncrypted bool      `gorm:"default:false" json:"is_encrypted"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Config) TableName() string {
+	return "system_configs"
+}
@@ -1,20 +1,117 @@
 package utils
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	dootask "github.com/dootask/tools/server/go"
 )
 
 type DooTaskClient struct {
 	Client *dootask.Client
+	Token  string // 用户令牌
+	Server string // DooTask服务地址
 }
 
 // NewDooTaskClient 创建 DooTask 客户端
 func NewDooTaskClient(token string) DooTaskClient {
 	server := os.Getenv("DOOTASK_API_BASE_URL")
 	if len(server) > 0 {
-		return DooTaskClient{Client: dootask.NewClient(token, dootask.WithServer(server))}
+		return DooTaskClient{Client: dootask.NewClient(token, dootask.WithServer(server)), Token: token, Server: server}
 	}
-	return DooTaskClient{Client: dootask.NewClient(token)}
+	return DooTaskClient{Client: dootask.NewClient(token), Token: token, Server: "http://nginx"}
+}
+
+// dooTaskResponse DooTask接口通用响应
+type dooTaskResponse struct {
+	Ret  int             `json:"ret"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Get 调用SDK未封装的DooTask接口（GET），将data字段解析到out
+func (d DooTaskClient) Get(ctx context.Context, path string, params map[string]string, out interface{}) error {
+	body, _, err := d.request(ctx, path, params, 15*time.Second, -1)
+	if err != nil {
+		return err
+	}
+	return parseDooTaskResponse(body, out)
+}
+
+// Download 下载DooTask接口返回的文件内容，超过 maxSize 字节时返回错误
+func (d DooTaskClient) Download(ctx context.Context, path string, params map[string]string, maxSize int64) ([]byte, error) {
+	body, contentType, err := d.request(ctx, path, params, 2*time.Minute, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	// 下载失败时接口返回JSON格式的错误信息
+	if strings.HasPrefix(contentType, "application/json") {
+		if err := parseDooTaskResponse(body, nil); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// request 发送GET请求，返回响应内容和类型（maxSize 小于0时不限制大小）
+func (d DooTaskClient) request(ctx context.Context, path string, params map[string]string, timeout time.Duration, maxSize int64) ([]byte, string, error) {
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	fullURL := strings.TrimRight(d.Server, "/") + "/api/" + strings.TrimLeft(path, "/")
+	if len(query) > 0 {
+		fullURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("token", d.Token)
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("请求DooTask失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("请求DooTask失败: HTTP %d", resp.StatusCode)
+	}
+
+	var reader io.Reader = resp.Body
+	if maxSize >= 0 {
+		reader = io.LimitReader(resp.Body, maxSize+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取响应失败: %v", err)
+	}
+	if maxSize >= 0 && int64(len(body)) > maxSize {
+		return nil, "", fmt.Errorf("文件大小超过限制(%dMB)", maxSize/1024/1024)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// parseDooTaskResponse 解析DooTask接口响应，将data字段解析到out
+func parseDooTaskResponse(body []byte, out interface{}) error {
+	var result dooTaskResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %v", err)
+	}
+	if result.Ret != 1 {
+		return fmt.Errorf("DooTask接口错误: %s", result.Msg)
+	}
+	if out != nil && len(result.Data) > 0 {
+		return json.Unmarshal(result.Data, out)
+	}
+	return nil
 }
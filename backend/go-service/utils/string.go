@@ -94,6 +94,12 @@ const (
 	TranslationKeyMcpToolCall TranslationKey = "mcp_tool_call"
 	// TranslationKeyMcpToolCallWithName MCP工具调用: %s（带工具名称）
 	TranslationKeyMcpToolCallWithName TranslationKey = "mcp_tool_call_with_name"
+	// TranslationKeyToolApproval 工具调用需要确认: %s（工具名称和参数）
+	TranslationKeyToolApproval TranslationKey = "tool_approval"
+	// TranslationKeySecretRequired 工具需要用户密钥: %s（工具名称）、%s（密钥名称）
+	TranslationKeySecretRequired TranslationKey = "secret_required"
+	// TranslationKeyOAuthRequired 工具需要用户授权: %s（工具名称）、%s（授权地址）
+	TranslationKeyOAuthRequired TranslationKey = "oauth_required"
 )
 
 // translations 翻译映射表
@@ -101,18 +107,30 @@ var translations = map[string]map[TranslationKey]string{
 	"zh": {
 		TranslationKeyMcpToolCall:         "MCP工具调用",
 		TranslationKeyMcpToolCallWithName: "#### MCP工具调用: %s\n",
+		TranslationKeyToolApproval:        "#### 需要确认: %s\n\n```json\n%s\n```\n\n回复“同意”执行该操作，回复其他内容将取消。\n",
+		TranslationKeySecretRequired:      "#### 需要密钥: %s\n\n该工具需要您的个人密钥 `%s`。请直接回复密钥内容，保存后将继续处理您的问题；回复“取消”将不使用该工具。\n",
+		TranslationKeyOAuthRequired:       "#### 需要授权: %s\n\n该工具需要您的账号授权，请[点击这里](%s)完成授权后重新发送消息；直接发送消息将不使用该工具继续处理。\n",
 	},
 	"zh-CN": {
 		TranslationKeyMcpToolCall:         "MCP工具调用",
 		TranslationKeyMcpToolCallWithName: "#### MCP工具调用: %s\n",
+		TranslationKeyToolApproval:        "#### 需要确认: %s\n\n```json\n%s\n```\n\n回复“同意”执行该操作，回复其他内容将取消。\n",
+		TranslationKeySecretRequired:      "#### 需要密钥: %s\n\n该工具需要您的个人密钥 `%s`。请直接回复密钥内容，保存后将继续处理您的问题；回复“取消”将不使用该工具。\n",
+		TranslationKeyOAuthRequired:       "#### 需要授权: %s\n\n该工具需要您的账号授权，请[点击这里](%s)完成授权后重新发送消息；直接发送消息将不使用该工具继续处理。\n",
 	},
 	"en": {
 		TranslationKeyMcpToolCall:         "MCP Tool Call",
 		TranslationKeyMcpToolCallWithName: "#### MCP Tool Call: %s\n",
+		TranslationKeyToolApproval:        "#### Confirmation required: %s\n\n```json\n%s\n```\n\nReply \"approve\" to run this action; any other reply cancels it.\n",
+		TranslationKeySecretRequired:      "#### Secret required: %s\n\nThis tool needs your personal secret `%s`. Reply with the secret value and your request will continue; reply \"cancel\" to go on without this tool.\n",
+		TranslationKeyOAuthRequired:       "#### Authorization required: %s\n\nThis tool needs access to your account. [Authorize here](%s), then send your message again; sending a message without authorizing continues without this tool.\n",
 	},
 	"en-US": {
 		TranslationKeyMcpToolCall:         "MCP Tool Call",
 		TranslationKeyMcpToolCallWithName: "#### MCP Tool Call: %s\n",
+		TranslationKeyToolApproval:        "#### Confirmation required: %s\n\n```json\n%s\n```\n\nReply \"approve\" to run this action; any other reply cancels it.\n",
+		TranslationKeySecretRequired:      "#### Secret required: %s\n\nThis tool needs your personal secret `%s`. Reply with the secret value and your request will continue; reply \"cancel\" to go on without this tool.\n",
+		TranslationKeyOAuthRequired:       "#### Authorization required: %s\n\nThis tool needs access to your account. [Authorize here](%s), then send your message again; sending a message without authorizing continues without this tool.\n",
 	},
 }
 
@@ -11,11 +11,13 @@ from langchain_core.runnables import (RunnableConfig, RunnableLambda,
 from langchain_core.runnables.base import RunnableSequence
 from langgraph.graph import END, MessagesState, StateGraph
 from langgraph.managed import RemainingSteps
+from langgraph.types import StreamWriter
 from langchain_postgres import PGVector
 from langchain_core.runnables import RunnableConfig
 from langchain.retrievers import MergerRetriever
 
 from core.embeddings import get_embeddings_by_provider
+from agents.utils import CustomData
 
 import logging
 logger = logging.getLogger("uvicorn")
@@ -120,7 +122,7 @@ def wrap_model(model: BaseChatModel, prompt: str | None) -> RunnableSerializable
     return RunnableSequence(preprocessor, model)
 
 
-async def retrieve_documents(state: AgentState, config: RunnableConfig) -> AgentState:
+async def retrieve_documents(state: AgentState, config: RunnableConfig, writer: StreamWriter) -> AgentState:
     """Retrieve relevant documents from the knowledge base."""
     # Get the last human message
     human_messages = [msg for msg in state["messages"] if isinstance(msg, HumanMessage)]
@@ -177,6 +179,9 @@ async def retrieve_documents(state: AgentState, config: RunnableConfig) -> Agent
             f"Retrieved {len(document_summaries)} documents for query: {query[:50]}..."
         )
 
+        # 推送检索结果，供调用方展示RAG检索过程
+        CustomData(data={"type": "rag_retrieval", "query": query, "documents": document_summaries}).dispatch(writer)
+
         return {"retrieved_documents": document_summaries, "messages": []}
 
     except Exception as e:
@@ -1,14 +1,85 @@
 import json
+import re
+from typing import Any
 from core import get_model_by_provider, settings
 from langchain_core.messages import BaseMessage,SystemMessage
 from langchain_core.runnables import RunnableConfig
+from langchain_core.tools import BaseTool, ToolException
 from langchain_mcp_adapters.client import MultiServerMCPClient
 from langgraph.func import entrypoint
 from langgraph.graph import START, MessagesState, StateGraph
 from langgraph.prebuilt import ToolNode, tools_condition
+from langgraph.types import interrupt
 import logging
 logger = logging.getLogger("uvicorn")
 
+def check_constraints(constraints: dict[str, dict[str, Any]], arguments: dict[str, Any]) -> str | None:
+    """校验工具参数是否满足约束，返回错误信息"""
+    for name, rule in constraints.items():
+        if name not in arguments:
+            if "const" in rule:
+                return f"参数 {name} 必须为 {rule['const']}"
+            continue
+        value = arguments[name]
+        if "const" in rule and value != rule["const"]:
+            return f"参数 {name} 必须为 {rule['const']}"
+        if "enum" in rule and value not in rule["enum"]:
+            return f"参数 {name} 只能为 {rule['enum']} 之一"
+        if "pattern" in rule and not re.fullmatch(rule["pattern"], str(value)):
+            return f"参数 {name} 不符合格式 {rule['pattern']}"
+        if "prefix" in rule and not str(value).startswith(rule["prefix"]):
+            return f"参数 {name} 必须以 {rule['prefix']} 开头"
+        if isinstance(value, (int, float)):
+            if "minimum" in rule and value < rule["minimum"]:
+                return f"参数 {name} 不能小于 {rule['minimum']}"
+            if "maximum" in rule and value > rule["maximum"]:
+                return f"参数 {name} 不能大于 {rule['maximum']}"
+    return None
+
+
+def guard_tool(tool: BaseTool, constraints: dict[str, dict[str, Any]], approval: str | None) -> BaseTool:
+    """为工具添加参数约束和审批：不满足约束时直接拒绝；需要确认时暂停运行，用户回复“同意”后才调用MCP服务"""
+    original = tool.coroutine
+
+    async def call(**arguments: Any):
+        if error := check_constraints(constraints, arguments):
+            raise ToolException(f"调用被拒绝：{error}")
+        if approval == "confirm":
+            reply = interrupt({
+                "type": "tool_approval",
+                "tool": tool.name,
+                "description": tool.description,
+                "arguments": arguments,
+            })
+            if str(reply).strip() != "同意":
+                raise ToolException("调用被拒绝：用户未批准该操作")
+        return await original(**arguments)
+
+    return tool.model_copy(update={"coroutine": call, "handle_tool_error": True})
+
+
+async def load_mcp_tools(mcp_config: dict[str, dict[str, Any]]) -> list[BaseTool]:
+    """加载MCP工具，按智能体配置的 allowed_tools 过滤函数并应用 tool_constraints 和 tool_approvals"""
+    policies = {}
+    for server_name, server_config in mcp_config.items():
+        policies[server_name] = (
+            server_config.pop("allowed_tools", None),
+            server_config.pop("tool_constraints", None) or {},
+            server_config.pop("tool_approvals", None) or {},
+        )
+
+    client = MultiServerMCPClient(mcp_config)
+    tools = []
+    for server_name, (allowed_tools, tool_constraints, tool_approvals) in policies.items():
+        for tool in await client.get_tools(server_name=server_name):
+            if allowed_tools is not None and tool.name not in allowed_tools:
+                continue
+            if tool_constraints.get(tool.name) or tool_approvals.get(tool.name):
+                tool = guard_tool(tool, tool_constraints.get(tool.name) or {}, tool_approvals.get(tool.name))
+            tools.append(tool)
+    return tools
+
+
 @entrypoint()
 async def mcp_agent(
     inputs: dict[str, list[BaseMessage]],
@@ -34,10 +105,7 @@ async def mcp_agent(
         config = {}
     mcp_config = json.loads(config_tuple) if config_tuple else {}
     logger.info(mcp_config)
-    client = MultiServerMCPClient(
-        mcp_config
-    )
-    tools = await client.get_tools()
+    tools = await load_mcp_tools(mcp_config)
 
     def call_model(state: MessagesState):
         response = model.bind_tools(tools).invoke(state["messages"])
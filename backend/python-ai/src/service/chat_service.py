@@ -10,6 +10,7 @@ import time
 from io import BytesIO
 
 from agents import DEFAULT_AGENT, AgentGraph, get_agent
+from agents.utils import CustomData
 from core import settings
 from fastapi import HTTPException
 from langchain_core.messages import (AIMessage, AIMessageChunk, AnyMessage,
@@ -267,7 +268,11 @@ class ChatService:
                         if node == "__interrupt__":
                             interrupt: Interrupt
                             for interrupt in updates:
-                                new_messages.append(AIMessage(content=interrupt.value))
+                                # 结构化的中断（如工具调用审批）以自定义数据返回，由调用方展示
+                                if isinstance(interrupt.value, dict):
+                                    new_messages.append(CustomData(data=interrupt.value).to_langchain())
+                                else:
+                                    new_messages.append(AIMessage(content=interrupt.value))
                             continue
                         updates = updates or {}
                         update_messages = updates.get("messages", [])
@@ -43,6 +43,7 @@ def langchain_to_chat_message(message: BaseMessage) -> ChatMessage:
                 type="tool",
                 content=convert_message_content_to_string(message.content),
                 tool_call_id=message.tool_call_id,
+                response_metadata={"status": message.status},
             )
             return tool_message
         case LangchainChatMessage():